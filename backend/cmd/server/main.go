@@ -41,19 +41,69 @@ func main() {
 	userRepo := repository.NewUserRepository(gormDB)
 	vaultRepo := repository.NewVaultRepository(gormDB)
 	shareRepo := repository.NewShareRepository(gormDB)
-
-	cryptoService := services.NewCryptoService()
+	sessionRepo := repository.NewSessionRepository(gormDB)
+	mfaChallengeRepo := repository.NewMFAChallengeRepository(gormDB)
+	sharedEditRepo := repository.NewSharedEditRepository(gormDB)
+	shareAccessLogRepo := repository.NewShareAccessLogRepository(gormDB)
+	collectionRepo := repository.NewCollectionRepository(gormDB)
+	organizationRepo := repository.NewOrganizationRepository(gormDB)
+	emergencyAccessRepo := repository.NewEmergencyAccessRepository(gormDB)
+	attachmentRepo := repository.NewAttachmentRepository(gormDB)
+	historyRepo := repository.NewPasswordHistoryRepository(gormDB)
+	revisionRepo := repository.NewVaultRevisionRepository(gormDB)
+	folderRepo := repository.NewFolderRepository(gormDB)
+	txManager := repository.NewTxManager(gormDB)
+
+	cryptoService := services.NewCryptoService(&cfg.Crypto)
 	emailService := services.NewEmailService(&cfg.Email)
 	breachService := services.NewBreachService(cfg.HIBP.APIKey)
 	passwordHealthService := services.NewPasswordHealthService()
 	importService := services.NewImportService()
-
-	authHandler := handlers.NewAuthHandler(userRepo, cryptoService, emailService, &cfg.JWT)
-	vaultHandler := handlers.NewVaultHandler(vaultRepo, cryptoService)
-	sharingHandler := handlers.NewSharingHandler(shareRepo, vaultRepo, userRepo, cryptoService, emailService)
-	healthHandler := handlers.NewHealthHandler(vaultRepo, cryptoService, passwordHealthService, breachService)
-	twoFAHandler := handlers.NewTwoFAHandler(userRepo, cryptoService)
-	importHandler := handlers.NewImportHandler(vaultRepo, importService, cryptoService)
+	exportService := services.NewExportService()
+	historyService := services.NewPasswordHistoryService(&cfg.History, historyRepo, cryptoService)
+	vaultKeyService := services.NewVaultKeyService(userRepo, vaultRepo, sharedEditRepo, cryptoService, historyService)
+	srpService := services.NewSRPService(cfg.JWT.Secret)
+	shareAccessService := services.NewShareAccessService(shareAccessLogRepo, userRepo, vaultRepo, emailService)
+	invitationService, err := services.NewShareInvitationService(shareRepo, cryptoService, &cfg.Sharing, cfg.JWT.Secret)
+	if err != nil {
+		log.Fatalf("Failed to load share invitation key: %v", err)
+	}
+	collectionService := services.NewCollectionService(cryptoService, vaultKeyService)
+	emergencyAccessService := services.NewEmergencyAccessService(emergencyAccessRepo, emailService)
+	policyService := services.NewPasswordPolicyService(&cfg.Policy, userRepo, organizationRepo, passwordHealthService, breachService)
+	blobStore, err := services.NewBlobStore(&cfg.Attachments)
+	if err != nil {
+		log.Fatalf("Failed to initialize attachment storage: %v", err)
+	}
+	attachmentService := services.NewAttachmentService(&cfg.Attachments, attachmentRepo, blobStore, cryptoService)
+	trashService := services.NewTrashService(&cfg.Trash, vaultRepo, attachmentService)
+	folderService := services.NewFolderService(folderRepo, vaultRepo, txManager)
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go vaultKeyService.RunUpgradeWorker(workerCtx)
+	go emergencyAccessService.RunAutoApproveWorker(workerCtx)
+	go trashService.RunPurgeWorker(workerCtx)
+	go folderService.MigrateLegacyFolders(workerCtx)
+
+	authHandler := handlers.NewAuthHandler(userRepo, sessionRepo, mfaChallengeRepo, vaultRepo, txManager, cryptoService, vaultKeyService, srpService, invitationService, emailService, policyService, &cfg.JWT)
+	vaultHandler := handlers.NewVaultHandler(vaultRepo, txManager, cryptoService, vaultKeyService, policyService, historyService, trashService, folderService)
+	sharingHandler := handlers.NewSharingHandler(shareRepo, vaultRepo, userRepo, txManager, cryptoService, vaultKeyService, invitationService, shareAccessService, emailService)
+	healthHandler := handlers.NewHealthHandler(vaultRepo, cryptoService, vaultKeyService, passwordHealthService, breachService, policyService)
+	twoFAHandler := handlers.NewTwoFAHandler(userRepo, cryptoService, srpService)
+	importHandler := handlers.NewImportHandler(vaultRepo, importService, cryptoService, vaultKeyService, policyService, folderService)
+	exportHandler := handlers.NewExportHandler(vaultRepo, vaultKeyService, exportService)
+	sessionHandler := handlers.NewSessionHandler(sessionRepo)
+	publicShareHandler := handlers.NewPublicShareHandler(shareRepo, cryptoService, shareAccessService)
+	clientVaultHandler := handlers.NewClientVaultHandler(userRepo, vaultRepo, sharedEditRepo, txManager, cryptoService, vaultKeyService, srpService, attachmentRepo, trashService, folderService)
+	collectionHandler := handlers.NewCollectionHandler(collectionRepo, organizationRepo, vaultRepo, userRepo, txManager, collectionService, vaultKeyService, attachmentRepo, historyRepo, policyService)
+	organizationHandler := handlers.NewOrganizationHandler(organizationRepo, collectionRepo, vaultRepo, userRepo, txManager, collectionService, vaultKeyService, emailService, attachmentRepo, historyRepo, policyService)
+	attachmentHandler := handlers.NewAttachmentHandler(attachmentRepo, vaultRepo, userRepo, vaultKeyService, attachmentService)
+	historyHandler := handlers.NewPasswordHistoryHandler(vaultRepo, historyRepo, userRepo, txManager, vaultKeyService, historyService, policyService)
+	revisionHandler := handlers.NewVaultRevisionHandler(vaultRepo, revisionRepo, userRepo, txManager, vaultKeyService, historyService, policyService, folderService)
+	trashHandler := handlers.NewTrashHandler(vaultRepo, userRepo, trashService, folderService)
+	folderHandler := handlers.NewFolderHandler(folderRepo, folderService)
+	emergencyAccessHandler := handlers.NewEmergencyAccessHandler(emergencyAccessRepo, vaultRepo, userRepo, txManager, cryptoService, vaultKeyService, collectionService, srpService, emergencyAccessService, policyService, emailService)
 
 	router := api.NewRouter(
 		authHandler,
@@ -62,6 +112,19 @@ func main() {
 		healthHandler,
 		twoFAHandler,
 		importHandler,
+		exportHandler,
+		attachmentHandler,
+		historyHandler,
+		revisionHandler,
+		trashHandler,
+		folderHandler,
+		sessionHandler,
+		clientVaultHandler,
+		publicShareHandler,
+		collectionHandler,
+		organizationHandler,
+		emergencyAccessHandler,
+		sessionRepo,
 		cfg,
 	)
 
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+	"github.com/tresor/password-manager/internal/services"
+)
+
+// multipartOverhead is the room left above the maximum file size for the
+// other form fields and the multipart framing.
+const multipartOverhead = 1024 * 1024
+
+// maxAttachmentFileNameLen bounds stored file names, in characters.
+const maxAttachmentFileNameLen = 255
+
+// AttachmentHandler serves the files attached to personal entries, for both
+// server-side and zero-knowledge accounts.
+type AttachmentHandler struct {
+	attachmentRepo    *repository.AttachmentRepository
+	vaultRepo         *repository.VaultRepository
+	userRepo          *repository.UserRepository
+	vaultKeyService   *services.VaultKeyService
+	attachmentService *services.AttachmentService
+}
+
+func NewAttachmentHandler(
+	attachmentRepo *repository.AttachmentRepository,
+	vaultRepo *repository.VaultRepository,
+	userRepo *repository.UserRepository,
+	vaultKeyService *services.VaultKeyService,
+	attachmentService *services.AttachmentService,
+) *AttachmentHandler {
+	return &AttachmentHandler{
+		attachmentRepo:    attachmentRepo,
+		vaultRepo:         vaultRepo,
+		userRepo:          userRepo,
+		vaultKeyService:   vaultKeyService,
+		attachmentService: attachmentService,
+	}
+}
+
+// UploadAttachment attaches the multipart "file" to an entry. Server-side
+// accounts send their "master_password" and the server encrypts the file;
+// zero-knowledge clients upload a file they encrypted, with "encrypted_key",
+// the file key they wrapped.
+func (h *AttachmentHandler) UploadAttachment(c *gin.Context) {
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	vault, ok := loadPersonalEntry(c, h.vaultRepo, user)
+	if !ok {
+		return
+	}
+
+	if maxSize := h.attachmentService.MaxFileSize(); maxSize > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead)
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File exceeds the maximum attachment size"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A file is required"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	attachment := &models.Attachment{
+		ID:          uuid.New(),
+		VaultID:     vault.ID,
+		UserID:      user.ID,
+		FileName:    attachmentFileName(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+	}
+
+	if user.ClientSideEncryption {
+		encryptedKey := c.PostForm("encrypted_key")
+		if encryptedKey == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Encrypted file key is required"})
+			return
+		}
+		err = h.attachmentService.StoreClientEncrypted(c.Request.Context(), attachment, file, encryptedKey)
+	} else {
+		masterPassword := c.PostForm("master_password")
+		if masterPassword == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Master password required"})
+			return
+		}
+		vaultKey, unlockErr := h.vaultKeyService.UnlockUser(c.Request.Context(), user, masterPassword)
+		if unlockErr != nil {
+			respondUnlockError(c, unlockErr)
+			return
+		}
+		err = h.attachmentService.Store(c.Request.Context(), attachment, file, vaultKey)
+	}
+
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, toAttachmentResponse(attachment))
+	case errors.Is(err, services.ErrAttachmentEmpty), errors.Is(err, services.ErrAttachmentSize):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is empty or incomplete"})
+	case errors.Is(err, services.ErrAttachmentTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File exceeds the maximum attachment size"})
+	case errors.Is(err, services.ErrAttachmentQuota):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Attachment storage quota exceeded"})
+	case errors.Is(err, services.ErrAttachmentEntryDeleted):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vault item not found"})
+	default:
+		log.Printf("Failed to store attachment for vault entry %s: %v", vault.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store attachment"})
+	}
+}
+
+// ListAttachments returns an entry's attachments and the caller's storage
+// usage. A quota of 0 means unlimited.
+func (h *AttachmentHandler) ListAttachments(c *gin.Context) {
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	vault, ok := loadPersonalEntry(c, h.vaultRepo, user)
+	if !ok {
+		return
+	}
+
+	attachments, err := h.attachmentRepo.GetByVaultID(c.Request.Context(), vault.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+		return
+	}
+
+	used, quota, err := h.attachmentService.Usage(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch storage usage"})
+		return
+	}
+
+	response := make([]models.AttachmentResponse, len(attachments))
+	for i := range attachments {
+		response[i] = toAttachmentResponse(&attachments[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"attachments": response,
+		"used_bytes":  used,
+		"quota_bytes": quota,
+	})
+}
+
+// DownloadAttachment streams an attachment. Server-side accounts pass
+// ?master_password= and get the decrypted file; zero-knowledge clients get
+// the file as they uploaded it.
+func (h *AttachmentHandler) DownloadAttachment(c *gin.Context) {
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	vault, ok := loadPersonalEntry(c, h.vaultRepo, user)
+	if !ok {
+		return
+	}
+
+	attachment, ok := h.loadAttachment(c, vault)
+	if !ok {
+		return
+	}
+
+	var fileKey []byte
+	contentType := attachment.ContentType
+	if attachment.IsClientEncrypted() {
+		contentType = "application/octet-stream"
+	} else {
+		masterPassword := c.Query("master_password")
+		if masterPassword == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Master password required"})
+			return
+		}
+		vaultKey, err := h.vaultKeyService.UnlockUser(c.Request.Context(), user, masterPassword)
+		if err != nil {
+			respondUnlockError(c, err)
+			return
+		}
+		fileKey, err = h.attachmentService.OpenKey(attachment, vaultKey)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to open attachment key"})
+			return
+		}
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so a failure can only cut the download short;
+	// the announced length lets the client notice.
+	if err := h.attachmentService.Stream(c.Request.Context(), attachment, fileKey, c.Writer); err != nil {
+		log.Printf("Failed to stream attachment %s: %v", attachment.ID, err)
+		c.Abort()
+	}
+}
+
+func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	vault, ok := loadPersonalEntry(c, h.vaultRepo, user)
+	if !ok {
+		return
+	}
+
+	attachment, ok := h.loadAttachment(c, vault)
+	if !ok {
+		return
+	}
+
+	if err := h.attachmentService.Delete(c.Request.Context(), attachment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attachment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+func (h *AttachmentHandler) loadAttachment(c *gin.Context, vault *models.Vault) (*models.Attachment, bool) {
+	attachmentID, err := uuid.Parse(c.Param("attachmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return nil, false
+	}
+
+	attachment, err := h.attachmentRepo.GetByID(c.Request.Context(), attachmentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachment"})
+		return nil, false
+	}
+	if attachment == nil || attachment.VaultID != vault.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return nil, false
+	}
+
+	return attachment, true
+}
+
+// attachmentFileName keeps the base name of an uploaded file, as browsers
+// may send a full path. Long names are shortened on a character boundary.
+func attachmentFileName(name string) string {
+	name = strings.ToValidUTF8(name, "\uFFFD")
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		name = "attachment"
+	}
+	if utf8.RuneCountInString(name) > maxAttachmentFileNameLen {
+		name = strings.TrimSpace(string([]rune(name)[:maxAttachmentFileNameLen]))
+	}
+	return name
+}
+
+func toAttachmentResponse(attachment *models.Attachment) models.AttachmentResponse {
+	response := models.AttachmentResponse{
+		ID:          attachment.ID,
+		VaultID:     attachment.VaultID,
+		FileName:    attachment.FileName,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		CreatedAt:   attachment.CreatedAt,
+	}
+	if attachment.IsClientEncrypted() {
+		response.EncryptedKey = attachment.EncryptedKey
+	}
+	return response
+}
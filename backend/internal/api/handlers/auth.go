@@ -1,40 +1,83 @@
 package handlers
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/api/middleware"
 	"github.com/tresor/password-manager/internal/config"
 	"github.com/tresor/password-manager/internal/models"
 	"github.com/tresor/password-manager/internal/repository"
 	"github.com/tresor/password-manager/internal/services"
 )
 
+// mfaPendingTTL bounds how long a password-verified login may wait for its
+// second factor before the user has to start over.
+const mfaPendingTTL = 5 * time.Minute
+
+// maxMFAAttempts is how many codes one mfa_pending token may try.
+const maxMFAAttempts = 5
+
+// totpStepSeconds is the TOTP period, used to number the time steps.
+const totpStepSeconds = 30
+
 type AuthHandler struct {
-	userRepo      *repository.UserRepository
-	cryptoService *services.CryptoService
-	emailService  *services.EmailService
-	jwtSecret     string
-	jwtExpire     int
+	userRepo          *repository.UserRepository
+	sessionRepo       *repository.SessionRepository
+	mfaChallengeRepo  *repository.MFAChallengeRepository
+	vaultRepo         *repository.VaultRepository
+	txManager         *repository.TxManager
+	cryptoService     *services.CryptoService
+	vaultKeyService   *services.VaultKeyService
+	srpService        *services.SRPService
+	invitationService *services.ShareInvitationService
+	emailService      *services.EmailService
+	policyService     *services.PasswordPolicyService
+	jwtSecret         string
+	accessTTL         time.Duration
+	refreshTTL        time.Duration
 }
 
 func NewAuthHandler(
 	userRepo *repository.UserRepository,
+	sessionRepo *repository.SessionRepository,
+	mfaChallengeRepo *repository.MFAChallengeRepository,
+	vaultRepo *repository.VaultRepository,
+	txManager *repository.TxManager,
 	cryptoService *services.CryptoService,
+	vaultKeyService *services.VaultKeyService,
+	srpService *services.SRPService,
+	invitationService *services.ShareInvitationService,
 	emailService *services.EmailService,
+	policyService *services.PasswordPolicyService,
 	cfg *config.JWTConfig,
 ) *AuthHandler {
 	return &AuthHandler{
-		userRepo:      userRepo,
-		cryptoService: cryptoService,
-		emailService:  emailService,
-		jwtSecret:     cfg.Secret,
-		jwtExpire:     cfg.ExpireTime,
+		userRepo:          userRepo,
+		sessionRepo:       sessionRepo,
+		mfaChallengeRepo:  mfaChallengeRepo,
+		vaultRepo:         vaultRepo,
+		txManager:         txManager,
+		cryptoService:     cryptoService,
+		vaultKeyService:   vaultKeyService,
+		srpService:        srpService,
+		invitationService: invitationService,
+		emailService:      emailService,
+		policyService:     policyService,
+		jwtSecret:         cfg.Secret,
+		accessTTL:         time.Minute * time.Duration(cfg.AccessExpireMinutes),
+		refreshTTL:        time.Hour * time.Duration(cfg.RefreshExpireHours),
 	}
 }
 
@@ -45,6 +88,11 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	if req.ClientSideEncryption && (req.ProtectedVaultKey == nil || *req.ProtectedVaultKey == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Protected vault key required for client-side encryption"})
+		return
+	}
+
 	existingUser, err := h.userRepo.GetByEmail(c.Request.Context(), req.Email)
 	if err != nil {
 		// Repository returned an unexpected error while checking for existing user
@@ -57,6 +105,15 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	// Zero-knowledge clients only send a secret derived from the master
+	// password, so they have to apply the policy themselves.
+	if !req.ClientSideEncryption {
+		if violations := h.policyService.CheckPassword(h.policyService.InstancePolicy(), req.MasterPassword); len(violations) > 0 {
+			respondPolicyViolations(c, violations)
+			return
+		}
+	}
+
 	hash, salt, err := h.cryptoService.HashPassword(req.MasterPassword)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
@@ -73,16 +130,48 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		UpdatedAt:          time.Now(),
 	}
 
+	// In zero-knowledge mode the client generated and wrapped the vault key
+	// and sharing keypair itself; the server only keeps the opaque result.
+	if req.ClientSideEncryption {
+		user.ClientSideEncryption = true
+		user.ProtectedVaultKey = req.ProtectedVaultKey
+		if err := applyClientKeyPair(h.cryptoService, user, req.ClientKeyPair); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client keypair"})
+			return
+		}
+	} else if _, err := h.vaultKeyService.InitializeVaultKey(user, req.MasterPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create vault key"})
+		return
+	}
+
 	if err := h.userRepo.Create(c.Request.Context(), user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
+	h.bindShareInvitation(c, user, req.InvitationToken)
+
 	go h.emailService.SendWelcomeEmail(req.Email)
 
 	c.JSON(http.StatusOK, gin.H{"message": "User created successfully"})
 }
 
+// bindShareInvitation hands the share of the invitation link the new user
+// registered from over to their account. Failures are logged rather than
+// failing the registration; the share stays pending and can still be claimed
+// with POST /share/invitations/accept.
+func (h *AuthHandler) bindShareInvitation(c *gin.Context, user *models.User, token string) {
+	if token == "" {
+		return
+	}
+	share, err := h.invitationService.BindInvitation(c.Request.Context(), user, hashInvitationToken(token))
+	if err != nil {
+		log.Printf("Failed to bind share invitation for user %s: %v", user.ID, err)
+		return
+	}
+	log.Printf("Bound pending share %s to user %s", share.ID, user.ID)
+}
+
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -91,26 +180,314 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	user, err := h.userRepo.GetByEmail(c.Request.Context(), req.Email)
-	if err != nil {
+	if err != nil || user == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	if !h.cryptoService.VerifyPassword(req.MasterPassword, user.MasterPasswordHash, user.Salt) {
+	// PAKE accounts have no password hash and must use /auth/pake/login/*.
+	if user.UsesPAKE() || !h.cryptoService.VerifyPassword(req.MasterPassword, user.MasterPasswordHash, user.Salt) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	token, err := h.generateToken(user.ID.String())
+	if h.cryptoService.PasswordHashNeedsRehash(user.MasterPasswordHash) {
+		if hash, salt, err := h.cryptoService.HashPassword(req.MasterPassword); err == nil {
+			user.MasterPasswordHash = hash
+			user.Salt = salt
+			if err := h.userRepo.Update(c.Request.Context(), user); err != nil {
+				log.Printf("Failed to rehash master password for user %s: %v", user.ID, err)
+			}
+		}
+	}
+
+	if user.TwoFactorEnabled {
+		mfaToken, err := h.generateMFAToken(c.Request.Context(), user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, models.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+			TokenType:   middleware.ScopeMFAPending,
+			ExpiresIn:   int(mfaPendingTTL.Seconds()),
+		})
+		return
+	}
+
+	response, err := h.startSession(c, user, req.DeviceName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// LoginTwoFactor completes a login started by Login for accounts with 2FA
+// enabled, exchanging the mfa_pending token and a TOTP or backup code for an
+// access token. A token completes a single login and may try
+// maxMFAAttempts codes; a TOTP code is accepted only once.
+func (h *AuthHandler) LoginTwoFactor(c *gin.Context) {
+	var req models.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, challengeID, err := h.parseMFAToken(req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(c.Request.Context(), userID)
+	if err != nil || user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	attempt, err := h.mfaChallengeRepo.UseAttempt(c.Request.Context(), challengeID, user.ID, maxMFAAttempts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify two-factor code"})
+		return
+	}
+	if !attempt {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	valid, backupCodeUsed := verifySecondFactor(user, req.Code)
+	if !valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	consumed, err := h.mfaChallengeRepo.Consume(c.Request.Context(), challengeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify two-factor code"})
+		return
+	}
+	if !consumed {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	if !backupCodeUsed {
+		advanced, err := h.userRepo.AdvanceTwoFactorStep(c.Request.Context(), user.ID, *user.TwoFactorLastStep)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify two-factor code"})
+			return
+		}
+		if !advanced {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+			return
+		}
+	}
+
+	if backupCodeUsed {
+		if err := h.userRepo.Update(c.Request.Context(), user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update backup codes"})
+			return
+		}
+	}
+
+	response, err := h.startSession(c, user, req.DeviceName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RefreshToken rotates a refresh token and returns a new token pair for the
+// same session. Presenting a refresh token that has already been rotated is
+// treated as theft: the whole session is revoked.
+func (h *AuthHandler) RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessionID, ok := parseRefreshToken(req.RefreshToken)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	session, err := h.sessionRepo.GetByID(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return
+	}
+	if session == nil || !session.IsActive() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked or expired"})
+		return
+	}
+
+	presentedHash := hashRefreshToken(req.RefreshToken)
+	refreshToken := newRefreshToken(session.ID)
+
+	rotated, err := h.sessionRepo.RotateRefreshToken(
+		c.Request.Context(),
+		session.ID,
+		presentedHash,
+		hashRefreshToken(refreshToken),
+		time.Now().Add(h.refreshTTL),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate refresh token"})
+		return
+	}
+	if !rotated {
+		_ = h.sessionRepo.Revoke(c.Request.Context(), session.ID, repository.SessionRevokedTokenReuse)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, session revoked"})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(c.Request.Context(), session.UserID)
+	if err != nil || user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	accessToken, err := h.generateToken(user.ID.String(), session.ID.String())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, models.LoginResponse{
-		AccessToken: token,
-		TokenType:   "bearer",
-		User:        *user,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "bearer",
+		ExpiresIn:    int(h.accessTTL.Seconds()),
+		User:         *user,
+	})
+}
+
+// Logout revokes the session the caller's access token belongs to.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	sessionID := c.GetString("session_id")
+
+	if err := h.sessionRepo.Revoke(c.Request.Context(), uuid.MustParse(sessionID), repository.SessionRevokedLogout); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// ChangeMasterPassword verifies the current master password, rewraps the
+// vault key under the new one, rotates the password hash and salt, and revokes
+// all sessions in a single transaction. Entries are encrypted with the vault
+// key and stay as they are; entries still on an older encryption version are
+// re-encrypted first. The caller gets a fresh session so it stays signed in.
+//
+// TOTP secrets are not derived from the master password (they must be readable
+// during the second login step) so they are left untouched.
+func (h *AuthHandler) ChangeMasterPassword(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req models.ChangeMasterPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	vaultKey, err := h.vaultKeyService.UnlockUser(c.Request.Context(), user, req.CurrentMasterPassword)
+	if err != nil {
+		respondUnlockError(c, err)
+		return
+	}
+
+	policy, err := h.policyService.EffectivePolicy(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load password policy"})
+		return
+	}
+	if violations := h.policyService.CheckPassword(policy, req.NewMasterPassword); len(violations) > 0 {
+		respondPolicyViolations(c, violations)
+		return
+	}
+
+	// Legacy entries are keyed by the current password itself, so they must
+	// be moved to the vault key now rather than by the background upgrade.
+	reencrypted, err := h.vaultKeyService.UpgradeEntries(c.Request.Context(), user.ID, vaultKey, req.CurrentMasterPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to re-encrypt vault entries"})
+		return
+	}
+
+	// Anything still on the legacy scheme was encrypted with a different
+	// password and would become unreadable.
+	remaining, err := h.vaultRepo.GetByEncryptionVersion(c.Request.Context(), user.ID, models.EncryptionVersionLegacy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vaults"})
+		return
+	}
+	if len(remaining) > 0 {
+		undecryptable := make([]uuid.UUID, len(remaining))
+		for i, vault := range remaining {
+			undecryptable[i] = vault.ID
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"error":                 "Some vault entries cannot be decrypted with the current master password",
+			"undecryptable_entries": undecryptable,
+		})
+		return
+	}
+
+	hash, salt, err := h.cryptoService.HashPassword(req.NewMasterPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+	if err := h.vaultKeyService.WrapVaultKey(user, vaultKey, req.NewMasterPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to wrap vault key"})
+		return
+	}
+	user.MasterPasswordHash = hash
+	user.Salt = salt
+	user.UpdatedAt = time.Now()
+
+	err = h.txManager.WithinTx(c.Request.Context(), func(repos *repository.TxRepositories) error {
+		if err := repos.Users.Update(c.Request.Context(), user); err != nil {
+			return err
+		}
+
+		return repos.Sessions.RevokeAllForUser(c.Request.Context(), user.ID, repository.SessionRevokedPassword)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change master password"})
+		return
+	}
+
+	response, err := h.startSession(c, user, req.DeviceName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Master password changed but failed to create session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":             "Master password changed successfully",
+		"reencrypted_entries": reencrypted,
+		"vault_key_rewrapped": true,
+		"session":             response,
 	})
 }
 
@@ -146,17 +523,143 @@ func (h *AuthHandler) RequestAccountDeletion(c *gin.Context) {
 	})
 }
 
-func (h *AuthHandler) generateToken(userID string) (string, error) {
+// startSession records a new session for the device and issues its first
+// access/refresh token pair.
+func (h *AuthHandler) startSession(c *gin.Context, user *models.User, deviceName string) (*models.LoginResponse, error) {
+	userAgent := c.Request.UserAgent()
+	if deviceName == "" {
+		deviceName = userAgent
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		DeviceName: deviceName,
+		IPAddress:  c.ClientIP(),
+		UserAgent:  userAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(h.refreshTTL),
+	}
+
+	refreshToken := newRefreshToken(session.ID)
+	session.RefreshTokenHash = hashRefreshToken(refreshToken)
+
+	if err := h.sessionRepo.Create(c.Request.Context(), session); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := h.generateToken(user.ID.String(), session.ID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.LoginResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "bearer",
+		ExpiresIn:    int(h.accessTTL.Seconds()),
+		User:         *user,
+	}, nil
+}
+
+func (h *AuthHandler) generateToken(userID, sessionID string) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":   userID,
+		"sid":   sessionID,
+		"scope": middleware.ScopeAccess,
+		"exp":   time.Now().Add(h.accessTTL).Unix(),
+		"iat":   time.Now().Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(h.jwtSecret))
+}
+
+// generateMFAToken issues a short-lived token that proves the master password
+// was verified but grants nothing beyond the second-factor exchange. Its
+// "jti" names the MFAChallenge that tracks its use.
+func (h *AuthHandler) generateMFAToken(ctx context.Context, userID uuid.UUID) (string, error) {
+	challenge := &models.MFAChallenge{
+		ID:        uuid.New(),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(mfaPendingTTL),
+	}
+	if err := h.mfaChallengeRepo.Create(ctx, challenge); err != nil {
+		return "", err
+	}
+
 	claims := jwt.MapClaims{
-		"sub": userID,
-		"exp": time.Now().Add(time.Hour * time.Duration(h.jwtExpire)).Unix(),
-		"iat": time.Now().Unix(),
+		"sub":   userID.String(),
+		"jti":   challenge.ID.String(),
+		"scope": middleware.ScopeMFAPending,
+		"exp":   challenge.ExpiresAt.Unix(),
+		"iat":   time.Now().Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(h.jwtSecret))
 }
 
+// parseMFAToken returns the user and the challenge ID of an mfa_pending
+// token.
+func (h *AuthHandler) parseMFAToken(tokenString string) (uuid.UUID, uuid.UUID, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(h.jwtSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return uuid.Nil, uuid.Nil, errors.New("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return uuid.Nil, uuid.Nil, errors.New("invalid token claims")
+	}
+
+	if scope, _ := claims["scope"].(string); scope != middleware.ScopeMFAPending {
+		return uuid.Nil, uuid.Nil, errors.New("token is not an MFA challenge")
+	}
+
+	sub, _ := claims["sub"].(string)
+	userID, err := uuid.Parse(sub)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	jti, _ := claims["jti"].(string)
+	challengeID, err := uuid.Parse(jti)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return userID, challengeID, nil
+}
+
+// Refresh tokens have the form "<session id>.<random>" so the session can be
+// looked up before the hash comparison.
+func newRefreshToken(sessionID uuid.UUID) string {
+	return sessionID.String() + "." + generateRandomToken(32)
+}
+
+func parseRefreshToken(token string) (uuid.UUID, bool) {
+	sessionPart, _, found := strings.Cut(token, ".")
+	if !found {
+		return uuid.Nil, false
+	}
+	sessionID, err := uuid.Parse(sessionPart)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return sessionID, true
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func generateRandomToken(length int) string {
 	b := make([]byte, length)
 	_, err := rand.Read(b)
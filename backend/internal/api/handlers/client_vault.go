@@ -0,0 +1,783 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+	"github.com/tresor/password-manager/internal/services"
+)
+
+var (
+	// errEntriesMismatch aborts the switch to zero-knowledge mode when the
+	// client did not re-upload exactly the account's entries.
+	errEntriesMismatch = errors.New("uploaded entries do not match the vault")
+	errInvalidKeyPair  = errors.New("invalid client keypair")
+)
+
+// ClientVaultHandler serves the zero-knowledge vault API. Clients encrypt
+// entries and the vault key themselves; the server only stores the opaque
+// blobs with their metadata and revision, and never sees the master password.
+type ClientVaultHandler struct {
+	userRepo        *repository.UserRepository
+	vaultRepo       *repository.VaultRepository
+	sharedEditRepo  *repository.SharedEditRepository
+	txManager       *repository.TxManager
+	cryptoService   *services.CryptoService
+	vaultKeyService *services.VaultKeyService
+	srpService      *services.SRPService
+	attachmentRepo  *repository.AttachmentRepository
+	trashService    *services.TrashService
+	folderService   *services.FolderService
+}
+
+func NewClientVaultHandler(
+	userRepo *repository.UserRepository,
+	vaultRepo *repository.VaultRepository,
+	sharedEditRepo *repository.SharedEditRepository,
+	txManager *repository.TxManager,
+	cryptoService *services.CryptoService,
+	vaultKeyService *services.VaultKeyService,
+	srpService *services.SRPService,
+	attachmentRepo *repository.AttachmentRepository,
+	trashService *services.TrashService,
+	folderService *services.FolderService,
+) *ClientVaultHandler {
+	return &ClientVaultHandler{
+		userRepo:        userRepo,
+		vaultRepo:       vaultRepo,
+		sharedEditRepo:  sharedEditRepo,
+		txManager:       txManager,
+		cryptoService:   cryptoService,
+		vaultKeyService: vaultKeyService,
+		srpService:      srpService,
+		attachmentRepo:  attachmentRepo,
+		trashService:    trashService,
+		folderService:   folderService,
+	}
+}
+
+// EnableClientEncryption switches the account to zero-knowledge mode. The
+// client proves knowledge of the master password one last time and uploads
+// every entry re-encrypted under its own vault key, plus a new sharing
+// keypair; shares already received, collection keys, org keys and emergency
+// access keys are re-sealed to the new public key, and the account's own
+// emergency contacts must be confirmed again. The server-side wraps are
+// dropped so the server can no longer decrypt anything, and other sessions
+// are signed out since legacy clients stop working.
+func (h *ClientVaultHandler) EnableClientEncryption(c *gin.Context) {
+	userID := c.GetString("user_id")
+	sessionID := c.GetString("session_id")
+
+	var req models.EnableClientEncryptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.ClientSideEncryption {
+		c.JSON(http.StatusConflict, gin.H{"error": "Client-side encryption is already enabled"})
+		return
+	}
+
+	// Attachment keys are wrapped with the server-side vault key, which the
+	// client never sees, so they could not be carried over.
+	attachmentCount, err := h.attachmentRepo.CountByUserID(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count attachments"})
+		return
+	}
+	if attachmentCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Delete your attachments before enabling client-side encryption"})
+		return
+	}
+
+	// Unlocking checks the master password and gives access to the old
+	// private key, needed to re-seal shares already received.
+	vaultKey, err := h.vaultKeyService.UnlockUser(c.Request.Context(), user, req.MasterPassword)
+	if err != nil {
+		respondUnlockError(c, err)
+		return
+	}
+	privateKey, err := h.vaultKeyService.OpenPrivateKey(user, vaultKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open private key"})
+		return
+	}
+
+	if req.PublicKey == nil || req.ProtectedPrivateKey == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A client keypair is required"})
+		return
+	}
+	if err := applyClientKeyPair(h.cryptoService, user, req.ClientKeyPair); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client keypair"})
+		return
+	}
+	newPublicKey, _ := base64.StdEncoding.DecodeString(user.PublicKey)
+
+	uploads := make(map[uuid.UUID]string, len(req.Entries))
+	for _, entry := range req.Entries {
+		uploads[uuid.MustParse(entry.ID)] = entry.EncryptedData
+	}
+
+	hash, salt, err := h.cryptoService.HashPassword(req.AuthSecret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	now := time.Now()
+	err = h.txManager.WithinTx(c.Request.Context(), func(repos *repository.TxRepositories) error {
+		// Entries in the trash are migrated too, or restoring them later
+		// would bring back data the client cannot read.
+		vaults, err := repos.Vaults.GetAllByUserID(c.Request.Context(), user.ID)
+		if err != nil {
+			return err
+		}
+		if len(vaults) != len(uploads) {
+			return errEntriesMismatch
+		}
+
+		for i := range vaults {
+			vault := &vaults[i]
+			data, ok := uploads[vault.ID]
+			if !ok {
+				return errEntriesMismatch
+			}
+			vault.EncryptedData = data
+			vault.EncryptionSalt = ""
+			vault.Nonce = ""
+			vault.EncryptionVersion = models.EncryptionVersionClient
+			vault.UpdatedAt = now
+			updated, err := repos.Vaults.UpdateIfRevision(c.Request.Context(), vault, vault.Revision)
+			if err != nil {
+				return err
+			}
+			if !updated {
+				return errEntryModified
+			}
+		}
+
+		// The password history is sealed with the server-side vault key,
+		// which is about to be dropped.
+		if err := repos.PasswordHistory.DeleteByUserID(c.Request.Context(), user.ID); err != nil {
+			return err
+		}
+
+		user.ClientSideEncryption = true
+		user.ProtectedVaultKey = &req.ProtectedVaultKey
+		user.EncryptedVaultKey = nil
+		user.VaultKeySalt = nil
+		user.VaultKeyNonce = nil
+		user.MasterPasswordHash = hash
+		user.Salt = salt
+		user.UpdatedAt = now
+		if err := repos.Users.Update(c.Request.Context(), user); err != nil {
+			return err
+		}
+
+		received, err := repos.Shares.GetReceivedShares(c.Request.Context(), user.ID)
+		if err != nil {
+			return err
+		}
+		for _, share := range received {
+			if share.EncryptionVersion != models.ShareEncryptionSealed {
+				continue
+			}
+			plaintext, err := h.cryptoService.OpenWithPrivateKey(share.EncryptedData, privateKey)
+			if err != nil {
+				continue
+			}
+			resealed, err := h.cryptoService.SealToPublicKey(plaintext, newPublicKey)
+			if err != nil {
+				return err
+			}
+			if err := repos.Shares.UpdateEncryptedData(c.Request.Context(), share.ID, resealed, models.ShareEncryptionSealed); err != nil {
+				return err
+			}
+		}
+
+		// Recipient edits not applied yet are handed to the client.
+		edits, err := repos.SharedEdits.GetPendingForOwner(c.Request.Context(), user.ID)
+		if err != nil {
+			return err
+		}
+		for _, edit := range edits {
+			plaintext, err := h.cryptoService.OpenWithPrivateKey(edit.EncryptedData, privateKey)
+			if err != nil {
+				continue
+			}
+			resealed, err := h.cryptoService.SealToPublicKey(plaintext, newPublicKey)
+			if err != nil {
+				return err
+			}
+			if err := repos.SharedEdits.UpdateEncryptedData(c.Request.Context(), edit.ID, resealed); err != nil {
+				return err
+			}
+		}
+
+		// So are collection and org keys, which are sealed to the old public
+		// key.
+		memberships, err := repos.Collections.GetMembershipsForUser(c.Request.Context(), user.ID)
+		if err != nil {
+			return err
+		}
+		for _, member := range memberships {
+			key, err := h.cryptoService.OpenWithPrivateKey(member.EncryptedKey, privateKey)
+			if err != nil {
+				continue
+			}
+			resealed, err := h.cryptoService.SealToPublicKey(key, newPublicKey)
+			if err != nil {
+				return err
+			}
+			if err := repos.Collections.UpdateMemberKey(c.Request.Context(), member.ID, resealed, member.KeyVersion); err != nil {
+				return err
+			}
+		}
+
+		orgMemberships, err := repos.Organizations.GetMembershipsForUser(c.Request.Context(), user.ID)
+		if err != nil {
+			return err
+		}
+		for _, member := range orgMemberships {
+			if !member.IsConfirmed() {
+				continue
+			}
+			key, err := h.cryptoService.OpenWithPrivateKey(member.EncryptedKey, privateKey)
+			if err != nil {
+				continue
+			}
+			resealed, err := h.cryptoService.SealToPublicKey(key, newPublicKey)
+			if err != nil {
+				return err
+			}
+			if err := repos.Organizations.UpdateMemberKey(c.Request.Context(), member.ID, resealed, member.KeyVersion); err != nil {
+				return err
+			}
+		}
+
+		// The vault key may have been replaced, so emergency contacts have to
+		// be confirmed again; copies of other grantors' keys are re-sealed.
+		if err := repos.EmergencyAccess.ResetGrantorKeys(c.Request.Context(), user.ID); err != nil {
+			return err
+		}
+		grants, err := repos.EmergencyAccess.GetByGrantee(c.Request.Context(), user.ID)
+		if err != nil {
+			return err
+		}
+		for _, grant := range grants {
+			if !grant.IsConfirmed() {
+				continue
+			}
+			key, err := h.cryptoService.OpenWithPrivateKey(grant.EncryptedKey, privateKey)
+			if err != nil {
+				continue
+			}
+			resealed, err := h.cryptoService.SealToPublicKey(key, newPublicKey)
+			if err != nil {
+				return err
+			}
+			if err := repos.EmergencyAccess.UpdateEncryptedKey(c.Request.Context(), grant.ID, resealed); err != nil {
+				return err
+			}
+		}
+
+		_, err = repos.Sessions.RevokeOthersForUser(c.Request.Context(), user.ID, uuid.MustParse(sessionID), repository.SessionRevokedPassword)
+		return err
+	})
+	if errors.Is(err, errEntriesMismatch) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Every vault entry must be uploaded exactly once"})
+		return
+	}
+	if errors.Is(err, errEntryModified) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An entry was modified at the same time; fetch the vault again and retry"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable client-side encryption"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Client-side encryption enabled",
+		"migrated_entries": len(uploads),
+	})
+}
+
+// GetProtectedKey returns the client-wrapped vault key and private key so a
+// client can unlock the vault and received shares locally after signing in.
+func (h *ClientVaultHandler) GetProtectedKey(c *gin.Context) {
+	user, ok := h.loadClientUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"protected_vault_key":   user.ProtectedVaultKey,
+		"public_key":            user.PublicKey,
+		"protected_private_key": user.PrivateKey,
+	})
+}
+
+// UpdateProtectedKey stores a re-wrapped vault key, e.g. after the master
+// password was changed on the client. Entries stay as they are since the
+// vault key itself does not change.
+func (h *ClientVaultHandler) UpdateProtectedKey(c *gin.Context) {
+	sessionID := c.GetString("session_id")
+
+	var req models.UpdateProtectedKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.loadClientUser(c)
+	if !ok {
+		return
+	}
+
+	if req.NewAuthSecret != nil && user.UsesPAKE() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "PAKE accounts rotate their verifier via /auth/pake/enroll"})
+		return
+	}
+
+	if !verifyAccountSecret(h.cryptoService, h.srpService, user, req.CurrentAuthSecret, req.PAKEProof) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	// Replacing an existing public key would orphan shares sealed to it.
+	if req.PublicKey != nil || req.ProtectedPrivateKey != nil {
+		if user.PublicKey != "" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Account already has a keypair"})
+			return
+		}
+		if err := applyClientKeyPair(h.cryptoService, user, req.ClientKeyPair); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client keypair"})
+			return
+		}
+	}
+
+	user.ProtectedVaultKey = &req.ProtectedVaultKey
+	if req.NewAuthSecret != nil {
+		hash, salt, err := h.cryptoService.HashPassword(*req.NewAuthSecret)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			return
+		}
+		user.MasterPasswordHash = hash
+		user.Salt = salt
+	}
+	user.UpdatedAt = time.Now()
+
+	err := h.txManager.WithinTx(c.Request.Context(), func(repos *repository.TxRepositories) error {
+		if err := repos.Users.Update(c.Request.Context(), user); err != nil {
+			return err
+		}
+		if req.NewAuthSecret == nil {
+			return nil
+		}
+
+		_, err := repos.Sessions.RevokeOthersForUser(c.Request.Context(), user.ID, uuid.MustParse(sessionID), repository.SessionRevokedPassword)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update vault key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Vault key updated successfully"})
+}
+
+// ListEntries returns the entries with their ciphertext, filtered, sorted
+// and paginated like GET /vault. With ?since=<RFC3339> only entries changed
+// after that instant are returned, including those moved to the trash,
+// oldest change first unless ?sort= says otherwise.
+func (h *ClientVaultHandler) ListEntries(c *gin.Context) {
+	user, ok := h.loadClientUser(c)
+	if !ok {
+		return
+	}
+
+	opts, ok := vaultListQuery(c)
+	if !ok {
+		return
+	}
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since timestamp"})
+			return
+		}
+		opts.UpdatedSince = &t
+		// Entries moved to the trash since then are listed with their
+		// deleted_at, so that the client can drop them.
+		opts.IncludeTrashed = true
+	}
+
+	vaults, ok := listVaultEntries(c, h.vaultRepo, user.ID, opts)
+	if !ok {
+		return
+	}
+
+	response := make([]models.ClientVaultResponse, len(vaults))
+	for i := range vaults {
+		response[i] = toClientVaultResponse(&vaults[i])
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *ClientVaultHandler) CreateEntry(c *gin.Context) {
+	var req models.ClientVaultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.loadClientUser(c)
+	if !ok {
+		return
+	}
+
+	itemType := req.Type
+	if itemType == "" {
+		itemType = models.ItemTypeLogin
+	}
+	if !models.IsItemType(itemType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown item type"})
+		return
+	}
+	tags, ok := normalizeTags(c, req.Tags)
+	if !ok {
+		return
+	}
+
+	vault := &models.Vault{
+		ID:                uuid.New(),
+		UserID:            user.ID,
+		Type:              itemType,
+		Title:             req.Title,
+		Website:           req.Website,
+		Username:          req.Username,
+		Tags:              pq.StringArray(tags),
+		Favorite:          req.Favorite,
+		EncryptedData:     req.EncryptedData,
+		EncryptionVersion: models.EncryptionVersionClient,
+		Revision:          1,
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+	}
+	if !assignFolder(c, h.folderService, user.ID, vault, req.FolderID, req.Folder) {
+		return
+	}
+
+	if err := h.vaultRepo.Create(c.Request.Context(), vault); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create vault entry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toClientVaultResponse(vault))
+}
+
+func (h *ClientVaultHandler) GetEntry(c *gin.Context) {
+	user, ok := h.loadClientUser(c)
+	if !ok {
+		return
+	}
+
+	vault, ok := h.loadOwnedEntry(c, user)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, toClientVaultResponse(vault))
+}
+
+// UpdateEntry replaces an entry if req.Revision still matches the stored
+// revision. A stale revision gets 409 with the current entry so the client
+// can merge and retry.
+func (h *ClientVaultHandler) UpdateEntry(c *gin.Context) {
+	var req models.ClientVaultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.loadClientUser(c)
+	if !ok {
+		return
+	}
+
+	vault, ok := h.loadOwnedEntry(c, user)
+	if !ok {
+		return
+	}
+
+	if req.Type != "" && req.Type != vault.Type {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errItemTypeChangeMessage})
+		return
+	}
+	tags, ok := normalizeTags(c, req.Tags)
+	if !ok {
+		return
+	}
+
+	if req.Revision != vault.Revision {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Vault entry was modified by another client",
+			"current": toClientVaultResponse(vault),
+		})
+		return
+	}
+
+	// The update may apply a recipient's edit, which is then credited to them.
+	modifiedBy := user.ID
+	var sharedEdit *models.SharedEdit
+	if req.SharedEditID != nil {
+		edit, err := h.sharedEditRepo.GetByID(c.Request.Context(), *req.SharedEditID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shared edit"})
+			return
+		}
+		if edit == nil || edit.OwnerID != user.ID || edit.VaultID != vault.ID || edit.AppliedAt != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Shared edit not found"})
+			return
+		}
+		sharedEdit = edit
+		modifiedBy = edit.EditorID
+	}
+
+	if !assignFolder(c, h.folderService, user.ID, vault, req.FolderID, req.Folder) {
+		return
+	}
+
+	vault.Title = req.Title
+	vault.Website = req.Website
+	vault.Username = req.Username
+	vault.Tags = pq.StringArray(tags)
+	vault.Favorite = req.Favorite
+	vault.EncryptedData = req.EncryptedData
+	vault.EncryptionSalt = ""
+	vault.Nonce = ""
+	vault.EncryptionVersion = models.EncryptionVersionClient
+	vault.LastModifiedBy = &modifiedBy
+	vault.UpdatedAt = time.Now()
+
+	updated, err := h.vaultRepo.UpdateIfRevision(c.Request.Context(), vault, req.Revision)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update vault"})
+		return
+	}
+	if !updated {
+		current, err := h.vaultRepo.GetByID(c.Request.Context(), vault.ID)
+		if err != nil || current == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Vault entry was modified by another client"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Vault entry was modified by another client",
+			"current": toClientVaultResponse(current),
+		})
+		return
+	}
+
+	if sharedEdit != nil {
+		if err := h.sharedEditRepo.MarkApplied(c.Request.Context(), sharedEdit.ID); err != nil {
+			log.Printf("Failed to mark shared edit %s applied: %v", sharedEdit.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, toClientVaultResponse(vault))
+}
+
+// ListSharedEdits returns the recipient edits waiting to be merged into the
+// user's entries. Each is sealed to the user's public key; the client applies
+// it with an entry update naming the edit, or dismisses it.
+func (h *ClientVaultHandler) ListSharedEdits(c *gin.Context) {
+	user, ok := h.loadClientUser(c)
+	if !ok {
+		return
+	}
+
+	edits, err := h.sharedEditRepo.GetPendingForOwner(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shared edits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"edits": edits})
+}
+
+// DismissSharedEdit discards a recipient edit without applying it.
+func (h *ClientVaultHandler) DismissSharedEdit(c *gin.Context) {
+	user, ok := h.loadClientUser(c)
+	if !ok {
+		return
+	}
+
+	editID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid edit ID"})
+		return
+	}
+
+	edit, err := h.sharedEditRepo.GetByID(c.Request.Context(), editID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shared edit"})
+		return
+	}
+	if edit == nil || edit.OwnerID != user.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shared edit not found"})
+		return
+	}
+
+	if err := h.sharedEditRepo.Delete(c.Request.Context(), edit.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to dismiss shared edit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Shared edit dismissed"})
+}
+
+// DeleteEntry moves an entry to the trash and returns it with its new
+// revision and deletion time.
+func (h *ClientVaultHandler) DeleteEntry(c *gin.Context) {
+	user, ok := h.loadClientUser(c)
+	if !ok {
+		return
+	}
+
+	vault, ok := h.loadOwnedEntry(c, user)
+	if !ok {
+		return
+	}
+
+	trashed, err := h.trashService.Trash(c.Request.Context(), vault, user.ID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete vault"})
+		return
+	}
+	if !trashed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Vault entry was modified by another client"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toClientVaultResponse(vault))
+}
+
+// loadClientUser loads the caller and makes sure the account is in
+// zero-knowledge mode, writing the error response otherwise.
+func (h *ClientVaultHandler) loadClientUser(c *gin.Context) (*models.User, bool) {
+	userID := c.GetString("user_id")
+
+	user, err := h.userRepo.GetByID(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return nil, false
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+
+	if !user.ClientSideEncryption {
+		c.JSON(http.StatusConflict, gin.H{"error": "Client-side encryption is not enabled for this account"})
+		return nil, false
+	}
+
+	return user, true
+}
+
+// loadOwnedEntry loads the :id entry and checks that user owns it. Entries
+// in the trash are not found; they are reached through the trash endpoints.
+func (h *ClientVaultHandler) loadOwnedEntry(c *gin.Context, user *models.User) (*models.Vault, bool) {
+	vaultID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vault ID"})
+		return nil, false
+	}
+
+	vault, err := h.vaultRepo.GetByID(c.Request.Context(), vaultID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vault"})
+		return nil, false
+	}
+	if vault == nil || vault.InTrash() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vault entry not found"})
+		return nil, false
+	}
+
+	if vault.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	if !vault.IsPersonal() {
+		c.JSON(http.StatusConflict, gin.H{"error": errNotPersonalEntryMessage})
+		return nil, false
+	}
+
+	return vault, true
+}
+
+// applyClientKeyPair stores a keypair generated by a zero-knowledge client on
+// user. Both halves must be present; a pair with neither is ignored.
+func applyClientKeyPair(cryptoService *services.CryptoService, user *models.User, keyPair models.ClientKeyPair) error {
+	if keyPair.PublicKey == nil && keyPair.ProtectedPrivateKey == nil {
+		return nil
+	}
+	if keyPair.PublicKey == nil || keyPair.ProtectedPrivateKey == nil || *keyPair.ProtectedPrivateKey == "" {
+		return errInvalidKeyPair
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(*keyPair.PublicKey)
+	if err != nil {
+		return errInvalidKeyPair
+	}
+	if err := cryptoService.ValidatePublicKey(publicKey); err != nil {
+		return errInvalidKeyPair
+	}
+
+	user.PublicKey = *keyPair.PublicKey
+	user.PrivateKey = *keyPair.ProtectedPrivateKey
+	return nil
+}
+
+func toClientVaultResponse(vault *models.Vault) models.ClientVaultResponse {
+	return models.ClientVaultResponse{
+		ID:             vault.ID,
+		Type:           vault.Type,
+		Title:          vault.Title,
+		Website:        vault.Website,
+		Username:       vault.Username,
+		FolderID:       vault.FolderID,
+		Folder:         vault.Folder,
+		Tags:           vault.TagList(),
+		Favorite:       vault.Favorite,
+		EncryptedData:  vault.EncryptedData,
+		Revision:       vault.Revision,
+		LastModifiedBy: vault.LastModifiedBy,
+		DeletedAt:      vault.DeletedAt,
+		CreatedAt:      vault.CreatedAt,
+		UpdatedAt:      vault.UpdatedAt,
+	}
+}
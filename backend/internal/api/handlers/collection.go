@@ -0,0 +1,686 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+	"github.com/tresor/password-manager/internal/services"
+)
+
+// CollectionHandler serves shared collections: folders whose entries are
+// visible to every member, with viewer, editor and manager roles.
+type CollectionHandler struct {
+	collectionRepo    *repository.CollectionRepository
+	organizationRepo  *repository.OrganizationRepository
+	vaultRepo         *repository.VaultRepository
+	userRepo          *repository.UserRepository
+	txManager         *repository.TxManager
+	collectionService *services.CollectionService
+	vaultKeyService   *services.VaultKeyService
+	entries           *sharedEntries
+}
+
+func NewCollectionHandler(
+	collectionRepo *repository.CollectionRepository,
+	organizationRepo *repository.OrganizationRepository,
+	vaultRepo *repository.VaultRepository,
+	userRepo *repository.UserRepository,
+	txManager *repository.TxManager,
+	collectionService *services.CollectionService,
+	vaultKeyService *services.VaultKeyService,
+	attachmentRepo *repository.AttachmentRepository,
+	historyRepo *repository.PasswordHistoryRepository,
+	policyService *services.PasswordPolicyService,
+) *CollectionHandler {
+	return &CollectionHandler{
+		collectionRepo:    collectionRepo,
+		organizationRepo:  organizationRepo,
+		vaultRepo:         vaultRepo,
+		userRepo:          userRepo,
+		txManager:         txManager,
+		collectionService: collectionService,
+		vaultKeyService:   vaultKeyService,
+		entries: &sharedEntries{
+			vaultRepo:         vaultRepo,
+			collectionService: collectionService,
+			vaultKeyService:   vaultKeyService,
+			attachmentRepo:    attachmentRepo,
+			historyRepo:       historyRepo,
+			policyService:     policyService,
+		},
+	}
+}
+
+// CreateCollection creates a collection with the caller as owner and first
+// manager.
+func (h *CollectionHandler) CreateCollection(c *gin.Context) {
+	var req models.CreateCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.loadUser(c)
+	if !ok {
+		return
+	}
+
+	if req.OrganizationID != nil {
+		orgMember, err := h.organizationRepo.GetMember(c.Request.Context(), *req.OrganizationID, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check organization membership"})
+			return
+		}
+		if orgMember == nil || !orgMember.IsConfirmed() || !orgMember.CanAdmin() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only organization admins can create its collections"})
+			return
+		}
+	}
+
+	encryptedKey, ok := h.entries.newKey(c, user, req.MasterPassword, req.EncryptedKey)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	collection := &models.Collection{
+		ID:             uuid.New(),
+		OwnerID:        user.ID,
+		OrganizationID: req.OrganizationID,
+		Name:           req.Name,
+		KeyVersion:     1,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	member := &models.CollectionMember{
+		ID:           uuid.New(),
+		CollectionID: collection.ID,
+		UserID:       user.ID,
+		Role:         models.CollectionRoleManager,
+		CanView:      true,
+		CanCopy:      true,
+		EncryptedKey: encryptedKey,
+		KeyVersion:   1,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	err := h.txManager.WithinTx(c.Request.Context(), func(repos *repository.TxRepositories) error {
+		if err := repos.Collections.Create(c.Request.Context(), collection); err != nil {
+			return err
+		}
+		return repos.Collections.AddMember(c.Request.Context(), member)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create collection"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, toCollectionResponse(collection, member))
+}
+
+// ListCollections lists the collections the caller belongs to.
+func (h *CollectionHandler) ListCollections(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	memberships, err := h.collectionRepo.GetMembershipsForUser(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collections"})
+		return
+	}
+
+	responses := make([]models.CollectionResponse, 0, len(memberships))
+	for i := range memberships {
+		responses = append(responses, toCollectionResponse(&memberships[i].Collection, &memberships[i]))
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// GetCollection returns a collection with its members. Member public keys
+// are included so zero-knowledge managers can re-key.
+func (h *CollectionHandler) GetCollection(c *gin.Context) {
+	collection, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.collectionRepo.GetMembers(c.Request.Context(), collection.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+
+	memberResponses := make([]models.CollectionMemberResponse, 0, len(members))
+	for _, m := range members {
+		memberResponses = append(memberResponses, models.CollectionMemberResponse{
+			UserID:    m.UserID,
+			Email:     m.User.Email,
+			PublicKey: m.User.PublicKey,
+			Role:      m.Role,
+			CanView:   m.CanView,
+			CanCopy:   m.CanCopy,
+			CreatedAt: m.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"collection": toCollectionResponse(collection, member),
+		"members":    memberResponses,
+	})
+}
+
+// UpdateCollection renames a collection. Managers only.
+func (h *CollectionHandler) UpdateCollection(c *gin.Context) {
+	var req models.UpdateCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanManage() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only managers can change the collection"})
+		return
+	}
+
+	collection.Name = req.Name
+	collection.UpdatedAt = time.Now()
+	if err := h.collectionRepo.Update(c.Request.Context(), collection); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toCollectionResponse(collection, member))
+}
+
+// DeleteCollection deletes a collection and every entry in it. Only the
+// owner can do this.
+func (h *CollectionHandler) DeleteCollection(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	collection, _, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if collection.OwnerID.String() != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can delete the collection"})
+		return
+	}
+
+	if err := h.collectionRepo.Delete(c.Request.Context(), collection.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collection deleted successfully"})
+}
+
+// AddMember gives another user access to the collection by sealing the
+// collection key to their public key. Managers only.
+func (h *CollectionHandler) AddMember(c *gin.Context) {
+	var req models.AddCollectionMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !models.IsValidCollectionRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be viewer, editor or manager"})
+		return
+	}
+
+	collection, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanManage() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only managers can add members"})
+		return
+	}
+
+	user, ok := h.loadUser(c)
+	if !ok {
+		return
+	}
+
+	newUser, err := h.userRepo.GetByEmail(c.Request.Context(), req.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up user"})
+		return
+	}
+	if newUser == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if collection.OrganizationID != nil {
+		orgMember, err := h.organizationRepo.GetMember(c.Request.Context(), *collection.OrganizationID, newUser.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check organization membership"})
+			return
+		}
+		if orgMember == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "User is not a member of the organization"})
+			return
+		}
+	}
+	if newUser.PublicKey == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "User has no encryption key yet; they must sign in once before they can be added"})
+		return
+	}
+
+	existing, err := h.collectionRepo.GetMember(c.Request.Context(), collection.ID, newUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check membership"})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member"})
+		return
+	}
+
+	var encryptedKey string
+	if user.ClientSideEncryption {
+		if req.EncryptedKey == nil || h.collectionService.ValidateSealedKey(*req.EncryptedKey) != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Collection key sealed to the new member is required"})
+			return
+		}
+		encryptedKey = *req.EncryptedKey
+	} else {
+		key, _, ok := h.entries.openKey(c, user, member.EncryptedKey, req.MasterPassword)
+		if !ok {
+			return
+		}
+		encryptedKey, err = h.collectionService.SealKeyForUser(key, newUser)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+			return
+		}
+	}
+
+	now := time.Now()
+	added := &models.CollectionMember{
+		ID:           uuid.New(),
+		CollectionID: collection.ID,
+		UserID:       newUser.ID,
+		Role:         req.Role,
+		CanView:      req.CanView == nil || *req.CanView,
+		CanCopy:      req.CanCopy == nil || *req.CanCopy,
+		EncryptedKey: encryptedKey,
+		KeyVersion:   collection.KeyVersion,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := h.collectionRepo.AddMember(c.Request.Context(), added); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CollectionMemberResponse{
+		UserID:    newUser.ID,
+		Email:     newUser.Email,
+		PublicKey: newUser.PublicKey,
+		Role:      added.Role,
+		CanView:   added.CanView,
+		CanCopy:   added.CanCopy,
+		CreatedAt: added.CreatedAt,
+	})
+}
+
+// UpdateMember changes a member's role and permissions. Managers only; the
+// owner always stays a manager.
+func (h *CollectionHandler) UpdateMember(c *gin.Context) {
+	var req models.UpdateCollectionMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !models.IsValidCollectionRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be viewer, editor or manager"})
+		return
+	}
+
+	collection, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanManage() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only managers can change members"})
+		return
+	}
+
+	target, ok := h.loadTargetMember(c, collection)
+	if !ok {
+		return
+	}
+	if target.UserID == collection.OwnerID && req.Role != models.CollectionRoleManager {
+		c.JSON(http.StatusConflict, gin.H{"error": "The owner must stay a manager"})
+		return
+	}
+
+	target.Role = req.Role
+	if req.CanView != nil {
+		target.CanView = *req.CanView
+	}
+	if req.CanCopy != nil {
+		target.CanCopy = *req.CanCopy
+	}
+
+	if err := h.collectionRepo.UpdateMemberAccess(c.Request.Context(), target); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member updated successfully"})
+}
+
+// RemoveMember removes a member and re-keys the collection: a new key
+// encrypts every entry and is sealed to the remaining members, so the
+// removed member's copy of the old key opens nothing that is stored from
+// now on. Managers only; the owner cannot be removed.
+func (h *CollectionHandler) RemoveMember(c *gin.Context) {
+	var req models.RemoveCollectionMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanManage() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only managers can remove members"})
+		return
+	}
+
+	target, ok := h.loadTargetMember(c, collection)
+	if !ok {
+		return
+	}
+	if target.UserID == collection.OwnerID {
+		c.JSON(http.StatusConflict, gin.H{"error": "The owner cannot be removed"})
+		return
+	}
+
+	user, ok := h.loadUser(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.collectionRepo.GetMembers(c.Request.Context(), collection.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+	remaining := make([]models.CollectionMember, 0, len(members))
+	recipients := make([]*models.User, 0, len(members))
+	for _, m := range members {
+		if m.ID != target.ID {
+			remaining = append(remaining, m)
+		}
+	}
+	for i := range remaining {
+		recipients = append(recipients, &remaining[i].User)
+	}
+
+	entries, err := h.vaultRepo.GetByCollectionID(c.Request.Context(), collection.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch entries"})
+		return
+	}
+
+	keys, ok := h.entries.rekey(c, user, member.EncryptedKey, req.MasterPassword, req.Keys, req.Entries, recipients, entries)
+	if !ok {
+		return
+	}
+
+	keyVersion := collection.KeyVersion + 1
+
+	err = h.txManager.WithinTx(c.Request.Context(), func(repos *repository.TxRepositories) error {
+		if err := saveRekeyedEntries(c, repos, entries); err != nil {
+			return err
+		}
+
+		for _, m := range remaining {
+			if err := repos.Collections.UpdateMemberKey(c.Request.Context(), m.ID, keys[m.UserID], keyVersion); err != nil {
+				return err
+			}
+		}
+
+		if err := repos.Collections.RemoveMember(c.Request.Context(), target.ID); err != nil {
+			return err
+		}
+
+		collection.KeyVersion = keyVersion
+		collection.UpdatedAt = time.Now()
+		return repos.Collections.Update(c.Request.Context(), collection)
+	})
+	if errors.Is(err, errSharedEntriesConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An entry changed during re-keying; try again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Member removed and collection re-keyed",
+		"key_version": keyVersion,
+	})
+}
+
+// ListEntries lists the entries of a collection. EncryptedData is included
+// for zero-knowledge clients, which hold the collection key themselves.
+func (h *CollectionHandler) ListEntries(c *gin.Context) {
+	collection, _, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+
+	entries, err := h.vaultRepo.GetByCollectionID(c.Request.Context(), collection.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch entries"})
+		return
+	}
+
+	h.entries.list(c, entries)
+}
+
+// GetEntry returns one entry. Server-side accounts pass ?master_password= and
+// get it decrypted, limited by their view and copy permissions.
+func (h *CollectionHandler) GetEntry(c *gin.Context) {
+	collection, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	scope := collectionScope(collection, member)
+
+	entry, ok := h.entries.load(c, scope)
+	if !ok {
+		return
+	}
+
+	user, ok := h.loadUser(c)
+	if !ok {
+		return
+	}
+
+	h.entries.get(c, scope, user, entry)
+}
+
+// CreateEntry adds an entry to a collection, or moves one of the caller's
+// personal entries into it when VaultID is set. Editors and managers only.
+func (h *CollectionHandler) CreateEntry(c *gin.Context) {
+	var req models.CollectionEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanEdit() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Viewers cannot add entries"})
+		return
+	}
+
+	user, ok := h.loadUser(c)
+	if !ok {
+		return
+	}
+
+	h.entries.create(c, collectionScope(collection, member), user, &req)
+}
+
+// UpdateEntry changes an entry of a collection. Revision must match the
+// stored revision. Editors and managers only.
+func (h *CollectionHandler) UpdateEntry(c *gin.Context) {
+	var req models.CollectionEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanEdit() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Viewers cannot change entries"})
+		return
+	}
+	scope := collectionScope(collection, member)
+
+	entry, ok := h.entries.load(c, scope)
+	if !ok {
+		return
+	}
+
+	user, ok := h.loadUser(c)
+	if !ok {
+		return
+	}
+
+	h.entries.update(c, scope, user, entry, &req)
+}
+
+// DeleteEntry removes an entry from a collection. Editors and managers only.
+func (h *CollectionHandler) DeleteEntry(c *gin.Context) {
+	collection, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanEdit() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Viewers cannot delete entries"})
+		return
+	}
+
+	entry, ok := h.entries.load(c, collectionScope(collection, member))
+	if !ok {
+		return
+	}
+
+	h.entries.delete(c, entry)
+}
+
+// loadMembership loads the :id collection and the caller's membership. Non
+// members get a 404 so that collection IDs do not leak.
+func (h *CollectionHandler) loadMembership(c *gin.Context) (*models.Collection, *models.CollectionMember, bool) {
+	userID := c.GetString("user_id")
+
+	collectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return nil, nil, false
+	}
+
+	member, err := h.collectionRepo.GetMember(c.Request.Context(), collectionID, uuid.MustParse(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collection"})
+		return nil, nil, false
+	}
+	if member == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return nil, nil, false
+	}
+
+	collection, err := h.collectionRepo.GetByID(c.Request.Context(), collectionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collection"})
+		return nil, nil, false
+	}
+	if collection == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return nil, nil, false
+	}
+
+	return collection, member, true
+}
+
+// loadTargetMember loads the :userId member of collection.
+func (h *CollectionHandler) loadTargetMember(c *gin.Context, collection *models.Collection) (*models.CollectionMember, bool) {
+	targetID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, false
+	}
+
+	target, err := h.collectionRepo.GetMember(c.Request.Context(), collection.ID, targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch member"})
+		return nil, false
+	}
+	if target == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return nil, false
+	}
+
+	return target, true
+}
+
+func (h *CollectionHandler) loadUser(c *gin.Context) (*models.User, bool) {
+	return loadCurrentUser(c, h.userRepo)
+}
+
+func collectionScope(collection *models.Collection, member *models.CollectionMember) *entryScope {
+	return &entryScope{
+		ownerID:      collection.OwnerID,
+		collectionID: &collection.ID,
+		sealedKey:    member.EncryptedKey,
+		canView:      member.CanView,
+		canCopy:      member.CanCopy,
+
+		policyOrganizationID: collection.OrganizationID,
+	}
+}
+
+func toCollectionResponse(collection *models.Collection, member *models.CollectionMember) models.CollectionResponse {
+	return models.CollectionResponse{
+		ID:             collection.ID,
+		OwnerID:        collection.OwnerID,
+		OrganizationID: collection.OrganizationID,
+		Name:           collection.Name,
+		Role:           member.Role,
+		CanView:        member.CanView,
+		CanCopy:        member.CanCopy,
+		CanEdit:        member.CanEdit(),
+		CanManage:      member.CanManage(),
+		KeyVersion:     member.KeyVersion,
+		EncryptedKey:   member.EncryptedKey,
+		CreatedAt:      collection.CreatedAt,
+		UpdatedAt:      collection.UpdatedAt,
+	}
+}
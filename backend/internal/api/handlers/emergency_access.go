@@ -0,0 +1,697 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+	"github.com/tresor/password-manager/internal/services"
+)
+
+// EmergencyAccessHandler serves emergency access: a grantor names trusted
+// contacts (grantees) who can request view or takeover access to their
+// vault. A grantee accepts by email, the grantor confirms them by sealing
+// their vault key to the grantee's public key, and a request is granted when
+// the grantor approves it or does not reject it within the waiting period.
+type EmergencyAccessHandler struct {
+	emergencyAccessRepo    *repository.EmergencyAccessRepository
+	vaultRepo              *repository.VaultRepository
+	userRepo               *repository.UserRepository
+	txManager              *repository.TxManager
+	cryptoService          *services.CryptoService
+	vaultKeyService        *services.VaultKeyService
+	collectionService      *services.CollectionService
+	srpService             *services.SRPService
+	emergencyAccessService *services.EmergencyAccessService
+	policyService          *services.PasswordPolicyService
+	emailService           *services.EmailService
+}
+
+func NewEmergencyAccessHandler(
+	emergencyAccessRepo *repository.EmergencyAccessRepository,
+	vaultRepo *repository.VaultRepository,
+	userRepo *repository.UserRepository,
+	txManager *repository.TxManager,
+	cryptoService *services.CryptoService,
+	vaultKeyService *services.VaultKeyService,
+	collectionService *services.CollectionService,
+	srpService *services.SRPService,
+	emergencyAccessService *services.EmergencyAccessService,
+	policyService *services.PasswordPolicyService,
+	emailService *services.EmailService,
+) *EmergencyAccessHandler {
+	return &EmergencyAccessHandler{
+		emergencyAccessRepo:    emergencyAccessRepo,
+		vaultRepo:              vaultRepo,
+		userRepo:               userRepo,
+		txManager:              txManager,
+		cryptoService:          cryptoService,
+		vaultKeyService:        vaultKeyService,
+		collectionService:      collectionService,
+		srpService:             srpService,
+		emergencyAccessService: emergencyAccessService,
+		policyService:          policyService,
+		emailService:           emailService,
+	}
+}
+
+// InviteContact names a trusted contact by email and sends them an
+// invitation.
+func (h *EmergencyAccessHandler) InviteContact(c *gin.Context) {
+	var req models.InviteEmergencyContactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	if strings.EqualFold(req.Email, user.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot be your own emergency contact"})
+		return
+	}
+
+	existing, err := h.emergencyAccessRepo.GetByGrantorAndEmail(c.Request.Context(), user.ID, req.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check emergency contacts"})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "This person is already an emergency contact"})
+		return
+	}
+
+	waitDays := req.WaitDays
+	if waitDays == 0 {
+		waitDays = models.EmergencyAccessDefaultWaitDays
+	}
+
+	token := generateRandomToken(32)
+	tokenHash := hashInvitationToken(token)
+	access := &models.EmergencyAccess{
+		ID:        uuid.New(),
+		GrantorID: user.ID,
+		Email:     req.Email,
+		Type:      req.Type,
+		Status:    models.EmergencyAccessInvited,
+		WaitDays:  waitDays,
+		TokenHash: &tokenHash,
+	}
+
+	if err := h.emergencyAccessRepo.Create(c.Request.Context(), access); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create emergency contact"})
+		return
+	}
+
+	acceptURL := "https://yourdomain.com/emergency-access/accept?token=" + token
+	go h.emailService.SendEmergencyAccessInvitation(req.Email, user.Email, req.Type, waitDays, acceptURL)
+
+	c.JSON(http.StatusCreated, toEmergencyAccessResponse(access, nil))
+}
+
+// ListTrustedContacts lists the caller's emergency contacts with their public
+// keys.
+func (h *EmergencyAccessHandler) ListTrustedContacts(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	grants, err := h.emergencyAccessRepo.GetByGrantor(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch emergency contacts"})
+		return
+	}
+
+	responses := make([]models.EmergencyAccessResponse, 0, len(grants))
+	for i := range grants {
+		responses = append(responses, toEmergencyAccessResponse(&grants[i], grants[i].Grantee))
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// ListGrantedAccess lists the vaults the caller is an emergency contact for.
+func (h *EmergencyAccessHandler) ListGrantedAccess(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	grants, err := h.emergencyAccessRepo.GetByGrantee(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch emergency access"})
+		return
+	}
+
+	responses := make([]models.EmergencyAccessResponse, 0, len(grants))
+	for i := range grants {
+		responses = append(responses, toEmergencyAccessResponse(&grants[i], &grants[i].Grantor))
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// AcceptInvitation makes the caller the trusted contact of an emailed
+// invitation. The caller's email must be the invited one.
+func (h *EmergencyAccessHandler) AcceptInvitation(c *gin.Context) {
+	var req models.AcceptEmergencyAccessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	access, err := h.emergencyAccessRepo.GetByTokenHash(c.Request.Context(), hashInvitationToken(req.Token))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitation"})
+		return
+	}
+	if access == nil || access.Status != models.EmergencyAccessInvited || !strings.EqualFold(access.Email, user.Email) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+
+	access.GranteeID = &user.ID
+	access.Status = models.EmergencyAccessAccepted
+	access.TokenHash = nil
+	if err := h.emergencyAccessRepo.Update(c.Request.Context(), access); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	go h.emailService.SendEmergencyAccessAccepted(access.Grantor.Email, user.Email)
+
+	c.JSON(http.StatusOK, toEmergencyAccessResponse(access, &access.Grantor))
+}
+
+// UpdateAccess changes the access type or waiting period of a contact. It
+// cannot change while a request is open.
+func (h *EmergencyAccessHandler) UpdateAccess(c *gin.Context) {
+	var req models.UpdateEmergencyAccessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	access, ok := h.loadAsGrantor(c)
+	if !ok {
+		return
+	}
+	if access.Status == models.EmergencyAccessRecoveryInitiated || access.Status == models.EmergencyAccessRecoveryApproved {
+		c.JSON(http.StatusConflict, gin.H{"error": "Reject the open request before changing this contact"})
+		return
+	}
+
+	access.Type = req.Type
+	access.WaitDays = req.WaitDays
+	if err := h.emergencyAccessRepo.Update(c.Request.Context(), access); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update emergency contact"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toEmergencyAccessResponse(access, access.Grantee))
+}
+
+// DeleteAccess removes a contact, or lets a contact step down.
+func (h *EmergencyAccessHandler) DeleteAccess(c *gin.Context) {
+	access, ok := h.loadAccess(c)
+	if !ok {
+		return
+	}
+
+	if err := h.emergencyAccessRepo.Delete(c.Request.Context(), access.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete emergency contact"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Emergency contact removed successfully"})
+}
+
+// ConfirmContact hands the caller's vault key to a contact who accepted, by
+// sealing it to their public key.
+func (h *EmergencyAccessHandler) ConfirmContact(c *gin.Context) {
+	var req models.ConfirmEmergencyAccessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	access, ok := h.loadAsGrantor(c)
+	if !ok {
+		return
+	}
+	if access.Status != models.EmergencyAccessAccepted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only contacts who accepted can be confirmed"})
+		return
+	}
+	if access.Grantee == nil || access.Grantee.PublicKey == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "The contact has no sharing key yet; they need to sign in once"})
+		return
+	}
+
+	var encryptedKey string
+	if access.Grantor.ClientSideEncryption {
+		if req.EncryptedKey == nil || *req.EncryptedKey == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Encrypted key required for client-side encryption"})
+			return
+		}
+		encryptedKey = *req.EncryptedKey
+	} else {
+		if req.MasterPassword == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Master password required"})
+			return
+		}
+		vaultKey, err := h.vaultKeyService.UnlockUser(c.Request.Context(), &access.Grantor, req.MasterPassword)
+		if err != nil {
+			respondUnlockError(c, err)
+			return
+		}
+		encryptedKey, err = h.collectionService.SealKeyForUser(vaultKey, access.Grantee)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to seal vault key"})
+			return
+		}
+	}
+
+	access.EncryptedKey = encryptedKey
+	access.Status = models.EmergencyAccessConfirmed
+	if err := h.emergencyAccessRepo.Update(c.Request.Context(), access); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm emergency contact"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toEmergencyAccessResponse(access, access.Grantee))
+}
+
+// InitiateRecovery requests access to the grantor's vault. The grantor is
+// emailed and access is granted when they approve, or automatically once
+// the waiting period is over.
+func (h *EmergencyAccessHandler) InitiateRecovery(c *gin.Context) {
+	access, ok := h.loadAsGrantee(c)
+	if !ok {
+		return
+	}
+
+	initiated, err := h.emergencyAccessService.Initiate(c.Request.Context(), access)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request emergency access"})
+		return
+	}
+	if !initiated {
+		c.JSON(http.StatusConflict, gin.H{"error": "Emergency access can only be requested once the grantor confirmed you, and not twice"})
+		return
+	}
+
+	h.respondReloaded(c, access.ID, true)
+}
+
+// ApproveRecovery grants a pending request without waiting.
+func (h *EmergencyAccessHandler) ApproveRecovery(c *gin.Context) {
+	access, ok := h.loadAsGrantor(c)
+	if !ok {
+		return
+	}
+
+	approved, err := h.emergencyAccessService.Approve(c.Request.Context(), access)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve emergency access"})
+		return
+	}
+	if !approved {
+		c.JSON(http.StatusConflict, gin.H{"error": "There is no pending request to approve"})
+		return
+	}
+
+	h.respondReloaded(c, access.ID, false)
+}
+
+// RejectRecovery turns down a pending request, or takes back access that was
+// already granted.
+func (h *EmergencyAccessHandler) RejectRecovery(c *gin.Context) {
+	access, ok := h.loadAsGrantor(c)
+	if !ok {
+		return
+	}
+	if access.Status != models.EmergencyAccessRecoveryInitiated && access.Status != models.EmergencyAccessRecoveryApproved {
+		c.JSON(http.StatusConflict, gin.H{"error": "There is no request to reject"})
+		return
+	}
+
+	rejected, err := h.emergencyAccessService.Reject(c.Request.Context(), access)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject emergency access"})
+		return
+	}
+	if !rejected {
+		c.JSON(http.StatusConflict, gin.H{"error": "The request changed in the meantime; try again"})
+		return
+	}
+
+	h.respondReloaded(c, access.ID, false)
+}
+
+// ViewVault returns the grantor's entries once access was granted.
+// Zero-knowledge grantees get the entries encrypted together with their
+// sealed copy of the grantor's vault key; server-side grantees pass
+// ?master_password= and get them decrypted.
+func (h *EmergencyAccessHandler) ViewVault(c *gin.Context) {
+	access, ok := h.loadApproved(c)
+	if !ok {
+		return
+	}
+
+	entries, err := h.vaultRepo.GetByUserID(c.Request.Context(), access.GrantorID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vaults"})
+		return
+	}
+
+	if access.Grantee.ClientSideEncryption {
+		responses := make([]models.ClientVaultResponse, 0, len(entries))
+		for i := range entries {
+			responses = append(responses, toClientVaultResponse(&entries[i]))
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"encrypted_key": access.EncryptedKey,
+			"entries":       responses,
+		})
+		return
+	}
+
+	vaultKey, ok := h.openGrantorKey(c, access, c.Query("master_password"))
+	if !ok {
+		return
+	}
+
+	responses := make([]gin.H, 0, len(entries))
+	undecryptable := []uuid.UUID{}
+	for i := range entries {
+		entry := &entries[i]
+		// Legacy entries are keyed by the grantor's master password and
+		// cannot be opened with the vault key.
+		plaintext, err := h.vaultKeyService.OpenEntry(entry, vaultKey, "")
+		if err != nil {
+			undecryptable = append(undecryptable, entry.ID)
+			continue
+		}
+		var data models.DecryptedVaultData
+		if err := json.Unmarshal([]byte(plaintext), &data); err != nil {
+			undecryptable = append(undecryptable, entry.ID)
+			continue
+		}
+		response := gin.H{
+			"id":         entry.ID,
+			"title":      entry.Title,
+			"website":    entry.Website,
+			"username":   entry.Username,
+			"password":   data.Password,
+			"notes":      data.Notes,
+			"folder":     entry.Folder,
+			"favorite":   entry.Favorite,
+			"created_at": entry.CreatedAt,
+			"updated_at": entry.UpdatedAt,
+		}
+		addItemFields(response, entry, &data)
+		responses = append(responses, response)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries":               responses,
+		"undecryptable_entries": undecryptable,
+	})
+}
+
+// errTakeoverUsed aborts a takeover whose approval was used or withdrawn
+// meanwhile.
+var errTakeoverUsed = errors.New("emergency access no longer approved")
+
+// Takeover sets a new master password on the grantor's account. All of the
+// grantor's sessions are signed out and their 2FA is turned off, since the
+// grantee would otherwise still be locked out. The approval is used up: the
+// grant goes back to confirmed, and another takeover needs a new request.
+func (h *EmergencyAccessHandler) Takeover(c *gin.Context) {
+	var req models.EmergencyTakeoverRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	access, ok := h.loadApproved(c)
+	if !ok {
+		return
+	}
+	if access.Type != models.EmergencyAccessTakeover {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This contact only has view access"})
+		return
+	}
+
+	grantor := &access.Grantor
+	if !h.applyTakeover(c, access, grantor, &req) {
+		return
+	}
+
+	grantor.TwoFactorEnabled = false
+	grantor.TwoFactorSecret = nil
+	grantor.BackupCodes = nil
+
+	err := h.txManager.WithinTx(c.Request.Context(), func(repos *repository.TxRepositories) error {
+		used, err := repos.EmergencyAccess.TransitionStatus(c.Request.Context(), access.ID, models.EmergencyAccessRecoveryApproved, models.EmergencyAccessConfirmed)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return errTakeoverUsed
+		}
+		if err := repos.Users.Update(c.Request.Context(), grantor); err != nil {
+			return err
+		}
+		return repos.Sessions.RevokeAllForUser(c.Request.Context(), grantor.ID, repository.SessionRevokedPassword)
+	})
+	if errors.Is(err, errTakeoverUsed) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Access is no longer approved; request it again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to take over account"})
+		return
+	}
+
+	go h.emailService.SendEmergencyTakeoverNotice(grantor.Email, access.Grantee.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account taken over successfully"})
+}
+
+// applyTakeover sets the new credentials on grantor, writing the error
+// response itself.
+func (h *EmergencyAccessHandler) applyTakeover(c *gin.Context, access *models.EmergencyAccess, grantor *models.User, req *models.EmergencyTakeoverRequest) bool {
+	if grantor.ClientSideEncryption {
+		if req.ProtectedVaultKey == nil || *req.ProtectedVaultKey == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Protected vault key required for client-side encryption"})
+			return false
+		}
+
+		if grantor.UsesPAKE() {
+			if req.SRPSalt == nil || req.SRPVerifier == nil ||
+				validateSRPEnrollment(h.srpService, *req.SRPSalt, *req.SRPVerifier) != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SRP salt or verifier"})
+				return false
+			}
+			grantor.SRPSalt = req.SRPSalt
+			grantor.SRPVerifier = req.SRPVerifier
+		} else {
+			if len(req.NewMasterPassword) < 8 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "New master password must be at least 8 characters"})
+				return false
+			}
+			hash, salt, err := h.cryptoService.HashPassword(req.NewMasterPassword)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+				return false
+			}
+			grantor.MasterPasswordHash = hash
+			grantor.Salt = salt
+		}
+
+		grantor.ProtectedVaultKey = req.ProtectedVaultKey
+		return true
+	}
+
+	if len(req.NewMasterPassword) < 8 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New master password must be at least 8 characters"})
+		return false
+	}
+
+	var vaultKey []byte
+	if req.VaultKey != nil {
+		key, err := base64.StdEncoding.DecodeString(*req.VaultKey)
+		// The grantor's private key is wrapped by the vault key, which makes
+		// it a cheap check that the uploaded key is the right one.
+		if err != nil || grantor.PrivateKey == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vault key"})
+			return false
+		}
+		if _, err := h.vaultKeyService.OpenPrivateKey(grantor, key); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vault key"})
+			return false
+		}
+		vaultKey = key
+	} else {
+		key, ok := h.openGrantorKey(c, access, req.MasterPassword)
+		if !ok {
+			return false
+		}
+		vaultKey = key
+	}
+
+	policy, err := h.policyService.EffectivePolicy(c.Request.Context(), grantor.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load password policy"})
+		return false
+	}
+	if violations := h.policyService.CheckPassword(policy, req.NewMasterPassword); len(violations) > 0 {
+		respondPolicyViolations(c, violations)
+		return false
+	}
+
+	hash, salt, err := h.cryptoService.HashPassword(req.NewMasterPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return false
+	}
+	if err := h.vaultKeyService.WrapVaultKey(grantor, vaultKey, req.NewMasterPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to wrap vault key"})
+		return false
+	}
+	grantor.MasterPasswordHash = hash
+	grantor.Salt = salt
+	return true
+}
+
+// openGrantorKey opens a server-side grantee's copy of the grantor's vault
+// key with their master password.
+func (h *EmergencyAccessHandler) openGrantorKey(c *gin.Context, access *models.EmergencyAccess, masterPassword string) ([]byte, bool) {
+	if masterPassword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Master password required"})
+		return nil, false
+	}
+
+	vaultKey, _, err := h.collectionService.OpenKey(c.Request.Context(), access.Grantee, access.EncryptedKey, masterPassword)
+	if errors.Is(err, services.ErrCollectionKey) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to open emergency access key"})
+		return nil, false
+	}
+	if err != nil {
+		respondUnlockError(c, err)
+		return nil, false
+	}
+
+	return vaultKey, true
+}
+
+// loadAccess loads the :id grant if the caller is either party. Anyone else
+// gets a 404.
+func (h *EmergencyAccessHandler) loadAccess(c *gin.Context) (*models.EmergencyAccess, bool) {
+	userID := uuid.MustParse(c.GetString("user_id"))
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid emergency access ID"})
+		return nil, false
+	}
+
+	access, err := h.emergencyAccessRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch emergency access"})
+		return nil, false
+	}
+	if access == nil || (access.GrantorID != userID && (access.GranteeID == nil || *access.GranteeID != userID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Emergency access not found"})
+		return nil, false
+	}
+
+	return access, true
+}
+
+func (h *EmergencyAccessHandler) loadAsGrantor(c *gin.Context) (*models.EmergencyAccess, bool) {
+	access, ok := h.loadAccess(c)
+	if !ok {
+		return nil, false
+	}
+	if access.GrantorID.String() != c.GetString("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the grantor can do this"})
+		return nil, false
+	}
+	return access, true
+}
+
+func (h *EmergencyAccessHandler) loadAsGrantee(c *gin.Context) (*models.EmergencyAccess, bool) {
+	access, ok := h.loadAccess(c)
+	if !ok {
+		return nil, false
+	}
+	if access.GranteeID == nil || access.GranteeID.String() != c.GetString("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the emergency contact can do this"})
+		return nil, false
+	}
+	return access, true
+}
+
+// loadApproved is loadAsGrantee for endpoints that need granted access.
+func (h *EmergencyAccessHandler) loadApproved(c *gin.Context) (*models.EmergencyAccess, bool) {
+	access, ok := h.loadAsGrantee(c)
+	if !ok {
+		return nil, false
+	}
+	if access.Status != models.EmergencyAccessRecoveryApproved {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Emergency access has not been granted"})
+		return nil, false
+	}
+	return access, true
+}
+
+// respondReloaded returns grant id as it is after a status change, described
+// from the grantee's side or the grantor's.
+func (h *EmergencyAccessHandler) respondReloaded(c *gin.Context, id uuid.UUID, asGrantee bool) {
+	access, err := h.emergencyAccessRepo.GetByID(c.Request.Context(), id)
+	if err != nil || access == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch emergency access"})
+		return
+	}
+
+	if asGrantee {
+		c.JSON(http.StatusOK, toEmergencyAccessResponse(access, &access.Grantor))
+		return
+	}
+	c.JSON(http.StatusOK, toEmergencyAccessResponse(access, access.Grantee))
+}
+
+// toEmergencyAccessResponse describes access to one party; other is the
+// opposite party's account, when there is one.
+func toEmergencyAccessResponse(access *models.EmergencyAccess, other *models.User) models.EmergencyAccessResponse {
+	response := models.EmergencyAccessResponse{
+		ID:                  access.ID,
+		GrantorID:           access.GrantorID,
+		GranteeID:           access.GranteeID,
+		Email:               access.Email,
+		Type:                access.Type,
+		Status:              access.Status,
+		WaitDays:            access.WaitDays,
+		RecoveryInitiatedAt: access.RecoveryInitiatedAt,
+		RecoveryDueAt:       access.RecoveryDueAt(),
+		CreatedAt:           access.CreatedAt,
+		UpdatedAt:           access.UpdatedAt,
+	}
+	if other != nil && other.ID != uuid.Nil {
+		response.Email = other.Email
+		response.PublicKey = other.PublicKey
+	}
+	return response
+}
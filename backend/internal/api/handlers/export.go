@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+	"github.com/tresor/password-manager/internal/services"
+)
+
+type ExportHandler struct {
+	vaultRepo       *repository.VaultRepository
+	vaultKeyService *services.VaultKeyService
+	exportService   *services.ExportService
+}
+
+func NewExportHandler(
+	vaultRepo *repository.VaultRepository,
+	vaultKeyService *services.VaultKeyService,
+	exportService *services.ExportService,
+) *ExportHandler {
+	return &ExportHandler{
+		vaultRepo:       vaultRepo,
+		vaultKeyService: vaultKeyService,
+		exportService:   exportService,
+	}
+}
+
+// ExportVault decrypts the caller's personal entries and returns them as an
+// unencrypted export file. Entries that cannot be decrypted are left out and
+// listed in skipped_entries. Zero-knowledge accounts export on the client.
+func (h *ExportHandler) ExportVault(c *gin.Context) {
+	userID := uuid.MustParse(c.GetString("user_id"))
+
+	var req models.ExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Format == "" {
+		req.Format = models.ExportFormatJSON
+	}
+
+	vaultKey, err := h.vaultKeyService.Unlock(c.Request.Context(), userID, req.MasterPassword)
+	if err != nil {
+		respondUnlockError(c, err)
+		return
+	}
+
+	vaults, err := h.vaultRepo.GetByUserID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vaults"})
+		return
+	}
+
+	entries := make([]models.ExportEntry, 0, len(vaults))
+	skipped := []uuid.UUID{}
+	for i := range vaults {
+		vault := &vaults[i]
+		plaintext, err := h.vaultKeyService.OpenEntry(vault, vaultKey, req.MasterPassword)
+		if err != nil {
+			skipped = append(skipped, vault.ID)
+			continue
+		}
+		var data models.DecryptedVaultData
+		if err := json.Unmarshal([]byte(plaintext), &data); err != nil {
+			skipped = append(skipped, vault.ID)
+			continue
+		}
+
+		entries = append(entries, models.ExportEntry{
+			Type:          vault.Type,
+			Title:         vault.Title,
+			Website:       vault.Website,
+			Username:      vault.Username,
+			Password:      data.Password,
+			Notes:         data.Notes,
+			Folder:        vault.Folder,
+			Tags:          vault.TagList(),
+			Favorite:      vault.Favorite,
+			Fields:        data.Fields,
+			VaultItemData: data.VaultItemData,
+			CreatedAt:     vault.CreatedAt,
+			UpdatedAt:     vault.UpdatedAt,
+		})
+	}
+
+	content, filename, err := h.exportService.Export(entries, req.Format, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export vault"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"format":          req.Format,
+		"filename":        filename,
+		"content":         string(content),
+		"exported":        len(entries),
+		"skipped_entries": skipped,
+	})
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+	"github.com/tresor/password-manager/internal/services"
+)
+
+// FolderHandler serves the caller's folder tree. Folder names are metadata
+// in clear, like entry titles, so it works the same for server-side and
+// zero-knowledge accounts.
+type FolderHandler struct {
+	folderRepo    *repository.FolderRepository
+	folderService *services.FolderService
+}
+
+func NewFolderHandler(folderRepo *repository.FolderRepository, folderService *services.FolderService) *FolderHandler {
+	return &FolderHandler{
+		folderRepo:    folderRepo,
+		folderService: folderService,
+	}
+}
+
+// ListFolders lists every folder of the caller, sorted by path, with its
+// item counts.
+func (h *FolderHandler) ListFolders(c *gin.Context) {
+	userID := uuid.MustParse(c.GetString("user_id"))
+
+	folders, err := h.folderService.List(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch folders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, folders)
+}
+
+func (h *FolderHandler) CreateFolder(c *gin.Context) {
+	userID := uuid.MustParse(c.GetString("user_id"))
+
+	var req models.FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	folder, err := h.folderService.Create(c.Request.Context(), userID, &req)
+	if err != nil {
+		respondFolderError(c, err, "Failed to create folder")
+		return
+	}
+
+	h.respondFolder(c, http.StatusCreated, folder)
+}
+
+// UpdateFolder renames a folder and moves it under parent_id, or to the top
+// level when it is null. Every entry below the folder gets its new path.
+func (h *FolderHandler) UpdateFolder(c *gin.Context) {
+	var req models.FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	folder, ok := h.loadFolder(c)
+	if !ok {
+		return
+	}
+
+	if err := h.folderService.Update(c.Request.Context(), folder, &req, folder.UserID, time.Now()); err != nil {
+		respondFolderError(c, err, "Failed to update folder")
+		return
+	}
+
+	h.respondFolder(c, http.StatusOK, folder)
+}
+
+// DeleteFolder deletes a folder. By default, or with ?mode=move, its entries
+// and subfolders move to its parent, or to the folder given by ?target_id=.
+// With ?mode=delete its subfolders are deleted too and all their entries go
+// to the trash.
+func (h *FolderHandler) DeleteFolder(c *gin.Context) {
+	mode := c.DefaultQuery("mode", services.FolderDeleteMove)
+	if mode != services.FolderDeleteMove && mode != services.FolderDeleteEntries {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be move or delete"})
+		return
+	}
+
+	var targetID *uuid.UUID
+	if value := c.Query("target_id"); value != "" {
+		if mode != services.FolderDeleteMove {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target_id only applies to mode=move"})
+			return
+		}
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target folder ID"})
+			return
+		}
+		targetID = &id
+	}
+
+	folder, ok := h.loadFolder(c)
+	if !ok {
+		return
+	}
+
+	count, err := h.folderService.Delete(c.Request.Context(), folder, mode, targetID, folder.UserID, time.Now())
+	if err != nil {
+		respondFolderError(c, err, "Failed to delete folder")
+		return
+	}
+
+	if mode == services.FolderDeleteEntries {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Folder deleted and its entries moved to the trash",
+			"trashed": count,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Folder deleted successfully",
+		"moved":   count,
+	})
+}
+
+// loadFolder loads the caller's folder named by the route, writing the error
+// response itself.
+func (h *FolderHandler) loadFolder(c *gin.Context) (*models.Folder, bool) {
+	folderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return nil, false
+	}
+
+	folder, err := h.folderRepo.GetByID(c.Request.Context(), folderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch folder"})
+		return nil, false
+	}
+	if folder == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return nil, false
+	}
+
+	if folder.UserID.String() != c.GetString("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return folder, true
+}
+
+func (h *FolderHandler) respondFolder(c *gin.Context, status int, folder *models.Folder) {
+	response, err := h.folderService.Describe(c.Request.Context(), folder)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch folder"})
+		return
+	}
+	c.JSON(status, response)
+}
+
+// respondFolderError writes the response for a failed folder operation.
+func respondFolderError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrFolderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+	case errors.Is(err, services.ErrInvalidFolderName):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "field": "name"})
+	case errors.Is(err, services.ErrFolderTooDeep), errors.Is(err, services.ErrFolderCycle):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrFolderExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrFolderEntriesModified):
+		c.JSON(http.StatusConflict, gin.H{"error": "Entries of the folder were modified at the same time; retry"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// assignFolder files an entry of the caller in folderID, or in the folder at
+// path, writing the error response itself.
+func assignFolder(c *gin.Context, folderService *services.FolderService, userID uuid.UUID, vault *models.Vault, folderID *uuid.UUID, path *string) bool {
+	err := folderService.Assign(c.Request.Context(), userID, vault, folderID, path)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrFolderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found", "field": "folder_id"})
+	case errors.Is(err, services.ErrInvalidFolderName), errors.Is(err, services.ErrFolderTooDeep):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "field": "folder"})
+	case errors.Is(err, services.ErrFolderExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "field": "folder"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to file the entry in its folder"})
+	}
+	return false
+}
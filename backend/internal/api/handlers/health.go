@@ -16,28 +16,38 @@ import (
 type HealthHandler struct {
 	vaultRepo         *repository.VaultRepository
 	cryptoService     *services.CryptoService
+	vaultKeyService   *services.VaultKeyService
 	passwordHealthSvc *services.PasswordHealthService
 	breachService     *services.BreachService
+	policyService     *services.PasswordPolicyService
 }
 
 func NewHealthHandler(
 	vaultRepo *repository.VaultRepository,
 	cryptoService *services.CryptoService,
+	vaultKeyService *services.VaultKeyService,
 	passwordHealthSvc *services.PasswordHealthService,
 	breachService *services.BreachService,
+	policyService *services.PasswordPolicyService,
 ) *HealthHandler {
 	return &HealthHandler{
 		vaultRepo:         vaultRepo,
 		cryptoService:     cryptoService,
+		vaultKeyService:   vaultKeyService,
 		passwordHealthSvc: passwordHealthSvc,
 		breachService:     breachService,
+		policyService:     policyService,
 	}
 }
 
+// loginEntries lists the entries whose password the health checks look at;
+// other item types have none.
+var loginEntries = repository.VaultListOptions{Type: models.ItemTypeLogin}
+
 func (h *HealthHandler) GetHealthReport(c *gin.Context) {
 	userID := c.GetString("user_id")
 
-	vaults, err := h.vaultRepo.GetByUserID(c.Request.Context(), uuid.MustParse(userID))
+	vaults, err := h.vaultRepo.List(c.Request.Context(), uuid.MustParse(userID), loginEntries)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vaults"})
 		return
@@ -57,21 +67,27 @@ func (h *HealthHandler) ScanAllPasswords(c *gin.Context) {
 		return
 	}
 
-	vaults, err := h.vaultRepo.GetByUserID(c.Request.Context(), uuid.MustParse(userID))
+	vaultKey, err := h.vaultKeyService.Unlock(c.Request.Context(), uuid.MustParse(userID), masterPassword)
+	if err != nil {
+		respondUnlockError(c, err)
+		return
+	}
+
+	vaults, err := h.vaultRepo.List(c.Request.Context(), uuid.MustParse(userID), loginEntries)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vaults"})
 		return
 	}
 
+	policy, _, ok := loadPasswordPolicy(c, h.policyService)
+	if !ok {
+		return
+	}
+
 	var vulnerablePasswords []map[string]interface{}
 
 	for _, vault := range vaults {
-		plaintext, err := h.cryptoService.DecryptData(
-			vault.EncryptedData,
-			masterPassword,
-			vault.EncryptionSalt,
-			vault.Nonce,
-		)
+		plaintext, err := h.vaultKeyService.OpenEntry(&vault, vaultKey, masterPassword)
 		if err != nil {
 			continue
 		}
@@ -84,9 +100,17 @@ func (h *HealthHandler) ScanAllPasswords(c *gin.Context) {
 
 		breached, count, _ := h.breachService.CheckBreach(password)
 
-		strength := h.passwordHealthSvc.CalculateStrength(password, vault.UpdatedAt)
+		strength := h.passwordHealthSvc.CalculateStrength(password, vault.UpdatedAt, &policy)
+		violations := strength["policy_violations"].([]models.PolicyViolation)
+		if policy.ForbidBreached && breached {
+			violations = append(violations, models.PolicyViolation{
+				Code:    models.PolicyViolationBreached,
+				Message: getBreachMessage(breached, count),
+			})
+			strength["policy_violations"] = violations
+		}
 
-		if breached || strength["score"].(int) < 60 {
+		if breached || strength["score"].(int) < 60 || len(violations) > 0 {
 			vulnerablePasswords = append(vulnerablePasswords, map[string]interface{}{
 				"vault_id": vault.ID,
 				"title":    vault.Title,
@@ -124,11 +148,31 @@ func (h *HealthHandler) AnalyzePassword(c *gin.Context) {
 		lastChanged = *req.LastChanged
 	}
 
-	strength := h.passwordHealthSvc.CalculateStrength(req.Password, lastChanged)
+	policy, _, ok := loadPasswordPolicy(c, h.policyService)
+	if !ok {
+		return
+	}
+
+	strength := h.passwordHealthSvc.CalculateStrength(req.Password, lastChanged, &policy)
 
 	c.JSON(http.StatusOK, strength)
 }
 
+// GetPasswordPolicy returns the password policy that applies to the caller
+// and the account-level rules they break. Zero-knowledge clients use it to
+// check passwords before encrypting them, since the server cannot.
+func (h *HealthHandler) GetPasswordPolicy(c *gin.Context) {
+	policy, violations, ok := loadPasswordPolicy(c, h.policyService)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"policy":     policy,
+		"violations": violations,
+	})
+}
+
 func (h *HealthHandler) CheckPasswordBreach(c *gin.Context) {
 	var req struct {
 		Password string `json:"password" binding:"required"`
@@ -19,23 +19,32 @@ type sessionEntry struct {
 }
 
 type ImportHandler struct {
-	vaultRepo     *repository.VaultRepository
-	importService *services.ImportService
-	cryptoService *services.CryptoService
-	sessions      map[string]sessionEntry
-	sessionsMu    sync.RWMutex
+	vaultRepo       *repository.VaultRepository
+	importService   *services.ImportService
+	cryptoService   *services.CryptoService
+	vaultKeyService *services.VaultKeyService
+	policyService   *services.PasswordPolicyService
+	folderService   *services.FolderService
+	sessions        map[string]sessionEntry
+	sessionsMu      sync.RWMutex
 }
 
 func NewImportHandler(
 	vaultRepo *repository.VaultRepository,
 	importService *services.ImportService,
 	cryptoService *services.CryptoService,
+	vaultKeyService *services.VaultKeyService,
+	policyService *services.PasswordPolicyService,
+	folderService *services.FolderService,
 ) *ImportHandler {
 	return &ImportHandler{
-		vaultRepo:     vaultRepo,
-		importService: importService,
-		cryptoService: cryptoService,
-		sessions:      make(map[string]sessionEntry),
+		vaultRepo:       vaultRepo,
+		importService:   importService,
+		cryptoService:   cryptoService,
+		vaultKeyService: vaultKeyService,
+		policyService:   policyService,
+		folderService:   folderService,
+		sessions:        make(map[string]sessionEntry),
 	}
 }
 
@@ -90,6 +99,12 @@ func (h *ImportHandler) UploadFile(c *gin.Context) {
 			continue
 		}
 
+		if err := services.NormalizeCustomFields(entry.Fields); err != nil {
+			entry.ValidationIssues = append(entry.ValidationIssues, "Invalid custom field: "+err.Error())
+			invalidEntries = append(invalidEntries, entry)
+			continue
+		}
+
 		strength := h.cryptoService.CalculatePasswordStrength(entry.Password)
 		if strength["score"].(int) < 40 {
 			warnings = append(warnings, entry.Title+" has a weak password")
@@ -174,9 +189,22 @@ func (h *ImportHandler) ConfirmImport(c *gin.Context) {
 		return
 	}
 
+	vaultKey, err := h.vaultKeyService.Unlock(c.Request.Context(), uuid.MustParse(userID), req.MasterPassword)
+	if err != nil {
+		respondUnlockError(c, err)
+		return
+	}
+
+	if !h.enforcePasswordPolicy(c, validEntries) {
+		return
+	}
+
 	imported := 0
 	skipped := 0
 	var errors []map[string]string
+	// Folder paths of the file, created once each.
+	folders := make(map[string]*models.Folder)
+	folderPaths := make(map[string]string)
 
 	for _, entry := range validEntries {
 		existing, _ := h.vaultRepo.GetByUserID(c.Request.Context(), uuid.MustParse(userID))
@@ -211,17 +239,47 @@ func (h *ImportHandler) ConfirmImport(c *gin.Context) {
 			}
 		}
 
-		dataJSON, _ := json.Marshal(map[string]interface{}{
-			"password": entry.Password,
-			"notes":    entry.Notes,
+		dataJSON, _ := json.Marshal(models.DecryptedVaultData{
+			Password: entry.Password,
+			Notes:    entry.Notes,
+			Fields:   entry.Fields,
 		})
 
-		ciphertext, salt, nonce, err := h.cryptoService.EncryptData(
-			string(dataJSON),
-			req.MasterPassword,
-		)
+		vault := &models.Vault{
+			ID:        uuid.New(),
+			UserID:    uuid.MustParse(userID),
+			Type:      models.ItemTypeLogin,
+			Title:     entry.Title,
+			Website:   entry.Website,
+			Username:  entry.Username,
+			Favorite:  entry.Favorite,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
 
-		if err != nil {
+		if entry.Folder != nil {
+			folder, seen := folders[*entry.Folder]
+			if !seen {
+				var path string
+				folder, path, err = h.folderService.EnsurePath(c.Request.Context(), vault.UserID, *entry.Folder)
+				if err != nil {
+					errors = append(errors, map[string]string{
+						"title": entry.Title,
+						"error": "Failed to create folder",
+					})
+					continue
+				}
+				folders[*entry.Folder] = folder
+				folderPaths[*entry.Folder] = path
+			}
+			if folder != nil {
+				path := folderPaths[*entry.Folder]
+				vault.FolderID = &folder.ID
+				vault.Folder = &path
+			}
+		}
+
+		if err := h.vaultKeyService.SealEntry(vault, vaultKey, string(dataJSON)); err != nil {
 			errors = append(errors, map[string]string{
 				"title": entry.Title,
 				"error": "Encryption failed",
@@ -229,21 +287,6 @@ func (h *ImportHandler) ConfirmImport(c *gin.Context) {
 			continue
 		}
 
-		vault := &models.Vault{
-			ID:             uuid.New(),
-			UserID:         uuid.MustParse(userID),
-			Title:          entry.Title,
-			Website:        entry.Website,
-			Username:       entry.Username,
-			EncryptedData:  ciphertext,
-			EncryptionSalt: salt,
-			Nonce:          nonce,
-			Folder:         entry.Folder,
-			Favorite:       entry.Favorite,
-			CreatedAt:      time.Now(),
-			UpdatedAt:      time.Now(),
-		}
-
 		if err := h.vaultRepo.Create(c.Request.Context(), vault); err != nil {
 			errors = append(errors, map[string]string{
 				"title": entry.Title,
@@ -267,6 +310,42 @@ func (h *ImportHandler) ConfirmImport(c *gin.Context) {
 	})
 }
 
+// enforcePasswordPolicy checks every entry of an import against the caller's
+// password policy before anything is written. The import is rejected as a
+// whole, listing each offending entry, and the session is kept so that it can
+// be confirmed again once the policy allows it.
+func (h *ImportHandler) enforcePasswordPolicy(c *gin.Context, entries []models.ImportEntry) bool {
+	policy, violations, ok := loadPasswordPolicy(c, h.policyService)
+	if !ok {
+		return false
+	}
+	if len(violations) > 0 {
+		respondPolicyViolations(c, violations)
+		return false
+	}
+
+	var rejected []entryPolicyViolations
+	for i, entry := range entries {
+		if violations := h.policyService.CheckPassword(policy, entry.Password); len(violations) > 0 {
+			rejected = append(rejected, entryPolicyViolations{
+				Index:      i,
+				Title:      entry.Title,
+				Violations: violations,
+			})
+		}
+	}
+	if len(rejected) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Some entries do not meet the password policy",
+			"code":    policyViolationCode,
+			"entries": rejected,
+		})
+		return false
+	}
+
+	return true
+}
+
 func (h *ImportHandler) GetSupportedFormats(c *gin.Context) {
 	formats := []map[string]interface{}{
 		{
@@ -0,0 +1,959 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+	"github.com/tresor/password-manager/internal/services"
+)
+
+// organizationInvitationTTL is how long an emailed invitation stays valid.
+const organizationInvitationTTL = 7 * 24 * time.Hour
+
+// OrganizationHandler serves organizations: tenants whose members share the
+// organization's own items and collections. Members join by invitation and
+// receive the org key once an admin confirms them.
+type OrganizationHandler struct {
+	organizationRepo  *repository.OrganizationRepository
+	collectionRepo    *repository.CollectionRepository
+	vaultRepo         *repository.VaultRepository
+	userRepo          *repository.UserRepository
+	txManager         *repository.TxManager
+	collectionService *services.CollectionService
+	emailService      *services.EmailService
+	entries           *sharedEntries
+}
+
+func NewOrganizationHandler(
+	organizationRepo *repository.OrganizationRepository,
+	collectionRepo *repository.CollectionRepository,
+	vaultRepo *repository.VaultRepository,
+	userRepo *repository.UserRepository,
+	txManager *repository.TxManager,
+	collectionService *services.CollectionService,
+	vaultKeyService *services.VaultKeyService,
+	emailService *services.EmailService,
+	attachmentRepo *repository.AttachmentRepository,
+	historyRepo *repository.PasswordHistoryRepository,
+	policyService *services.PasswordPolicyService,
+) *OrganizationHandler {
+	return &OrganizationHandler{
+		organizationRepo:  organizationRepo,
+		collectionRepo:    collectionRepo,
+		vaultRepo:         vaultRepo,
+		userRepo:          userRepo,
+		txManager:         txManager,
+		collectionService: collectionService,
+		emailService:      emailService,
+		entries: &sharedEntries{
+			vaultRepo:         vaultRepo,
+			collectionService: collectionService,
+			vaultKeyService:   vaultKeyService,
+			attachmentRepo:    attachmentRepo,
+			historyRepo:       historyRepo,
+			policyService:     policyService,
+		},
+	}
+}
+
+// CreateOrganization creates an organization with the caller as owner and
+// first confirmed member.
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var req models.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	encryptedKey, ok := h.entries.newKey(c, user, req.MasterPassword, req.EncryptedKey)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	organization := &models.Organization{
+		ID:         uuid.New(),
+		OwnerID:    user.ID,
+		Name:       req.Name,
+		KeyVersion: 1,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	member := &models.OrganizationMember{
+		ID:             uuid.New(),
+		OrganizationID: organization.ID,
+		UserID:         user.ID,
+		Role:           models.OrganizationRoleOwner,
+		Status:         models.OrganizationMemberConfirmed,
+		EncryptedKey:   encryptedKey,
+		KeyVersion:     1,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	err := h.txManager.WithinTx(c.Request.Context(), func(repos *repository.TxRepositories) error {
+		if err := repos.Organizations.Create(c.Request.Context(), organization); err != nil {
+			return err
+		}
+		return repos.Organizations.AddMember(c.Request.Context(), member)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, toOrganizationResponse(organization, member))
+}
+
+// ListOrganizations lists the organizations the caller belongs to.
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	memberships, err := h.organizationRepo.GetMembershipsForUser(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organizations"})
+		return
+	}
+
+	responses := make([]models.OrganizationResponse, 0, len(memberships))
+	for i := range memberships {
+		responses = append(responses, toOrganizationResponse(&memberships[i].Organization, &memberships[i]))
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// GetOrganization returns an organization with its members. Member public
+// keys are included so zero-knowledge admins can confirm members and re-key.
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	organization, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.organizationRepo.GetMembers(c.Request.Context(), organization.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+
+	memberResponses := make([]models.OrganizationMemberResponse, 0, len(members))
+	for _, m := range members {
+		memberResponses = append(memberResponses, models.OrganizationMemberResponse{
+			UserID:    m.UserID,
+			Email:     m.User.Email,
+			PublicKey: m.User.PublicKey,
+			Role:      m.Role,
+			Status:    m.Status,
+			CreatedAt: m.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organization": toOrganizationResponse(organization, member),
+		"members":      memberResponses,
+	})
+}
+
+// UpdateOrganization renames an organization. Admins only.
+func (h *OrganizationHandler) UpdateOrganization(c *gin.Context) {
+	var req models.UpdateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organization, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can change the organization"})
+		return
+	}
+
+	organization.Name = req.Name
+	organization.UpdatedAt = time.Now()
+	if err := h.organizationRepo.Update(c.Request.Context(), organization); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toOrganizationResponse(organization, member))
+}
+
+// UpdatePolicy replaces the password policy the organization's members are
+// held to, on top of the instance policy. Admins only.
+func (h *OrganizationHandler) UpdatePolicy(c *gin.Context) {
+	var req models.PasswordPolicy
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organization, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can change the password policy"})
+		return
+	}
+
+	organization.Policy = req
+	organization.UpdatedAt = time.Now()
+	if err := h.organizationRepo.Update(c.Request.Context(), organization); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toOrganizationResponse(organization, member))
+}
+
+// DeleteOrganization deletes an organization with its items and collections.
+// Only the owner can do this.
+func (h *OrganizationHandler) DeleteOrganization(c *gin.Context) {
+	organization, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if member.Role != models.OrganizationRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can delete the organization"})
+		return
+	}
+
+	if err := h.organizationRepo.Delete(c.Request.Context(), organization.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organization deleted successfully"})
+}
+
+// ListCollections lists the organization's collections. Joining one still
+// goes through its managers.
+func (h *OrganizationHandler) ListCollections(c *gin.Context) {
+	organization, _, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+
+	collections, err := h.collectionRepo.GetByOrganizationID(c.Request.Context(), organization.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collections"})
+		return
+	}
+
+	c.JSON(http.StatusOK, collections)
+}
+
+// InviteMember emails an invitation to join the organization. Admins only;
+// only the owner can invite admins.
+func (h *OrganizationHandler) InviteMember(c *gin.Context) {
+	var req models.InviteOrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Role != models.OrganizationRoleAdmin && req.Role != models.OrganizationRoleMember {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be admin or member"})
+		return
+	}
+
+	organization, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can invite members"})
+		return
+	}
+	if req.Role == models.OrganizationRoleAdmin && member.Role != models.OrganizationRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can invite admins"})
+		return
+	}
+
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	invitee, err := h.userRepo.GetByEmail(c.Request.Context(), req.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up user"})
+		return
+	}
+	if invitee != nil {
+		existing, err := h.organizationRepo.GetMember(c.Request.Context(), organization.ID, invitee.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check membership"})
+			return
+		}
+		if existing != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "User is already a member"})
+			return
+		}
+	}
+
+	token := generateRandomToken(32)
+	if token == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invitation"})
+		return
+	}
+
+	invitation := &models.OrganizationInvitation{
+		ID:             uuid.New(),
+		OrganizationID: organization.ID,
+		Email:          strings.ToLower(req.Email),
+		Role:           req.Role,
+		TokenHash:      hashInvitationToken(token),
+		InvitedBy:      user.ID,
+		ExpiresAt:      time.Now().Add(organizationInvitationTTL),
+		CreatedAt:      time.Now(),
+	}
+	if err := h.organizationRepo.CreateInvitation(c.Request.Context(), invitation); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	acceptURL := fmt.Sprintf("https://yourdomain.com/organizations/accept?token=%s", url.QueryEscape(token))
+	go h.emailService.SendOrganizationInvitation(invitation.Email, organization.Name, user.Email, acceptURL)
+
+	invitation.Organization = *organization
+	c.JSON(http.StatusCreated, toOrganizationInvitationResponse(invitation))
+}
+
+// ListInvitations lists the organization's open invitations. Admins only.
+func (h *OrganizationHandler) ListInvitations(c *gin.Context) {
+	organization, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can list invitations"})
+		return
+	}
+
+	invitations, err := h.organizationRepo.GetPendingInvitations(c.Request.Context(), organization.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toOrganizationInvitationResponses(invitations))
+}
+
+// RevokeInvitation cancels an open invitation. Admins only.
+func (h *OrganizationHandler) RevokeInvitation(c *gin.Context) {
+	organization, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can revoke invitations"})
+		return
+	}
+
+	invitationID, err := uuid.Parse(c.Param("invitationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	invitation, err := h.organizationRepo.GetInvitationByID(c.Request.Context(), invitationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitation"})
+		return
+	}
+	if invitation == nil || invitation.OrganizationID != organization.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+
+	if err := h.organizationRepo.DeleteInvitation(c.Request.Context(), invitation.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
+
+// ListMyInvitations lists the open invitations sent to the caller's email.
+func (h *OrganizationHandler) ListMyInvitations(c *gin.Context) {
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.organizationRepo.GetPendingInvitationsForEmail(c.Request.Context(), user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toOrganizationInvitationResponses(invitations))
+}
+
+// AcceptInvitation joins an organization with the emailed token. The caller's
+// email must be the invited one, and they must have 2FA enabled if the
+// organization's policy requires it. The new member still needs an admin to
+// confirm them before they receive the org key.
+func (h *OrganizationHandler) AcceptInvitation(c *gin.Context) {
+	var req models.AcceptOrganizationInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	invitation, err := h.organizationRepo.GetInvitationByTokenHash(c.Request.Context(), hashInvitationToken(req.Token))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitation"})
+		return
+	}
+	if invitation == nil || invitation.AcceptedAt != nil || !strings.EqualFold(invitation.Email, user.Email) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+	if invitation.IsExpired() {
+		c.JSON(http.StatusGone, gin.H{"error": "Invitation has expired"})
+		return
+	}
+	if invitation.Organization.Policy.Require2FA && !user.TwoFactorEnabled {
+		respondPolicyViolations(c, []models.PolicyViolation{{
+			Code:    models.PolicyViolation2FA,
+			Message: "This organization requires two-factor authentication",
+		}})
+		return
+	}
+
+	existing, err := h.organizationRepo.GetMember(c.Request.Context(), invitation.OrganizationID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check membership"})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already a member"})
+		return
+	}
+
+	now := time.Now()
+	member := &models.OrganizationMember{
+		ID:             uuid.New(),
+		OrganizationID: invitation.OrganizationID,
+		UserID:         user.ID,
+		Role:           invitation.Role,
+		Status:         models.OrganizationMemberAccepted,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	err = h.txManager.WithinTx(c.Request.Context(), func(repos *repository.TxRepositories) error {
+		if err := repos.Organizations.AddMember(c.Request.Context(), member); err != nil {
+			return err
+		}
+		return repos.Organizations.MarkInvitationAccepted(c.Request.Context(), invitation.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toOrganizationResponse(&invitation.Organization, member))
+}
+
+// ConfirmMember hands the org key to a member who accepted an invitation, by
+// sealing it to their public key. Admins only.
+func (h *OrganizationHandler) ConfirmMember(c *gin.Context) {
+	var req models.ConfirmOrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organization, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanAdmin() || !member.IsConfirmed() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only confirmed admins can confirm members"})
+		return
+	}
+
+	target, ok := h.loadTargetMember(c, organization)
+	if !ok {
+		return
+	}
+	if target.IsConfirmed() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Member is already confirmed"})
+		return
+	}
+
+	targetUser, err := h.userRepo.GetByID(c.Request.Context(), target.UserID)
+	if err != nil || targetUser == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+	if targetUser.PublicKey == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Member has no encryption key yet; they must unlock their vault once before they can be confirmed"})
+		return
+	}
+
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	var encryptedKey string
+	if user.ClientSideEncryption {
+		if req.EncryptedKey == nil || h.collectionService.ValidateSealedKey(*req.EncryptedKey) != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Org key sealed to the member is required"})
+			return
+		}
+		encryptedKey = *req.EncryptedKey
+	} else {
+		key, _, ok := h.entries.openKey(c, user, member.EncryptedKey, req.MasterPassword)
+		if !ok {
+			return
+		}
+		encryptedKey, err = h.collectionService.SealKeyForUser(key, targetUser)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+			return
+		}
+	}
+
+	if err := h.organizationRepo.UpdateMemberKey(c.Request.Context(), target.ID, encryptedKey, organization.KeyVersion); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member confirmed successfully"})
+}
+
+// UpdateMember changes a member's role. Only the owner can do this, and the
+// owner's own role cannot change.
+func (h *OrganizationHandler) UpdateMember(c *gin.Context) {
+	var req models.UpdateOrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Role != models.OrganizationRoleAdmin && req.Role != models.OrganizationRoleMember {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be admin or member"})
+		return
+	}
+
+	organization, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+	if member.Role != models.OrganizationRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can change roles"})
+		return
+	}
+
+	target, ok := h.loadTargetMember(c, organization)
+	if !ok {
+		return
+	}
+	if target.Role == models.OrganizationRoleOwner {
+		c.JSON(http.StatusConflict, gin.H{"error": "The owner's role cannot change"})
+		return
+	}
+
+	if err := h.organizationRepo.UpdateMemberRole(c.Request.Context(), target.ID, req.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member updated successfully"})
+}
+
+// RemoveMember removes a member, or lets a member leave. Admins can remove
+// members and the owner can remove admins; the owner cannot be removed.
+// Removing a confirmed member re-keys the organization the same way removing
+// a collection member does. Members must be removed from the organization's
+// collections first, since each of those has its own key to replace.
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	var req models.RemoveOrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organization, member, ok := h.loadMembership(c)
+	if !ok {
+		return
+	}
+
+	target, ok := h.loadTargetMember(c, organization)
+	if !ok {
+		return
+	}
+	if target.Role == models.OrganizationRoleOwner {
+		c.JSON(http.StatusConflict, gin.H{"error": "The owner cannot be removed"})
+		return
+	}
+
+	leaving := target.ID == member.ID
+	if !leaving {
+		canRemove := member.CanAdmin() &&
+			(target.Role == models.OrganizationRoleMember || member.Role == models.OrganizationRoleOwner)
+		if !canRemove {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot remove this member"})
+			return
+		}
+	}
+
+	count, err := h.organizationRepo.CountCollectionMemberships(c.Request.Context(), organization.ID, target.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check collections"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Remove the member from the organization's collections first"})
+		return
+	}
+
+	// A member who never received the org key can simply be dropped.
+	if !target.IsConfirmed() {
+		if err := h.organizationRepo.RemoveMember(c.Request.Context(), target.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+		return
+	}
+
+	if !member.IsConfirmed() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only confirmed members can re-key the organization"})
+		return
+	}
+
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	members, err := h.organizationRepo.GetMembers(c.Request.Context(), organization.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+	remaining := make([]models.OrganizationMember, 0, len(members))
+	recipients := make([]*models.User, 0, len(members))
+	for _, m := range members {
+		if m.ID != target.ID && m.IsConfirmed() {
+			remaining = append(remaining, m)
+		}
+	}
+	for i := range remaining {
+		recipients = append(recipients, &remaining[i].User)
+	}
+
+	entries, err := h.vaultRepo.GetByOrganizationID(c.Request.Context(), organization.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch entries"})
+		return
+	}
+
+	keys, ok := h.entries.rekey(c, user, member.EncryptedKey, req.MasterPassword, req.Keys, req.Entries, recipients, entries)
+	if !ok {
+		return
+	}
+
+	keyVersion := organization.KeyVersion + 1
+
+	err = h.txManager.WithinTx(c.Request.Context(), func(repos *repository.TxRepositories) error {
+		if err := saveRekeyedEntries(c, repos, entries); err != nil {
+			return err
+		}
+
+		for _, m := range remaining {
+			if err := repos.Organizations.UpdateMemberKey(c.Request.Context(), m.ID, keys[m.UserID], keyVersion); err != nil {
+				return err
+			}
+		}
+
+		if err := repos.Organizations.RemoveMember(c.Request.Context(), target.ID); err != nil {
+			return err
+		}
+
+		organization.KeyVersion = keyVersion
+		organization.UpdatedAt = time.Now()
+		return repos.Organizations.Update(c.Request.Context(), organization)
+	})
+	if errors.Is(err, errSharedEntriesConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An item changed during re-keying; try again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Member removed and organization re-keyed",
+		"key_version": keyVersion,
+	})
+}
+
+// ListEntries lists the organization's items. Confirmed members only.
+func (h *OrganizationHandler) ListEntries(c *gin.Context) {
+	organization, _, ok := h.loadConfirmedMembership(c)
+	if !ok {
+		return
+	}
+
+	entries, err := h.vaultRepo.GetByOrganizationID(c.Request.Context(), organization.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch entries"})
+		return
+	}
+
+	h.entries.list(c, entries)
+}
+
+// GetEntry returns one organization item. Server-side accounts pass
+// ?master_password= and get it decrypted.
+func (h *OrganizationHandler) GetEntry(c *gin.Context) {
+	organization, member, ok := h.loadConfirmedMembership(c)
+	if !ok {
+		return
+	}
+	scope := organizationScope(organization, member)
+
+	entry, ok := h.entries.load(c, scope)
+	if !ok {
+		return
+	}
+
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	h.entries.get(c, scope, user, entry)
+}
+
+// CreateEntry adds an organization item, or moves one of the caller's
+// personal entries into the organization when VaultID is set. Admins only.
+func (h *OrganizationHandler) CreateEntry(c *gin.Context) {
+	var req models.CollectionEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organization, member, ok := h.loadConfirmedMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can add organization items"})
+		return
+	}
+
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	h.entries.create(c, organizationScope(organization, member), user, &req)
+}
+
+// UpdateEntry changes an organization item. Revision must match the stored
+// revision. Admins only.
+func (h *OrganizationHandler) UpdateEntry(c *gin.Context) {
+	var req models.CollectionEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organization, member, ok := h.loadConfirmedMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can change organization items"})
+		return
+	}
+	scope := organizationScope(organization, member)
+
+	entry, ok := h.entries.load(c, scope)
+	if !ok {
+		return
+	}
+
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	h.entries.update(c, scope, user, entry, &req)
+}
+
+// DeleteEntry removes an organization item. Admins only.
+func (h *OrganizationHandler) DeleteEntry(c *gin.Context) {
+	organization, member, ok := h.loadConfirmedMembership(c)
+	if !ok {
+		return
+	}
+	if !member.CanAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can delete organization items"})
+		return
+	}
+
+	entry, ok := h.entries.load(c, organizationScope(organization, member))
+	if !ok {
+		return
+	}
+
+	h.entries.delete(c, entry)
+}
+
+// loadMembership loads the :id organization and the caller's membership.
+// Non-members get a 404 so that organization IDs do not leak.
+func (h *OrganizationHandler) loadMembership(c *gin.Context) (*models.Organization, *models.OrganizationMember, bool) {
+	userID := c.GetString("user_id")
+
+	organizationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return nil, nil, false
+	}
+
+	member, err := h.organizationRepo.GetMember(c.Request.Context(), organizationID, uuid.MustParse(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
+		return nil, nil, false
+	}
+	if member == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return nil, nil, false
+	}
+
+	organization, err := h.organizationRepo.GetByID(c.Request.Context(), organizationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
+		return nil, nil, false
+	}
+	if organization == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return nil, nil, false
+	}
+
+	return organization, member, true
+}
+
+// loadConfirmedMembership is loadMembership for endpoints that need the org
+// key.
+func (h *OrganizationHandler) loadConfirmedMembership(c *gin.Context) (*models.Organization, *models.OrganizationMember, bool) {
+	organization, member, ok := h.loadMembership(c)
+	if !ok {
+		return nil, nil, false
+	}
+	if !member.IsConfirmed() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your membership has not been confirmed by an admin yet"})
+		return nil, nil, false
+	}
+	return organization, member, true
+}
+
+// loadTargetMember loads the :userId member of organization.
+func (h *OrganizationHandler) loadTargetMember(c *gin.Context, organization *models.Organization) (*models.OrganizationMember, bool) {
+	targetID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, false
+	}
+
+	target, err := h.organizationRepo.GetMember(c.Request.Context(), organization.ID, targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch member"})
+		return nil, false
+	}
+	if target == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return nil, false
+	}
+
+	return target, true
+}
+
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// organizationScope describes the organization's own items. They are stored
+// under the owner's account so that they outlive whoever added them.
+func organizationScope(organization *models.Organization, member *models.OrganizationMember) *entryScope {
+	return &entryScope{
+		ownerID:        organization.OwnerID,
+		organizationID: &organization.ID,
+		sealedKey:      member.EncryptedKey,
+		canView:        true,
+		canCopy:        true,
+
+		policyOrganizationID: &organization.ID,
+	}
+}
+
+func toOrganizationResponse(organization *models.Organization, member *models.OrganizationMember) models.OrganizationResponse {
+	return models.OrganizationResponse{
+		ID:           organization.ID,
+		OwnerID:      organization.OwnerID,
+		Name:         organization.Name,
+		Role:         member.Role,
+		Status:       member.Status,
+		KeyVersion:   member.KeyVersion,
+		EncryptedKey: member.EncryptedKey,
+		Policy:       organization.Policy,
+		CreatedAt:    organization.CreatedAt,
+		UpdatedAt:    organization.UpdatedAt,
+	}
+}
+
+func toOrganizationInvitationResponse(invitation *models.OrganizationInvitation) models.OrganizationInvitationResponse {
+	return models.OrganizationInvitationResponse{
+		ID:               invitation.ID,
+		OrganizationID:   invitation.OrganizationID,
+		OrganizationName: invitation.Organization.Name,
+		Email:            invitation.Email,
+		Role:             invitation.Role,
+		ExpiresAt:        invitation.ExpiresAt,
+		CreatedAt:        invitation.CreatedAt,
+	}
+}
+
+func toOrganizationInvitationResponses(invitations []models.OrganizationInvitation) []models.OrganizationInvitationResponse {
+	responses := make([]models.OrganizationInvitationResponse, 0, len(invitations))
+	for i := range invitations {
+		responses = append(responses, toOrganizationInvitationResponse(&invitations[i]))
+	}
+	return responses
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/api/middleware"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+	"github.com/tresor/password-manager/internal/services"
+)
+
+// PAKERegister creates an account that signs in with SRP. The client sends
+// the SRP salt and verifier and its own wrap of the vault key; the master
+// password never reaches the server. PAKE accounts are always in
+// zero-knowledge mode.
+func (h *AuthHandler) PAKERegister(c *gin.Context) {
+	var req models.PAKERegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateSRPEnrollment(h.srpService, req.SRPSalt, req.SRPVerifier); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SRP salt or verifier"})
+		return
+	}
+
+	existingUser, err := h.userRepo.GetByEmail(c.Request.Context(), req.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing user"})
+		return
+	}
+	if existingUser != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+		return
+	}
+
+	user := &models.User{
+		ID:                   uuid.New(),
+		Email:                req.Email,
+		ClientSideEncryption: true,
+		ProtectedVaultKey:    &req.ProtectedVaultKey,
+		SRPSalt:              &req.SRPSalt,
+		SRPVerifier:          &req.SRPVerifier,
+		TwoFactorEnabled:     false,
+		CreatedAt:            time.Now(),
+		UpdatedAt:            time.Now(),
+	}
+
+	if err := applyClientKeyPair(h.cryptoService, user, req.ClientKeyPair); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client keypair"})
+		return
+	}
+
+	if err := h.userRepo.Create(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	h.bindShareInvitation(c, user, req.InvitationToken)
+
+	go h.emailService.SendWelcomeEmail(req.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "User created successfully"})
+}
+
+// PAKELoginInit is the first SRP round: the client sends A, the server answers
+// with the salt and B. Unknown emails get a plausible fake challenge.
+func (h *AuthHandler) PAKELoginInit(c *gin.Context) {
+	var req models.PAKELoginInitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	clientPublic, err := base64.StdEncoding.DecodeString(req.ClientPublic)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client public value"})
+		return
+	}
+
+	user, err := h.userRepo.GetByEmail(c.Request.Context(), req.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	challenge, err := h.srpService.BeginLogin(user, req.Email, clientPublic)
+	if errors.Is(err, services.ErrSRPInvalidPublic) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client public value"})
+		return
+	}
+	if errors.Is(err, services.ErrSRPTooManyHandshakes) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many pending logins, try again later"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PAKELoginInitResponse{
+		HandshakeID:  challenge.HandshakeID,
+		SRPSalt:      base64.StdEncoding.EncodeToString(challenge.Salt),
+		ServerPublic: base64.StdEncoding.EncodeToString(challenge.ServerPublic),
+		ExpiresIn:    int(services.SRPHandshakeTTL.Seconds()),
+	})
+}
+
+// PAKELoginVerify is the second SRP round: the client proves knowledge of the
+// password with M1 and gets M2 back together with a session, or an MFA
+// challenge when 2FA is enabled.
+func (h *AuthHandler) PAKELoginVerify(c *gin.Context) {
+	var req models.PAKELoginVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	clientProof, err := base64.StdEncoding.DecodeString(req.ClientProof)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client proof"})
+		return
+	}
+
+	userID, serverProof, err := h.srpService.FinishLogin(req.HandshakeID, clientProof)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(c.Request.Context(), userID)
+	if err != nil || user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	response := models.PAKELoginVerifyResponse{
+		ServerProof: base64.StdEncoding.EncodeToString(serverProof),
+	}
+
+	if user.TwoFactorEnabled {
+		mfaToken, err := h.generateMFAToken(c.Request.Context(), user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		response.MFA = &models.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+			TokenType:   middleware.ScopeMFAPending,
+			ExpiresIn:   int(mfaPendingTTL.Seconds()),
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	session, err := h.startSession(c, user, req.DeviceName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+	response.Session = session
+
+	c.JSON(http.StatusOK, response)
+}
+
+// PAKEEnroll moves a zero-knowledge account to SRP login, or rotates the
+// verifier of a PAKE account after its master password changed. The stored
+// password hash is dropped and every other session is signed out.
+func (h *AuthHandler) PAKEEnroll(c *gin.Context) {
+	userID := c.GetString("user_id")
+	sessionID := c.GetString("session_id")
+
+	var req models.PAKEEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// The server needs the master password to unlock server-side vaults, so
+	// only zero-knowledge accounts can stop sending it.
+	if !user.ClientSideEncryption {
+		c.JSON(http.StatusConflict, gin.H{"error": "Enable client-side encryption before switching to PAKE login"})
+		return
+	}
+
+	if err := validateSRPEnrollment(h.srpService, req.SRPSalt, req.SRPVerifier); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SRP salt or verifier"})
+		return
+	}
+
+	if !verifyAccountSecret(h.cryptoService, h.srpService, user, req.CurrentAuthSecret, req.PAKEProof) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	user.SRPSalt = &req.SRPSalt
+	user.SRPVerifier = &req.SRPVerifier
+	user.MasterPasswordHash = ""
+	user.Salt = ""
+	if req.ProtectedVaultKey != nil {
+		user.ProtectedVaultKey = req.ProtectedVaultKey
+	}
+	user.UpdatedAt = time.Now()
+
+	err = h.txManager.WithinTx(c.Request.Context(), func(repos *repository.TxRepositories) error {
+		if err := repos.Users.Update(c.Request.Context(), user); err != nil {
+			return err
+		}
+
+		_, err := repos.Sessions.RevokeOthersForUser(c.Request.Context(), user.ID, uuid.MustParse(sessionID), repository.SessionRevokedPassword)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enroll PAKE verifier"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "PAKE login enabled"})
+}
+
+func validateSRPEnrollment(srpService *services.SRPService, saltStr, verifierStr string) error {
+	salt, err := base64.StdEncoding.DecodeString(saltStr)
+	if err != nil {
+		return err
+	}
+	verifier, err := base64.StdEncoding.DecodeString(verifierStr)
+	if err != nil {
+		return err
+	}
+	return srpService.ValidateEnrollment(salt, verifier)
+}
+
+// verifyAccountSecret re-authenticates user for a sensitive operation. Hash
+// based accounts present their master password (or, in zero-knowledge mode,
+// the client-derived auth secret); PAKE accounts present a proof for a fresh
+// SRP handshake instead.
+func verifyAccountSecret(
+	cryptoService *services.CryptoService,
+	srpService *services.SRPService,
+	user *models.User,
+	secret string,
+	proof models.PAKEProof,
+) bool {
+	if !user.UsesPAKE() {
+		return secret != "" && cryptoService.VerifyPassword(secret, user.MasterPasswordHash, user.Salt)
+	}
+
+	clientProof, err := base64.StdEncoding.DecodeString(proof.ClientProof)
+	if err != nil || proof.HandshakeID == "" {
+		return false
+	}
+
+	provedID, _, err := srpService.FinishLogin(proof.HandshakeID, clientProof)
+	return err == nil && provedID == user.ID
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+	"github.com/tresor/password-manager/internal/services"
+)
+
+// errEntryModified aborts a restore when the entry changed since it was read.
+var errEntryModified = errors.New("entry modified concurrently")
+
+// PasswordHistoryHandler serves the passwords a personal entry held before.
+// Zero-knowledge clients keep their history inside their own encrypted
+// entries, so these endpoints answer 409 for them.
+type PasswordHistoryHandler struct {
+	vaultRepo       *repository.VaultRepository
+	historyRepo     *repository.PasswordHistoryRepository
+	userRepo        *repository.UserRepository
+	txManager       *repository.TxManager
+	vaultKeyService *services.VaultKeyService
+	historyService  *services.PasswordHistoryService
+	policyService   *services.PasswordPolicyService
+}
+
+func NewPasswordHistoryHandler(
+	vaultRepo *repository.VaultRepository,
+	historyRepo *repository.PasswordHistoryRepository,
+	userRepo *repository.UserRepository,
+	txManager *repository.TxManager,
+	vaultKeyService *services.VaultKeyService,
+	historyService *services.PasswordHistoryService,
+	policyService *services.PasswordPolicyService,
+) *PasswordHistoryHandler {
+	return &PasswordHistoryHandler{
+		vaultRepo:       vaultRepo,
+		historyRepo:     historyRepo,
+		userRepo:        userRepo,
+		txManager:       txManager,
+		vaultKeyService: vaultKeyService,
+		historyService:  historyService,
+		policyService:   policyService,
+	}
+}
+
+// GetHistory lists the previous passwords of an entry, most recent change
+// first, decrypted with ?master_password=.
+func (h *PasswordHistoryHandler) GetHistory(c *gin.Context) {
+	masterPassword := c.Query("master_password")
+	if masterPassword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Master password required"})
+		return
+	}
+
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	vault, ok := loadPersonalEntry(c, h.vaultRepo, user)
+	if !ok {
+		return
+	}
+
+	vaultKey, err := h.vaultKeyService.UnlockUser(c.Request.Context(), user, masterPassword)
+	if err != nil {
+		respondUnlockError(c, err)
+		return
+	}
+
+	history, err := h.historyService.List(c.Request.Context(), vault.ID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch password history"})
+		return
+	}
+
+	response := make([]models.PasswordHistoryResponse, 0, len(history))
+	for i := range history {
+		password, err := h.historyService.Open(&history[i], vaultKey)
+		if err != nil {
+			log.Printf("Failed to decrypt password history %s: %v", history[i].ID, err)
+			continue
+		}
+		response = append(response, models.PasswordHistoryResponse{
+			ID:        history[i].ID,
+			Password:  password,
+			ChangedBy: history[i].ChangedBy,
+			ChangedAt: history[i].ChangedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RestorePassword makes a password from the history current again. The
+// password it replaces is kept in the history, so a restore can itself be
+// undone. The restored password must meet the password policy in force
+// today, like any other new password of the entry.
+func (h *PasswordHistoryHandler) RestorePassword(c *gin.Context) {
+	var req models.RestorePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	// Unlocking applies pending shared edits, so the entry is read after.
+	vaultKey, err := h.vaultKeyService.UnlockUser(c.Request.Context(), user, req.MasterPassword)
+	if err != nil {
+		respondUnlockError(c, err)
+		return
+	}
+
+	vault, ok := loadPersonalEntry(c, h.vaultRepo, user)
+	if !ok {
+		return
+	}
+
+	historyID, err := uuid.Parse(c.Param("historyId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid history ID"})
+		return
+	}
+	restored, err := h.historyRepo.GetByID(c.Request.Context(), historyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch password history"})
+		return
+	}
+	if restored == nil || restored.VaultID != vault.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Password history not found"})
+		return
+	}
+
+	password, err := h.historyService.Open(restored, vaultKey)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to decrypt password history"})
+		return
+	}
+
+	plaintext, err := h.vaultKeyService.OpenEntry(vault, vaultKey, req.MasterPassword)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to decrypt vault entry"})
+		return
+	}
+	var data models.DecryptedVaultData
+	if err := json.Unmarshal([]byte(plaintext), &data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt data"})
+		return
+	}
+
+	if data.Password == password {
+		c.JSON(http.StatusConflict, gin.H{"error": "The entry already has this password"})
+		return
+	}
+	// The old password is held to the policy in force today.
+	if !enforcePasswordPolicy(c, h.policyService, password) {
+		return
+	}
+
+	now := time.Now()
+	var replaced *models.PasswordHistory
+	if data.Password != "" {
+		replaced, err = h.historyService.Seal(vault.ID, vaultKey, data.Password, user.ID, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+			return
+		}
+	}
+
+	data.Password = password
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare data"})
+		return
+	}
+	if err := h.vaultKeyService.SealEntry(vault, vaultKey, string(dataJSON)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+		return
+	}
+
+	expectedRevision := vault.Revision
+	vault.LastModifiedBy = &user.ID
+	vault.UpdatedAt = now
+
+	err = h.txManager.WithinTx(c.Request.Context(), func(repos *repository.TxRepositories) error {
+		updated, err := repos.Vaults.UpdateIfRevision(c.Request.Context(), vault, expectedRevision)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return errEntryModified
+		}
+		if replaced != nil {
+			if err := repos.PasswordHistory.Create(c.Request.Context(), replaced); err != nil {
+				return err
+			}
+		}
+		return repos.PasswordHistory.Delete(c.Request.Context(), restored.ID)
+	})
+	if errors.Is(err, errEntryModified) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The entry was modified at the same time; fetch it again and retry"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore password"})
+		return
+	}
+
+	if err := h.historyService.Prune(c.Request.Context(), vault.ID, now); err != nil {
+		log.Printf("Failed to prune password history of vault entry %s: %v", vault.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Password restored successfully",
+		"revision": vault.Revision,
+	})
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/services"
+)
+
+// policyViolationCode tags 422 responses caused by the password policy, so
+// clients can tell them from validation errors and show each violation.
+const policyViolationCode = "policy_violation"
+
+// entryPolicyViolations lists the violations of one rejected import entry.
+type entryPolicyViolations struct {
+	Index      int                      `json:"index"`
+	Title      string                   `json:"title"`
+	Violations []models.PolicyViolation `json:"violations"`
+}
+
+// respondPolicyViolations writes the response for a password or account that
+// breaks the password policy.
+func respondPolicyViolations(c *gin.Context, violations []models.PolicyViolation) {
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"error":      "Password does not meet the password policy",
+		"code":       policyViolationCode,
+		"violations": violations,
+	})
+}
+
+// loadPasswordPolicy loads the policy that applies to the caller together with
+// the account-level rules they already break.
+func loadPasswordPolicy(c *gin.Context, policyService *services.PasswordPolicyService) (models.PasswordPolicy, []models.PolicyViolation, bool) {
+	userID := uuid.MustParse(c.GetString("user_id"))
+
+	policy, err := policyService.EffectivePolicy(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load password policy"})
+		return models.PasswordPolicy{}, nil, false
+	}
+
+	violations, err := policyService.CheckAccount(c.Request.Context(), userID, policy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check password policy"})
+		return models.PasswordPolicy{}, nil, false
+	}
+
+	return policy, violations, true
+}
+
+// enforcePasswordPolicy checks a password the caller is about to store
+// against the policy that applies to them. An empty password only checks the
+// account-level rules.
+func enforcePasswordPolicy(c *gin.Context, policyService *services.PasswordPolicyService, password string) bool {
+	return enforceEntryPasswordPolicy(c, policyService, nil, password)
+}
+
+// enforceEntryPasswordPolicy is enforcePasswordPolicy for an entry of the
+// organization organizationID, whose policy applies on top of the caller's.
+func enforceEntryPasswordPolicy(c *gin.Context, policyService *services.PasswordPolicyService, organizationID *uuid.UUID, password string) bool {
+	userID := uuid.MustParse(c.GetString("user_id"))
+
+	policy, err := policyService.EntryPolicy(c.Request.Context(), userID, organizationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load password policy"})
+		return false
+	}
+
+	violations, err := policyService.CheckAccount(c.Request.Context(), userID, policy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check password policy"})
+		return false
+	}
+
+	if password != "" {
+		violations = append(violations, policyService.CheckPassword(policy, password)...)
+	}
+	if len(violations) > 0 {
+		respondPolicyViolations(c, violations)
+		return false
+	}
+
+	return true
+}
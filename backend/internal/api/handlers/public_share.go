@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+	"github.com/tresor/password-manager/internal/services"
+)
+
+// PublicShareHandler serves anonymous share links to people without an
+// account. It only ever hands out ciphertext: the link key lives in the URL
+// fragment and is never sent to the server.
+type PublicShareHandler struct {
+	shareRepo     *repository.ShareRepository
+	cryptoService *services.CryptoService
+	accessService *services.ShareAccessService
+}
+
+func NewPublicShareHandler(
+	shareRepo *repository.ShareRepository,
+	cryptoService *services.CryptoService,
+	accessService *services.ShareAccessService,
+) *PublicShareHandler {
+	return &PublicShareHandler{
+		shareRepo:     shareRepo,
+		cryptoService: cryptoService,
+		accessService: accessService,
+	}
+}
+
+// GetShareInfo tells the viewer whether a link can still be opened and
+// whether it needs a password, without consuming a view. Opening is a
+// separate POST so that link previews and prefetchers cannot burn it.
+func (h *PublicShareHandler) GetShareInfo(c *gin.Context) {
+	share, ok := h.loadOpenableShare(c, false)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"require_password": share.RequirePassword,
+		"expires_at":       share.ExpiresAt,
+		"views_remaining":  calculateViewsRemaining(share),
+	})
+}
+
+// OpenShare consumes one view of a link and returns its encrypted payload.
+// The last view wipes the payload from the database.
+func (h *PublicShareHandler) OpenShare(c *gin.Context) {
+	// The body is optional: only password-protected links need one.
+	var req models.OpenPublicShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	share, ok := h.loadOpenableShare(c, true)
+	if !ok {
+		return
+	}
+
+	if share.RequirePassword {
+		if req.SharePassword == "" {
+			recordShareAccess(c, h.accessService, share, nil, models.ShareAccessPasswordRequired)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Share password required"})
+			return
+		}
+		if !verifySharePassword(h.cryptoService, share, req.SharePassword) {
+			recordShareAccess(c, h.accessService, share, nil, models.ShareAccessWrongPassword)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid share password"})
+			return
+		}
+	}
+
+	payload, viewCount, consumed, err := h.shareRepo.ConsumeView(c.Request.Context(), share.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open share"})
+		return
+	}
+	if !consumed {
+		recordShareAccess(c, h.accessService, share, nil, models.ShareAccessViewLimitReached)
+		c.JSON(http.StatusGone, gin.H{"error": "Share is no longer available"})
+		return
+	}
+	recordShareView(c, h.accessService, share, nil, viewCount)
+
+	remaining := calculateViewsRemaining(share)
+	if remaining != nil {
+		*remaining--
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"encrypted_data":  payload,
+		"views_remaining": remaining,
+		"burned":          remaining != nil && *remaining <= 0,
+	})
+}
+
+// loadOpenableShare loads the :token link and checks that it can still be
+// opened, writing the error response otherwise. Links found expired are
+// burned on the spot; links to an entry in the trash are only suspended.
+// When opening, refused attempts are recorded in the access log.
+func (h *PublicShareHandler) loadOpenableShare(c *gin.Context, opening bool) (*models.SharedPassword, bool) {
+	share, err := h.shareRepo.GetByToken(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch share"})
+		return nil, false
+	}
+	if share == nil || share.ShareType != models.ShareTypeLink {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+		return nil, false
+	}
+
+	if share.Revoked {
+		if opening {
+			recordShareAccess(c, h.accessService, share, nil, models.ShareAccessRevoked)
+		}
+		c.JSON(http.StatusGone, gin.H{"error": "Share has been revoked"})
+		return nil, false
+	}
+
+	if share.ExpiresAt != nil && time.Now().After(*share.ExpiresAt) {
+		if share.EncryptedData != "" {
+			_ = h.shareRepo.Burn(c.Request.Context(), share.ID)
+		}
+		if opening {
+			recordShareAccess(c, h.accessService, share, nil, models.ShareAccessExpired)
+		}
+		c.JSON(http.StatusGone, gin.H{"error": "Share has expired"})
+		return nil, false
+	}
+
+	if share.EncryptedData == "" || (share.MaxViews != nil && share.ViewCount >= *share.MaxViews) {
+		if opening {
+			recordShareAccess(c, h.accessService, share, nil, models.ShareAccessViewLimitReached)
+		}
+		c.JSON(http.StatusGone, gin.H{"error": "Share has already been viewed"})
+		return nil, false
+	}
+
+	suspended, err := h.shareRepo.IsSuspended(c.Request.Context(), share)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch share"})
+		return nil, false
+	}
+	if suspended {
+		if opening {
+			recordShareAccess(c, h.accessService, share, nil, models.ShareAccessSuspended)
+		}
+		c.JSON(http.StatusGone, gin.H{"error": errShareSuspendedMessage})
+		return nil, false
+	}
+
+	return share, true
+}
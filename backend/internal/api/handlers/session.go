@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+)
+
+type SessionHandler struct {
+	sessionRepo *repository.SessionRepository
+}
+
+func NewSessionHandler(sessionRepo *repository.SessionRepository) *SessionHandler {
+	return &SessionHandler{sessionRepo: sessionRepo}
+}
+
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	userID := c.GetString("user_id")
+	currentID := c.GetString("session_id")
+
+	sessions, err := h.sessionRepo.GetActiveByUserID(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	response := make([]models.SessionResponse, len(sessions))
+	for i, session := range sessions {
+		response[i] = models.SessionResponse{
+			ID:         session.ID,
+			DeviceName: session.DeviceName,
+			IPAddress:  session.IPAddress,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID.String() == currentID,
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	session, err := h.sessionRepo.GetByID(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return
+	}
+	if session == nil || session.UserID.String() != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if err := h.sessionRepo.Revoke(c.Request.Context(), session.ID, repository.SessionRevokedByUser); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Session revoked successfully",
+		"current": session.ID.String() == c.GetString("session_id"),
+	})
+}
+
+// RevokeOtherSessions signs out every device except the one making the call.
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	userID := c.GetString("user_id")
+	currentID := c.GetString("session_id")
+
+	revoked, err := h.sessionRepo.RevokeOthersForUser(
+		c.Request.Context(),
+		uuid.MustParse(userID),
+		uuid.MustParse(currentID),
+		repository.SessionRevokedByUser,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Other sessions revoked successfully",
+		"revoked": revoked,
+	})
+}
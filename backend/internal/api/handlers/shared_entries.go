@@ -0,0 +1,641 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+	"github.com/tresor/password-manager/internal/services"
+)
+
+var (
+	errSharedEntriesConflict = errors.New("shared entry modified concurrently")
+	errRekeyMismatch         = errors.New("re-key upload does not match the entries")
+)
+
+// errNotPersonalEntryMessage is returned by the personal vault and sharing
+// endpoints for entries that live in a collection or an organization.
+const errNotPersonalEntryMessage = "Entry belongs to a collection or organization; use the /collections or /organizations endpoints"
+
+// entryScope is a set of entries encrypted under one shared key: a
+// collection, or an organization's own items.
+type entryScope struct {
+	// ownerID is stored as the entries' UserID so that they outlive the
+	// member who added them.
+	ownerID        uuid.UUID
+	collectionID   *uuid.UUID
+	organizationID *uuid.UUID
+	// sealedKey is the caller's copy of the scope key.
+	sealedKey string
+	canView   bool
+	canCopy   bool
+	// policyOrganizationID is the organization whose password policy the
+	// entries are held to, if any.
+	policyOrganizationID *uuid.UUID
+}
+
+func (s *entryScope) contains(vault *models.Vault) bool {
+	if s.collectionID != nil {
+		return vault.CollectionID != nil && *vault.CollectionID == *s.collectionID
+	}
+	return vault.OrganizationID != nil && *vault.OrganizationID == *s.organizationID
+}
+
+func (s *entryScope) assign(vault *models.Vault) {
+	vault.UserID = s.ownerID
+	vault.CollectionID = s.collectionID
+	vault.OrganizationID = s.organizationID
+}
+
+// sharedEntries implements the entry endpoints common to collections and
+// organizations. Server-side accounts send their master password and the
+// server opens the scope key; zero-knowledge clients send envelopes sealed
+// with it.
+type sharedEntries struct {
+	vaultRepo         *repository.VaultRepository
+	collectionService *services.CollectionService
+	vaultKeyService   *services.VaultKeyService
+	attachmentRepo    *repository.AttachmentRepository
+	historyRepo       *repository.PasswordHistoryRepository
+	policyService     *services.PasswordPolicyService
+}
+
+// list returns entries, keeping only the item type given by ?type= if any.
+func (s *sharedEntries) list(c *gin.Context, entries []models.Vault) {
+	itemType, ok := itemTypeQuery(c)
+	if !ok {
+		return
+	}
+
+	responses := make([]models.ClientVaultResponse, 0, len(entries))
+	for i := range entries {
+		if itemType != "" && entries[i].Type != itemType {
+			continue
+		}
+		responses = append(responses, toClientVaultResponse(&entries[i]))
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// get returns one entry, decrypted for server-side accounts and limited by
+// the scope's view and copy permissions.
+func (s *sharedEntries) get(c *gin.Context, scope *entryScope, user *models.User, entry *models.Vault) {
+	if user.ClientSideEncryption {
+		c.JSON(http.StatusOK, toClientVaultResponse(entry))
+		return
+	}
+
+	key, _, ok := s.openKey(c, user, scope.sealedKey, c.Query("master_password"))
+	if !ok {
+		return
+	}
+
+	data, ok := s.open(c, entry, key)
+	if !ok {
+		return
+	}
+
+	response := gin.H{
+		"id":               entry.ID,
+		"collection_id":    entry.CollectionID,
+		"organization_id":  entry.OrganizationID,
+		"type":             entry.Type,
+		"title":            entry.Title,
+		"website":          entry.Website,
+		"username":         entry.Username,
+		"folder":           entry.Folder,
+		"revision":         entry.Revision,
+		"last_modified_by": entry.LastModifiedBy,
+		"created_at":       entry.CreatedAt,
+		"updated_at":       entry.UpdatedAt,
+	}
+	if scope.canView || scope.canCopy {
+		response["password"] = data.Password
+	}
+	if scope.canView {
+		response["notes"] = data.Notes
+		addItemFields(response, entry, data)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// create adds an entry to scope, or moves one of the caller's personal
+// entries into it when VaultID is set.
+func (s *sharedEntries) create(c *gin.Context, scope *entryScope, user *models.User, req *models.CollectionEntryRequest) {
+	var entry *models.Vault
+	if req.VaultID != nil {
+		personal, err := s.vaultRepo.GetByID(c.Request.Context(), *req.VaultID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vault"})
+			return
+		}
+		if personal == nil || personal.UserID != user.ID || !personal.IsPersonal() || personal.InTrash() {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Vault entry not found"})
+			return
+		}
+		entry = personal
+		if req.Type != "" && req.Type != entry.Type {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errItemTypeChangeMessage})
+			return
+		}
+		// Attachment keys are wrapped with the owner's vault key, which the
+		// other members cannot open.
+		attachmentCount, err := s.attachmentRepo.CountByVaultID(c.Request.Context(), personal.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count attachments"})
+			return
+		}
+		if attachmentCount > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Entries with attachments cannot be moved; delete the attachments first"})
+			return
+		}
+	} else {
+		if req.Title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Title is required"})
+			return
+		}
+		itemType := req.Type
+		if itemType == "" {
+			itemType = models.ItemTypeLogin
+		}
+		if !models.IsItemType(itemType) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown item type"})
+			return
+		}
+		entry = &models.Vault{
+			ID:        uuid.New(),
+			Type:      itemType,
+			Revision:  1,
+			CreatedAt: time.Now(),
+		}
+	}
+	moving := req.VaultID != nil
+	expectedRevision := entry.Revision
+
+	if user.ClientSideEncryption {
+		if req.EncryptedData == nil || s.collectionService.ValidateEntryEnvelope(*req.EncryptedData) != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Encrypted data sealed with the shared key is required"})
+			return
+		}
+		entry.EncryptedData = *req.EncryptedData
+		entry.EncryptionSalt = ""
+		entry.Nonce = ""
+		entry.EncryptionVersion = models.EncryptionVersionCurrent
+	} else {
+		key, vaultKey, ok := s.openKey(c, user, scope.sealedKey, req.MasterPassword)
+		if !ok {
+			return
+		}
+
+		var data models.DecryptedVaultData
+		if moving {
+			plaintext, err := s.vaultKeyService.OpenEntry(entry, vaultKey, req.MasterPassword)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to decrypt vault entry"})
+				return
+			}
+			if err := json.Unmarshal([]byte(plaintext), &data); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt data"})
+				return
+			}
+		}
+
+		if !s.seal(c, entry, key, &data, req) {
+			return
+		}
+		if !enforceEntryPasswordPolicy(c, s.policyService, scope.policyOrganizationID, data.Password) {
+			return
+		}
+	}
+
+	scope.assign(entry)
+	// Folders are personal; the entry keeps its path as a plain label.
+	entry.FolderID = nil
+	applyEntryMetadata(entry, req)
+	entry.LastModifiedBy = &user.ID
+	entry.UpdatedAt = time.Now()
+
+	if moving {
+		updated, err := s.vaultRepo.UpdateIfRevision(c.Request.Context(), entry, expectedRevision)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move entry"})
+			return
+		}
+		if !updated {
+			c.JSON(http.StatusConflict, gin.H{"error": "Vault entry was modified by another client"})
+			return
+		}
+		// The history is sealed with the former owner's vault key, which the
+		// other members cannot open.
+		if err := s.historyRepo.DeleteByVaultID(c.Request.Context(), entry.ID); err != nil {
+			log.Printf("Failed to delete password history of moved entry %s: %v", entry.ID, err)
+		}
+		c.JSON(http.StatusOK, toClientVaultResponse(entry))
+		return
+	}
+
+	if err := s.vaultRepo.Create(c.Request.Context(), entry); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create entry"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, toClientVaultResponse(entry))
+}
+
+// update changes an entry. req.Revision must match the stored revision.
+func (s *sharedEntries) update(c *gin.Context, scope *entryScope, user *models.User, entry *models.Vault, req *models.CollectionEntryRequest) {
+	if req.Revision != entry.Revision {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Entry was modified by another member",
+			"current": toClientVaultResponse(entry),
+		})
+		return
+	}
+
+	if req.Type != "" && req.Type != entry.Type {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errItemTypeChangeMessage})
+		return
+	}
+
+	if user.ClientSideEncryption {
+		if req.EncryptedData != nil {
+			if s.collectionService.ValidateEntryEnvelope(*req.EncryptedData) != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Encrypted data must be sealed with the shared key"})
+				return
+			}
+			entry.EncryptedData = *req.EncryptedData
+		}
+	} else if req.Password != nil || req.Notes != nil || req.Fields != nil || req.VaultItemData != (models.VaultItemData{}) {
+		key, _, ok := s.openKey(c, user, scope.sealedKey, req.MasterPassword)
+		if !ok {
+			return
+		}
+
+		data, ok := s.open(c, entry, key)
+		if !ok {
+			return
+		}
+		previousPassword := data.Password
+		if !s.seal(c, entry, key, data, req) {
+			return
+		}
+		// An unchanged password may predate the policy; entries can still be
+		// edited.
+		newPassword := data.Password
+		if newPassword == previousPassword {
+			newPassword = ""
+		}
+		if !enforceEntryPasswordPolicy(c, s.policyService, scope.policyOrganizationID, newPassword) {
+			return
+		}
+	}
+
+	applyEntryMetadata(entry, req)
+	entry.LastModifiedBy = &user.ID
+	entry.UpdatedAt = time.Now()
+
+	updated, err := s.vaultRepo.UpdateIfRevision(c.Request.Context(), entry, req.Revision)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update entry"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusConflict, gin.H{"error": "Entry was modified by another member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toClientVaultResponse(entry))
+}
+
+func (s *sharedEntries) delete(c *gin.Context, entry *models.Vault) {
+	if err := s.vaultRepo.Delete(c.Request.Context(), entry.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete entry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Entry deleted successfully"})
+}
+
+// load loads the :entryId entry and checks that it is in scope.
+func (s *sharedEntries) load(c *gin.Context, scope *entryScope) (*models.Vault, bool) {
+	entryID, err := uuid.Parse(c.Param("entryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry ID"})
+		return nil, false
+	}
+
+	entry, err := s.vaultRepo.GetByID(c.Request.Context(), entryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch entry"})
+		return nil, false
+	}
+	if entry == nil || !scope.contains(entry) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Entry not found"})
+		return nil, false
+	}
+
+	return entry, true
+}
+
+// newKey returns a new collection or org key sealed to the caller. The server
+// generates it for server-side accounts; zero-knowledge clients generate it
+// and send it already sealed (encryptedKey).
+func (s *sharedEntries) newKey(c *gin.Context, user *models.User, masterPassword string, encryptedKey *string) (string, bool) {
+	if user.ClientSideEncryption {
+		if encryptedKey == nil || s.collectionService.ValidateSealedKey(*encryptedKey) != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A key sealed to your public key is required"})
+			return "", false
+		}
+		if user.PublicKey == "" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Set up a sharing keypair first"})
+			return "", false
+		}
+		return *encryptedKey, true
+	}
+
+	if masterPassword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Master password required"})
+		return "", false
+	}
+
+	// Unlocking also gives the account a keypair if it has none yet.
+	if _, err := s.vaultKeyService.UnlockUser(c.Request.Context(), user, masterPassword); err != nil {
+		respondUnlockError(c, err)
+		return "", false
+	}
+
+	key, err := s.collectionService.GenerateKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate key"})
+		return "", false
+	}
+	sealed, err := s.collectionService.SealKeyForUser(key, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+		return "", false
+	}
+
+	return sealed, true
+}
+
+// rekey replaces the scope key before a member leaves. It re-encrypts
+// entries in place and returns the new key sealed to each recipient, by user
+// ID. Server-side callers send their master password; zero-knowledge callers
+// upload the result of doing the same locally, which must cover exactly the
+// recipients and the entries.
+func (s *sharedEntries) rekey(
+	c *gin.Context,
+	user *models.User,
+	sealedKey, masterPassword string,
+	keyUploads []models.MemberKeyUpload,
+	entryUploads []models.ClientEntryUpload,
+	recipients []*models.User,
+	entries []models.Vault,
+) (map[uuid.UUID]string, bool) {
+	if user.ClientSideEncryption {
+		keys, err := s.applyClientRekey(keyUploads, entryUploads, recipients, entries)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The new key must be sealed to every remaining member and every entry re-encrypted exactly once"})
+			return nil, false
+		}
+		return keys, true
+	}
+
+	oldKey, _, ok := s.openKey(c, user, sealedKey, masterPassword)
+	if !ok {
+		return nil, false
+	}
+
+	newKey, err := s.collectionService.GenerateKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate key"})
+		return nil, false
+	}
+
+	if err := s.collectionService.ReencryptEntries(entries, oldKey, newKey); errors.Is(err, services.ErrInvalidCollectionData) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to decrypt a shared entry"})
+		return nil, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+		return nil, false
+	}
+
+	keys := make(map[uuid.UUID]string, len(recipients))
+	for _, recipient := range recipients {
+		sealed, err := s.collectionService.SealKeyForUser(newKey, recipient)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+			return nil, false
+		}
+		keys[recipient.ID] = sealed
+	}
+
+	return keys, true
+}
+
+// saveRekeyedEntries writes re-keyed entries inside a transaction, failing
+// with errSharedEntriesConflict if any of them changed meanwhile.
+func saveRekeyedEntries(c *gin.Context, repos *repository.TxRepositories, entries []models.Vault) error {
+	now := time.Now()
+	for i := range entries {
+		entries[i].UpdatedAt = now
+		updated, err := repos.Vaults.UpdateIfRevision(c.Request.Context(), &entries[i], entries[i].Revision)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return errSharedEntriesConflict
+		}
+	}
+	return nil
+}
+
+func (s *sharedEntries) applyClientRekey(
+	keyUploads []models.MemberKeyUpload,
+	entryUploads []models.ClientEntryUpload,
+	recipients []*models.User,
+	entries []models.Vault,
+) (map[uuid.UUID]string, error) {
+	if len(keyUploads) != len(recipients) || len(entryUploads) != len(entries) {
+		return nil, errRekeyMismatch
+	}
+
+	byUser := make(map[uuid.UUID]string, len(keyUploads))
+	for _, upload := range keyUploads {
+		if s.collectionService.ValidateSealedKey(upload.EncryptedKey) != nil {
+			return nil, errRekeyMismatch
+		}
+		byUser[upload.UserID] = upload.EncryptedKey
+	}
+	for _, recipient := range recipients {
+		if _, ok := byUser[recipient.ID]; !ok {
+			return nil, errRekeyMismatch
+		}
+	}
+
+	byEntry := make(map[uuid.UUID]string, len(entryUploads))
+	for _, upload := range entryUploads {
+		if s.collectionService.ValidateEntryEnvelope(upload.EncryptedData) != nil {
+			return nil, errRekeyMismatch
+		}
+		byEntry[uuid.MustParse(upload.ID)] = upload.EncryptedData
+	}
+	for i := range entries {
+		data, ok := byEntry[entries[i].ID]
+		if !ok {
+			return nil, errRekeyMismatch
+		}
+		entries[i].EncryptedData = data
+		entries[i].EncryptionSalt = ""
+		entries[i].Nonce = ""
+		entries[i].EncryptionVersion = models.EncryptionVersionCurrent
+	}
+
+	return byUser, nil
+}
+
+// openKey opens the caller's copy of a collection or org key with their
+// master password, writing the error response itself.
+func (s *sharedEntries) openKey(c *gin.Context, user *models.User, sealedKey, masterPassword string) ([]byte, []byte, bool) {
+	if masterPassword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Master password required"})
+		return nil, nil, false
+	}
+
+	key, vaultKey, err := s.collectionService.OpenKey(c.Request.Context(), user, sealedKey, masterPassword)
+	if errors.Is(err, services.ErrCollectionKey) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to open shared key"})
+		return nil, nil, false
+	}
+	if err != nil {
+		respondUnlockError(c, err)
+		return nil, nil, false
+	}
+
+	return key, vaultKey, true
+}
+
+func (s *sharedEntries) open(c *gin.Context, entry *models.Vault, key []byte) (*models.DecryptedVaultData, bool) {
+	plaintext, err := s.collectionService.OpenEntry(entry, key)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to decrypt shared entry"})
+		return nil, false
+	}
+
+	var data models.DecryptedVaultData
+	if err := json.Unmarshal([]byte(plaintext), &data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt data"})
+		return nil, false
+	}
+
+	return &data, true
+}
+
+// seal merges the request's fields into data, checks the result against the
+// entry's item type and encrypts it into entry.
+func (s *sharedEntries) seal(c *gin.Context, entry *models.Vault, key []byte, data *models.DecryptedVaultData, req *models.CollectionEntryRequest) bool {
+	if req.Password != nil {
+		data.Password = *req.Password
+	}
+	if req.Notes != nil {
+		data.Notes = req.Notes
+	}
+	if req.Fields != nil {
+		data.Fields = req.Fields
+	}
+	if req.Card != nil {
+		data.Card = req.Card
+	}
+	if req.Identity != nil {
+		data.Identity = req.Identity
+	}
+	if req.SSHKey != nil {
+		data.SSHKey = req.SSHKey
+	}
+	if req.APICredential != nil {
+		data.APICredential = req.APICredential
+	}
+	if !validateVaultItem(c, entry.Type, data) {
+		return false
+	}
+
+	dataJSON, _ := json.Marshal(data)
+	if err := s.collectionService.SealEntry(entry, key, string(dataJSON)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+		return false
+	}
+
+	return true
+}
+
+// loadCurrentUser loads the signed-in user, writing the error response itself.
+func loadCurrentUser(c *gin.Context, userRepo *repository.UserRepository) (*models.User, bool) {
+	userID := c.GetString("user_id")
+
+	user, err := userRepo.GetByID(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return nil, false
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+
+	return user, true
+}
+
+// loadPersonalEntry loads the caller's personal entry named by the route,
+// writing the error response itself. Entries in the trash are not found.
+func loadPersonalEntry(c *gin.Context, vaultRepo *repository.VaultRepository, user *models.User) (*models.Vault, bool) {
+	vaultID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vault ID"})
+		return nil, false
+	}
+
+	vault, err := vaultRepo.GetByID(c.Request.Context(), vaultID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vault"})
+		return nil, false
+	}
+	if vault == nil || vault.InTrash() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vault entry not found"})
+		return nil, false
+	}
+
+	if vault.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	if !vault.IsPersonal() {
+		c.JSON(http.StatusConflict, gin.H{"error": errNotPersonalEntryMessage})
+		return nil, false
+	}
+
+	return vault, true
+}
+
+func applyEntryMetadata(entry *models.Vault, req *models.CollectionEntryRequest) {
+	if req.Title != "" {
+		entry.Title = req.Title
+	}
+	if req.Website != nil {
+		entry.Website = req.Website
+	}
+	if req.Username != nil {
+		entry.Username = req.Username
+	}
+	if req.Folder != nil {
+		entry.Folder = req.Folder
+	}
+}
@@ -3,8 +3,13 @@ package handlers
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,30 +19,51 @@ import (
 	"github.com/tresor/password-manager/internal/services"
 )
 
+var (
+	errMissingSharePassword = errors.New("share password required")
+	errSharedEntryConflict  = errors.New("shared entry modified concurrently")
+)
+
 type SharingHandler struct {
-	shareRepo     *repository.ShareRepository
-	vaultRepo     *repository.VaultRepository
-	userRepo      *repository.UserRepository
-	cryptoService *services.CryptoService
-	emailService  *services.EmailService
+	shareRepo         *repository.ShareRepository
+	vaultRepo         *repository.VaultRepository
+	userRepo          *repository.UserRepository
+	txManager         *repository.TxManager
+	cryptoService     *services.CryptoService
+	vaultKeyService   *services.VaultKeyService
+	invitationService *services.ShareInvitationService
+	accessService     *services.ShareAccessService
+	emailService      *services.EmailService
 }
 
 func NewSharingHandler(
 	shareRepo *repository.ShareRepository,
 	vaultRepo *repository.VaultRepository,
 	userRepo *repository.UserRepository,
+	txManager *repository.TxManager,
 	cryptoService *services.CryptoService,
+	vaultKeyService *services.VaultKeyService,
+	invitationService *services.ShareInvitationService,
+	accessService *services.ShareAccessService,
 	emailService *services.EmailService,
 ) *SharingHandler {
 	return &SharingHandler{
-		shareRepo:     shareRepo,
-		vaultRepo:     vaultRepo,
-		userRepo:      userRepo,
-		cryptoService: cryptoService,
-		emailService:  emailService,
+		shareRepo:         shareRepo,
+		vaultRepo:         vaultRepo,
+		userRepo:          userRepo,
+		txManager:         txManager,
+		cryptoService:     cryptoService,
+		vaultKeyService:   vaultKeyService,
+		invitationService: invitationService,
+		accessService:     accessService,
+		emailService:      emailService,
 	}
 }
 
+// SharePassword shares an entry with another user. When the recipient has no
+// account yet the share is kept pending, sealed to the invitation key, and
+// they are emailed an invitation link; it is bound to them when they present
+// its token, at registration or afterwards.
 func (h *SharingHandler) SharePassword(c *gin.Context) {
 	userID := c.GetString("user_id")
 
@@ -48,14 +74,51 @@ func (h *SharingHandler) SharePassword(c *gin.Context) {
 	}
 
 	vault, err := h.vaultRepo.GetByID(c.Request.Context(), uuid.MustParse(req.VaultID))
-	if err != nil || vault.UserID.String() != userID {
+	if err != nil || vault == nil || vault.UserID.String() != userID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
+	if !vault.IsPersonal() {
+		c.JSON(http.StatusConflict, gin.H{"error": errNotPersonalEntryMessage})
+		return
+	}
+	if vault.InTrash() {
+		c.JSON(http.StatusConflict, gin.H{"error": errEntryInTrashMessage})
+		return
+	}
 
 	recipient, err := h.userRepo.GetByEmail(c.Request.Context(), req.RecipientEmail)
-	if err != nil || recipient == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Recipient not found"})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up recipient"})
+		return
+	}
+
+	pending := recipient == nil
+	recipientKey := h.invitationService.PublicKey()
+	encryptionVersion := models.ShareEncryptionInvitation
+	var recipientID *uuid.UUID
+
+	if !pending {
+		if recipient.PublicKey == "" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Recipient has no encryption key yet; they must sign in once before items can be shared with them"})
+			return
+		}
+		recipientKey = recipient.PublicKey
+		encryptionVersion = models.ShareEncryptionSealed
+		recipientID = &recipient.ID
+	}
+
+	owner, err := h.userRepo.GetByID(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil || owner == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+
+	canView := req.CanView == nil || *req.CanView
+	canCopy := req.CanCopy == nil || *req.CanCopy
+
+	sealed, ok := h.sealForRecipient(c, owner, vault, recipientKey, canView, canCopy, &req)
+	if !ok {
 		return
 	}
 
@@ -67,35 +130,51 @@ func (h *SharingHandler) SharePassword(c *gin.Context) {
 		expiresAt = &exp
 	}
 
-	var sharePasswordHash *string
-	if req.RequirePassword && req.SharePassword != nil {
-		hash, _, err := h.cryptoService.HashPassword(*req.SharePassword)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash share password"})
+	sharePasswordHash, sharePasswordSalt, err := h.hashSharePassword(req.RequirePassword, req.SharePassword)
+	if errors.Is(err, errMissingSharePassword) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Share password required"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash share password"})
+		return
+	}
+
+	var invitationToken string
+	var invitationTokenHash *string
+	if pending {
+		invitationToken = generateRandomToken(32)
+		if invitationToken == "" {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invitation"})
 			return
 		}
-		sharePasswordHash = &hash
+		tokenHash := hashInvitationToken(invitationToken)
+		invitationTokenHash = &tokenHash
 	}
 
 	share := &models.SharedPassword{
 		ID:                uuid.New(),
 		VaultID:           vault.ID,
 		OwnerID:           uuid.MustParse(userID),
-		RecipientID:       &recipient.ID,
+		ShareType:         models.ShareTypeUser,
+		RecipientID:       recipientID,
 		RecipientEmail:    req.RecipientEmail,
-		EncryptedData:     vault.EncryptedData,
+		EncryptedData:     sealed,
+		EncryptionVersion: encryptionVersion,
 		ShareToken:        shareToken,
 		ExpiresAt:         expiresAt,
 		MaxViews:          req.MaxViews,
 		ViewCount:         0,
 		RequirePassword:   req.RequirePassword,
 		SharePasswordHash: sharePasswordHash,
-		CanView:           true,
-		CanCopy:           true,
+		SharePasswordSalt: sharePasswordSalt,
+		CanView:           canView,
+		CanCopy:           canCopy,
 		CanEdit:           req.CanEdit,
 		Revoked:           false,
 		CreatedAt:         time.Now(),
 	}
+	share.InvitationTokenHash = invitationTokenHash
 
 	if err := h.shareRepo.Create(c.Request.Context(), share); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share"})
@@ -103,86 +182,807 @@ func (h *SharingHandler) SharePassword(c *gin.Context) {
 	}
 
 	shareURL := fmt.Sprintf("https://yourdomain.com/shared/%s", shareToken)
-	go h.emailService.SendShareNotification(req.RecipientEmail, vault.Title, shareURL)
+	if pending {
+		registerURL := fmt.Sprintf("https://yourdomain.com/register?email=%s&invitation=%s", url.QueryEscape(req.RecipientEmail), url.QueryEscape(invitationToken))
+		go h.emailService.SendShareInvitation(req.RecipientEmail, vault.Title, registerURL)
+	} else {
+		go h.emailService.SendShareNotification(req.RecipientEmail, vault.Title, shareURL)
+	}
 
 	c.JSON(http.StatusOK, models.SharePasswordResponse{
 		ShareToken: shareToken,
 		ShareURL:   shareURL,
 		ExpiresAt:  expiresAt,
+		Pending:    pending,
 	})
 }
 
+// AcceptShareInvitation claims a pending share with the token from its
+// invitation email, for recipients who registered without following the
+// link. The caller's email must be the one the share was sent to.
+func (h *SharingHandler) AcceptShareInvitation(c *gin.Context) {
+	var req models.AcceptShareInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	share, err := h.invitationService.BindInvitation(c.Request.Context(), user, hashInvitationToken(req.Token))
+	if errors.Is(err, services.ErrShareInvitationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Invitation accepted",
+		"share_token": share.ShareToken,
+	})
+}
+
+// GetSharedPassword opens a share for its recipient. The password, and the
+// copyable secret of a typed item, are only returned if the share allows
+// viewing or copying them; the notes and the other fields only if it allows
+// viewing.
 func (h *SharingHandler) GetSharedPassword(c *gin.Context) {
 	userID := c.GetString("user_id")
-	shareToken := c.Param("token")
-	sharePassword := c.Query("share_password")
 
-	share, err := h.shareRepo.GetByToken(c.Request.Context(), shareToken)
-	if err != nil || share == nil {
+	share, ok := h.loadRecipientShare(c, userID, c.Query("share_password"))
+	if !ok {
+		return
+	}
+
+	vault, err := h.vaultRepo.GetByID(c.Request.Context(), share.VaultID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve password"})
+		return
+	}
+	if vault == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+		return
+	}
+
+	viewer, err := h.userRepo.GetByID(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil || viewer == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+
+	data := gin.H{
+		"title":    vault.Title,
+		"website":  vault.Website,
+		"username": vault.Username,
+	}
+
+	if viewer.ClientSideEncryption {
+		if !h.claimEscrowedShare(c, share, viewer) {
+			return
+		}
+		// The client holds the private key and opens the payload itself, so
+		// the payload is withheld entirely when it may not be revealed.
+		if share.CanView || share.CanCopy {
+			data["encrypted_data"] = share.EncryptedData
+		}
+	} else {
+		masterPassword := c.Query("master_password")
+		if masterPassword == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Master password required"})
+			return
+		}
+
+		vaultKey, err := h.vaultKeyService.UnlockUser(c.Request.Context(), viewer, masterPassword)
+		if err != nil {
+			respondUnlockError(c, err)
+			return
+		}
+
+		// Unlocking generated the viewer's keypair if they had none.
+		if !h.claimEscrowedShare(c, share, viewer) {
+			return
+		}
+
+		privateKey, err := h.vaultKeyService.OpenPrivateKey(viewer, vaultKey)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open private key"})
+			return
+		}
+
+		plaintext, err := h.cryptoService.OpenWithPrivateKey(share.EncryptedData, privateKey)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to decrypt shared password"})
+			return
+		}
+
+		var decrypted models.DecryptedVaultData
+		if err := json.Unmarshal(plaintext, &decrypted); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt data"})
+			return
+		}
+		if share.CanView || share.CanCopy {
+			data["password"] = decrypted.Password
+		}
+		if share.CanView {
+			data["notes"] = decrypted.Notes
+			addItemFields(data, vault, &decrypted)
+		} else if share.CanCopy {
+			// Older copy-only payloads may still carry the whole item.
+			copyable := models.DecryptedVaultData{VaultItemData: decrypted.VaultItemData.CopyOnly()}
+			addItemFields(data, vault, &copyable)
+		}
+	}
+
+	if share.CanEdit {
+		data["revision"] = vault.Revision
+		data["last_modified_by"] = vault.LastModifiedBy
+	}
+
+	viewCount, err := h.shareRepo.IncrementViewCount(c.Request.Context(), share.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record share access"})
+		return
+	}
+	recordShareView(c, h.accessService, share, &viewer.ID, viewCount)
+
+	metadata := gin.H{
+		"owner":           share.OwnerID,
+		"expires_at":      share.ExpiresAt,
+		"views_remaining": calculateViewsRemaining(share),
+	}
+	if share.CanEdit && viewer.ClientSideEncryption {
+		// Zero-knowledge recipients seal their edits to the owner themselves.
+		if owner, err := h.userRepo.GetByID(c.Request.Context(), share.OwnerID); err == nil && owner != nil {
+			metadata["owner_public_key"] = owner.PublicKey
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+		"permissions": gin.H{
+			"can_view": share.CanView,
+			"can_copy": share.CanCopy,
+			"can_edit": share.CanEdit,
+		},
+		"metadata": metadata,
+	})
+}
+
+// UpdateSharedPassword lets a recipient with edit permission change a shared
+// entry. Title, website and username are written to the owner's entry at
+// once. Password and notes are sealed to the owner's public key and applied
+// to the entry when the owner next unlocks their vault (or by their client in
+// zero-knowledge mode); the recipient's own copy of the share is refreshed
+// immediately. The owner is notified by email.
+func (h *SharingHandler) UpdateSharedPassword(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req models.UpdateSharedPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Title != nil && *req.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Title cannot be empty"})
+		return
+	}
+
+	share, ok := h.loadRecipientShare(c, userID, req.SharePassword)
+	if !ok {
+		return
+	}
+
+	if !share.CanEdit {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This share does not allow editing"})
+		return
+	}
+
+	vault, err := h.vaultRepo.GetByID(c.Request.Context(), share.VaultID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vault"})
+		return
+	}
+	if vault == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
 		return
 	}
 
+	editor, err := h.userRepo.GetByID(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil || editor == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+
+	owner, err := h.userRepo.GetByID(c.Request.Context(), share.OwnerID)
+	if err != nil || owner == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get owner"})
+		return
+	}
+
+	var sharedEdit *models.SharedEdit
+	var sharePayload string
+
+	secretChanged := req.Password != nil || req.Notes != nil
+	if secretChanged {
+		if owner.PublicKey == "" {
+			c.JSON(http.StatusConflict, gin.H{"error": "The owner has no encryption key yet"})
+			return
+		}
+
+		ownerPayload, refreshed, ok := h.sealSharedEdit(c, share, editor, owner, &req)
+		if !ok {
+			return
+		}
+		sharePayload = refreshed
+
+		sharedEdit = &models.SharedEdit{
+			ID:            uuid.New(),
+			ShareID:       share.ID,
+			VaultID:       vault.ID,
+			OwnerID:       owner.ID,
+			EditorID:      editor.ID,
+			EncryptedData: ownerPayload,
+			BaseRevision:  vault.Revision,
+			CreatedAt:     time.Now(),
+		}
+	}
+
+	metadataChanged := req.Title != nil || req.Website != nil || req.Username != nil
+	if req.Title != nil {
+		vault.Title = *req.Title
+	}
+	if req.Website != nil {
+		vault.Website = req.Website
+	}
+	if req.Username != nil {
+		vault.Username = req.Username
+	}
+
+	if !metadataChanged && !secretChanged {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+		return
+	}
+
+	err = h.txManager.WithinTx(c.Request.Context(), func(repos *repository.TxRepositories) error {
+		if metadataChanged {
+			vault.LastModifiedBy = &editor.ID
+			vault.UpdatedAt = time.Now()
+			updated, err := repos.Vaults.UpdateIfRevision(c.Request.Context(), vault, vault.Revision)
+			if err != nil {
+				return err
+			}
+			if !updated {
+				return errSharedEntryConflict
+			}
+		}
+
+		if sharedEdit != nil {
+			if err := repos.SharedEdits.Create(c.Request.Context(), sharedEdit); err != nil {
+				return err
+			}
+			if err := repos.Shares.UpdateEncryptedData(c.Request.Context(), share.ID, sharePayload, models.ShareEncryptionSealed); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errSharedEntryConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The entry was modified at the same time; fetch it again and retry"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update shared entry"})
+		return
+	}
+
+	recordShareAccess(c, h.accessService, share, &editor.ID, models.ShareAccessEdited)
+	go h.emailService.SendSharedEditNotification(owner.Email, vault.Title, editor.Email)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":             "Shared entry updated",
+		"revision":            vault.Revision,
+		"pending_owner_apply": secretChanged,
+		"last_modified_by":    editor.ID,
+	})
+}
+
+// loadRecipientShare loads the :token share for the signed-in recipient and
+// checks that it can still be used, writing the error response otherwise.
+// Recipients may pass the share ID instead of the token, since listings do
+// not give them the token. Refused attempts are recorded in the share's
+// access log.
+func (h *SharingHandler) loadRecipientShare(c *gin.Context, userID, sharePassword string) (*models.SharedPassword, bool) {
+	share, err := h.shareRepo.GetByToken(c.Request.Context(), c.Param("token"))
+	if shareID, parseErr := uuid.Parse(c.Param("token")); err == nil && share == nil && parseErr == nil {
+		share, err = h.shareRepo.GetByID(c.Request.Context(), shareID)
+	}
+	if err != nil || share == nil || share.ShareType == models.ShareTypeLink {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+		return nil, false
+	}
+
+	viewerID := uuid.MustParse(userID)
+
 	if share.Revoked {
+		recordShareAccess(c, h.accessService, share, &viewerID, models.ShareAccessRevoked)
 		c.JSON(http.StatusGone, gin.H{"error": "Share has been revoked"})
-		return
+		return nil, false
 	}
 
 	if share.ExpiresAt != nil && time.Now().After(*share.ExpiresAt) {
+		recordShareAccess(c, h.accessService, share, &viewerID, models.ShareAccessExpired)
 		c.JSON(http.StatusGone, gin.H{"error": "Share has expired"})
-		return
+		return nil, false
 	}
 
 	if share.MaxViews != nil && share.ViewCount >= *share.MaxViews {
+		recordShareAccess(c, h.accessService, share, &viewerID, models.ShareAccessViewLimitReached)
 		c.JSON(http.StatusGone, gin.H{"error": "Share view limit reached"})
-		return
+		return nil, false
 	}
 
-	if share.RecipientID != nil && share.RecipientID.String() != userID {
+	// Pending shares have no recipient yet and cannot be opened by anyone.
+	if share.RecipientID == nil || *share.RecipientID != viewerID {
+		recordShareAccess(c, h.accessService, share, &viewerID, models.ShareAccessDenied)
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
+		return nil, false
+	}
+
+	suspended, err := h.shareRepo.IsSuspended(c.Request.Context(), share)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch share"})
+		return nil, false
+	}
+	if suspended {
+		recordShareAccess(c, h.accessService, share, &viewerID, models.ShareAccessSuspended)
+		c.JSON(http.StatusGone, gin.H{"error": errShareSuspendedMessage})
+		return nil, false
 	}
 
 	if share.RequirePassword {
 		if sharePassword == "" {
+			recordShareAccess(c, h.accessService, share, &viewerID, models.ShareAccessPasswordRequired)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Share password required"})
-			return
+			return nil, false
 		}
-		if !h.cryptoService.VerifyPassword(sharePassword, *share.SharePasswordHash, "") {
+		if !verifySharePassword(h.cryptoService, share, sharePassword) {
+			recordShareAccess(c, h.accessService, share, &viewerID, models.ShareAccessWrongPassword)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid share password"})
+			return nil, false
+		}
+	}
+
+	if share.EncryptionVersion == models.ShareEncryptionLegacy {
+		c.JSON(http.StatusConflict, gin.H{"error": "This share predates end-to-end encryption; ask the owner to share the item again"})
+		return nil, false
+	}
+
+	return share, true
+}
+
+// sealSharedEdit prepares a recipient's password or notes change: the edit
+// sealed to the owner, and the recipient's share payload refreshed with it.
+// Zero-knowledge recipients send both ready-made. It writes the error
+// response itself.
+func (h *SharingHandler) sealSharedEdit(
+	c *gin.Context,
+	share *models.SharedPassword,
+	editor *models.User,
+	owner *models.User,
+	req *models.UpdateSharedPasswordRequest,
+) (string, string, bool) {
+	if editor.ClientSideEncryption {
+		if req.OwnerEncryptedData == nil || *req.OwnerEncryptedData == "" ||
+			req.EncryptedData == nil || *req.EncryptedData == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Encrypted data for the owner and the share is required"})
+			return "", "", false
+		}
+		return *req.OwnerEncryptedData, *req.EncryptedData, true
+	}
+
+	if req.MasterPassword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Master password required"})
+		return "", "", false
+	}
+
+	vaultKey, err := h.vaultKeyService.UnlockUser(c.Request.Context(), editor, req.MasterPassword)
+	if err != nil {
+		respondUnlockError(c, err)
+		return "", "", false
+	}
+
+	if !h.claimEscrowedShare(c, share, editor) {
+		return "", "", false
+	}
+
+	privateKey, err := h.vaultKeyService.OpenPrivateKey(editor, vaultKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open private key"})
+		return "", "", false
+	}
+
+	plaintext, err := h.cryptoService.OpenWithPrivateKey(share.EncryptedData, privateKey)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to decrypt shared password"})
+		return "", "", false
+	}
+
+	var data models.DecryptedVaultData
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt data"})
+		return "", "", false
+	}
+	if req.Password != nil {
+		data.Password = *req.Password
+	}
+	if req.Notes != nil {
+		data.Notes = req.Notes
+	}
+
+	editJSON, _ := json.Marshal(models.SharedEditPayload{Password: req.Password, Notes: req.Notes})
+	dataJSON, _ := json.Marshal(data)
+
+	ownerKey, err := base64.StdEncoding.DecodeString(owner.PublicKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Owner key is invalid"})
+		return "", "", false
+	}
+	editorKey, err := base64.StdEncoding.DecodeString(editor.PublicKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Recipient key is invalid"})
+		return "", "", false
+	}
+
+	ownerPayload, err := h.cryptoService.SealToPublicKey(editJSON, ownerKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+		return "", "", false
+	}
+	sharePayload, err := h.cryptoService.SealToPublicKey(dataJSON, editorKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+		return "", "", false
+	}
+
+	return ownerPayload, sharePayload, true
+}
+
+// CreateLinkShare creates an anonymous link to an entry for someone without
+// an account. The entry is encrypted with a random link key that is only ever
+// part of the URL fragment, which browsers do not send to the server. Links
+// are single view unless MaxViews says otherwise, and the payload is wiped
+// once the last view is consumed.
+func (h *SharingHandler) CreateLinkShare(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req models.CreateLinkShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	vault, err := h.vaultRepo.GetByID(c.Request.Context(), uuid.MustParse(req.VaultID))
+	if err != nil || vault == nil || vault.UserID.String() != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	if !vault.IsPersonal() {
+		c.JSON(http.StatusConflict, gin.H{"error": errNotPersonalEntryMessage})
+		return
+	}
+	if vault.InTrash() {
+		c.JSON(http.StatusConflict, gin.H{"error": errEntryInTrashMessage})
+		return
+	}
+
+	owner, err := h.userRepo.GetByID(c.Request.Context(), uuid.MustParse(userID))
+	if err != nil || owner == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+
+	maxViews := req.MaxViews
+	if maxViews == nil || *maxViews < 1 {
+		one := 1
+		maxViews = &one
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInHours != nil && *req.ExpiresInHours > 0 {
+		exp := time.Now().Add(time.Hour * time.Duration(*req.ExpiresInHours))
+		expiresAt = &exp
+	}
+
+	sharePasswordHash, sharePasswordSalt, err := h.hashSharePassword(req.RequirePassword, req.SharePassword)
+	if errors.Is(err, errMissingSharePassword) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Share password required"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash share password"})
+		return
+	}
+
+	var payload, fragment string
+	if owner.ClientSideEncryption {
+		if req.EncryptedData == nil || *req.EncryptedData == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Encrypted data is required"})
+			return
+		}
+		payload = *req.EncryptedData
+	} else {
+		if req.MasterPassword == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Master password required"})
+			return
+		}
+
+		vaultKey, err := h.vaultKeyService.UnlockUser(c.Request.Context(), owner, req.MasterPassword)
+		if err != nil {
+			respondUnlockError(c, err)
+			return
+		}
+
+		plaintext, err := h.vaultKeyService.OpenEntry(vault, vaultKey, req.MasterPassword)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to decrypt vault entry"})
 			return
 		}
+
+		var data models.DecryptedVaultData
+		if err := json.Unmarshal([]byte(plaintext), &data); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt data"})
+			return
+		}
+
+		linkPayload, err := json.Marshal(models.LinkSharePayload{
+			Type:          vault.Type,
+			Title:         vault.Title,
+			Website:       vault.Website,
+			Username:      vault.Username,
+			Password:      data.Password,
+			Notes:         data.Notes,
+			Fields:        data.Fields,
+			VaultItemData: data.VaultItemData,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare data"})
+			return
+		}
+
+		linkKey, err := h.cryptoService.GenerateKey()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate link key"})
+			return
+		}
+
+		payload, err = h.cryptoService.SealWithKey(linkPayload, linkKey)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+			return
+		}
+		fragment = base64.RawURLEncoding.EncodeToString(linkKey)
 	}
 
-	vault, err := h.vaultRepo.GetByID(c.Request.Context(), share.VaultID)
+	shareToken := h.generateShareToken()
+
+	share := &models.SharedPassword{
+		ID:                uuid.New(),
+		VaultID:           vault.ID,
+		OwnerID:           owner.ID,
+		ShareType:         models.ShareTypeLink,
+		EncryptedData:     payload,
+		EncryptionVersion: models.ShareEncryptionLinkKey,
+		ShareToken:        shareToken,
+		ExpiresAt:         expiresAt,
+		MaxViews:          maxViews,
+		ViewCount:         0,
+		RequirePassword:   req.RequirePassword,
+		SharePasswordHash: sharePasswordHash,
+		SharePasswordSalt: sharePasswordSalt,
+		CanView:           true,
+		CanCopy:           true,
+		CanEdit:           false,
+		Revoked:           false,
+		CreatedAt:         time.Now(),
+	}
+
+	if err := h.shareRepo.Create(c.Request.Context(), share); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share"})
+		return
+	}
+
+	shareURL := fmt.Sprintf("https://yourdomain.com/s/%s", shareToken)
+	if fragment != "" {
+		shareURL += "#" + fragment
+	}
+
+	c.JSON(http.StatusOK, models.LinkShareResponse{
+		ShareToken: shareToken,
+		ShareURL:   shareURL,
+		ExpiresAt:  expiresAt,
+		MaxViews:   maxViews,
+	})
+}
+
+// GetRecipientKey returns the public key of a prospective recipient so
+// zero-knowledge clients can seal an entry to it before sharing. Emails
+// without an account get the invitation key instead, flagged as pending.
+func (h *SharingHandler) GetRecipientKey(c *gin.Context) {
+	email := c.Query("email")
+	if email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email required"})
+		return
+	}
+
+	recipient, err := h.userRepo.GetByEmail(c.Request.Context(), email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve password"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up recipient"})
+		return
+	}
+
+	if recipient == nil {
+		c.JSON(http.StatusOK, models.RecipientKeyResponse{
+			Email:     email,
+			PublicKey: h.invitationService.PublicKey(),
+			Pending:   true,
+		})
 		return
 	}
 
-	err = h.shareRepo.IncrementViewCount(c.Request.Context(), share.ID)
+	if recipient.PublicKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipient not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecipientKeyResponse{
+		UserID:    &recipient.ID,
+		Email:     recipient.Email,
+		PublicKey: recipient.PublicKey,
+	})
+}
+
+// claimEscrowedShare re-seals a share still held under the invitation key to
+// the viewer's own public key, for recipients who had no key when their
+// pending share was bound. It writes the error response itself.
+func (h *SharingHandler) claimEscrowedShare(c *gin.Context, share *models.SharedPassword, viewer *models.User) bool {
+	if share.EncryptionVersion != models.ShareEncryptionInvitation {
+		return true
+	}
+
+	if viewer.PublicKey == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Set up a sharing keypair before opening shares"})
+		return false
+	}
+
+	rekeyed, err := h.invitationService.Rekey(share.EncryptedData, viewer.PublicKey)
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to re-key share"})
+		return false
+	}
+
+	if err := h.shareRepo.UpdateEncryptedData(c.Request.Context(), share.ID, rekeyed, models.ShareEncryptionSealed); err != nil {
+		log.Printf("Failed to store re-keyed share %s: %v", share.ID, err)
+	}
+
+	share.EncryptedData = rekeyed
+	share.EncryptionVersion = models.ShareEncryptionSealed
+	return true
+}
+
+// sealForRecipient produces the share payload: the entry sealed to
+// recipientKey, the recipient's public key or the invitation key. Zero-knowledge
+// owners have sealed it already; for server-side owners the entry is
+// decrypted with their master password and sealed here, leaving out what the
+// recipient may not see: without can_view, the custom fields are dropped and a
+// typed item keeps only the secret it can copy. It writes the error response itself.
+func (h *SharingHandler) sealForRecipient(
+	c *gin.Context,
+	owner *models.User,
+	vault *models.Vault,
+	recipientKey string,
+	canView, canCopy bool,
+	req *models.SharePasswordRequest,
+) (string, bool) {
+	if owner.ClientSideEncryption {
+		if req.EncryptedData == nil || *req.EncryptedData == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Encrypted data sealed to the recipient is required"})
+			return "", false
+		}
+		return *req.EncryptedData, true
+	}
+
+	if req.MasterPassword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Master password required"})
+		return "", false
+	}
+
+	vaultKey, err := h.vaultKeyService.UnlockUser(c.Request.Context(), owner, req.MasterPassword)
+	if err != nil {
+		respondUnlockError(c, err)
+		return "", false
+	}
+
+	plaintext, err := h.vaultKeyService.OpenEntry(vault, vaultKey, req.MasterPassword)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to decrypt vault entry"})
+		return "", false
+	}
+
+	var data models.DecryptedVaultData
+	if err := json.Unmarshal([]byte(plaintext), &data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt data"})
+		return "", false
+	}
+	if !canView && !canCopy {
+		data.Password = ""
+		data.VaultItemData = models.VaultItemData{}
+	}
+	if !canView {
+		data.Notes = nil
+		// Hidden and TOTP fields are secrets of their own.
+		data.Fields = nil
+		data.VaultItemData = data.VaultItemData.CopyOnly()
+	}
+	payload, _ := json.Marshal(data)
+
+	publicKey, err := base64.StdEncoding.DecodeString(recipientKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Recipient key is invalid"})
+		return "", false
+	}
+
+	sealed, err := h.cryptoService.SealToPublicKey(payload, publicKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+		return "", false
+	}
+
+	return sealed, true
+}
+
+// GetAccessLog returns the access history of one of the user's shares,
+// newest first. ?limit= caps the number of entries (default 100, max 500).
+func (h *SharingHandler) GetAccessLog(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	share, err := h.shareRepo.GetByToken(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch share"})
+		return
+	}
+	if share == nil || share.OwnerID.String() != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+		return
+	}
+
+	limit := 100
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = min(parsed, 500)
+	}
+
+	entries, err := h.accessService.History(c.Request.Context(), share.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch access log"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data": gin.H{
-			"title":    vault.Title,
-			"website":  vault.Website,
-			"username": vault.Username,
-			"password": "***",
-			"notes":    nil,
-		},
-		"permissions": gin.H{
-			"can_view": share.CanView,
-			"can_copy": share.CanCopy,
-			"can_edit": share.CanEdit,
-		},
-		"metadata": gin.H{
-			"owner":           share.OwnerID,
-			"expires_at":      share.ExpiresAt,
-			"views_remaining": calculateViewsRemaining(share),
-		},
+		"share_token":   share.ShareToken,
+		"view_count":    share.ViewCount,
+		"last_accessed": share.LastAccessed,
+		"entries":       entries,
 	})
 }
 
@@ -214,12 +1014,68 @@ func (h *SharingHandler) ListShares(c *gin.Context) {
 		return
 	}
 
+	if err := h.shareRepo.MarkSuspended(c.Request.Context(), sent); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sent shares"})
+		return
+	}
+	if err := h.shareRepo.MarkSuspended(c.Request.Context(), received); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch received shares"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"sent":     sent,
-		"received": received,
+		"sent":     toShareListItems(sent, false),
+		"received": toShareListItems(received, true),
 	})
 }
 
+// toShareListItems describes shares for a listing. The token of a received
+// share is left out: its recipient opens it by ID.
+func toShareListItems(shares []models.SharedPassword, received bool) []models.ShareListItem {
+	items := make([]models.ShareListItem, len(shares))
+	for i, share := range shares {
+		items[i] = models.ShareListItem{
+			ID:              share.ID,
+			VaultID:         share.VaultID,
+			OwnerID:         share.OwnerID,
+			ShareType:       share.ShareType,
+			RecipientID:     share.RecipientID,
+			RecipientEmail:  share.RecipientEmail,
+			ExpiresAt:       share.ExpiresAt,
+			MaxViews:        share.MaxViews,
+			ViewCount:       share.ViewCount,
+			RequirePassword: share.RequirePassword,
+			CanView:         share.CanView,
+			CanCopy:         share.CanCopy,
+			CanEdit:         share.CanEdit,
+			Revoked:         share.Revoked,
+			Suspended:       share.Suspended,
+			CreatedAt:       share.CreatedAt,
+			LastAccessed:    share.LastAccessed,
+		}
+		if !received {
+			items[i].ShareToken = share.ShareToken
+		}
+	}
+	return items
+}
+
+// hashSharePassword hashes the optional password protecting a share.
+func (h *SharingHandler) hashSharePassword(required bool, password *string) (*string, *string, error) {
+	if !required {
+		return nil, nil, nil
+	}
+	if password == nil || *password == "" {
+		return nil, nil, errMissingSharePassword
+	}
+
+	hash, salt, err := h.cryptoService.HashPassword(*password)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &hash, &salt, nil
+}
+
 func (h *SharingHandler) generateShareToken() string {
 	b := make([]byte, 32)
 	_, err := rand.Read(b)
@@ -236,3 +1092,23 @@ func calculateViewsRemaining(share *models.SharedPassword) *int {
 	remaining := *share.MaxViews - share.ViewCount
 	return &remaining
 }
+
+// recordShareAccess adds an attempt on share to its access log. userID is nil
+// for anonymous link shares.
+func recordShareAccess(c *gin.Context, accessService *services.ShareAccessService, share *models.SharedPassword, userID *uuid.UUID, outcome string) {
+	accessService.Record(c.Request.Context(), share, userID, c.ClientIP(), c.Request.UserAgent(), outcome)
+}
+
+// recordShareView adds a granted access to share to its access log; viewCount
+// is the count the view brought the share to.
+func recordShareView(c *gin.Context, accessService *services.ShareAccessService, share *models.SharedPassword, userID *uuid.UUID, viewCount int) {
+	accessService.RecordView(c.Request.Context(), share, userID, c.ClientIP(), c.Request.UserAgent(), viewCount)
+}
+
+// verifySharePassword checks the password protecting share.
+func verifySharePassword(cryptoService *services.CryptoService, share *models.SharedPassword, password string) bool {
+	if share.SharePasswordHash == nil || share.SharePasswordSalt == nil {
+		return false
+	}
+	return cryptoService.VerifyPassword(password, *share.SharePasswordHash, *share.SharePasswordSalt)
+}
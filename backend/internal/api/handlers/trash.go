@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+	"github.com/tresor/password-manager/internal/services"
+)
+
+// errEntryInTrashMessage is returned when an entry in the trash is used
+// where a live one is expected.
+const errEntryInTrashMessage = "Entry is in the trash; restore it first"
+
+// errShareSuspendedMessage is returned for shares of an entry in the trash.
+const errShareSuspendedMessage = "Share is suspended"
+
+// TrashHandler serves the trash of personal entries: listing it, restoring
+// entries, and deleting them for good before the scheduled purge. It works
+// the same for server-side and zero-knowledge accounts, since nothing here
+// touches the encrypted payload.
+type TrashHandler struct {
+	vaultRepo     *repository.VaultRepository
+	userRepo      *repository.UserRepository
+	trashService  *services.TrashService
+	folderService *services.FolderService
+}
+
+func NewTrashHandler(
+	vaultRepo *repository.VaultRepository,
+	userRepo *repository.UserRepository,
+	trashService *services.TrashService,
+	folderService *services.FolderService,
+) *TrashHandler {
+	return &TrashHandler{
+		vaultRepo:     vaultRepo,
+		userRepo:      userRepo,
+		trashService:  trashService,
+		folderService: folderService,
+	}
+}
+
+// ListTrash lists the entries in the caller's trash, most recently deleted
+// first, with when each is due to be purged.
+func (h *TrashHandler) ListTrash(c *gin.Context) {
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	vaults, err := h.vaultRepo.GetTrashByUserID(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the trash"})
+		return
+	}
+
+	entries := make([]models.TrashEntryResponse, len(vaults))
+	for i := range vaults {
+		vault := &vaults[i]
+		entries[i] = models.TrashEntryResponse{
+			ID:        vault.ID,
+			Type:      vault.Type,
+			Title:     vault.Title,
+			Website:   vault.Website,
+			Username:  vault.Username,
+			FolderID:  vault.FolderID,
+			Folder:    vault.Folder,
+			Revision:  vault.Revision,
+			DeletedAt: *vault.DeletedAt,
+			PurgeAt:   h.trashService.PurgeAt(vault),
+		}
+		if user.ClientSideEncryption {
+			entries[i].EncryptedData = vault.EncryptedData
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries":        entries,
+		"retention_days": h.trashService.RetentionDays(),
+	})
+}
+
+// RestoreEntry takes an entry out of the trash, which also resumes its
+// shares. An entry whose folder was deleted meanwhile goes back to a folder
+// at its former path.
+func (h *TrashHandler) RestoreEntry(c *gin.Context) {
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	vault, ok := h.loadTrashedEntry(c, user)
+	if !ok {
+		return
+	}
+
+	if err := h.folderService.Refile(c.Request.Context(), user.ID, vault); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore the entry's folder"})
+		return
+	}
+
+	restored, err := h.trashService.Restore(c.Request.Context(), vault, user.ID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore vault entry"})
+		return
+	}
+	if !restored {
+		c.JSON(http.StatusConflict, gin.H{"error": "The entry was modified at the same time; fetch it again and retry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Vault entry restored successfully",
+		"revision": vault.Revision,
+	})
+}
+
+// DeleteEntry deletes an entry in the trash for good, with its attachments,
+// history and shares.
+func (h *TrashHandler) DeleteEntry(c *gin.Context) {
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	vault, ok := h.loadTrashedEntry(c, user)
+	if !ok {
+		return
+	}
+
+	if err := h.trashService.Delete(c.Request.Context(), vault.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete vault"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Vault entry deleted permanently"})
+}
+
+// EmptyTrash deletes every entry in the caller's trash for good.
+func (h *TrashHandler) EmptyTrash(c *gin.Context) {
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	vaults, err := h.vaultRepo.GetTrashByUserID(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the trash"})
+		return
+	}
+
+	deleted := 0
+	for i := range vaults {
+		if err := h.trashService.Delete(c.Request.Context(), vaults[i].ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to empty the trash",
+				"deleted": deleted,
+			})
+			return
+		}
+		deleted++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Trash emptied successfully",
+		"deleted": deleted,
+	})
+}
+
+// loadTrashedEntry loads the caller's personal entry named by the route and
+// checks that it is in the trash, writing the error response itself.
+func (h *TrashHandler) loadTrashedEntry(c *gin.Context, user *models.User) (*models.Vault, bool) {
+	vaultID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vault ID"})
+		return nil, false
+	}
+
+	vault, err := h.vaultRepo.GetByID(c.Request.Context(), vaultID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vault"})
+		return nil, false
+	}
+	if vault == nil || !vault.InTrash() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vault entry not found in the trash"})
+		return nil, false
+	}
+
+	if vault.UserID != user.ID || !vault.IsPersonal() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return vault, true
+}
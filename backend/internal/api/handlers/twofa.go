@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/skip2/go-qrcode"
+	"github.com/tresor/password-manager/internal/models"
 	"github.com/tresor/password-manager/internal/repository"
 	"github.com/tresor/password-manager/internal/services"
 	"github.com/xlzd/gotp"
@@ -17,15 +18,18 @@ import (
 type TwoFAHandler struct {
 	userRepo      *repository.UserRepository
 	cryptoService *services.CryptoService
+	srpService    *services.SRPService
 }
 
 func NewTwoFAHandler(
 	userRepo *repository.UserRepository,
 	cryptoService *services.CryptoService,
+	srpService *services.SRPService,
 ) *TwoFAHandler {
 	return &TwoFAHandler{
 		userRepo:      userRepo,
 		cryptoService: cryptoService,
+		srpService:    srpService,
 	}
 }
 
@@ -33,7 +37,8 @@ func (h *TwoFAHandler) Enable2FA(c *gin.Context) {
 	userID := c.GetString("user_id")
 
 	var req struct {
-		MasterPassword string `json:"master_password" binding:"required"`
+		MasterPassword string `json:"master_password"`
+		models.PAKEProof
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -51,7 +56,7 @@ func (h *TwoFAHandler) Enable2FA(c *gin.Context) {
 		return
 	}
 
-	if !h.cryptoService.VerifyPassword(req.MasterPassword, user.MasterPasswordHash, user.Salt) {
+	if !verifyAccountSecret(h.cryptoService, h.srpService, user, req.MasterPassword, req.PAKEProof) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid master password"})
 		return
 	}
@@ -158,34 +163,37 @@ func (h *TwoFAHandler) Verify2FA(c *gin.Context) {
 		return
 	}
 
-	totp := gotp.NewDefaultTOTP(*user.TwoFactorSecret)
-	if totp.Verify(req.Token, int64(int(time.Now().Unix()))) {
-		c.JSON(http.StatusOK, gin.H{"valid": true})
+	valid, backupCodeUsed := verifySecondFactor(user, req.Token)
+	if valid && !backupCodeUsed {
+		valid, err = h.userRepo.AdvanceTwoFactorStep(c.Request.Context(), user.ID, *user.TwoFactorLastStep)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify token"})
+			return
+		}
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token"})
 		return
 	}
 
-	for i, code := range user.BackupCodes {
-		if code == req.Token {
-			user.BackupCodes = append(user.BackupCodes[:i], user.BackupCodes[i+1:]...)
-			err := h.userRepo.Update(c.Request.Context(), user)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update backup codes"})
-				return
-			}
-
-			c.JSON(http.StatusOK, gin.H{"valid": true, "backup_code_used": true})
+	if backupCodeUsed {
+		if err := h.userRepo.Update(c.Request.Context(), user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update backup codes"})
 			return
 		}
+		c.JSON(http.StatusOK, gin.H{"valid": true, "backup_code_used": true})
+		return
 	}
 
-	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token"})
+	c.JSON(http.StatusOK, gin.H{"valid": true})
 }
 
 func (h *TwoFAHandler) Disable2FA(c *gin.Context) {
 	userID := c.GetString("user_id")
 
 	var req struct {
-		MasterPassword string `json:"master_password" binding:"required"`
+		MasterPassword string `json:"master_password"`
+		models.PAKEProof
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -203,7 +211,7 @@ func (h *TwoFAHandler) Disable2FA(c *gin.Context) {
 		return
 	}
 
-	if !h.cryptoService.VerifyPassword(req.MasterPassword, user.MasterPasswordHash, user.Salt) {
+	if !verifyAccountSecret(h.cryptoService, h.srpService, user, req.MasterPassword, req.PAKEProof) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid master password"})
 		return
 	}
@@ -221,6 +229,38 @@ func (h *TwoFAHandler) Disable2FA(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "2FA disabled successfully"})
 }
 
+// verifySecondFactor checks a TOTP code or, failing that, a backup code.
+// A TOTP code is refused if its time step was already used, and otherwise
+// sets user.TwoFactorLastStep; the caller records it with
+// AdvanceTwoFactorStep. A matching backup code is removed from
+// user.BackupCodes; the caller is responsible for persisting the user when
+// backupCodeUsed is true.
+func verifySecondFactor(user *models.User, code string) (valid bool, backupCodeUsed bool) {
+	if !user.TwoFactorEnabled || user.TwoFactorSecret == nil {
+		return false, false
+	}
+
+	now := time.Now().Unix()
+	totp := gotp.NewDefaultTOTP(*user.TwoFactorSecret)
+	if totp.Verify(code, now) {
+		step := now / totpStepSeconds
+		if user.TwoFactorLastStep != nil && step <= *user.TwoFactorLastStep {
+			return false, false
+		}
+		user.TwoFactorLastStep = &step
+		return true, false
+	}
+
+	for i, backupCode := range user.BackupCodes {
+		if backupCode == code {
+			user.BackupCodes = append(user.BackupCodes[:i], user.BackupCodes[i+1:]...)
+			return true, true
+		}
+	}
+
+	return false, false
+}
+
 func (h *TwoFAHandler) generateBackupCodes(count int) []string {
 	codes := make([]string, count)
 
@@ -2,29 +2,54 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/tresor/password-manager/internal/models"
 	"github.com/tresor/password-manager/internal/repository"
 	"github.com/tresor/password-manager/internal/services"
 )
 
+// errItemTypeChangeMessage is returned when an update names a different
+// item type than the stored entry's.
+const errItemTypeChangeMessage = "Item type cannot be changed"
+
 type VaultHandler struct {
-	vaultRepo     *repository.VaultRepository
-	cryptoService *services.CryptoService
+	vaultRepo       *repository.VaultRepository
+	txManager       *repository.TxManager
+	cryptoService   *services.CryptoService
+	vaultKeyService *services.VaultKeyService
+	policyService   *services.PasswordPolicyService
+	historyService  *services.PasswordHistoryService
+	trashService    *services.TrashService
+	folderService   *services.FolderService
 }
 
 func NewVaultHandler(
 	vaultRepo *repository.VaultRepository,
+	txManager *repository.TxManager,
 	cryptoService *services.CryptoService,
+	vaultKeyService *services.VaultKeyService,
+	policyService *services.PasswordPolicyService,
+	historyService *services.PasswordHistoryService,
+	trashService *services.TrashService,
+	folderService *services.FolderService,
 ) *VaultHandler {
 	return &VaultHandler{
-		vaultRepo:     vaultRepo,
-		cryptoService: cryptoService,
+		vaultRepo:       vaultRepo,
+		txManager:       txManager,
+		cryptoService:   cryptoService,
+		vaultKeyService: vaultKeyService,
+		policyService:   policyService,
+		historyService:  historyService,
+		trashService:    trashService,
+		folderService:   folderService,
 	}
 }
 
@@ -37,9 +62,23 @@ func (h *VaultHandler) CreateVault(c *gin.Context) {
 		return
 	}
 
+	itemType := req.Type
+	if itemType == "" {
+		itemType = models.ItemTypeLogin
+	}
+
 	data := models.DecryptedVaultData{
-		Password: req.Password,
-		Notes:    req.Notes,
+		Password:      req.Password,
+		Notes:         req.Notes,
+		Fields:        req.Fields,
+		VaultItemData: req.VaultItemData,
+	}
+	if !validateVaultItem(c, itemType, &data) {
+		return
+	}
+	tags, ok := normalizeTags(c, req.Tags)
+	if !ok {
+		return
 	}
 
 	dataJSON, err := json.Marshal(data)
@@ -48,28 +87,35 @@ func (h *VaultHandler) CreateVault(c *gin.Context) {
 		return
 	}
 
-	ciphertext, salt, nonce, err := h.cryptoService.EncryptData(
-		string(dataJSON),
-		req.MasterPassword,
-	)
+	vaultKey, err := h.vaultKeyService.Unlock(c.Request.Context(), uuid.MustParse(userID), req.MasterPassword)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+		respondUnlockError(c, err)
+		return
+	}
+
+	if !enforcePasswordPolicy(c, h.policyService, data.Password) {
 		return
 	}
 
 	vault := &models.Vault{
-		ID:             uuid.New(),
-		UserID:         uuid.MustParse(userID),
-		Title:          req.Title,
-		Website:        req.Website,
-		Username:       req.Username,
-		EncryptedData:  ciphertext,
-		EncryptionSalt: salt,
-		Nonce:          nonce,
-		Folder:         req.Folder,
-		Favorite:       false,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
+		ID:        uuid.New(),
+		UserID:    uuid.MustParse(userID),
+		Type:      itemType,
+		Title:     req.Title,
+		Website:   req.Website,
+		Username:  req.Username,
+		Tags:      pq.StringArray(tags),
+		Favorite:  false,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if !assignFolder(c, h.folderService, vault.UserID, vault, req.FolderID, req.Folder) {
+		return
+	}
+
+	if err := h.vaultKeyService.SealEntry(vault, vaultKey, string(dataJSON)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+		return
 	}
 
 	if err := h.vaultRepo.Create(c.Request.Context(), vault); err != nil {
@@ -80,12 +126,19 @@ func (h *VaultHandler) CreateVault(c *gin.Context) {
 	c.JSON(http.StatusOK, h.toVaultResponse(vault))
 }
 
+// GetVaults lists the caller's entries, filtered, sorted and paginated as
+// described by vaultListQuery. The body is the page itself; the total and
+// the next cursor come as headers.
 func (h *VaultHandler) GetVaults(c *gin.Context) {
 	userID := c.GetString("user_id")
 
-	vaults, err := h.vaultRepo.GetByUserID(c.Request.Context(), uuid.MustParse(userID))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vaults"})
+	opts, ok := vaultListQuery(c)
+	if !ok {
+		return
+	}
+
+	vaults, ok := listVaultEntries(c, h.vaultRepo, uuid.MustParse(userID), opts)
+	if !ok {
 		return
 	}
 
@@ -112,7 +165,7 @@ func (h *VaultHandler) GetVault(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vault"})
 		return
 	}
-	if vault == nil {
+	if vault == nil || vault.InTrash() {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Vault entry not found"})
 		return
 	}
@@ -122,14 +175,20 @@ func (h *VaultHandler) GetVault(c *gin.Context) {
 		return
 	}
 
-	plaintext, err := h.cryptoService.DecryptData(
-		vault.EncryptedData,
-		masterPassword,
-		vault.EncryptionSalt,
-		vault.Nonce,
-	)
+	if !vault.IsPersonal() {
+		c.JSON(http.StatusConflict, gin.H{"error": errNotPersonalEntryMessage})
+		return
+	}
+
+	vaultKey, err := h.vaultKeyService.Unlock(c.Request.Context(), vault.UserID, masterPassword)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid master password"})
+		respondUnlockError(c, err)
+		return
+	}
+
+	plaintext, err := h.vaultKeyService.OpenEntry(vault, vaultKey, masterPassword)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to decrypt vault entry"})
 		return
 	}
 
@@ -141,18 +200,23 @@ func (h *VaultHandler) GetVault(c *gin.Context) {
 
 	response := h.toVaultResponse(vault)
 
-	c.JSON(http.StatusOK, gin.H{
+	entry := gin.H{
 		"id":         response.ID,
 		"title":      response.Title,
 		"website":    response.Website,
 		"username":   response.Username,
 		"password":   data.Password,
 		"notes":      data.Notes,
+		"folder_id":  response.FolderID,
 		"folder":     response.Folder,
+		"tags":       response.Tags,
 		"favorite":   response.Favorite,
 		"created_at": response.CreatedAt,
 		"updated_at": response.UpdatedAt,
-	})
+	}
+	addItemFields(entry, vault, &data)
+
+	c.JSON(http.StatusOK, entry)
 }
 
 func (h *VaultHandler) UpdateVault(c *gin.Context) {
@@ -170,7 +234,7 @@ func (h *VaultHandler) UpdateVault(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vault"})
 		return
 	}
-	if vault == nil {
+	if vault == nil || vault.InTrash() {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Vault entry not found"})
 		return
 	}
@@ -180,18 +244,70 @@ func (h *VaultHandler) UpdateVault(c *gin.Context) {
 		return
 	}
 
+	if !vault.IsPersonal() {
+		c.JSON(http.StatusConflict, gin.H{"error": errNotPersonalEntryMessage})
+		return
+	}
+
+	if req.Type != "" && req.Type != vault.Type {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errItemTypeChangeMessage})
+		return
+	}
+
 	data := models.DecryptedVaultData{
-		Password: req.Password,
-		Notes:    req.Notes,
+		Password:      req.Password,
+		Notes:         req.Notes,
+		Fields:        req.Fields,
+		VaultItemData: req.VaultItemData,
+	}
+	if !validateVaultItem(c, vault.Type, &data) {
+		return
+	}
+	tags, ok := normalizeTags(c, req.Tags)
+	if !ok {
+		return
 	}
 
 	dataJSON, _ := json.Marshal(data)
 
-	ciphertext, salt, nonce, err := h.cryptoService.EncryptData(
-		string(dataJSON),
-		req.MasterPassword,
-	)
+	vaultKey, err := h.vaultKeyService.Unlock(c.Request.Context(), vault.UserID, req.MasterPassword)
 	if err != nil {
+		respondUnlockError(c, err)
+		return
+	}
+
+	// An unchanged password is not held to the policy again, so that older
+	// entries can still be edited; only the account-level rules apply.
+	newPassword := data.Password
+	var previousPassword string
+	if plaintext, err := h.vaultKeyService.OpenEntry(vault, vaultKey, req.MasterPassword); err == nil {
+		var current models.DecryptedVaultData
+		if json.Unmarshal([]byte(plaintext), &current) == nil {
+			previousPassword = current.Password
+			if current.Password == data.Password {
+				newPassword = ""
+			}
+		}
+	}
+	if !enforcePasswordPolicy(c, h.policyService, newPassword) {
+		return
+	}
+
+	now := time.Now()
+	var history *models.PasswordHistory
+	if previousPassword != "" && previousPassword != data.Password {
+		history, err = h.historyService.Seal(vault.ID, vaultKey, previousPassword, vault.UserID, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+			return
+		}
+	}
+
+	if !assignFolder(c, h.folderService, vault.UserID, vault, req.FolderID, req.Folder) {
+		return
+	}
+
+	if err := h.vaultKeyService.SealEntry(vault, vaultKey, string(dataJSON)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
 		return
 	}
@@ -199,20 +315,44 @@ func (h *VaultHandler) UpdateVault(c *gin.Context) {
 	vault.Title = req.Title
 	vault.Website = req.Website
 	vault.Username = req.Username
-	vault.EncryptedData = ciphertext
-	vault.EncryptionSalt = salt
-	vault.Nonce = nonce
-	vault.Folder = req.Folder
-	vault.UpdatedAt = time.Now()
-
-	if err := h.vaultRepo.Update(c.Request.Context(), vault); err != nil {
+	vault.Tags = pq.StringArray(tags)
+	expectedRevision := vault.Revision
+	vault.LastModifiedBy = &vault.UserID
+	vault.UpdatedAt = now
+
+	err = h.txManager.WithinTx(c.Request.Context(), func(repos *repository.TxRepositories) error {
+		updated, err := repos.Vaults.UpdateIfRevision(c.Request.Context(), vault, expectedRevision)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return errEntryModified
+		}
+		if history != nil {
+			return repos.PasswordHistory.Create(c.Request.Context(), history)
+		}
+		return nil
+	})
+	if errors.Is(err, errEntryModified) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The entry was modified at the same time; fetch it again and retry"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update vault"})
 		return
 	}
 
+	if history != nil {
+		if err := h.historyService.Prune(c.Request.Context(), vault.ID, now); err != nil {
+			log.Printf("Failed to prune password history of vault entry %s: %v", vault.ID, err)
+		}
+	}
+
 	c.JSON(http.StatusOK, h.toVaultResponse(vault))
 }
 
+// DeleteVault moves an entry to the trash, from which it can be restored
+// until it is purged. Its shares are suspended meanwhile.
 func (h *VaultHandler) DeleteVault(c *gin.Context) {
 	userID := c.GetString("user_id")
 	vaultID := c.Param("id")
@@ -222,7 +362,7 @@ func (h *VaultHandler) DeleteVault(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vault"})
 		return
 	}
-	if vault == nil {
+	if vault == nil || vault.InTrash() {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Vault entry not found"})
 		return
 	}
@@ -232,12 +372,25 @@ func (h *VaultHandler) DeleteVault(c *gin.Context) {
 		return
 	}
 
-	if err := h.vaultRepo.Delete(c.Request.Context(), uuid.MustParse(vaultID)); err != nil {
+	if !vault.IsPersonal() {
+		c.JSON(http.StatusConflict, gin.H{"error": errNotPersonalEntryMessage})
+		return
+	}
+
+	trashed, err := h.trashService.Trash(c.Request.Context(), vault, vault.UserID, time.Now())
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete vault"})
 		return
 	}
+	if !trashed {
+		c.JSON(http.StatusConflict, gin.H{"error": "The entry was modified at the same time; fetch it again and retry"})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Vault entry deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Vault entry moved to the trash",
+		"purge_at": h.trashService.PurgeAt(vault),
+	})
 }
 
 func (h *VaultHandler) GeneratePassword(c *gin.Context) {
@@ -265,13 +418,80 @@ func (h *VaultHandler) GeneratePassword(c *gin.Context) {
 
 func (h *VaultHandler) toVaultResponse(vault *models.Vault) models.VaultResponse {
 	return models.VaultResponse{
-		ID:        vault.ID,
-		Title:     vault.Title,
-		Website:   vault.Website,
-		Username:  vault.Username,
-		Folder:    vault.Folder,
-		Favorite:  vault.Favorite,
-		CreatedAt: vault.CreatedAt,
-		UpdatedAt: vault.UpdatedAt,
+		ID:             vault.ID,
+		Type:           vault.Type,
+		Title:          vault.Title,
+		Website:        vault.Website,
+		Username:       vault.Username,
+		FolderID:       vault.FolderID,
+		Folder:         vault.Folder,
+		Tags:           vault.TagList(),
+		Favorite:       vault.Favorite,
+		LastModifiedBy: vault.LastModifiedBy,
+		DeletedAt:      vault.DeletedAt,
+		CreatedAt:      vault.CreatedAt,
+		UpdatedAt:      vault.UpdatedAt,
+	}
+}
+
+// validateVaultItem checks an entry's payload against its item type and
+// normalizes it, writing the error response itself.
+func validateVaultItem(c *gin.Context, itemType string, data *models.DecryptedVaultData) bool {
+	err := services.ValidateVaultItem(itemType, data, time.Now())
+	if err == nil {
+		return true
+	}
+
+	var invalid *services.ItemValidationError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalid.Error(), "field": invalid.Field})
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// itemTypeQuery reads the optional ?type= filter of entry listings, writing
+// the error response itself.
+func itemTypeQuery(c *gin.Context) (string, bool) {
+	itemType := c.Query("type")
+	if itemType != "" && !models.IsItemType(itemType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown item type"})
+		return "", false
+	}
+	return itemType, true
+}
+
+// addItemFields adds an entry's item type, the fields specific to it and its
+// custom fields to a decrypted entry response.
+func addItemFields(response gin.H, entry *models.Vault, data *models.DecryptedVaultData) {
+	response["type"] = entry.Type
+	response["fields"] = data.Fields
+	if data.Fields == nil {
+		response["fields"] = []models.CustomField{}
+	}
+	switch {
+	case data.Card != nil:
+		response["card"] = data.Card
+	case data.Identity != nil:
+		response["identity"] = data.Identity
+	case data.SSHKey != nil:
+		response["ssh_key"] = data.SSHKey
+	case data.APICredential != nil:
+		response["api_credential"] = data.APICredential
+	}
+}
+
+// respondUnlockError writes the response for a failed vault unlock.
+func respondUnlockError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidMasterPassword):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid master password"})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, services.ErrClientSideEncryption):
+		c.JSON(http.StatusConflict, gin.H{"error": "Account uses client-side encryption; use the /zk endpoints"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock vault"})
 	}
 }
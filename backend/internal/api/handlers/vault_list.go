@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+	"github.com/tresor/password-manager/internal/services"
+)
+
+// maxVaultPageSize bounds ?limit= on entry listings.
+const maxVaultPageSize = 200
+
+// Headers of a paginated entry listing, whose body stays a bare array.
+const (
+	headerTotalCount = "X-Total-Count"
+	headerNextCursor = "X-Next-Cursor"
+)
+
+// vaultListQuery reads the filters, sort order and page of an entry listing,
+// writing the error response itself:
+//
+//	?q=         text in the title, website or username
+//	?folder_id= folder ID
+//	?folder=    folder path; empty for entries without a folder
+//	?favorite=  true or false
+//	?type=      item type
+//	?tag=       tag, repeatable; entries must carry every one
+//	?sort=      created, updated, title or last_used
+//	?order=     asc or desc; titles default to asc, the rest to desc
+//	?cursor=    X-Next-Cursor of the previous page
+//	?limit=     page size, at most 200; every entry when omitted
+func vaultListQuery(c *gin.Context) (repository.VaultListOptions, bool) {
+	var opts repository.VaultListOptions
+
+	itemType, ok := itemTypeQuery(c)
+	if !ok {
+		return opts, false
+	}
+	opts.Type = itemType
+	opts.Query = c.Query("q")
+
+	if value := c.Query("folder_id"); value != "" {
+		folderID, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+			return opts, false
+		}
+		opts.FolderID = &folderID
+	}
+	if folder, ok := c.GetQuery("folder"); ok {
+		opts.Folder = &folder
+	}
+
+	if value := c.Query("favorite"); value != "" {
+		favorite, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "favorite must be true or false"})
+			return opts, false
+		}
+		opts.Favorite = &favorite
+	}
+
+	tags, ok := normalizeTags(c, c.QueryArray("tag"))
+	if !ok {
+		return opts, false
+	}
+	opts.Tags = tags
+
+	sort, order := c.Query("sort"), c.Query("order")
+	if sort != "" && !repository.IsVaultSort(sort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of created, updated, title or last_used"})
+		return opts, false
+	}
+	if order != "" && order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return opts, false
+	}
+	if sort != "" || order != "" {
+		if sort == "" {
+			sort = repository.VaultSortCreated
+		}
+		opts.Sort = sort
+		opts.Descending = order == "desc" || (order == "" && sort != repository.VaultSortTitle)
+	}
+
+	opts.After = c.Query("cursor")
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return opts, false
+		}
+		opts.Limit = min(limit, maxVaultPageSize)
+	}
+
+	return opts, true
+}
+
+// listVaultEntries lists a page of the user's entries and sets the headers
+// giving the number of matching entries and the cursor of the next page,
+// which is left out on the last page. It writes the error response itself.
+func listVaultEntries(c *gin.Context, vaultRepo *repository.VaultRepository, userID uuid.UUID, opts repository.VaultListOptions) ([]models.Vault, bool) {
+	page := opts
+	if opts.Limit > 0 {
+		// One more entry tells whether there is a next page.
+		page.Limit = opts.Limit + 1
+	}
+
+	vaults, err := vaultRepo.List(c.Request.Context(), userID, page)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vaults"})
+		return nil, false
+	}
+
+	total, err := vaultRepo.Count(c.Request.Context(), userID, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vaults"})
+		return nil, false
+	}
+	c.Header(headerTotalCount, strconv.FormatInt(total, 10))
+
+	if opts.Limit > 0 && len(vaults) > opts.Limit {
+		vaults = vaults[:opts.Limit]
+		c.Header(headerNextCursor, repository.VaultCursor(opts, &vaults[len(vaults)-1]))
+	}
+	return vaults, true
+}
+
+// normalizeTags normalizes the tags of a request, writing the error response
+// itself.
+func normalizeTags(c *gin.Context, tags []string) ([]string, bool) {
+	normalized, err := services.NormalizeTags(tags)
+	if err != nil {
+		var invalid *services.ItemValidationError
+		if errors.As(err, &invalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": invalid.Error(), "field": invalid.Field})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	return normalized, true
+}
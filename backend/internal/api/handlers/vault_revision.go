@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+	"github.com/tresor/password-manager/internal/services"
+)
+
+// VaultRevisionHandler serves the revisions of personal entries: what
+// changed at each one, and restoring an entry to an earlier one. Server-side
+// accounts see changes to the encrypted payload once they pass their master
+// password; zero-knowledge clients get each revision's blob to diff
+// themselves.
+type VaultRevisionHandler struct {
+	vaultRepo       *repository.VaultRepository
+	revisionRepo    *repository.VaultRevisionRepository
+	userRepo        *repository.UserRepository
+	txManager       *repository.TxManager
+	vaultKeyService *services.VaultKeyService
+	historyService  *services.PasswordHistoryService
+	policyService   *services.PasswordPolicyService
+	folderService   *services.FolderService
+}
+
+func NewVaultRevisionHandler(
+	vaultRepo *repository.VaultRepository,
+	revisionRepo *repository.VaultRevisionRepository,
+	userRepo *repository.UserRepository,
+	txManager *repository.TxManager,
+	vaultKeyService *services.VaultKeyService,
+	historyService *services.PasswordHistoryService,
+	policyService *services.PasswordPolicyService,
+	folderService *services.FolderService,
+) *VaultRevisionHandler {
+	return &VaultRevisionHandler{
+		vaultRepo:       vaultRepo,
+		revisionRepo:    revisionRepo,
+		userRepo:        userRepo,
+		txManager:       txManager,
+		vaultKeyService: vaultKeyService,
+		historyService:  historyService,
+		policyService:   policyService,
+		folderService:   folderService,
+	}
+}
+
+// ListRevisions lists an entry's revisions, newest first, each with the
+// fields changed since the one before. Secret fields are only compared when
+// ?master_password= is given.
+func (h *VaultRevisionHandler) ListRevisions(c *gin.Context) {
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	masterPassword := c.Query("master_password")
+	vaultKey, ok := h.optionalUnlock(c, user, masterPassword)
+	if !ok {
+		return
+	}
+
+	vault, ok := loadPersonalEntry(c, h.vaultRepo, user)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.revisionRepo.GetByVaultID(c.Request.Context(), vault.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+
+	response := make([]models.VaultRevisionResponse, len(revisions))
+	var previous *models.VaultRevision
+	var previousData *models.DecryptedVaultData
+	for i := range revisions {
+		revision := &revisions[i]
+		data := h.openRevision(revision, vaultKey, masterPassword)
+
+		var changes []models.FieldChange
+		if previous == nil || (previousData != nil) == (data != nil) {
+			changes = services.DiffRevisions(previous, revision, previousData, data)
+		} else {
+			// Only one side could be opened; compare what is in clear.
+			changes = services.DiffRevisions(previous, revision, nil, nil)
+		}
+
+		// Newest first.
+		response[len(revisions)-1-i] = h.toRevisionResponse(user, revision, changes)
+		previous, previousData = revision, data
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"revisions":        response,
+		"secrets_included": vaultKey != nil,
+	})
+}
+
+// DiffRevisions compares two revisions of an entry, ?from= and ?to=, with
+// secret fields when ?master_password= is given.
+func (h *VaultRevisionHandler) DiffRevisions(c *gin.Context) {
+	from, errFrom := strconv.Atoi(c.Query("from"))
+	to, errTo := strconv.Atoi(c.Query("to"))
+	if errFrom != nil || errTo != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be revision numbers"})
+		return
+	}
+
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	masterPassword := c.Query("master_password")
+	vaultKey, ok := h.optionalUnlock(c, user, masterPassword)
+	if !ok {
+		return
+	}
+
+	vault, ok := loadPersonalEntry(c, h.vaultRepo, user)
+	if !ok {
+		return
+	}
+
+	fromRevision, ok := h.loadRevision(c, vault, from)
+	if !ok {
+		return
+	}
+	toRevision, ok := h.loadRevision(c, vault, to)
+	if !ok {
+		return
+	}
+
+	fromData := h.openRevision(fromRevision, vaultKey, masterPassword)
+	toData := h.openRevision(toRevision, vaultKey, masterPassword)
+	secretsIncluded := fromData != nil && toData != nil
+	if !secretsIncluded {
+		fromData, toData = nil, nil
+	}
+
+	changes := services.DiffRevisions(fromRevision, toRevision, fromData, toData)
+	if changes == nil {
+		changes = []models.FieldChange{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":             from,
+		"to":               to,
+		"changes":          changes,
+		"secrets_included": secretsIncluded,
+	})
+}
+
+// RestoreRevision writes an earlier revision back as the entry's newest
+// one, so the restore itself shows up in the revisions and can be undone.
+// The password it replaces goes into the password history.
+func (h *VaultRevisionHandler) RestoreRevision(c *gin.Context) {
+	var req models.RestoreRevisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision"})
+		return
+	}
+
+	user, ok := loadCurrentUser(c, h.userRepo)
+	if !ok {
+		return
+	}
+
+	var vaultKey []byte
+	if !user.ClientSideEncryption {
+		if req.MasterPassword == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Master password required"})
+			return
+		}
+		// Unlocking applies pending shared edits, so the entry is read after.
+		vaultKey, err = h.vaultKeyService.UnlockUser(c.Request.Context(), user, req.MasterPassword)
+		if err != nil {
+			respondUnlockError(c, err)
+			return
+		}
+	}
+
+	vault, ok := loadPersonalEntry(c, h.vaultRepo, user)
+	if !ok {
+		return
+	}
+
+	revision, ok := h.loadRevision(c, vault, number)
+	if !ok {
+		return
+	}
+	if revision.Revision == vault.Revision {
+		c.JSON(http.StatusConflict, gin.H{"error": "This is already the current revision"})
+		return
+	}
+
+	now := time.Now()
+	var history *models.PasswordHistory
+	if user.ClientSideEncryption {
+		if revision.EncryptionVersion != models.EncryptionVersionClient {
+			c.JSON(http.StatusConflict, gin.H{"error": "This revision predates client-side encryption and cannot be restored"})
+			return
+		}
+		vault.EncryptedData = revision.EncryptedData
+		vault.EncryptionSalt = ""
+		vault.Nonce = ""
+		vault.EncryptionVersion = models.EncryptionVersionClient
+	} else {
+		data := h.openRevision(revision, vaultKey, req.MasterPassword)
+		if data == nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to decrypt revision"})
+			return
+		}
+
+		current := h.openEntry(vault, vaultKey, req.MasterPassword)
+		if current != nil && current.Password != "" && current.Password != data.Password {
+			history, err = h.historyService.Seal(vault.ID, vaultKey, current.Password, user.ID, now)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+				return
+			}
+		}
+
+		// The old password is held to the policy in force today.
+		restoredPassword := data.Password
+		if current != nil && current.Password == data.Password {
+			restoredPassword = ""
+		}
+		if !enforcePasswordPolicy(c, h.policyService, restoredPassword) {
+			return
+		}
+
+		dataJSON, err := json.Marshal(data)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare data"})
+			return
+		}
+		if err := h.vaultKeyService.SealEntry(vault, vaultKey, string(dataJSON)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+			return
+		}
+	}
+
+	expectedRevision := vault.Revision
+	vault.Title = revision.Title
+	vault.Website = revision.Website
+	vault.Username = revision.Username
+	vault.FolderID = revision.FolderID
+	vault.Folder = revision.Folder
+	vault.Tags = revision.Tags
+	vault.Favorite = revision.Favorite
+	vault.LastModifiedBy = &user.ID
+	vault.UpdatedAt = now
+	// The folder may have been renamed or deleted since.
+	if err := h.folderService.Refile(c.Request.Context(), user.ID, vault); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore the entry's folder"})
+		return
+	}
+
+	err = h.txManager.WithinTx(c.Request.Context(), func(repos *repository.TxRepositories) error {
+		updated, err := repos.Vaults.UpdateIfRevision(c.Request.Context(), vault, expectedRevision)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return errEntryModified
+		}
+		if history != nil {
+			return repos.PasswordHistory.Create(c.Request.Context(), history)
+		}
+		return nil
+	})
+	if errors.Is(err, errEntryModified) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The entry was modified at the same time; fetch it again and retry"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore revision"})
+		return
+	}
+
+	if history != nil {
+		if err := h.historyService.Prune(c.Request.Context(), vault.ID, now); err != nil {
+			log.Printf("Failed to prune password history of vault entry %s: %v", vault.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Revision restored successfully",
+		"restored_from": revision.Revision,
+		"revision":      vault.Revision,
+	})
+}
+
+// optionalUnlock unlocks a server-side account's vault when a master
+// password is given. It returns a nil key, and no error, when there is
+// nothing to unlock.
+func (h *VaultRevisionHandler) optionalUnlock(c *gin.Context, user *models.User, masterPassword string) ([]byte, bool) {
+	if masterPassword == "" || user.ClientSideEncryption {
+		return nil, true
+	}
+
+	vaultKey, err := h.vaultKeyService.UnlockUser(c.Request.Context(), user, masterPassword)
+	if err != nil {
+		respondUnlockError(c, err)
+		return nil, false
+	}
+	return vaultKey, true
+}
+
+func (h *VaultRevisionHandler) loadRevision(c *gin.Context, vault *models.Vault, number int) (*models.VaultRevision, bool) {
+	revision, err := h.revisionRepo.GetByRevision(c.Request.Context(), vault.ID, number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revision"})
+		return nil, false
+	}
+	if revision == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return nil, false
+	}
+	return revision, true
+}
+
+// openRevision decrypts a revision's payload, or returns nil without a key
+// or when the revision cannot be opened with it, as for revisions written
+// while the entry was in a collection or encrypted by a client.
+func (h *VaultRevisionHandler) openRevision(revision *models.VaultRevision, vaultKey []byte, masterPassword string) *models.DecryptedVaultData {
+	if revision.CollectionID != nil || revision.OrganizationID != nil {
+		return nil
+	}
+	return h.openEntry(revision.Entry(), vaultKey, masterPassword)
+}
+
+func (h *VaultRevisionHandler) openEntry(vault *models.Vault, vaultKey []byte, masterPassword string) *models.DecryptedVaultData {
+	if vaultKey == nil || vault.EncryptionVersion == models.EncryptionVersionClient {
+		return nil
+	}
+
+	plaintext, err := h.vaultKeyService.OpenEntry(vault, vaultKey, masterPassword)
+	if err != nil {
+		return nil
+	}
+	var data models.DecryptedVaultData
+	if err := json.Unmarshal([]byte(plaintext), &data); err != nil {
+		return nil
+	}
+	return &data
+}
+
+func (h *VaultRevisionHandler) toRevisionResponse(user *models.User, revision *models.VaultRevision, changes []models.FieldChange) models.VaultRevisionResponse {
+	if changes == nil {
+		changes = []models.FieldChange{}
+	}
+	response := models.VaultRevisionResponse{
+		Revision:  revision.Revision,
+		Title:     revision.Title,
+		ChangedBy: revision.ChangedBy,
+		CreatedAt: revision.CreatedAt,
+		Changes:   changes,
+	}
+	if user.ClientSideEncryption && revision.EncryptionVersion == models.EncryptionVersionClient {
+		response.EncryptedData = revision.EncryptedData
+	}
+	return response
+}
@@ -3,12 +3,26 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/repository"
 )
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+// Token scopes carried in the "scope" claim. Only ScopeAccess tokens may reach
+// protected routes; ScopeMFAPending tokens can only be exchanged at the
+// second-factor login endpoint.
+const (
+	ScopeAccess     = "access"
+	ScopeMFAPending = "mfa_pending"
+)
+
+// sessionTouchInterval limits how often a request updates last_seen_at.
+const sessionTouchInterval = time.Minute
+
+func AuthMiddleware(jwtSecret string, sessionRepo *repository.SessionRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -52,7 +66,38 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
+		if scope, _ := claims["scope"].(string); scope != ScopeAccess {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token not valid for this resource"})
+			c.Abort()
+			return
+		}
+
+		sid, _ := claims["sid"].(string)
+		sessionID, err := uuid.Parse(sid)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session in token"})
+			c.Abort()
+			return
+		}
+
+		session, err := sessionRepo.GetByID(c.Request.Context(), sessionID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify session"})
+			c.Abort()
+			return
+		}
+		if session == nil || !session.IsActive() || session.UserID.String() != userID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked or expired"})
+			c.Abort()
+			return
+		}
+
+		if time.Since(session.LastSeenAt) > sessionTouchInterval {
+			_ = sessionRepo.Touch(c.Request.Context(), session.ID, c.ClientIP())
+		}
+
 		c.Set("user_id", userID)
+		c.Set("session_id", session.ID.String())
 		c.Next()
 	}
 }
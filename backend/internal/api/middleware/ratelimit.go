@@ -18,11 +18,13 @@ type visitor struct {
 	count    int
 }
 
-var limiter = &rateLimiter{
-	visitors: make(map[string]*visitor),
-}
-
+// RateLimitMiddleware limits requests per client IP. Each call gets its own
+// counters so a strict per-route limit is not consumed by the global one.
 func RateLimitMiddleware(requestsPerMinute int) gin.HandlerFunc {
+	limiter := &rateLimiter{
+		visitors: make(map[string]*visitor),
+	}
+
 	go func() {
 		ticker := time.NewTicker(5 * time.Minute)
 		defer ticker.Stop()
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TransferDeadline gives a request timeout to read its body and write its
+// response, instead of the server's read and write timeouts, which are far
+// too short for large file transfers. A zero timeout leaves them in place.
+func TransferDeadline(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout > 0 {
+			deadline := time.Now().Add(timeout)
+			controller := http.NewResponseController(c.Writer)
+			if err := controller.SetReadDeadline(deadline); err != nil {
+				log.Printf("Failed to extend read deadline: %v", err)
+			}
+			if err := controller.SetWriteDeadline(deadline); err != nil {
+				log.Printf("Failed to extend write deadline: %v", err)
+			}
+		}
+
+		c.Next()
+	}
+}
@@ -1,20 +1,37 @@
 package api
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/tresor/password-manager/internal/api/handlers"
 	"github.com/tresor/password-manager/internal/api/middleware"
 	"github.com/tresor/password-manager/internal/config"
+	"github.com/tresor/password-manager/internal/repository"
 )
 
 type Router struct {
-	authHandler    *handlers.AuthHandler
-	vaultHandler   *handlers.VaultHandler
-	sharingHandler *handlers.SharingHandler
-	healthHandler  *handlers.HealthHandler
-	twoFAHandler   *handlers.TwoFAHandler
-	importHandler  *handlers.ImportHandler
-	jwtSecret      string
+	authHandler         *handlers.AuthHandler
+	vaultHandler        *handlers.VaultHandler
+	sharingHandler      *handlers.SharingHandler
+	healthHandler       *handlers.HealthHandler
+	twoFAHandler        *handlers.TwoFAHandler
+	importHandler       *handlers.ImportHandler
+	exportHandler       *handlers.ExportHandler
+	attachmentHandler   *handlers.AttachmentHandler
+	historyHandler      *handlers.PasswordHistoryHandler
+	revisionHandler     *handlers.VaultRevisionHandler
+	trashHandler        *handlers.TrashHandler
+	folderHandler       *handlers.FolderHandler
+	sessionHandler      *handlers.SessionHandler
+	clientVaultHandler  *handlers.ClientVaultHandler
+	publicShareHandler  *handlers.PublicShareHandler
+	collectionHandler   *handlers.CollectionHandler
+	organizationHandler *handlers.OrganizationHandler
+	emergencyHandler    *handlers.EmergencyAccessHandler
+	sessionRepo         *repository.SessionRepository
+	jwtSecret           string
+	transferTimeout     time.Duration
 }
 
 func NewRouter(
@@ -24,16 +41,43 @@ func NewRouter(
 	healthHandler *handlers.HealthHandler,
 	twoFAHandler *handlers.TwoFAHandler,
 	importHandler *handlers.ImportHandler,
+	exportHandler *handlers.ExportHandler,
+	attachmentHandler *handlers.AttachmentHandler,
+	historyHandler *handlers.PasswordHistoryHandler,
+	revisionHandler *handlers.VaultRevisionHandler,
+	trashHandler *handlers.TrashHandler,
+	folderHandler *handlers.FolderHandler,
+	sessionHandler *handlers.SessionHandler,
+	clientVaultHandler *handlers.ClientVaultHandler,
+	publicShareHandler *handlers.PublicShareHandler,
+	collectionHandler *handlers.CollectionHandler,
+	organizationHandler *handlers.OrganizationHandler,
+	emergencyHandler *handlers.EmergencyAccessHandler,
+	sessionRepo *repository.SessionRepository,
 	cfg *config.Config,
 ) *Router {
 	return &Router{
-		authHandler:    authHandler,
-		vaultHandler:   vaultHandler,
-		sharingHandler: sharingHandler,
-		healthHandler:  healthHandler,
-		twoFAHandler:   twoFAHandler,
-		importHandler:  importHandler,
-		jwtSecret:      cfg.JWT.Secret,
+		authHandler:         authHandler,
+		vaultHandler:        vaultHandler,
+		sharingHandler:      sharingHandler,
+		healthHandler:       healthHandler,
+		twoFAHandler:        twoFAHandler,
+		importHandler:       importHandler,
+		exportHandler:       exportHandler,
+		attachmentHandler:   attachmentHandler,
+		historyHandler:      historyHandler,
+		revisionHandler:     revisionHandler,
+		trashHandler:        trashHandler,
+		folderHandler:       folderHandler,
+		sessionHandler:      sessionHandler,
+		clientVaultHandler:  clientVaultHandler,
+		publicShareHandler:  publicShareHandler,
+		collectionHandler:   collectionHandler,
+		organizationHandler: organizationHandler,
+		emergencyHandler:    emergencyHandler,
+		sessionRepo:         sessionRepo,
+		jwtSecret:           cfg.JWT.Secret,
+		transferTimeout:     time.Duration(cfg.Attachments.TransferTimeoutMinutes) * time.Minute,
 	}
 }
 
@@ -54,12 +98,28 @@ func (r *Router) Setup() *gin.Engine {
 		{
 			auth.POST("/register", r.authHandler.Register)
 			auth.POST("/login", middleware.RateLimitMiddleware(5), r.authHandler.Login)
+			auth.POST("/login/2fa", middleware.RateLimitMiddleware(5), r.authHandler.LoginTwoFactor)
+			auth.POST("/refresh", middleware.RateLimitMiddleware(30), r.authHandler.RefreshToken)
 			auth.POST("/request-deletion", r.authHandler.RequestAccountDeletion)
+			auth.POST("/pake/register", r.authHandler.PAKERegister)
+			auth.POST("/pake/login/init", middleware.RateLimitMiddleware(5), r.authHandler.PAKELoginInit)
+			auth.POST("/pake/login/verify", middleware.RateLimitMiddleware(5), r.authHandler.PAKELoginVerify)
+		}
+
+		public := v1.Group("/public")
+		public.Use(middleware.RateLimitMiddleware(10))
+		{
+			public.GET("/share/:token", r.publicShareHandler.GetShareInfo)
+			public.POST("/share/:token", r.publicShareHandler.OpenShare)
 		}
 
 		protected := v1.Group("")
-		protected.Use(middleware.AuthMiddleware(r.jwtSecret))
+		protected.Use(middleware.AuthMiddleware(r.jwtSecret, r.sessionRepo))
 		{
+			protected.POST("/auth/logout", r.authHandler.Logout)
+			protected.POST("/auth/change-master-password", middleware.RateLimitMiddleware(5), r.authHandler.ChangeMasterPassword)
+			protected.POST("/auth/pake/enroll", middleware.RateLimitMiddleware(5), r.authHandler.PAKEEnroll)
+
 			vault := protected.Group("/vault")
 			{
 				vault.POST("", r.vaultHandler.CreateVault)
@@ -69,6 +129,42 @@ func (r *Router) Setup() *gin.Engine {
 				vault.DELETE("/:id", r.vaultHandler.DeleteVault)
 				vault.POST("/generate-password", r.vaultHandler.GeneratePassword)
 				vault.POST("/scan-all", r.healthHandler.ScanAllPasswords)
+				vault.POST("/export", middleware.RateLimitMiddleware(5), r.exportHandler.ExportVault)
+				vault.POST("/:id/attachments", middleware.TransferDeadline(r.transferTimeout), r.attachmentHandler.UploadAttachment)
+				vault.GET("/:id/attachments", r.attachmentHandler.ListAttachments)
+				vault.GET("/:id/attachments/:attachmentId", middleware.TransferDeadline(r.transferTimeout), r.attachmentHandler.DownloadAttachment)
+				vault.DELETE("/:id/attachments/:attachmentId", r.attachmentHandler.DeleteAttachment)
+				vault.GET("/:id/history", r.historyHandler.GetHistory)
+				vault.POST("/:id/history/:historyId/restore", r.historyHandler.RestorePassword)
+				vault.GET("/:id/revisions", r.revisionHandler.ListRevisions)
+				vault.GET("/:id/revisions/diff", r.revisionHandler.DiffRevisions)
+				vault.POST("/:id/revisions/:revision/restore", r.revisionHandler.RestoreRevision)
+				vault.GET("/trash", r.trashHandler.ListTrash)
+				vault.DELETE("/trash", r.trashHandler.EmptyTrash)
+				vault.POST("/:id/restore", r.trashHandler.RestoreEntry)
+				vault.DELETE("/:id/permanent", r.trashHandler.DeleteEntry)
+			}
+
+			folders := protected.Group("/folders")
+			{
+				folders.GET("", r.folderHandler.ListFolders)
+				folders.POST("", r.folderHandler.CreateFolder)
+				folders.PUT("/:id", r.folderHandler.UpdateFolder)
+				folders.DELETE("/:id", r.folderHandler.DeleteFolder)
+			}
+
+			zk := protected.Group("/zk")
+			{
+				zk.POST("/enable", middleware.RateLimitMiddleware(5), r.clientVaultHandler.EnableClientEncryption)
+				zk.GET("/keys", r.clientVaultHandler.GetProtectedKey)
+				zk.PUT("/keys", middleware.RateLimitMiddleware(5), r.clientVaultHandler.UpdateProtectedKey)
+				zk.GET("/vault", r.clientVaultHandler.ListEntries)
+				zk.POST("/vault", r.clientVaultHandler.CreateEntry)
+				zk.GET("/vault/:id", r.clientVaultHandler.GetEntry)
+				zk.PUT("/vault/:id", r.clientVaultHandler.UpdateEntry)
+				zk.DELETE("/vault/:id", r.clientVaultHandler.DeleteEntry)
+				zk.GET("/shared-edits", r.clientVaultHandler.ListSharedEdits)
+				zk.DELETE("/shared-edits/:id", r.clientVaultHandler.DismissSharedEdit)
 			}
 
 			health := protected.Group("/health")
@@ -80,20 +176,83 @@ func (r *Router) Setup() *gin.Engine {
 			password := protected.Group("/password")
 			{
 				password.POST("/check-breach", r.healthHandler.CheckPasswordBreach)
+				password.GET("/policy", r.healthHandler.GetPasswordPolicy)
 			}
 
 			share := protected.Group("/share")
 			{
 				share.POST("", r.sharingHandler.SharePassword)
+				share.GET("/recipient-key", r.sharingHandler.GetRecipientKey)
+				share.POST("/invitations/accept", r.sharingHandler.AcceptShareInvitation)
+				share.POST("/link", r.sharingHandler.CreateLinkShare)
 			}
 
 			shared := protected.Group("/shared")
 			{
 				shared.GET("", r.sharingHandler.ListShares)
 				shared.GET("/:token", r.sharingHandler.GetSharedPassword)
+				shared.PUT("/:token", r.sharingHandler.UpdateSharedPassword)
+				shared.GET("/:token/access-log", r.sharingHandler.GetAccessLog)
 				shared.POST("/:token/revoke", r.sharingHandler.RevokeShare)
 			}
 
+			collections := protected.Group("/collections")
+			{
+				collections.POST("", r.collectionHandler.CreateCollection)
+				collections.GET("", r.collectionHandler.ListCollections)
+				collections.GET("/:id", r.collectionHandler.GetCollection)
+				collections.PUT("/:id", r.collectionHandler.UpdateCollection)
+				collections.DELETE("/:id", r.collectionHandler.DeleteCollection)
+				collections.POST("/:id/members", r.collectionHandler.AddMember)
+				collections.PUT("/:id/members/:userId", r.collectionHandler.UpdateMember)
+				collections.DELETE("/:id/members/:userId", r.collectionHandler.RemoveMember)
+				collections.GET("/:id/vault", r.collectionHandler.ListEntries)
+				collections.POST("/:id/vault", r.collectionHandler.CreateEntry)
+				collections.GET("/:id/vault/:entryId", r.collectionHandler.GetEntry)
+				collections.PUT("/:id/vault/:entryId", r.collectionHandler.UpdateEntry)
+				collections.DELETE("/:id/vault/:entryId", r.collectionHandler.DeleteEntry)
+			}
+
+			organizations := protected.Group("/organizations")
+			{
+				organizations.POST("", r.organizationHandler.CreateOrganization)
+				organizations.GET("", r.organizationHandler.ListOrganizations)
+				organizations.GET("/invitations", r.organizationHandler.ListMyInvitations)
+				organizations.POST("/invitations/accept", r.organizationHandler.AcceptInvitation)
+				organizations.GET("/:id", r.organizationHandler.GetOrganization)
+				organizations.PUT("/:id", r.organizationHandler.UpdateOrganization)
+				organizations.DELETE("/:id", r.organizationHandler.DeleteOrganization)
+				organizations.PUT("/:id/policy", r.organizationHandler.UpdatePolicy)
+				organizations.GET("/:id/collections", r.organizationHandler.ListCollections)
+				organizations.POST("/:id/invitations", r.organizationHandler.InviteMember)
+				organizations.GET("/:id/invitations", r.organizationHandler.ListInvitations)
+				organizations.DELETE("/:id/invitations/:invitationId", r.organizationHandler.RevokeInvitation)
+				organizations.POST("/:id/members/:userId/confirm", r.organizationHandler.ConfirmMember)
+				organizations.PUT("/:id/members/:userId", r.organizationHandler.UpdateMember)
+				organizations.DELETE("/:id/members/:userId", r.organizationHandler.RemoveMember)
+				organizations.GET("/:id/vault", r.organizationHandler.ListEntries)
+				organizations.POST("/:id/vault", r.organizationHandler.CreateEntry)
+				organizations.GET("/:id/vault/:entryId", r.organizationHandler.GetEntry)
+				organizations.PUT("/:id/vault/:entryId", r.organizationHandler.UpdateEntry)
+				organizations.DELETE("/:id/vault/:entryId", r.organizationHandler.DeleteEntry)
+			}
+
+			emergency := protected.Group("/emergency-access")
+			{
+				emergency.POST("", r.emergencyHandler.InviteContact)
+				emergency.GET("/trusted", r.emergencyHandler.ListTrustedContacts)
+				emergency.GET("/granted", r.emergencyHandler.ListGrantedAccess)
+				emergency.POST("/accept", r.emergencyHandler.AcceptInvitation)
+				emergency.PUT("/:id", r.emergencyHandler.UpdateAccess)
+				emergency.DELETE("/:id", r.emergencyHandler.DeleteAccess)
+				emergency.POST("/:id/confirm", r.emergencyHandler.ConfirmContact)
+				emergency.POST("/:id/initiate", r.emergencyHandler.InitiateRecovery)
+				emergency.POST("/:id/approve", r.emergencyHandler.ApproveRecovery)
+				emergency.POST("/:id/reject", r.emergencyHandler.RejectRecovery)
+				emergency.GET("/:id/vault", r.emergencyHandler.ViewVault)
+				emergency.POST("/:id/takeover", middleware.RateLimitMiddleware(5), r.emergencyHandler.Takeover)
+			}
+
 			twofa := protected.Group("/2fa")
 			{
 				twofa.POST("/enable", r.twoFAHandler.Enable2FA)
@@ -102,6 +261,13 @@ func (r *Router) Setup() *gin.Engine {
 				twofa.POST("/disable", r.twoFAHandler.Disable2FA)
 			}
 
+			sessions := protected.Group("/sessions")
+			{
+				sessions.GET("", r.sessionHandler.ListSessions)
+				sessions.DELETE("/:id", r.sessionHandler.RevokeSession)
+				sessions.POST("/revoke-others", r.sessionHandler.RevokeOtherSessions)
+			}
+
 			importRoutes := protected.Group("/import")
 			{
 				importRoutes.POST("/upload", r.importHandler.UploadFile)
@@ -9,12 +9,18 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
-	Email    EmailConfig
-	HIBP     HIBPConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	Redis       RedisConfig
+	JWT         JWTConfig
+	Email       EmailConfig
+	HIBP        HIBPConfig
+	Crypto      CryptoConfig
+	Sharing     SharingConfig
+	Policy      PolicyConfig
+	Attachments AttachmentsConfig
+	History     HistoryConfig
+	Trash       TrashConfig
 }
 
 type ServerConfig struct {
@@ -39,8 +45,9 @@ type RedisConfig struct {
 }
 
 type JWTConfig struct {
-	Secret     string
-	ExpireTime int
+	Secret              string
+	AccessExpireMinutes int
+	RefreshExpireHours  int
 }
 
 type EmailConfig struct {
@@ -55,6 +62,70 @@ type HIBPConfig struct {
 	APIKey string
 }
 
+// CryptoConfig holds the Argon2id cost used for new password-derived keys.
+// Existing data records the parameters it was created with, so these can be
+// raised at any time.
+type CryptoConfig struct {
+	Argon2Iterations  int
+	Argon2MemoryKiB   int
+	Argon2Parallelism int
+}
+
+// SharingConfig holds the key that escrows shares sent to people without an
+// account until they register. InvitationKey is a base64 X25519 private key;
+// when empty one is derived from the JWT secret.
+type SharingConfig struct {
+	InvitationKey string
+}
+
+// PolicyConfig is the instance-wide password policy applied to every user,
+// on top of which organizations can add stricter rules. Zero values disable
+// a rule.
+type PolicyConfig struct {
+	MinLength      int
+	RequireLower   bool
+	RequireUpper   bool
+	RequireDigit   bool
+	RequireSpecial bool
+	MaxAgeDays     int
+	ForbidBreached bool
+	Require2FA     bool
+}
+
+// AttachmentsConfig selects where encrypted attachment chunks are stored and
+// bounds their size. Storage is "local" (files under LocalPath) or "s3" (any
+// S3-compatible service; S3PathStyle is needed by most self-hosted ones).
+// TransferTimeoutMinutes replaces the server's read and write timeouts for
+// uploads and downloads, which take longer than any other request.
+type AttachmentsConfig struct {
+	Storage                string
+	LocalPath              string
+	MaxFileSizeMB          int
+	UserQuotaMB            int
+	ChunkSizeKiB           int
+	TransferTimeoutMinutes int
+	S3Endpoint             string
+	S3Region               string
+	S3Bucket               string
+	S3AccessKeyID          string
+	S3SecretAccessKey      string
+	S3PathStyle            bool
+}
+
+// HistoryConfig bounds the password history kept for each entry. A record
+// is dropped once there are more than MaxEntries newer ones or it is older
+// than MaxAgeDays; zero disables a limit.
+type HistoryConfig struct {
+	MaxEntries int
+	MaxAgeDays int
+}
+
+// TrashConfig sets how long deleted entries stay in the trash before they
+// are purged for good; zero keeps them until the trash is emptied.
+type TrashConfig struct {
+	RetentionDays int
+}
+
 func Load() (*Config, error) {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -71,6 +142,21 @@ func Load() (*Config, error) {
 	viper.SetDefault("database.dbname", "password_manager")
 	viper.SetDefault("redis.host", "localhost")
 	viper.SetDefault("redis.port", "6379")
+	viper.SetDefault("jwt.access_expire_minutes", 15)
+	viper.SetDefault("jwt.refresh_expire_hours", 720)
+	viper.SetDefault("crypto.argon2_iterations", 3)
+	viper.SetDefault("crypto.argon2_memory_kib", 64*1024)
+	viper.SetDefault("crypto.argon2_parallelism", 4)
+	viper.SetDefault("attachments.storage", "local")
+	viper.SetDefault("attachments.local_path", "./data/attachments")
+	viper.SetDefault("attachments.max_file_size_mb", 100)
+	viper.SetDefault("attachments.user_quota_mb", 1024)
+	viper.SetDefault("attachments.chunk_size_kib", 1024)
+	viper.SetDefault("attachments.transfer_timeout_minutes", 30)
+	viper.SetDefault("attachments.s3_region", "us-east-1")
+	viper.SetDefault("history.max_entries", 20)
+	viper.SetDefault("history.max_age_days", 365)
+	viper.SetDefault("trash.retention_days", 30)
 
 	if err := viper.ReadInConfig(); err != nil {
 		log.Printf("Warning: Config file not found, using defaults and environment variables: %v", err)
@@ -101,8 +187,9 @@ func Load() (*Config, error) {
 			DB:       getEnvIntOrDefault("REDIS_DB", viper.GetInt("redis.db")),
 		},
 		JWT: JWTConfig{
-			Secret:     getEnvOrDefault("JWT_SECRET", viper.GetString("jwt.secret")),
-			ExpireTime: getEnvIntOrDefault("JWT_EXPIRE_TIME", viper.GetInt("jwt.expire_time")),
+			Secret:              getEnvOrDefault("JWT_SECRET", viper.GetString("jwt.secret")),
+			AccessExpireMinutes: getEnvIntOrDefault("JWT_ACCESS_EXPIRE_MINUTES", viper.GetInt("jwt.access_expire_minutes")),
+			RefreshExpireHours:  getEnvIntOrDefault("JWT_REFRESH_EXPIRE_HOURS", viper.GetInt("jwt.refresh_expire_hours")),
 		},
 		Email: EmailConfig{
 			Host:     getEnvOrDefault("EMAIL_HOST", viper.GetString("email.host")),
@@ -114,6 +201,45 @@ func Load() (*Config, error) {
 		HIBP: HIBPConfig{
 			APIKey: getEnvOrDefault("HIBP_API_KEY", viper.GetString("hibp.api_key")),
 		},
+		Crypto: CryptoConfig{
+			Argon2Iterations:  getEnvIntOrDefault("CRYPTO_ARGON2_ITERATIONS", viper.GetInt("crypto.argon2_iterations")),
+			Argon2MemoryKiB:   getEnvIntOrDefault("CRYPTO_ARGON2_MEMORY_KIB", viper.GetInt("crypto.argon2_memory_kib")),
+			Argon2Parallelism: getEnvIntOrDefault("CRYPTO_ARGON2_PARALLELISM", viper.GetInt("crypto.argon2_parallelism")),
+		},
+		Sharing: SharingConfig{
+			InvitationKey: getEnvOrDefault("SHARING_INVITATION_KEY", viper.GetString("sharing.invitation_key")),
+		},
+		Policy: PolicyConfig{
+			MinLength:      getEnvIntOrDefault("POLICY_MIN_LENGTH", viper.GetInt("policy.min_length")),
+			RequireLower:   getEnvBoolOrDefault("POLICY_REQUIRE_LOWER", viper.GetBool("policy.require_lower")),
+			RequireUpper:   getEnvBoolOrDefault("POLICY_REQUIRE_UPPER", viper.GetBool("policy.require_upper")),
+			RequireDigit:   getEnvBoolOrDefault("POLICY_REQUIRE_DIGIT", viper.GetBool("policy.require_digit")),
+			RequireSpecial: getEnvBoolOrDefault("POLICY_REQUIRE_SPECIAL", viper.GetBool("policy.require_special")),
+			MaxAgeDays:     getEnvIntOrDefault("POLICY_MAX_AGE_DAYS", viper.GetInt("policy.max_age_days")),
+			ForbidBreached: getEnvBoolOrDefault("POLICY_FORBID_BREACHED", viper.GetBool("policy.forbid_breached")),
+			Require2FA:     getEnvBoolOrDefault("POLICY_REQUIRE_2FA", viper.GetBool("policy.require_2fa")),
+		},
+		Attachments: AttachmentsConfig{
+			Storage:                getEnvOrDefault("ATTACHMENTS_STORAGE", viper.GetString("attachments.storage")),
+			LocalPath:              getEnvOrDefault("ATTACHMENTS_LOCAL_PATH", viper.GetString("attachments.local_path")),
+			MaxFileSizeMB:          getEnvIntOrDefault("ATTACHMENTS_MAX_FILE_SIZE_MB", viper.GetInt("attachments.max_file_size_mb")),
+			UserQuotaMB:            getEnvIntOrDefault("ATTACHMENTS_USER_QUOTA_MB", viper.GetInt("attachments.user_quota_mb")),
+			ChunkSizeKiB:           getEnvIntOrDefault("ATTACHMENTS_CHUNK_SIZE_KIB", viper.GetInt("attachments.chunk_size_kib")),
+			TransferTimeoutMinutes: getEnvIntOrDefault("ATTACHMENTS_TRANSFER_TIMEOUT_MINUTES", viper.GetInt("attachments.transfer_timeout_minutes")),
+			S3Endpoint:             getEnvOrDefault("ATTACHMENTS_S3_ENDPOINT", viper.GetString("attachments.s3_endpoint")),
+			S3Region:               getEnvOrDefault("ATTACHMENTS_S3_REGION", viper.GetString("attachments.s3_region")),
+			S3Bucket:               getEnvOrDefault("ATTACHMENTS_S3_BUCKET", viper.GetString("attachments.s3_bucket")),
+			S3AccessKeyID:          getEnvOrDefault("ATTACHMENTS_S3_ACCESS_KEY_ID", viper.GetString("attachments.s3_access_key_id")),
+			S3SecretAccessKey:      getEnvOrDefault("ATTACHMENTS_S3_SECRET_ACCESS_KEY", viper.GetString("attachments.s3_secret_access_key")),
+			S3PathStyle:            getEnvBoolOrDefault("ATTACHMENTS_S3_PATH_STYLE", viper.GetBool("attachments.s3_path_style")),
+		},
+		History: HistoryConfig{
+			MaxEntries: getEnvIntOrDefault("HISTORY_MAX_ENTRIES", viper.GetInt("history.max_entries")),
+			MaxAgeDays: getEnvIntOrDefault("HISTORY_MAX_AGE_DAYS", viper.GetInt("history.max_age_days")),
+		},
+		Trash: TrashConfig{
+			RetentionDays: getEnvIntOrDefault("TRASH_RETENTION_DAYS", viper.GetInt("trash.retention_days")),
+		},
 	}
 
 	if config.Database.DBName == "" {
@@ -138,3 +264,12 @@ func getEnvIntOrDefault(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvBoolOrDefault(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
@@ -50,6 +50,58 @@ func NewPostgresDB(cfg *config.DatabaseConfig) (*sqlx.DB, error) {
 	return db, nil
 }
 
+// expressionIndexes back the sort orders of the vault listing. AutoMigrate
+// cannot declare expression indexes, so they are created after it.
+var expressionIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_vaults_user_created ON vaults(user_id, created_at, id)",
+	"CREATE INDEX IF NOT EXISTS idx_vaults_user_updated ON vaults(user_id, updated_at, id)",
+	"CREATE INDEX IF NOT EXISTS idx_vaults_user_title ON vaults(user_id, LOWER(title), id)",
+	"CREATE INDEX IF NOT EXISTS idx_vaults_user_last_used ON vaults(user_id, COALESCE(last_used, to_timestamp(0)), id)",
+}
+
+// uniqueIndexes are expression indexes that enforce an invariant rather than
+// speed up queries: folder names are unique among siblings, ignoring case.
+var uniqueIndexes = []string{
+	"CREATE UNIQUE INDEX IF NOT EXISTS idx_folders_user_parent_name ON folders(user_id, COALESCE(parent_id, '00000000-0000-0000-0000-000000000000'), LOWER(name))",
+}
+
+// trigramIndexes back the free-text search of the vault listing; they need
+// the pg_trgm extension.
+var trigramIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_vaults_title_trgm ON vaults USING gin (title gin_trgm_ops)",
+	"CREATE INDEX IF NOT EXISTS idx_vaults_website_trgm ON vaults USING gin (website gin_trgm_ops)",
+	"CREATE INDEX IF NOT EXISTS idx_vaults_username_trgm ON vaults USING gin (username gin_trgm_ops)",
+}
+
+// createIndexes creates the indexes AutoMigrate cannot. The unique indexes
+// are required and their failure is returned. The server works without the
+// others, only slower, so their failures are logged rather than fatal: the
+// database user may not be allowed to create the pg_trgm extension.
+func createIndexes(db *gorm.DB) error {
+	for _, statement := range uniqueIndexes {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, statement := range expressionIndexes {
+		if err := db.Exec(statement).Error; err != nil {
+			log.Printf("Warning: failed to create index: %v", err)
+		}
+	}
+
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		log.Printf("Warning: pg_trgm is not available, vault search will not use trigram indexes: %v", err)
+		return nil
+	}
+	for _, statement := range trigramIndexes {
+		if err := db.Exec(statement).Error; err != nil {
+			log.Printf("Warning: failed to create vault search index: %v", err)
+		}
+	}
+	return nil
+}
+
 // NewGormDB creates a new GORM database connection with auto-migration
 func NewGormDB(cfg *config.DatabaseConfig) (*gorm.DB, error) {
 	// Log config values for debugging
@@ -69,7 +121,8 @@ func NewGormDB(cfg *config.DatabaseConfig) (*gorm.DB, error) {
 
 	// Open GORM connection
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger:         logger.Default.LogMode(logger.Info),
+		TranslateError: true,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database with GORM: %w", err)
@@ -80,12 +133,30 @@ func NewGormDB(cfg *config.DatabaseConfig) (*gorm.DB, error) {
 	// Auto-migrate tables
 	if err := db.AutoMigrate(
 		&models.User{},
+		&models.Folder{},
 		&models.Vault{},
 		&models.SharedPassword{},
+		&models.Session{},
+		&models.MFAChallenge{},
+		&models.SharedEdit{},
+		&models.ShareAccessLog{},
+		&models.Collection{},
+		&models.CollectionMember{},
+		&models.Organization{},
+		&models.OrganizationMember{},
+		&models.OrganizationInvitation{},
+		&models.EmergencyAccess{},
+		&models.Attachment{},
+		&models.PasswordHistory{},
+		&models.VaultRevision{},
 	); err != nil {
 		return nil, fmt.Errorf("failed to run auto-migrations: %w", err)
 	}
 
+	if err := createIndexes(db); err != nil {
+		return nil, fmt.Errorf("failed to create indexes: %w", err)
+	}
+
 	log.Println("Database migrations completed successfully")
 
 	// Configure connection pool
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Attachment is a file attached to a vault entry. Its content is split into
+// chunks of ChunkSize bytes, each stored as a separate blob.
+//
+// For server-side accounts every chunk is encrypted with a random per-file
+// key, and EncryptedKey is that key wrapped by the entry's key (the owner's
+// vault key). Zero-knowledge clients upload a file they encrypted themselves
+// with their own wrapped key, and EncryptionVersion is EncryptionVersionClient.
+//
+// The record is created Pending before its chunks are uploaded, so that its
+// size counts against the quota from the start; it is only listed once the
+// upload completes.
+type Attachment struct {
+	ID                uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	VaultID           uuid.UUID `gorm:"type:uuid;not null;index" json:"vault_id"`
+	UserID            uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	FileName          string    `gorm:"not null" json:"file_name"`
+	ContentType       string    `gorm:"not null" json:"content_type"`
+	Size              int64     `gorm:"not null" json:"size"`
+	ChunkSize         int       `gorm:"not null" json:"-"`
+	ChunkCount        int       `gorm:"not null" json:"-"`
+	EncryptedKey      string    `gorm:"not null" json:"-"`
+	EncryptionVersion int       `gorm:"not null" json:"-"`
+	Pending           bool      `gorm:"not null;default:false" json:"-"`
+	CreatedAt         time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	// Relations
+	Vault Vault `gorm:"foreignKey:VaultID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// TableName specifies the table name for GORM
+func (Attachment) TableName() string {
+	return "attachments"
+}
+
+// IsClientEncrypted reports whether the attachment was encrypted by a
+// zero-knowledge client, in which case the server stores it as is.
+func (a *Attachment) IsClientEncrypted() bool {
+	return a.EncryptionVersion == EncryptionVersionClient
+}
+
+// AttachmentResponse describes an attachment. EncryptedKey is only returned
+// to zero-knowledge clients, which decrypt the file themselves.
+type AttachmentResponse struct {
+	ID           uuid.UUID `json:"id"`
+	VaultID      uuid.UUID `json:"vault_id"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	EncryptedKey string    `json:"encrypted_key,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Collection member roles, from least to most privileged.
+const (
+	// CollectionRoleViewer members can read entries.
+	CollectionRoleViewer = "viewer"
+	// CollectionRoleEditor members can also create, change and delete entries.
+	CollectionRoleEditor = "editor"
+	// CollectionRoleManager members can also manage members and the
+	// collection itself.
+	CollectionRoleManager = "manager"
+)
+
+// Collection is a shared folder: every entry in it is readable by all of its
+// members. Entries are encrypted with a random collection key, and each
+// member holds that key sealed to their own public key. Removing a member
+// replaces the key. The creator owns the collection and cannot be removed.
+// A collection that belongs to an organization only admits its members.
+type Collection struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	OwnerID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"owner_id"`
+	OrganizationID *uuid.UUID `gorm:"type:uuid;index" json:"organization_id,omitempty"`
+	Name           string     `gorm:"not null" json:"name"`
+	KeyVersion     int        `gorm:"not null;default:1" json:"key_version"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Relations
+	Owner User `gorm:"foreignKey:OwnerID" json:"-"`
+}
+
+// TableName specifies the table name for GORM
+func (Collection) TableName() string {
+	return "collections"
+}
+
+// CollectionMember grants a user access to a collection. EncryptedKey is the
+// collection key sealed to the member's public key. CanView and CanCopy work
+// as on a SharedPassword and only restrict what the server reveals.
+type CollectionMember struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	CollectionID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_collection_member" json:"collection_id"`
+	UserID       uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_collection_member;index" json:"user_id"`
+	Role         string    `gorm:"not null;default:'viewer'" json:"role"`
+	CanView      bool      `gorm:"default:true" json:"can_view"`
+	CanCopy      bool      `gorm:"default:true" json:"can_copy"`
+	EncryptedKey string    `gorm:"not null" json:"-"`
+	KeyVersion   int       `gorm:"not null;default:1" json:"key_version"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Relations
+	Collection Collection `gorm:"foreignKey:CollectionID" json:"-"`
+	User       User       `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// TableName specifies the table name for GORM
+func (CollectionMember) TableName() string {
+	return "collection_members"
+}
+
+// CanEdit reports whether the member may write entries.
+func (m *CollectionMember) CanEdit() bool {
+	return m.Role == CollectionRoleEditor || m.Role == CollectionRoleManager
+}
+
+// CanManage reports whether the member may manage members and the collection.
+func (m *CollectionMember) CanManage() bool {
+	return m.Role == CollectionRoleManager
+}
+
+// IsValidCollectionRole reports whether role is a known member role.
+func IsValidCollectionRole(role string) bool {
+	switch role {
+	case CollectionRoleViewer, CollectionRoleEditor, CollectionRoleManager:
+		return true
+	}
+	return false
+}
+
+// MemberKeyUpload is a collection or org key sealed by a zero-knowledge
+// client to one member's public key.
+type MemberKeyUpload struct {
+	UserID       uuid.UUID `json:"user_id" binding:"required"`
+	EncryptedKey string    `json:"encrypted_key" binding:"required"`
+}
+
+// CreateCollectionRequest creates a collection. Server-side accounts send
+// their MasterPassword and the server generates the key; zero-knowledge
+// accounts generate it and send it sealed to their own public key. Admins of
+// an organization set OrganizationID to create it in the organization.
+type CreateCollectionRequest struct {
+	Name           string     `json:"name" binding:"required"`
+	OrganizationID *uuid.UUID `json:"organization_id"`
+	MasterPassword string     `json:"master_password"`
+	EncryptedKey   *string    `json:"encrypted_key"`
+}
+
+type UpdateCollectionRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// AddCollectionMemberRequest adds a user by email. The collection key is
+// sealed to the new member's public key: by the server with the manager's
+// MasterPassword, or by a zero-knowledge manager's client (EncryptedKey).
+type AddCollectionMemberRequest struct {
+	Email          string  `json:"email" binding:"required,email"`
+	Role           string  `json:"role" binding:"required"`
+	CanView        *bool   `json:"can_view"`
+	CanCopy        *bool   `json:"can_copy"`
+	MasterPassword string  `json:"master_password"`
+	EncryptedKey   *string `json:"encrypted_key"`
+}
+
+type UpdateCollectionMemberRequest struct {
+	Role    string `json:"role" binding:"required"`
+	CanView *bool  `json:"can_view"`
+	CanCopy *bool  `json:"can_copy"`
+}
+
+// RemoveCollectionMemberRequest removes a member and replaces the collection
+// key. Server-side accounts send their MasterPassword; zero-knowledge clients
+// send the new key sealed to every remaining member (Keys) and every entry
+// re-encrypted under it (Entries).
+type RemoveCollectionMemberRequest struct {
+	MasterPassword string              `json:"master_password"`
+	Keys           []MemberKeyUpload   `json:"keys" binding:"dive"`
+	Entries        []ClientEntryUpload `json:"entries" binding:"dive"`
+}
+
+// CollectionEntryRequest creates or updates an entry in a collection.
+// Server-side accounts send Password, Notes and their MasterPassword;
+// zero-knowledge clients send EncryptedData, an envelope sealed with the
+// collection key. VaultID moves an existing personal entry into the
+// collection instead of creating one. On update, Revision must match.
+// Type only applies to new entries and defaults to ItemTypeLogin; Fields and
+// the VaultItemData members replace the stored ones when set.
+type CollectionEntryRequest struct {
+	VaultID        *uuid.UUID    `json:"vault_id"`
+	Type           string        `json:"type"`
+	Title          string        `json:"title"`
+	Website        *string       `json:"website"`
+	Username       *string       `json:"username"`
+	Password       *string       `json:"password"`
+	Notes          *string       `json:"notes"`
+	Folder         *string       `json:"folder"`
+	MasterPassword string        `json:"master_password"`
+	EncryptedData  *string       `json:"encrypted_data"`
+	Revision       int           `json:"revision"`
+	Fields         []CustomField `json:"fields"`
+	VaultItemData
+}
+
+// CollectionResponse describes a collection as seen by one member.
+// EncryptedKey is that member's sealed copy of the collection key.
+type CollectionResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	OwnerID        uuid.UUID  `json:"owner_id"`
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+	Name           string     `json:"name"`
+	Role           string     `json:"role"`
+	CanView        bool       `json:"can_view"`
+	CanCopy        bool       `json:"can_copy"`
+	CanEdit        bool       `json:"can_edit"`
+	CanManage      bool       `json:"can_manage"`
+	KeyVersion     int        `json:"key_version"`
+	EncryptedKey   string     `json:"encrypted_key"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type CollectionMemberResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	PublicKey string    `json:"public_key"`
+	Role      string    `json:"role"`
+	CanView   bool      `json:"can_view"`
+	CanCopy   bool      `json:"can_copy"`
+	CreatedAt time.Time `json:"created_at"`
+}
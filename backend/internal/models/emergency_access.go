@@ -0,0 +1,150 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Emergency access types.
+const (
+	// EmergencyAccessView lets the trusted contact read the grantor's vault.
+	EmergencyAccessView = "view"
+	// EmergencyAccessTakeover also lets them set a new master password on the
+	// grantor's account.
+	EmergencyAccessTakeover = "takeover"
+)
+
+// Emergency access statuses, in the order a grant goes through them.
+const (
+	// EmergencyAccessInvited grants wait for the trusted contact to accept.
+	EmergencyAccessInvited = "invited"
+	// EmergencyAccessAccepted grants wait for the grantor to seal their vault
+	// key to the trusted contact.
+	EmergencyAccessAccepted = "accepted"
+	// EmergencyAccessConfirmed grants can be used to request access.
+	EmergencyAccessConfirmed = "confirmed"
+	// EmergencyAccessRecoveryInitiated grants have a pending request that the
+	// grantor can reject until the waiting period is over.
+	EmergencyAccessRecoveryInitiated = "recovery_initiated"
+	// EmergencyAccessRecoveryApproved grants give the trusted contact access.
+	EmergencyAccessRecoveryApproved = "recovery_approved"
+)
+
+// Bounds of the waiting period a grantor can choose, in days.
+const (
+	EmergencyAccessMinWaitDays     = 1
+	EmergencyAccessMaxWaitDays     = 90
+	EmergencyAccessDefaultWaitDays = 7
+)
+
+// EmergencyAccess designates a trusted contact (grantee) who can ask for
+// access to the grantor's vault. The grantor's vault key is sealed to the
+// grantee's public key once the grantor confirms them, but the server only
+// hands it out after the grantor approves a request or lets the waiting
+// period run out without rejecting it.
+type EmergencyAccess struct {
+	ID                  uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	GrantorID           uuid.UUID  `gorm:"type:uuid;not null;index" json:"grantor_id"`
+	GranteeID           *uuid.UUID `gorm:"type:uuid;index" json:"grantee_id,omitempty"`
+	Email               string     `gorm:"not null;index" json:"email"`
+	Type                string     `gorm:"not null" json:"type"`
+	Status              string     `gorm:"not null;default:'invited'" json:"status"`
+	WaitDays            int        `gorm:"not null" json:"wait_days"`
+	TokenHash           *string    `gorm:"uniqueIndex" json:"-"`
+	EncryptedKey        string     `json:"-"`
+	RecoveryInitiatedAt *time.Time `json:"recovery_initiated_at,omitempty"`
+	CreatedAt           time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt           time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Relations
+	Grantor User  `gorm:"foreignKey:GrantorID" json:"-"`
+	Grantee *User `gorm:"foreignKey:GranteeID" json:"-"`
+}
+
+// TableName specifies the table name for GORM
+func (EmergencyAccess) TableName() string {
+	return "emergency_access"
+}
+
+// IsConfirmed reports whether the grantee holds the sealed vault key, i.e.
+// whether the grant is confirmed or further along.
+func (e *EmergencyAccess) IsConfirmed() bool {
+	switch e.Status {
+	case EmergencyAccessConfirmed, EmergencyAccessRecoveryInitiated, EmergencyAccessRecoveryApproved:
+		return true
+	}
+	return false
+}
+
+// RecoveryDueAt is when a pending request is approved automatically.
+func (e *EmergencyAccess) RecoveryDueAt() *time.Time {
+	if e.RecoveryInitiatedAt == nil {
+		return nil
+	}
+	due := e.RecoveryInitiatedAt.Add(time.Duration(e.WaitDays) * 24 * time.Hour)
+	return &due
+}
+
+// InviteEmergencyContactRequest designates a trusted contact by email.
+// WaitDays defaults to EmergencyAccessDefaultWaitDays.
+type InviteEmergencyContactRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Type     string `json:"type" binding:"required,oneof=view takeover"`
+	WaitDays int    `json:"wait_days" binding:"omitempty,min=1,max=90"`
+}
+
+type UpdateEmergencyAccessRequest struct {
+	Type     string `json:"type" binding:"required,oneof=view takeover"`
+	WaitDays int    `json:"wait_days" binding:"required,min=1,max=90"`
+}
+
+type AcceptEmergencyAccessRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ConfirmEmergencyAccessRequest hands the grantor's vault key to a trusted
+// contact who accepted: sealed by the server with the grantor's
+// MasterPassword, or by a zero-knowledge grantor's client (EncryptedKey).
+type ConfirmEmergencyAccessRequest struct {
+	MasterPassword string  `json:"master_password"`
+	EncryptedKey   *string `json:"encrypted_key"`
+}
+
+// EmergencyTakeoverRequest sets a new master password on the grantor's
+// account.
+//
+// For server-side grantors the server re-wraps their vault key under
+// NewMasterPassword. It opens its sealed copy with the grantee's own
+// MasterPassword, or takes the VaultKey (base64) a zero-knowledge grantee
+// opened locally.
+//
+// Zero-knowledge grantors get the ProtectedVaultKey wrapped by the grantee's
+// client, and NewMasterPassword is the new authentication secret; PAKE
+// grantors need a new SRPSalt and SRPVerifier instead.
+type EmergencyTakeoverRequest struct {
+	MasterPassword    string  `json:"master_password"`
+	VaultKey          *string `json:"vault_key"`
+	NewMasterPassword string  `json:"new_master_password"`
+	ProtectedVaultKey *string `json:"protected_vault_key"`
+	SRPSalt           *string `json:"srp_salt"`
+	SRPVerifier       *string `json:"srp_verifier"`
+}
+
+// EmergencyAccessResponse describes a grant to either side. Email and
+// PublicKey are the other party's; PublicKey lets zero-knowledge grantors
+// seal their vault key to the grantee.
+type EmergencyAccessResponse struct {
+	ID                  uuid.UUID  `json:"id"`
+	GrantorID           uuid.UUID  `json:"grantor_id"`
+	GranteeID           *uuid.UUID `json:"grantee_id,omitempty"`
+	Email               string     `json:"email"`
+	PublicKey           string     `json:"public_key,omitempty"`
+	Type                string     `json:"type"`
+	Status              string     `json:"status"`
+	WaitDays            int        `json:"wait_days"`
+	RecoveryInitiatedAt *time.Time `json:"recovery_initiated_at,omitempty"`
+	RecoveryDueAt       *time.Time `json:"recovery_due_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
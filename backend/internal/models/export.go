@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Export formats.
+const (
+	// ExportFormatJSON is this server's own format and keeps every item type
+	// and field.
+	ExportFormatJSON = "json"
+	// ExportFormatBitwarden is Bitwarden's unencrypted JSON export, which
+	// other password managers can import too.
+	ExportFormatBitwarden = "bitwarden"
+)
+
+// ExportRequest asks for a decrypted export of the caller's vault. Format
+// defaults to ExportFormatJSON.
+type ExportRequest struct {
+	MasterPassword string `json:"master_password" binding:"required"`
+	Format         string `json:"format" binding:"omitempty,oneof=json bitwarden"`
+}
+
+// ExportEntry is a decrypted entry as written to an export file.
+type ExportEntry struct {
+	Type     string        `json:"type"`
+	Title    string        `json:"title"`
+	Website  *string       `json:"website,omitempty"`
+	Username *string       `json:"username,omitempty"`
+	Password string        `json:"password,omitempty"`
+	Notes    *string       `json:"notes,omitempty"`
+	Folder   *string       `json:"folder,omitempty"`
+	Tags     []string      `json:"tags,omitempty"`
+	Favorite bool          `json:"favorite"`
+	Fields   []CustomField `json:"fields,omitempty"`
+	VaultItemData
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FolderPathSeparator joins the names of nested folders into a path, as
+// stored in Vault.Folder.
+const FolderPathSeparator = "/"
+
+// Folder is a node of a user's folder tree. Personal entries point at their
+// folder with Vault.FolderID and carry its path in Vault.Folder, which is
+// rewritten whenever the folder or one of its parents is renamed or moved.
+// Names are unique among siblings, ignoring case.
+type Folder struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	ParentID  *uuid.UUID `gorm:"type:uuid;index" json:"parent_id"`
+	Name      string     `gorm:"not null" json:"name"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// TableName specifies the table name for GORM
+func (Folder) TableName() string {
+	return "folders"
+}
+
+// FolderRequest creates a folder, or renames and moves one. A nil ParentID
+// puts the folder at the top level.
+type FolderRequest struct {
+	Name     string     `json:"name" binding:"required"`
+	ParentID *uuid.UUID `json:"parent_id"`
+}
+
+// FolderResponse describes a folder with its full path. ItemCount counts
+// the entries directly in it, TotalItemCount those in its subfolders too;
+// entries in the trash are not counted.
+type FolderResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	ParentID       *uuid.UUID `json:"parent_id"`
+	Name           string     `json:"name"`
+	Path           string     `json:"path"`
+	ItemCount      int64      `json:"item_count"`
+	TotalItemCount int64      `json:"total_item_count"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
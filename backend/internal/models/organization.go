@@ -0,0 +1,194 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Organization member roles, from most to least privileged.
+const (
+	// OrganizationRoleOwner is the member who created the organization. Org
+	// items are stored under their account and they cannot be removed.
+	OrganizationRoleOwner = "owner"
+	// OrganizationRoleAdmin members invite, confirm and remove members and
+	// write org items.
+	OrganizationRoleAdmin = "admin"
+	// OrganizationRoleMember members read org items.
+	OrganizationRoleMember = "member"
+)
+
+// Organization member statuses.
+const (
+	// OrganizationMemberAccepted members accepted an invitation but do not
+	// hold the org key yet.
+	OrganizationMemberAccepted = "accepted"
+	// OrganizationMemberConfirmed members hold the org key sealed to their
+	// public key.
+	OrganizationMemberConfirmed = "confirmed"
+)
+
+// Organization is a tenant shared by a team. Its own items are encrypted with
+// a random org key that every confirmed member holds sealed to their public
+// key, like a collection key; removing a confirmed member replaces it. An
+// organization can also own collections, which only its members can join.
+// Policy is the password policy its members are held to.
+type Organization struct {
+	ID         uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	OwnerID    uuid.UUID      `gorm:"type:uuid;not null;index" json:"owner_id"`
+	Name       string         `gorm:"not null" json:"name"`
+	KeyVersion int            `gorm:"not null;default:1" json:"key_version"`
+	Policy     PasswordPolicy `gorm:"embedded;embeddedPrefix:policy_" json:"policy"`
+	CreatedAt  time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Relations
+	Owner User `gorm:"foreignKey:OwnerID" json:"-"`
+}
+
+// TableName specifies the table name for GORM
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+// OrganizationMember links a user to an organization. EncryptedKey is empty
+// until an admin confirms the member by sealing the org key to them.
+type OrganizationMember struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_organization_member" json:"organization_id"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_organization_member;index" json:"user_id"`
+	Role           string    `gorm:"not null;default:'member'" json:"role"`
+	Status         string    `gorm:"not null;default:'accepted'" json:"status"`
+	EncryptedKey   string    `json:"-"`
+	KeyVersion     int       `gorm:"not null;default:0" json:"key_version"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Relations
+	Organization Organization `gorm:"foreignKey:OrganizationID" json:"-"`
+	User         User         `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// TableName specifies the table name for GORM
+func (OrganizationMember) TableName() string {
+	return "organization_members"
+}
+
+// IsConfirmed reports whether the member holds the org key.
+func (m *OrganizationMember) IsConfirmed() bool {
+	return m.Status == OrganizationMemberConfirmed
+}
+
+// CanAdmin reports whether the member may manage members and write org items.
+func (m *OrganizationMember) CanAdmin() bool {
+	return m.Role == OrganizationRoleOwner || m.Role == OrganizationRoleAdmin
+}
+
+// OrganizationInvitation invites an email address to an organization. Only a
+// hash of the emailed token is stored.
+type OrganizationInvitation struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;index" json:"organization_id"`
+	Email          string     `gorm:"not null;index" json:"email"`
+	Role           string     `gorm:"not null" json:"role"`
+	TokenHash      string     `gorm:"uniqueIndex;not null" json:"-"`
+	InvitedBy      uuid.UUID  `gorm:"type:uuid;not null" json:"invited_by"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	// Relations
+	Organization Organization `gorm:"foreignKey:OrganizationID" json:"-"`
+}
+
+// TableName specifies the table name for GORM
+func (OrganizationInvitation) TableName() string {
+	return "organization_invitations"
+}
+
+// IsExpired checks if the invitation can no longer be accepted.
+func (i *OrganizationInvitation) IsExpired() bool {
+	return time.Now().After(i.ExpiresAt)
+}
+
+// CreateOrganizationRequest creates an organization with the caller as owner.
+// Server-side accounts send their MasterPassword and the server generates the
+// org key; zero-knowledge accounts send it sealed to their own public key.
+type CreateOrganizationRequest struct {
+	Name           string  `json:"name" binding:"required"`
+	MasterPassword string  `json:"master_password"`
+	EncryptedKey   *string `json:"encrypted_key"`
+}
+
+type UpdateOrganizationRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// InviteOrganizationMemberRequest invites an email address as admin or member.
+type InviteOrganizationMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+type AcceptOrganizationInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ConfirmOrganizationMemberRequest hands the org key to an accepted member:
+// sealed by the server with the admin's MasterPassword, or by a
+// zero-knowledge admin's client (EncryptedKey).
+type ConfirmOrganizationMemberRequest struct {
+	MasterPassword string  `json:"master_password"`
+	EncryptedKey   *string `json:"encrypted_key"`
+}
+
+type UpdateOrganizationMemberRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// RemoveOrganizationMemberRequest removes a member. Removing a confirmed
+// member replaces the org key: server-side admins send their MasterPassword;
+// zero-knowledge admins send the new key sealed to every remaining confirmed
+// member (Keys) and every org item re-encrypted under it (Entries).
+type RemoveOrganizationMemberRequest struct {
+	MasterPassword string              `json:"master_password"`
+	Keys           []MemberKeyUpload   `json:"keys" binding:"dive"`
+	Entries        []ClientEntryUpload `json:"entries" binding:"dive"`
+}
+
+// OrganizationResponse describes an organization as seen by one member.
+// EncryptedKey is that member's sealed copy of the org key, empty until they
+// are confirmed.
+type OrganizationResponse struct {
+	ID           uuid.UUID      `json:"id"`
+	OwnerID      uuid.UUID      `json:"owner_id"`
+	Name         string         `json:"name"`
+	Role         string         `json:"role"`
+	Status       string         `json:"status"`
+	KeyVersion   int            `json:"key_version"`
+	EncryptedKey string         `json:"encrypted_key,omitempty"`
+	Policy       PasswordPolicy `json:"policy"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+type OrganizationMemberResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	PublicKey string    `json:"public_key"`
+	Role      string    `json:"role"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrganizationInvitationResponse is a pending invitation, as listed to the
+// organization's admins or to the invited user.
+type OrganizationInvitationResponse struct {
+	ID               uuid.UUID `json:"id"`
+	OrganizationID   uuid.UUID `json:"organization_id"`
+	OrganizationName string    `json:"organization_name"`
+	Email            string    `json:"email"`
+	Role             string    `json:"role"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	CreatedAt        time.Time `json:"created_at"`
+}
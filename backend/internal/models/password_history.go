@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordHistory keeps a password an entry held before it was changed,
+// sealed with the same key as the entry (its owner's vault key) so that a
+// botched rotation can be undone.
+type PasswordHistory struct {
+	ID                uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	VaultID           uuid.UUID `gorm:"type:uuid;not null;index:idx_password_history_vault,priority:1" json:"vault_id"`
+	EncryptedPassword string    `gorm:"not null" json:"-"`
+	EncryptionVersion int       `gorm:"not null" json:"-"`
+	// ChangedBy is who replaced the password: the owner, or a recipient
+	// whose shared edit was applied.
+	ChangedBy uuid.UUID `gorm:"type:uuid;not null" json:"changed_by"`
+	ChangedAt time.Time `gorm:"not null;index:idx_password_history_vault,priority:2,sort:desc" json:"changed_at"`
+
+	// Relations
+	Vault Vault `gorm:"foreignKey:VaultID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// TableName specifies the table name for GORM
+func (PasswordHistory) TableName() string {
+	return "password_history"
+}
+
+type PasswordHistoryResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Password  string    `json:"password"`
+	ChangedBy uuid.UUID `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// RestorePasswordRequest puts a password from the history back into its
+// entry; the password it replaces goes into the history in turn.
+type RestorePasswordRequest struct {
+	MasterPassword string `json:"master_password" binding:"required"`
+}
@@ -0,0 +1,54 @@
+package models
+
+// Password policy violation codes, as returned to clients.
+const (
+	PolicyViolationMinLength = "min_length"
+	PolicyViolationLowercase = "require_lower"
+	PolicyViolationUppercase = "require_upper"
+	PolicyViolationDigit     = "require_digit"
+	PolicyViolationSpecial   = "require_special"
+	PolicyViolationMaxAge    = "max_age"
+	PolicyViolationBreached  = "breached"
+	PolicyViolation2FA       = "require_2fa"
+)
+
+// PasswordPolicy lists the rules passwords must follow. The zero value
+// enforces nothing. The instance policy comes from configuration and each
+// organization can add its own; a user is held to the strictest combination
+// of the instance policy and those of every organization they belong to.
+type PasswordPolicy struct {
+	MinLength      int  `gorm:"not null;default:0" json:"min_length" binding:"min=0,max=128"`
+	RequireLower   bool `gorm:"not null;default:false" json:"require_lower"`
+	RequireUpper   bool `gorm:"not null;default:false" json:"require_upper"`
+	RequireDigit   bool `gorm:"not null;default:false" json:"require_digit"`
+	RequireSpecial bool `gorm:"not null;default:false" json:"require_special"`
+	MaxAgeDays     int  `gorm:"not null;default:0" json:"max_age_days" binding:"min=0"`
+	ForbidBreached bool `gorm:"not null;default:false" json:"forbid_breached"`
+	Require2FA     bool `gorm:"column:require_2fa;not null;default:false" json:"require_2fa"`
+}
+
+// Merge returns the strictest combination of p and other.
+func (p PasswordPolicy) Merge(other PasswordPolicy) PasswordPolicy {
+	merged := PasswordPolicy{
+		MinLength:      max(p.MinLength, other.MinLength),
+		RequireLower:   p.RequireLower || other.RequireLower,
+		RequireUpper:   p.RequireUpper || other.RequireUpper,
+		RequireDigit:   p.RequireDigit || other.RequireDigit,
+		RequireSpecial: p.RequireSpecial || other.RequireSpecial,
+		MaxAgeDays:     p.MaxAgeDays,
+		ForbidBreached: p.ForbidBreached || other.ForbidBreached,
+		Require2FA:     p.Require2FA || other.Require2FA,
+	}
+	if other.MaxAgeDays > 0 && (merged.MaxAgeDays == 0 || other.MaxAgeDays < merged.MaxAgeDays) {
+		merged.MaxAgeDays = other.MaxAgeDays
+	}
+	return merged
+}
+
+// PolicyViolation is one rule a password or account breaks. Limit carries
+// the rule's threshold (length or days) when it has one.
+type PolicyViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Limit   int    `json:"limit,omitempty"`
+}
@@ -6,19 +6,46 @@ import (
 	"github.com/google/uuid"
 )
 
+// Encryption versions of a share payload.
+const (
+	// ShareEncryptionLegacy shares hold a copy of the owner's ciphertext,
+	// which the recipient cannot decrypt.
+	ShareEncryptionLegacy = 0
+	// ShareEncryptionSealed shares hold the entry sealed to the recipient's
+	// public key.
+	ShareEncryptionSealed = 1
+	// ShareEncryptionLinkKey shares hold the entry encrypted with a random
+	// key that only travels in the fragment of the share URL.
+	ShareEncryptionLinkKey = 2
+	// ShareEncryptionInvitation shares are sealed to the server's invitation
+	// key while the recipient has no account or no key yet.
+	ShareEncryptionInvitation = 3
+)
+
+// Share types.
+const (
+	// ShareTypeUser shares go to a registered recipient.
+	ShareTypeUser = "user"
+	// ShareTypeLink shares are anonymous one-time links.
+	ShareTypeLink = "link"
+)
+
 type SharedPassword struct {
 	ID                uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
 	VaultID           uuid.UUID  `gorm:"type:uuid;not null" json:"vault_id"`
 	OwnerID           uuid.UUID  `gorm:"type:uuid;not null" json:"owner_id"`
+	ShareType         string     `gorm:"not null;default:'user'" json:"share_type"`
 	RecipientID       *uuid.UUID `gorm:"type:uuid" json:"recipient_id"`
 	RecipientEmail    string     `gorm:"not null" json:"recipient_email"`
-	EncryptedData     string     `gorm:"not null" json:"encrypted_data"`
+	EncryptedData     string     `gorm:"not null" json:"-"`
+	EncryptionVersion int        `gorm:"not null;default:0" json:"-"`
 	ShareToken        string     `gorm:"uniqueIndex;not null" json:"share_token"`
 	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
 	MaxViews          *int       `json:"max_views,omitempty"`
 	ViewCount         int        `gorm:"default:0" json:"view_count"`
 	RequirePassword   bool       `gorm:"default:false" json:"require_password"`
 	SharePasswordHash *string    `json:"-"`
+	SharePasswordSalt *string    `json:"-"`
 	CanView           bool       `gorm:"default:true" json:"can_view"`
 	CanCopy           bool       `gorm:"default:true" json:"can_copy"`
 	CanEdit           bool       `gorm:"default:false" json:"can_edit"`
@@ -26,6 +53,14 @@ type SharedPassword struct {
 	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
 	LastAccessed      *time.Time `json:"last_accessed,omitempty"`
 
+	// Suspended is set on listings for shares whose entry is in the trash;
+	// they cannot be opened until it is restored.
+	Suspended bool `gorm:"-" json:"suspended"`
+
+	// InvitationTokenHash is set while a share waits for a recipient without
+	// an account: only whoever presents the emailed token can claim it.
+	InvitationTokenHash *string `gorm:"uniqueIndex" json:"-"`
+
 	// Relations
 	Vault     Vault  `gorm:"foreignKey:VaultID" json:"-"`
 	Owner     User   `gorm:"foreignKey:OwnerID" json:"-"`
@@ -37,18 +72,113 @@ func (SharedPassword) TableName() string {
 	return "shared_passwords"
 }
 
+// SharePasswordRequest shares an entry with another user. Server-side
+// accounts send their MasterPassword so the entry can be re-encrypted to the
+// recipient's public key; zero-knowledge accounts seal it themselves (to the
+// key from /share/recipient-key) and send the result as EncryptedData.
+// CanView and CanCopy default to true; a share that allows neither carries no
+// password.
 type SharePasswordRequest struct {
 	VaultID         string  `json:"vault_id" binding:"required"`
+	MasterPassword  string  `json:"master_password"`
+	EncryptedData   *string `json:"encrypted_data"`
 	RecipientEmail  string  `json:"recipient_email" binding:"required,email"`
 	ExpiresInHours  *int    `json:"expires_in_hours"`
 	MaxViews        *int    `json:"max_views"`
 	RequirePassword bool    `json:"require_password"`
 	SharePassword   *string `json:"share_password"`
+	CanView         *bool   `json:"can_view"`
+	CanCopy         *bool   `json:"can_copy"`
 	CanEdit         bool    `json:"can_edit"`
 }
 
+// ShareListItem describes a share in GET /shared. It never carries the
+// payload, which is only served once the checks of GET /shared/:token pass.
+// ShareToken is only set on sent shares; recipients open theirs by ID.
+type ShareListItem struct {
+	ID              uuid.UUID  `json:"id"`
+	VaultID         uuid.UUID  `json:"vault_id"`
+	OwnerID         uuid.UUID  `json:"owner_id"`
+	ShareType       string     `json:"share_type"`
+	RecipientID     *uuid.UUID `json:"recipient_id"`
+	RecipientEmail  string     `json:"recipient_email"`
+	ShareToken      string     `json:"share_token,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	MaxViews        *int       `json:"max_views,omitempty"`
+	ViewCount       int        `json:"view_count"`
+	RequirePassword bool       `json:"require_password"`
+	CanView         bool       `json:"can_view"`
+	CanCopy         bool       `json:"can_copy"`
+	CanEdit         bool       `json:"can_edit"`
+	Revoked         bool       `json:"revoked"`
+	Suspended       bool       `json:"suspended"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastAccessed    *time.Time `json:"last_accessed,omitempty"`
+}
+
+// SharePasswordResponse is returned when a share is created. Pending is set
+// when the recipient has no account yet and was sent an invitation instead.
 type SharePasswordResponse struct {
 	ShareToken string     `json:"share_token"`
 	ShareURL   string     `json:"share_url"`
 	ExpiresAt  *time.Time `json:"expires_at"`
+	Pending    bool       `json:"pending"`
+}
+
+// AcceptShareInvitationRequest claims a pending share with the token from
+// its invitation email.
+type AcceptShareInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RecipientKeyResponse lets zero-knowledge clients seal an entry to a
+// recipient before sharing it. For emails without an account Pending is set,
+// UserID is empty and PublicKey is the server's invitation key.
+type RecipientKeyResponse struct {
+	UserID    *uuid.UUID `json:"user_id,omitempty"`
+	Email     string     `json:"email"`
+	PublicKey string     `json:"public_key"`
+	Pending   bool       `json:"pending"`
+}
+
+// CreateLinkShareRequest creates an anonymous link. Server-side accounts send
+// their MasterPassword and the server encrypts the entry with a fresh link
+// key; zero-knowledge accounts encrypt it themselves and send EncryptedData.
+// MaxViews defaults to a single view.
+type CreateLinkShareRequest struct {
+	VaultID         string  `json:"vault_id" binding:"required,uuid"`
+	MasterPassword  string  `json:"master_password"`
+	EncryptedData   *string `json:"encrypted_data"`
+	ExpiresInHours  *int    `json:"expires_in_hours"`
+	MaxViews        *int    `json:"max_views"`
+	RequirePassword bool    `json:"require_password"`
+	SharePassword   *string `json:"share_password"`
+}
+
+// LinkShareResponse returns the link. ShareURL carries the link key in its
+// fragment when the server generated it; zero-knowledge clients append their
+// own key.
+type LinkShareResponse struct {
+	ShareToken string     `json:"share_token"`
+	ShareURL   string     `json:"share_url"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	MaxViews   *int       `json:"max_views"`
+}
+
+// LinkSharePayload is what server-side accounts encrypt into a link: the link
+// is opened by someone without an account, so it carries the metadata and the
+// item type too.
+type LinkSharePayload struct {
+	Type     string        `json:"type"`
+	Title    string        `json:"title"`
+	Website  *string       `json:"website"`
+	Username *string       `json:"username"`
+	Password string        `json:"password"`
+	Notes    *string       `json:"notes"`
+	Fields   []CustomField `json:"fields,omitempty"`
+	VaultItemData
+}
+
+type OpenPublicShareRequest struct {
+	SharePassword string `json:"share_password"`
 }
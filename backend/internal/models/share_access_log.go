@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outcomes recorded in the share access log.
+const (
+	ShareAccessGranted          = "granted"
+	ShareAccessEdited           = "edited"
+	ShareAccessDenied           = "denied"
+	ShareAccessPasswordRequired = "share_password_required"
+	ShareAccessWrongPassword    = "wrong_share_password"
+	ShareAccessExpired          = "expired"
+	ShareAccessRevoked          = "revoked"
+	ShareAccessViewLimitReached = "view_limit_reached"
+	ShareAccessSuspended        = "suspended"
+)
+
+// ShareAccessLog records one attempt to open a share, successful or not.
+// UserID is empty for anonymous link shares.
+type ShareAccessLog struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ShareID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"share_id"`
+	UserID    *uuid.UUID `gorm:"type:uuid" json:"user_id,omitempty"`
+	IPAddress string     `json:"ip_address"`
+	UserAgent string     `json:"user_agent"`
+	Outcome   string     `gorm:"not null" json:"outcome"`
+	CreatedAt time.Time  `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (ShareAccessLog) TableName() string {
+	return "share_access_logs"
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SharedEdit is a change to an entry's password or notes made by a recipient
+// with edit permission. Only the owner can encrypt into their vault, so the
+// change is sealed to the owner's public key and waits here until it is
+// applied: on the owner's next unlock for server-side accounts, by the client
+// for zero-knowledge accounts.
+type SharedEdit struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ShareID       uuid.UUID  `gorm:"type:uuid;not null" json:"share_id"`
+	VaultID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"vault_id"`
+	OwnerID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"owner_id"`
+	EditorID      uuid.UUID  `gorm:"type:uuid;not null" json:"editor_id"`
+	EncryptedData string     `gorm:"not null" json:"encrypted_data"`
+	BaseRevision  int        `gorm:"not null" json:"base_revision"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	AppliedAt     *time.Time `json:"applied_at,omitempty"`
+}
+
+// TableName specifies the table name for GORM
+func (SharedEdit) TableName() string {
+	return "shared_edits"
+}
+
+// SharedEditPayload is the plaintext of a SharedEdit. Nil fields are left
+// unchanged.
+type SharedEditPayload struct {
+	Password *string `json:"password,omitempty"`
+	Notes    *string `json:"notes,omitempty"`
+}
+
+// UpdateSharedPasswordRequest edits a shared entry as its recipient. Title,
+// Website and Username are written to the entry directly. Password and Notes
+// changes reach the owner sealed to their key: server-side recipients send
+// them in the clear with their MasterPassword; zero-knowledge recipients seal
+// a SharedEditPayload to the owner themselves (OwnerEncryptedData) and send
+// their own refreshed copy of the share (EncryptedData).
+type UpdateSharedPasswordRequest struct {
+	Title              *string `json:"title"`
+	Website            *string `json:"website"`
+	Username           *string `json:"username"`
+	Password           *string `json:"password"`
+	Notes              *string `json:"notes"`
+	MasterPassword     string  `json:"master_password"`
+	SharePassword      string  `json:"share_password"`
+	EncryptedData      *string `json:"encrypted_data"`
+	OwnerEncryptedData *string `json:"owner_encrypted_data"`
+}
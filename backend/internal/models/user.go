@@ -8,21 +8,29 @@ import (
 )
 
 type User struct {
-	ID                 uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	Email              string         `gorm:"uniqueIndex;not null" json:"email"`
-	MasterPasswordHash string         `gorm:"not null" json:"-"`
-	Salt               string         `gorm:"not null" json:"-"`
-	PublicKey          string         `json:"public_key,omitempty"`
-	PrivateKey         string         `json:"-"`
-	TwoFactorEnabled   bool           `gorm:"default:false" json:"two_factor_enabled"`
-	TwoFactorSecret    *string        `json:"-"`
-	BackupCodes        pq.StringArray `gorm:"type:text[]" json:"-"`
-	CreatedAt          time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt          time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	ID                   uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Email                string         `gorm:"uniqueIndex;not null" json:"email"`
+	MasterPasswordHash   string         `gorm:"not null" json:"-"`
+	Salt                 string         `gorm:"not null" json:"-"`
+	EncryptedVaultKey    *string        `json:"-"`
+	VaultKeySalt         *string        `json:"-"`
+	VaultKeyNonce        *string        `json:"-"`
+	ClientSideEncryption bool           `gorm:"default:false" json:"client_side_encryption"`
+	ProtectedVaultKey    *string        `json:"-"`
+	SRPSalt              *string        `json:"-"`
+	SRPVerifier          *string        `json:"-"`
+	PublicKey            string         `json:"public_key,omitempty"`
+	PrivateKey           string         `json:"-"`
+	TwoFactorEnabled     bool           `gorm:"default:false" json:"two_factor_enabled"`
+	TwoFactorSecret      *string        `json:"-"`
+	BackupCodes          pq.StringArray `gorm:"type:text[]" json:"-"`
+	TwoFactorLastStep    *int64         `json:"-"`
+	CreatedAt            time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt            time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
 
 	// Relations
-	Vaults            []Vault         `gorm:"foreignKey:UserID" json:"-"`
-	SharedPasswords   []SharedPassword `gorm:"foreignKey:OwnerID" json:"-"`
+	Vaults          []Vault          `gorm:"foreignKey:UserID" json:"-"`
+	SharedPasswords []SharedPassword `gorm:"foreignKey:OwnerID" json:"-"`
 }
 
 // TableName specifies the table name for GORM
@@ -30,18 +38,169 @@ func (User) TableName() string {
 	return "users"
 }
 
+// HasVaultKey reports whether the account has a wrapped vault key. Accounts
+// created before the key hierarchy get one on their next unlock.
+//
+// EncryptedVaultKey is an envelope; VaultKeySalt and VaultKeyNonce are only
+// set for keys wrapped before envelopes existed. Accounts in zero-knowledge
+// mode (ClientSideEncryption) have no server-side wrap; their vault key is
+// only stored as the opaque, client-wrapped ProtectedVaultKey.
+func (u *User) HasVaultKey() bool {
+	return u.EncryptedVaultKey != nil
+}
+
+// UsesPAKE reports whether the account signs in with SRP. Such accounts have
+// no MasterPasswordHash: the server only stores the SRP salt and verifier,
+// and the account is always in zero-knowledge mode.
+func (u *User) UsesPAKE() bool {
+	return u.SRPVerifier != nil
+}
+
 type LoginRequest struct {
 	Email          string `json:"email" binding:"required,email"`
 	MasterPassword string `json:"master_password" binding:"required"`
+	DeviceName     string `json:"device_name"`
 }
 
+// RegisterRequest creates an account. With ClientSideEncryption set,
+// MasterPassword carries the client-derived authentication secret rather
+// than the master password itself, ProtectedVaultKey is required, and the
+// client may upload its own sharing keypair.
 type RegisterRequest struct {
-	Email          string `json:"email" binding:"required,email"`
-	MasterPassword string `json:"master_password" binding:"required,min=8"`
+	Email                string  `json:"email" binding:"required,email"`
+	MasterPassword       string  `json:"master_password" binding:"required,min=8"`
+	ClientSideEncryption bool    `json:"client_side_encryption"`
+	ProtectedVaultKey    *string `json:"protected_vault_key"`
+	// InvitationToken is the token of a share invitation link, which hands
+	// the share over to the new account.
+	InvitationToken string `json:"invitation_token"`
+	ClientKeyPair
+}
+
+// ClientKeyPair is a sharing keypair generated by a zero-knowledge client:
+// a base64 X25519 public key and the private key wrapped by the client.
+type ClientKeyPair struct {
+	PublicKey           *string `json:"public_key"`
+	ProtectedPrivateKey *string `json:"protected_private_key"`
 }
 
 type LoginResponse struct {
-	AccessToken string `json:"access_token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	User         User   `json:"user"`
+}
+
+// MFAChallengeResponse is returned by login when the account has 2FA enabled.
+// The MFAToken must be exchanged together with a TOTP or backup code.
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
 	TokenType   string `json:"token_type"`
-	User        User   `json:"user"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type MFALoginRequest struct {
+	MFAToken   string `json:"mfa_token" binding:"required"`
+	Code       string `json:"code" binding:"required"`
+	DeviceName string `json:"device_name"`
+}
+
+type ChangeMasterPasswordRequest struct {
+	CurrentMasterPassword string `json:"current_master_password" binding:"required"`
+	NewMasterPassword     string `json:"new_master_password" binding:"required,min=8"`
+	DeviceName            string `json:"device_name"`
+}
+
+// ClientEntryUpload is an entry re-encrypted by the client while switching an
+// account to zero-knowledge mode.
+type ClientEntryUpload struct {
+	ID            string `json:"id" binding:"required,uuid"`
+	EncryptedData string `json:"encrypted_data" binding:"required"`
+}
+
+// EnableClientEncryptionRequest switches an account to zero-knowledge mode.
+// Entries must contain every existing vault entry, re-encrypted by the client;
+// AuthSecret replaces the master password for login from then on. The client
+// keypair is required: shares already received are re-sealed to it.
+type EnableClientEncryptionRequest struct {
+	MasterPassword    string              `json:"master_password" binding:"required"`
+	AuthSecret        string              `json:"auth_secret" binding:"required,min=8"`
+	ProtectedVaultKey string              `json:"protected_vault_key" binding:"required"`
+	Entries           []ClientEntryUpload `json:"entries" binding:"dive"`
+	DeviceName        string              `json:"device_name"`
+	ClientKeyPair
+}
+
+// UpdateProtectedKeyRequest replaces the client-wrapped vault key, typically
+// after the master password was changed on the client. A NewAuthSecret
+// rotates the login secret and signs out every other session.
+//
+// PAKE accounts authenticate with a PAKEProof instead of CurrentAuthSecret
+// and rotate their verifier through /auth/pake/enroll. A keypair can only be
+// uploaded by accounts that have none yet.
+type UpdateProtectedKeyRequest struct {
+	CurrentAuthSecret string  `json:"current_auth_secret"`
+	NewAuthSecret     *string `json:"new_auth_secret" binding:"omitempty,min=8"`
+	ProtectedVaultKey string  `json:"protected_vault_key" binding:"required"`
+	PAKEProof
+	ClientKeyPair
+}
+
+// PAKEProof re-authenticates a PAKE account for a sensitive operation: the
+// handshake from a fresh /auth/pake/login/init round and the client proof M1
+// computed for it.
+type PAKEProof struct {
+	HandshakeID string `json:"handshake_id"`
+	ClientProof string `json:"client_proof"`
+}
+
+// PAKERegisterRequest creates an account that signs in with SRP. The salt,
+// verifier and client proofs are base64 encoded.
+type PAKERegisterRequest struct {
+	Email             string `json:"email" binding:"required,email"`
+	SRPSalt           string `json:"srp_salt" binding:"required"`
+	SRPVerifier       string `json:"srp_verifier" binding:"required"`
+	ProtectedVaultKey string `json:"protected_vault_key" binding:"required"`
+	InvitationToken   string `json:"invitation_token"`
+	ClientKeyPair
+}
+
+type PAKELoginInitRequest struct {
+	Email        string `json:"email" binding:"required,email"`
+	ClientPublic string `json:"client_public" binding:"required"`
+}
+
+type PAKELoginInitResponse struct {
+	HandshakeID  string `json:"handshake_id"`
+	SRPSalt      string `json:"srp_salt"`
+	ServerPublic string `json:"server_public"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type PAKELoginVerifyRequest struct {
+	HandshakeID string `json:"handshake_id" binding:"required"`
+	ClientProof string `json:"client_proof" binding:"required"`
+	DeviceName  string `json:"device_name"`
+}
+
+// PAKELoginVerifyResponse carries the server proof M2, which the client must
+// check, and either a session or, with 2FA enabled, an MFA challenge.
+type PAKELoginVerifyResponse struct {
+	ServerProof string                `json:"server_proof"`
+	Session     *LoginResponse        `json:"session,omitempty"`
+	MFA         *MFAChallengeResponse `json:"mfa,omitempty"`
+}
+
+// PAKEEnrollRequest moves a zero-knowledge account to SRP login, or rotates
+// the verifier of a PAKE account after a master password change. The caller
+// proves the current secret with CurrentAuthSecret or, for PAKE accounts, a
+// PAKEProof.
+type PAKEEnrollRequest struct {
+	SRPSalt           string  `json:"srp_salt" binding:"required"`
+	SRPVerifier       string  `json:"srp_verifier" binding:"required"`
+	ProtectedVaultKey *string `json:"protected_vault_key"`
+	CurrentAuthSecret string  `json:"current_auth_secret"`
+	PAKEProof
 }
@@ -4,22 +4,53 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Encryption versions of a vault entry.
+const (
+	// EncryptionVersionLegacy entries carry their own Argon2 salt and are
+	// encrypted with a key derived from the master password.
+	EncryptionVersionLegacy = 0
+	// EncryptionVersionVaultKey entries are encrypted with the user's random
+	// vault key; EncryptionSalt is empty.
+	EncryptionVersionVaultKey = 1
+	// EncryptionVersionEnvelope entries hold a self-describing envelope in
+	// EncryptedData; EncryptionSalt and Nonce are empty.
+	EncryptionVersionEnvelope = 2
+	// EncryptionVersionClient entries hold an opaque blob encrypted by the
+	// client in zero-knowledge mode. The server never decrypts or upgrades
+	// them, so the value sits above every server-side version.
+	EncryptionVersionClient = 100
+
+	// EncryptionVersionCurrent is what new entries are written with. Entries
+	// below it are upgraded in the background after an unlock.
+	EncryptionVersionCurrent = EncryptionVersionEnvelope
 )
 
 type Vault struct {
-	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	UserID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
-	Title          string     `gorm:"not null" json:"title"`
-	Website        *string    `json:"website,omitempty"`
-	Username       *string    `json:"username,omitempty"`
-	EncryptedData  string     `gorm:"not null" json:"-"`
-	EncryptionSalt string     `gorm:"not null" json:"-"`
-	Nonce          string     `gorm:"not null" json:"-"`
-	Folder         *string    `json:"folder,omitempty"`
-	Favorite       bool       `gorm:"default:false" json:"favorite"`
-	LastUsed       *time.Time `json:"last_used,omitempty"`
-	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	ID                uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID            uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	CollectionID      *uuid.UUID     `gorm:"type:uuid;index" json:"collection_id,omitempty"`
+	OrganizationID    *uuid.UUID     `gorm:"type:uuid;index" json:"organization_id,omitempty"`
+	Type              string         `gorm:"not null;default:'login';index" json:"type"`
+	Title             string         `gorm:"not null" json:"title"`
+	Website           *string        `json:"website,omitempty"`
+	Username          *string        `json:"username,omitempty"`
+	EncryptedData     string         `gorm:"not null" json:"-"`
+	EncryptionSalt    string         `gorm:"not null" json:"-"`
+	Nonce             string         `gorm:"not null" json:"-"`
+	EncryptionVersion int            `gorm:"not null;default:0;index" json:"-"`
+	Revision          int            `gorm:"not null;default:1" json:"revision"`
+	LastModifiedBy    *uuid.UUID     `gorm:"type:uuid" json:"last_modified_by,omitempty"`
+	FolderID          *uuid.UUID     `gorm:"type:uuid;index" json:"folder_id,omitempty"`
+	Folder            *string        `json:"folder,omitempty"`
+	Tags              pq.StringArray `gorm:"type:text[];index:idx_vaults_tags,type:gin" json:"tags"`
+	Favorite          bool           `gorm:"default:false" json:"favorite"`
+	LastUsed          *time.Time     `json:"last_used,omitempty"`
+	DeletedAt         *time.Time     `gorm:"index" json:"deleted_at,omitempty"`
+	CreatedAt         time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
 
 	// Relations
 	User User `gorm:"foreignKey:UserID" json:"-"`
@@ -30,28 +61,134 @@ func (Vault) TableName() string {
 	return "vaults"
 }
 
+// InCollection reports whether the entry belongs to a shared collection and
+// is encrypted with the collection key rather than its owner's vault key.
+func (v *Vault) InCollection() bool {
+	return v.CollectionID != nil
+}
+
+// InOrganization reports whether the entry is an organization item,
+// encrypted with the org key.
+func (v *Vault) InOrganization() bool {
+	return v.OrganizationID != nil
+}
+
+// InTrash reports whether the entry was deleted and waits in its owner's
+// trash to be restored or purged.
+func (v *Vault) InTrash() bool {
+	return v.DeletedAt != nil
+}
+
+// TagList returns the entry's tags, empty rather than nil so that it encodes
+// as a JSON array.
+func (v *Vault) TagList() []string {
+	if v.Tags == nil {
+		return []string{}
+	}
+	return v.Tags
+}
+
+// IsPersonal reports whether the entry is in its owner's own vault, as
+// opposed to a collection or an organization.
+func (v *Vault) IsPersonal() bool {
+	return !v.InCollection() && !v.InOrganization()
+}
+
+// CreateVaultRequest creates or replaces an entry. Type defaults to
+// ItemTypeLogin, which requires Password; other types carry their fields in
+// the matching VaultItemData member. FolderID files the entry in one of the
+// caller's folders; without it, a Folder path is filed in the folder at that
+// path, created as needed.
 type CreateVaultRequest struct {
-	Title          string  `json:"title" binding:"required"`
-	Website        *string `json:"website"`
-	Username       *string `json:"username"`
-	Password       string  `json:"password" binding:"required"`
-	Notes          *string `json:"notes"`
-	Folder         *string `json:"folder"`
-	MasterPassword string  `json:"master_password" binding:"required"`
+	Type           string        `json:"type"`
+	Title          string        `json:"title" binding:"required"`
+	Website        *string       `json:"website"`
+	Username       *string       `json:"username"`
+	Password       string        `json:"password"`
+	Notes          *string       `json:"notes"`
+	FolderID       *uuid.UUID    `json:"folder_id"`
+	Folder         *string       `json:"folder"`
+	Tags           []string      `json:"tags"`
+	Fields         []CustomField `json:"fields"`
+	MasterPassword string        `json:"master_password" binding:"required"`
+	VaultItemData
 }
 
 type VaultResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Title     string    `json:"title"`
-	Website   *string   `json:"website"`
-	Username  *string   `json:"username"`
-	Folder    *string   `json:"folder"`
-	Favorite  bool      `json:"favorite"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID             uuid.UUID  `json:"id"`
+	Type           string     `json:"type"`
+	Title          string     `json:"title"`
+	Website        *string    `json:"website"`
+	Username       *string    `json:"username"`
+	FolderID       *uuid.UUID `json:"folder_id"`
+	Folder         *string    `json:"folder"`
+	Tags           []string   `json:"tags"`
+	Favorite       bool       `json:"favorite"`
+	LastModifiedBy *uuid.UUID `json:"last_modified_by,omitempty"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// ClientVaultRequest creates or updates an entry in zero-knowledge mode.
+// EncryptedData is produced and only ever read by the client. On update,
+// Revision must match the stored revision or the write is rejected, and
+// SharedEditID names the recipient edit the update applies, if any. Type
+// defaults to ItemTypeLogin and cannot change once the entry exists.
+// FolderID and Folder file the entry as in CreateVaultRequest.
+type ClientVaultRequest struct {
+	Type          string     `json:"type"`
+	Title         string     `json:"title" binding:"required"`
+	Website       *string    `json:"website"`
+	Username      *string    `json:"username"`
+	FolderID      *uuid.UUID `json:"folder_id"`
+	Folder        *string    `json:"folder"`
+	Tags          []string   `json:"tags"`
+	Favorite      bool       `json:"favorite"`
+	EncryptedData string     `json:"encrypted_data" binding:"required"`
+	Revision      int        `json:"revision"`
+	SharedEditID  *uuid.UUID `json:"shared_edit_id"`
+}
+
+type ClientVaultResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	Type           string     `json:"type"`
+	Title          string     `json:"title"`
+	Website        *string    `json:"website"`
+	Username       *string    `json:"username"`
+	FolderID       *uuid.UUID `json:"folder_id"`
+	Folder         *string    `json:"folder"`
+	Tags           []string   `json:"tags"`
+	Favorite       bool       `json:"favorite"`
+	EncryptedData  string     `json:"encrypted_data"`
+	Revision       int        `json:"revision"`
+	LastModifiedBy *uuid.UUID `json:"last_modified_by,omitempty"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// TrashEntryResponse describes an entry in the trash. PurgeAt is when it
+// will be deleted for good, if the trash is purged on this instance;
+// EncryptedData is given to zero-knowledge clients only.
+type TrashEntryResponse struct {
+	ID            uuid.UUID  `json:"id"`
+	Type          string     `json:"type"`
+	Title         string     `json:"title"`
+	Website       *string    `json:"website"`
+	Username      *string    `json:"username"`
+	FolderID      *uuid.UUID `json:"folder_id"`
+	Folder        *string    `json:"folder"`
+	EncryptedData string     `json:"encrypted_data,omitempty"`
+	Revision      int        `json:"revision"`
+	DeletedAt     time.Time  `json:"deleted_at"`
+	PurgeAt       *time.Time `json:"purge_at"`
 }
 
+// DecryptedVaultData is the encrypted payload of an entry.
 type DecryptedVaultData struct {
-	Password string  `json:"password"`
-	Notes    *string `json:"notes"`
+	Password string        `json:"password"`
+	Notes    *string       `json:"notes"`
+	Fields   []CustomField `json:"fields,omitempty"`
+	VaultItemData
 }
@@ -0,0 +1,132 @@
+package models
+
+import "time"
+
+// Vault item types. The type is stored in clear next to the title so that
+// listings can be filtered without decrypting anything; the type-specific
+// fields live in the encrypted payload.
+const (
+	// ItemTypeLogin entries hold a password for a website or service.
+	ItemTypeLogin = "login"
+	// ItemTypeSecureNote entries only hold notes.
+	ItemTypeSecureNote = "secure_note"
+	// ItemTypeCard entries hold a payment card.
+	ItemTypeCard = "card"
+	// ItemTypeIdentity entries hold personal details used to fill forms.
+	ItemTypeIdentity = "identity"
+	// ItemTypeSSHKey entries hold an SSH private key.
+	ItemTypeSSHKey = "ssh_key"
+	// ItemTypeAPICredential entries hold an API key or client secret.
+	ItemTypeAPICredential = "api_credential"
+)
+
+// ItemTypes lists every vault item type.
+var ItemTypes = []string{
+	ItemTypeLogin,
+	ItemTypeSecureNote,
+	ItemTypeCard,
+	ItemTypeIdentity,
+	ItemTypeSSHKey,
+	ItemTypeAPICredential,
+}
+
+// IsItemType reports whether t is a known vault item type.
+func IsItemType(t string) bool {
+	for _, itemType := range ItemTypes {
+		if t == itemType {
+			return true
+		}
+	}
+	return false
+}
+
+// Custom field types.
+const (
+	CustomFieldText    = "text"
+	CustomFieldHidden  = "hidden"
+	CustomFieldBoolean = "boolean"
+	CustomFieldURL     = "url"
+	// CustomFieldTOTP values are otpauth:// URIs or base32 TOTP secrets.
+	CustomFieldTOTP = "totp"
+)
+
+// CustomField is a user-defined field of an entry, such as a security
+// question or a PIN. Fields keep the order the user gave them.
+type CustomField struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// VaultItemData holds the type-specific fields of an entry. Only the one
+// matching the entry's type is set; logins and secure notes only use the
+// password and notes of DecryptedVaultData.
+type VaultItemData struct {
+	Card          *CardData          `json:"card,omitempty"`
+	Identity      *IdentityData      `json:"identity,omitempty"`
+	SSHKey        *SSHKeyData        `json:"ssh_key,omitempty"`
+	APICredential *APICredentialData `json:"api_credential,omitempty"`
+}
+
+// CopyOnly keeps the one secret of the item that a recipient allowed to copy,
+// but not view, an entry may copy: the card number, the SSH private key or
+// the API secret. Identities have none.
+func (d VaultItemData) CopyOnly() VaultItemData {
+	var copyable VaultItemData
+	switch {
+	case d.Card != nil:
+		copyable.Card = &CardData{Number: d.Card.Number}
+	case d.SSHKey != nil:
+		copyable.SSHKey = &SSHKeyData{PrivateKey: d.SSHKey.PrivateKey}
+	case d.APICredential != nil:
+		copyable.APICredential = &APICredentialData{Secret: d.APICredential.Secret}
+	}
+	return copyable
+}
+
+// CardData is a payment card. Number is stored without separators and Brand
+// is detected from it when left empty.
+type CardData struct {
+	CardholderName string `json:"cardholder_name"`
+	Number         string `json:"number"`
+	ExpMonth       int    `json:"exp_month"`
+	ExpYear        int    `json:"exp_year"`
+	CVV            string `json:"cvv,omitempty"`
+	Brand          string `json:"brand,omitempty"`
+}
+
+type IdentityData struct {
+	Title          string `json:"title,omitempty"`
+	FirstName      string `json:"first_name,omitempty"`
+	MiddleName     string `json:"middle_name,omitempty"`
+	LastName       string `json:"last_name,omitempty"`
+	Email          string `json:"email,omitempty"`
+	Phone          string `json:"phone,omitempty"`
+	Company        string `json:"company,omitempty"`
+	Address1       string `json:"address1,omitempty"`
+	Address2       string `json:"address2,omitempty"`
+	City           string `json:"city,omitempty"`
+	State          string `json:"state,omitempty"`
+	PostalCode     string `json:"postal_code,omitempty"`
+	Country        string `json:"country,omitempty"`
+	SSN            string `json:"ssn,omitempty"`
+	PassportNumber string `json:"passport_number,omitempty"`
+	LicenseNumber  string `json:"license_number,omitempty"`
+}
+
+// SSHKeyData is an SSH key pair. PublicKey and Fingerprint are derived from
+// PrivateKey; for an encrypted PEM key stored without its Passphrase the
+// client must provide PublicKey.
+type SSHKeyData struct {
+	PrivateKey  string `json:"private_key"`
+	Passphrase  string `json:"passphrase,omitempty"`
+	PublicKey   string `json:"public_key,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+type APICredentialData struct {
+	KeyID     string     `json:"key_id,omitempty"`
+	Secret    string     `json:"secret"`
+	Endpoint  string     `json:"endpoint,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
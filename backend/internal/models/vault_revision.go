@@ -0,0 +1,129 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// VaultRevision is a snapshot of an entry as written at one revision. Every
+// write through the vault repository appends one, so the table is the full,
+// append-only history of the entry. The encrypted payload is kept as it was
+// written, under the key the entry used at the time. An entry has at most one
+// row per revision, which writers rely on to detect concurrent updates.
+type VaultRevision struct {
+	ID                uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	VaultID           uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_vault_revisions_vault_revision,priority:1" json:"vault_id"`
+	Revision          int            `gorm:"not null;uniqueIndex:idx_vault_revisions_vault_revision,priority:2" json:"revision"`
+	CollectionID      *uuid.UUID     `gorm:"type:uuid" json:"collection_id,omitempty"`
+	OrganizationID    *uuid.UUID     `gorm:"type:uuid" json:"organization_id,omitempty"`
+	Type              string         `gorm:"not null" json:"type"`
+	Title             string         `gorm:"not null" json:"title"`
+	Website           *string        `json:"website,omitempty"`
+	Username          *string        `json:"username,omitempty"`
+	FolderID          *uuid.UUID     `gorm:"type:uuid" json:"folder_id,omitempty"`
+	Folder            *string        `json:"folder,omitempty"`
+	Tags              pq.StringArray `gorm:"type:text[]" json:"tags"`
+	Favorite          bool           `gorm:"not null;default:false" json:"favorite"`
+	EncryptedData     string         `gorm:"not null" json:"-"`
+	EncryptionSalt    string         `gorm:"not null" json:"-"`
+	Nonce             string         `gorm:"not null" json:"-"`
+	EncryptionVersion int            `gorm:"not null" json:"-"`
+	ChangedBy         uuid.UUID      `gorm:"type:uuid;not null" json:"changed_by"`
+	CreatedAt         time.Time      `gorm:"not null" json:"created_at"`
+
+	// Relations
+	Vault Vault `gorm:"foreignKey:VaultID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// TableName specifies the table name for GORM
+func (VaultRevision) TableName() string {
+	return "vault_revisions"
+}
+
+// NewVaultRevision snapshots vault as just written. The author is the last
+// modifier, or the owner for entries nobody else touched.
+func NewVaultRevision(vault *Vault) *VaultRevision {
+	changedBy := vault.UserID
+	if vault.LastModifiedBy != nil {
+		changedBy = *vault.LastModifiedBy
+	}
+	createdAt := vault.UpdatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	return &VaultRevision{
+		ID:                uuid.New(),
+		VaultID:           vault.ID,
+		Revision:          vault.Revision,
+		CollectionID:      vault.CollectionID,
+		OrganizationID:    vault.OrganizationID,
+		Type:              vault.Type,
+		Title:             vault.Title,
+		Website:           vault.Website,
+		Username:          vault.Username,
+		FolderID:          vault.FolderID,
+		Folder:            vault.Folder,
+		Tags:              vault.Tags,
+		Favorite:          vault.Favorite,
+		EncryptedData:     vault.EncryptedData,
+		EncryptionSalt:    vault.EncryptionSalt,
+		Nonce:             vault.Nonce,
+		EncryptionVersion: vault.EncryptionVersion,
+		ChangedBy:         changedBy,
+		CreatedAt:         createdAt,
+	}
+}
+
+// Entry returns the entry as it was at this revision, so that its payload
+// can be opened like any entry's.
+func (r *VaultRevision) Entry() *Vault {
+	return &Vault{
+		ID:                r.VaultID,
+		CollectionID:      r.CollectionID,
+		OrganizationID:    r.OrganizationID,
+		Type:              r.Type,
+		Title:             r.Title,
+		Website:           r.Website,
+		Username:          r.Username,
+		FolderID:          r.FolderID,
+		Folder:            r.Folder,
+		Tags:              r.Tags,
+		Favorite:          r.Favorite,
+		EncryptedData:     r.EncryptedData,
+		EncryptionSalt:    r.EncryptionSalt,
+		Nonce:             r.Nonce,
+		EncryptionVersion: r.EncryptionVersion,
+		Revision:          r.Revision,
+	}
+}
+
+// FieldChange is one field that differs between two revisions. Field is a
+// JSON path such as "title", "card.number" or "fields.PIN"; Old or New is
+// null when the field is absent on that side.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// VaultRevisionResponse describes a revision and what changed since the
+// previous one. Changes to the encrypted payload are only listed when the
+// vault was unlocked; EncryptedData is given to zero-knowledge clients so
+// they can diff it themselves.
+type VaultRevisionResponse struct {
+	Revision      int           `json:"revision"`
+	Title         string        `json:"title"`
+	ChangedBy     uuid.UUID     `json:"changed_by"`
+	CreatedAt     time.Time     `json:"created_at"`
+	Changes       []FieldChange `json:"changes"`
+	EncryptedData string        `json:"encrypted_data,omitempty"`
+}
+
+// RestoreRevisionRequest restores an entry to a revision. Zero-knowledge
+// accounts leave MasterPassword empty.
+type RestoreRevisionRequest struct {
+	MasterPassword string `json:"master_password"`
+}
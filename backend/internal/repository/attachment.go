@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AttachmentRepository struct {
+	db *gorm.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB) *AttachmentRepository {
+	return &AttachmentRepository{db: db}
+}
+
+// Reserve records a pending attachment if its size fits in the user's quota,
+// counting the other pending uploads. The user's row is locked meanwhile, so
+// concurrent uploads are checked one after the other. A quota of 0 is
+// unlimited. It reports false when the quota would be exceeded.
+func (r *AttachmentRepository) Reserve(ctx context.Context, attachment *models.Attachment, quota int64) (bool, error) {
+	reserved := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if quota > 0 {
+			var user models.User
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", attachment.UserID).First(&user).Error; err != nil {
+				return err
+			}
+
+			var used int64
+			if err := tx.Model(&models.Attachment{}).
+				Where("user_id = ?", attachment.UserID).
+				Select("COALESCE(SUM(size), 0)").
+				Scan(&used).Error; err != nil {
+				return err
+			}
+			if used+attachment.Size > quota {
+				return nil
+			}
+		}
+
+		attachment.Pending = true
+		if err := tx.Create(attachment).Error; err != nil {
+			return err
+		}
+		reserved = true
+		return nil
+	})
+	return reserved, err
+}
+
+// MarkStored completes a pending attachment once its chunks are uploaded. It
+// reports false if the record was deleted meanwhile, with its entry.
+func (r *AttachmentRepository) MarkStored(ctx context.Context, id uuid.UUID) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.Attachment{}).
+		Where("id = ? AND pending = ?", id, true).
+		Update("pending", false)
+	return result.RowsAffected == 1, result.Error
+}
+
+// GetStalePending lists a user's uploads still pending since before cutoff,
+// left behind by a server that stopped mid-upload.
+func (r *AttachmentRepository) GetStalePending(ctx context.Context, userID uuid.UUID, cutoff time.Time) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND pending = ? AND created_at < ?", userID, true, cutoff).
+		Find(&attachments).Error
+	return attachments, err
+}
+
+// GetByID returns a stored attachment; pending uploads are not found.
+func (r *AttachmentRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Attachment, error) {
+	var attachment models.Attachment
+	err := r.db.WithContext(ctx).Where("id = ? AND pending = ?", id, false).First(&attachment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &attachment, err
+}
+
+// GetByVaultID lists the stored attachments of an entry, oldest first.
+func (r *AttachmentRepository) GetByVaultID(ctx context.Context, vaultID uuid.UUID) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := r.db.WithContext(ctx).
+		Where("vault_id = ? AND pending = ?", vaultID, false).
+		Order("created_at ASC").
+		Find(&attachments).Error
+	return attachments, err
+}
+
+// CountByVaultID counts the attachments of an entry.
+func (r *AttachmentRepository) CountByVaultID(ctx context.Context, vaultID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.Attachment{}).
+		Where("vault_id = ?", vaultID).
+		Count(&count).Error
+	return count, err
+}
+
+// CountByUserID counts the attachments a user uploaded.
+func (r *AttachmentRepository) CountByUserID(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.Attachment{}).
+		Where("user_id = ?", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// TotalSizeByUserID is the storage used by a user's attachments, in bytes,
+// including uploads in progress.
+func (r *AttachmentRepository) TotalSizeByUserID(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var total int64
+	err := r.db.WithContext(ctx).
+		Model(&models.Attachment{}).
+		Where("user_id = ?", userID).
+		Select("COALESCE(SUM(size), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+func (r *AttachmentRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.Attachment{}, id).Error
+}
+
+// DeleteByVaultID removes the attachment records of an entry.
+func (r *AttachmentRepository) DeleteByVaultID(ctx context.Context, vaultID uuid.UUID) error {
+	return r.db.WithContext(ctx).Where("vault_id = ?", vaultID).Delete(&models.Attachment{}).Error
+}
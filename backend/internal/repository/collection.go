@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"gorm.io/gorm"
+)
+
+type CollectionRepository struct {
+	db *gorm.DB
+}
+
+func NewCollectionRepository(db *gorm.DB) *CollectionRepository {
+	return &CollectionRepository{db: db}
+}
+
+func (r *CollectionRepository) Create(ctx context.Context, collection *models.Collection) error {
+	return r.db.WithContext(ctx).Create(collection).Error
+}
+
+func (r *CollectionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Collection, error) {
+	var collection models.Collection
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&collection).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &collection, err
+}
+
+// GetMembershipsForUser lists the collections userID belongs to together with
+// their membership, newest first.
+func (r *CollectionRepository) GetMembershipsForUser(ctx context.Context, userID uuid.UUID) ([]models.CollectionMember, error) {
+	var members []models.CollectionMember
+	err := r.db.WithContext(ctx).
+		Preload("Collection").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&members).Error
+	return members, err
+}
+
+// GetByOrganizationID lists the collections of an organization.
+func (r *CollectionRepository) GetByOrganizationID(ctx context.Context, organizationID uuid.UUID) ([]models.Collection, error) {
+	var collections []models.Collection
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ?", organizationID).
+		Order("name ASC").
+		Find(&collections).Error
+	return collections, err
+}
+
+func (r *CollectionRepository) Update(ctx context.Context, collection *models.Collection) error {
+	return r.db.WithContext(ctx).Save(collection).Error
+}
+
+// Delete removes a collection with its members and entries.
+func (r *CollectionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", id).Delete(&models.Vault{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("collection_id = ?", id).Delete(&models.CollectionMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Collection{}, id).Error
+	})
+}
+
+func (r *CollectionRepository) GetMember(ctx context.Context, collectionID, userID uuid.UUID) (*models.CollectionMember, error) {
+	var member models.CollectionMember
+	err := r.db.WithContext(ctx).
+		Where("collection_id = ? AND user_id = ?", collectionID, userID).
+		First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &member, err
+}
+
+// GetMembers lists the members of a collection with their user loaded.
+func (r *CollectionRepository) GetMembers(ctx context.Context, collectionID uuid.UUID) ([]models.CollectionMember, error) {
+	var members []models.CollectionMember
+	err := r.db.WithContext(ctx).
+		Preload("User").
+		Where("collection_id = ?", collectionID).
+		Order("created_at ASC").
+		Find(&members).Error
+	return members, err
+}
+
+// AddMember inserts member. The view and copy permissions are written again
+// afterwards because GORM replaces a false value with the column default.
+func (r *CollectionRepository) AddMember(ctx context.Context, member *models.CollectionMember) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(member).Error; err != nil {
+			return err
+		}
+		if member.CanView && member.CanCopy {
+			return nil
+		}
+		return tx.Model(&models.CollectionMember{}).
+			Where("id = ?", member.ID).
+			Updates(map[string]interface{}{
+				"can_view": member.CanView,
+				"can_copy": member.CanCopy,
+			}).Error
+	})
+}
+
+// UpdateMemberAccess changes a member's role and permissions.
+func (r *CollectionRepository) UpdateMemberAccess(ctx context.Context, member *models.CollectionMember) error {
+	return r.db.WithContext(ctx).
+		Model(&models.CollectionMember{}).
+		Where("id = ?", member.ID).
+		Updates(map[string]interface{}{
+			"role":     member.Role,
+			"can_view": member.CanView,
+			"can_copy": member.CanCopy,
+		}).Error
+}
+
+// UpdateMemberKey stores a member's copy of a new collection key.
+func (r *CollectionRepository) UpdateMemberKey(ctx context.Context, memberID uuid.UUID, encryptedKey string, keyVersion int) error {
+	return r.db.WithContext(ctx).
+		Model(&models.CollectionMember{}).
+		Where("id = ?", memberID).
+		Updates(map[string]interface{}{
+			"encrypted_key": encryptedKey,
+			"key_version":   keyVersion,
+		}).Error
+}
+
+func (r *CollectionRepository) RemoveMember(ctx context.Context, memberID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.CollectionMember{}, memberID).Error
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EmergencyAccessRepository struct {
+	db *gorm.DB
+}
+
+func NewEmergencyAccessRepository(db *gorm.DB) *EmergencyAccessRepository {
+	return &EmergencyAccessRepository{db: db}
+}
+
+func (r *EmergencyAccessRepository) Create(ctx context.Context, access *models.EmergencyAccess) error {
+	return r.db.WithContext(ctx).Create(access).Error
+}
+
+// GetByID loads a grant with both parties.
+func (r *EmergencyAccessRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.EmergencyAccess, error) {
+	var access models.EmergencyAccess
+	err := r.db.WithContext(ctx).
+		Preload("Grantor").
+		Preload("Grantee").
+		Where("id = ?", id).
+		First(&access).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &access, err
+}
+
+func (r *EmergencyAccessRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.EmergencyAccess, error) {
+	var access models.EmergencyAccess
+	err := r.db.WithContext(ctx).
+		Preload("Grantor").
+		Where("token_hash = ?", tokenHash).
+		First(&access).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &access, err
+}
+
+// GetByGrantorAndEmail finds the grant grantorID gave to email, if any.
+func (r *EmergencyAccessRepository) GetByGrantorAndEmail(ctx context.Context, grantorID uuid.UUID, email string) (*models.EmergencyAccess, error) {
+	var access models.EmergencyAccess
+	err := r.db.WithContext(ctx).
+		Where("grantor_id = ? AND LOWER(email) = LOWER(?)", grantorID, email).
+		First(&access).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &access, err
+}
+
+// GetByGrantor lists the trusted contacts of grantorID.
+func (r *EmergencyAccessRepository) GetByGrantor(ctx context.Context, grantorID uuid.UUID) ([]models.EmergencyAccess, error) {
+	var grants []models.EmergencyAccess
+	err := r.db.WithContext(ctx).
+		Preload("Grantee").
+		Where("grantor_id = ?", grantorID).
+		Order("created_at DESC").
+		Find(&grants).Error
+	return grants, err
+}
+
+// GetByGrantee lists the grants that made granteeID a trusted contact.
+func (r *EmergencyAccessRepository) GetByGrantee(ctx context.Context, granteeID uuid.UUID) ([]models.EmergencyAccess, error) {
+	var grants []models.EmergencyAccess
+	err := r.db.WithContext(ctx).
+		Preload("Grantor").
+		Where("grantee_id = ?", granteeID).
+		Order("created_at DESC").
+		Find(&grants).Error
+	return grants, err
+}
+
+// GetDueRecoveries lists the pending requests whose waiting period ended
+// before now.
+func (r *EmergencyAccessRepository) GetDueRecoveries(ctx context.Context, now time.Time) ([]models.EmergencyAccess, error) {
+	var grants []models.EmergencyAccess
+	err := r.db.WithContext(ctx).
+		Preload("Grantor").
+		Preload("Grantee").
+		Where("status = ? AND recovery_initiated_at + make_interval(days => wait_days) <= ?", models.EmergencyAccessRecoveryInitiated, now).
+		Find(&grants).Error
+	return grants, err
+}
+
+// Update saves access without touching the preloaded users.
+func (r *EmergencyAccessRepository) Update(ctx context.Context, access *models.EmergencyAccess) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Save(access).Error
+}
+
+// UpdateEncryptedKey replaces the grantee's sealed copy of the grantor's
+// vault key.
+func (r *EmergencyAccessRepository) UpdateEncryptedKey(ctx context.Context, id uuid.UUID, encryptedKey string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.EmergencyAccess{}).
+		Where("id = ?", id).
+		Update("encrypted_key", encryptedKey).Error
+}
+
+// TransitionStatus moves a grant from one status to another and reports
+// whether it was still in the expected status, so that a grantor's answer
+// and the auto-approval cannot both apply.
+func (r *EmergencyAccessRepository) TransitionStatus(ctx context.Context, id uuid.UUID, from, to string) (bool, error) {
+	updates := map[string]interface{}{"status": to}
+	switch to {
+	case models.EmergencyAccessRecoveryInitiated:
+		updates["recovery_initiated_at"] = time.Now()
+	case models.EmergencyAccessConfirmed:
+		updates["recovery_initiated_at"] = nil
+	}
+
+	result := r.db.WithContext(ctx).
+		Model(&models.EmergencyAccess{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ResetGrantorKeys drops every grantee's copy of grantorID's vault key after
+// it was replaced. Confirmed grants and pending requests go back to accepted
+// until the grantor confirms their contacts again.
+func (r *EmergencyAccessRepository) ResetGrantorKeys(ctx context.Context, grantorID uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&models.EmergencyAccess{}).
+		Where("grantor_id = ? AND status IN ?", grantorID, []string{
+			models.EmergencyAccessConfirmed,
+			models.EmergencyAccessRecoveryInitiated,
+			models.EmergencyAccessRecoveryApproved,
+		}).
+		Updates(map[string]interface{}{
+			"status":                models.EmergencyAccessAccepted,
+			"encrypted_key":         "",
+			"recovery_initiated_at": nil,
+		}).Error
+}
+
+func (r *EmergencyAccessRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.EmergencyAccess{}, id).Error
+}
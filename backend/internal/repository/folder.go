@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrFolderNameTaken is returned when a folder would sit next to another of
+// the same name, as enforced by idx_folders_user_parent_name.
+var ErrFolderNameTaken = errors.New("folder name taken")
+
+type FolderRepository struct {
+	db *gorm.DB
+}
+
+func NewFolderRepository(db *gorm.DB) *FolderRepository {
+	return &FolderRepository{db: db}
+}
+
+func (r *FolderRepository) Create(ctx context.Context, folder *models.Folder) error {
+	return translateFolderError(r.db.WithContext(ctx).Create(folder).Error)
+}
+
+func (r *FolderRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Folder, error) {
+	var folder models.Folder
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&folder).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &folder, err
+}
+
+// GetByUserID lists every folder of a user, whatever its depth.
+func (r *FolderRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Folder, error) {
+	var folders []models.Folder
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("name ASC").Find(&folders).Error
+	return folders, err
+}
+
+func (r *FolderRepository) Update(ctx context.Context, folder *models.Folder) error {
+	return translateFolderError(r.db.WithContext(ctx).Save(folder).Error)
+}
+
+func translateFolderError(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrFolderNameTaken
+	}
+	return err
+}
+
+// MoveChildren moves the subfolders of parentID under newParentID, or to the
+// top level when it is nil.
+func (r *FolderRepository) MoveChildren(ctx context.Context, parentID uuid.UUID, newParentID *uuid.UUID) error {
+	err := r.db.WithContext(ctx).
+		Model(&models.Folder{}).
+		Where("parent_id = ?", parentID).
+		Update("parent_id", newParentID).Error
+	return translateFolderError(err)
+}
+
+// Delete removes folders; their entries are left to the caller.
+func (r *FolderRepository) Delete(ctx context.Context, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Where("id IN ?", ids).Delete(&models.Folder{}).Error
+}
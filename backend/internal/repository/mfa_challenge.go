@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"gorm.io/gorm"
+)
+
+type MFAChallengeRepository struct {
+	db *gorm.DB
+}
+
+func NewMFAChallengeRepository(db *gorm.DB) *MFAChallengeRepository {
+	return &MFAChallengeRepository{db: db}
+}
+
+// Create records a challenge, dropping the user's expired ones on the way.
+func (r *MFAChallengeRepository) Create(ctx context.Context, challenge *models.MFAChallenge) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND expires_at < ?", challenge.UserID, time.Now()).
+			Delete(&models.MFAChallenge{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(challenge).Error
+	})
+}
+
+// UseAttempt counts one code attempt against an unexpired challenge of
+// userID. It reports false when the challenge is unknown, expired or out of
+// attempts, so that concurrent guesses cannot exceed maxAttempts.
+func (r *MFAChallengeRepository) UseAttempt(ctx context.Context, id, userID uuid.UUID, maxAttempts int) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.MFAChallenge{}).
+		Where("id = ? AND user_id = ? AND attempts < ? AND expires_at > ?", id, userID, maxAttempts, time.Now()).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	return result.RowsAffected == 1, result.Error
+}
+
+// Consume deletes a challenge once its code was accepted. It reports false
+// if another request consumed it first.
+func (r *MFAChallengeRepository) Consume(ctx context.Context, id uuid.UUID) (bool, error) {
+	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&models.MFAChallenge{})
+	return result.RowsAffected == 1, result.Error
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"gorm.io/gorm"
+)
+
+type OrganizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+func (r *OrganizationRepository) Create(ctx context.Context, organization *models.Organization) error {
+	return r.db.WithContext(ctx).Create(organization).Error
+}
+
+func (r *OrganizationRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Organization, error) {
+	var organization models.Organization
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&organization).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &organization, err
+}
+
+func (r *OrganizationRepository) Update(ctx context.Context, organization *models.Organization) error {
+	return r.db.WithContext(ctx).Save(organization).Error
+}
+
+// Delete removes an organization with its items, collections, members and
+// invitations.
+func (r *OrganizationRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		collections := tx.Model(&models.Collection{}).Select("id").Where("organization_id = ?", id)
+		if err := tx.Where("organization_id = ? OR collection_id IN (?)", id, collections).Delete(&models.Vault{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("collection_id IN (?)", collections).Delete(&models.CollectionMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&models.Collection{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&models.OrganizationInvitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&models.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Organization{}, id).Error
+	})
+}
+
+// GetMembershipsForUser lists the organizations userID belongs to together
+// with their membership.
+func (r *OrganizationRepository) GetMembershipsForUser(ctx context.Context, userID uuid.UUID) ([]models.OrganizationMember, error) {
+	var members []models.OrganizationMember
+	err := r.db.WithContext(ctx).
+		Preload("Organization").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&members).Error
+	return members, err
+}
+
+func (r *OrganizationRepository) GetMember(ctx context.Context, organizationID, userID uuid.UUID) (*models.OrganizationMember, error) {
+	var member models.OrganizationMember
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ? AND user_id = ?", organizationID, userID).
+		First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &member, err
+}
+
+// GetMembers lists the members of an organization with their user loaded.
+func (r *OrganizationRepository) GetMembers(ctx context.Context, organizationID uuid.UUID) ([]models.OrganizationMember, error) {
+	var members []models.OrganizationMember
+	err := r.db.WithContext(ctx).
+		Preload("User").
+		Where("organization_id = ?", organizationID).
+		Order("created_at ASC").
+		Find(&members).Error
+	return members, err
+}
+
+func (r *OrganizationRepository) AddMember(ctx context.Context, member *models.OrganizationMember) error {
+	return r.db.WithContext(ctx).Create(member).Error
+}
+
+func (r *OrganizationRepository) UpdateMemberRole(ctx context.Context, memberID uuid.UUID, role string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.OrganizationMember{}).
+		Where("id = ?", memberID).
+		Update("role", role).Error
+}
+
+// UpdateMemberKey stores a member's copy of the org key and marks them
+// confirmed.
+func (r *OrganizationRepository) UpdateMemberKey(ctx context.Context, memberID uuid.UUID, encryptedKey string, keyVersion int) error {
+	return r.db.WithContext(ctx).
+		Model(&models.OrganizationMember{}).
+		Where("id = ?", memberID).
+		Updates(map[string]interface{}{
+			"encrypted_key": encryptedKey,
+			"key_version":   keyVersion,
+			"status":        models.OrganizationMemberConfirmed,
+		}).Error
+}
+
+func (r *OrganizationRepository) RemoveMember(ctx context.Context, memberID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.OrganizationMember{}, memberID).Error
+}
+
+// CountCollectionMemberships counts the organization's collections userID
+// still belongs to.
+func (r *OrganizationRepository) CountCollectionMemberships(ctx context.Context, organizationID, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.CollectionMember{}).
+		Joins("JOIN collections ON collections.id = collection_members.collection_id").
+		Where("collections.organization_id = ? AND collection_members.user_id = ?", organizationID, userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *OrganizationRepository) CreateInvitation(ctx context.Context, invitation *models.OrganizationInvitation) error {
+	return r.db.WithContext(ctx).Create(invitation).Error
+}
+
+func (r *OrganizationRepository) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*models.OrganizationInvitation, error) {
+	var invitation models.OrganizationInvitation
+	err := r.db.WithContext(ctx).
+		Preload("Organization").
+		Where("token_hash = ?", tokenHash).
+		First(&invitation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &invitation, err
+}
+
+func (r *OrganizationRepository) GetInvitationByID(ctx context.Context, id uuid.UUID) (*models.OrganizationInvitation, error) {
+	var invitation models.OrganizationInvitation
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&invitation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &invitation, err
+}
+
+// GetPendingInvitations lists the open invitations of an organization.
+func (r *OrganizationRepository) GetPendingInvitations(ctx context.Context, organizationID uuid.UUID) ([]models.OrganizationInvitation, error) {
+	var invitations []models.OrganizationInvitation
+	err := r.db.WithContext(ctx).
+		Preload("Organization").
+		Where("organization_id = ? AND accepted_at IS NULL AND expires_at > ?", organizationID, time.Now()).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+// GetPendingInvitationsForEmail lists the open invitations sent to email.
+func (r *OrganizationRepository) GetPendingInvitationsForEmail(ctx context.Context, email string) ([]models.OrganizationInvitation, error) {
+	var invitations []models.OrganizationInvitation
+	err := r.db.WithContext(ctx).
+		Preload("Organization").
+		Where("LOWER(email) = LOWER(?) AND accepted_at IS NULL AND expires_at > ?", email, time.Now()).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *OrganizationRepository) MarkInvitationAccepted(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&models.OrganizationInvitation{}).
+		Where("id = ?", id).
+		Update("accepted_at", time.Now()).Error
+}
+
+func (r *OrganizationRepository) DeleteInvitation(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.OrganizationInvitation{}, id).Error
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"gorm.io/gorm"
+)
+
+type PasswordHistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordHistoryRepository(db *gorm.DB) *PasswordHistoryRepository {
+	return &PasswordHistoryRepository{db: db}
+}
+
+func (r *PasswordHistoryRepository) Create(ctx context.Context, history *models.PasswordHistory) error {
+	return r.db.WithContext(ctx).Create(history).Error
+}
+
+func (r *PasswordHistoryRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.PasswordHistory, error) {
+	var history models.PasswordHistory
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&history).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &history, err
+}
+
+// GetByVaultID lists the history of an entry, most recent change first.
+func (r *PasswordHistoryRepository) GetByVaultID(ctx context.Context, vaultID uuid.UUID) ([]models.PasswordHistory, error) {
+	var history []models.PasswordHistory
+	err := r.db.WithContext(ctx).
+		Where("vault_id = ?", vaultID).
+		Order("changed_at DESC").
+		Find(&history).Error
+	return history, err
+}
+
+// Prune drops the records of an entry beyond the keep most recent ones, and
+// those changed before olderThan. A zero keep or olderThan skips that rule.
+func (r *PasswordHistoryRepository) Prune(ctx context.Context, vaultID uuid.UUID, keep int, olderThan time.Time) error {
+	db := r.db.WithContext(ctx)
+
+	if keep > 0 {
+		kept := db.Model(&models.PasswordHistory{}).
+			Select("id").
+			Where("vault_id = ?", vaultID).
+			Order("changed_at DESC").
+			Limit(keep)
+		if err := db.Where("vault_id = ? AND id NOT IN (?)", vaultID, kept).Delete(&models.PasswordHistory{}).Error; err != nil {
+			return err
+		}
+	}
+
+	if !olderThan.IsZero() {
+		if err := db.Where("vault_id = ? AND changed_at < ?", vaultID, olderThan).Delete(&models.PasswordHistory{}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *PasswordHistoryRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.PasswordHistory{}, id).Error
+}
+
+// DeleteByUserID removes the history of every entry a user owns.
+func (r *PasswordHistoryRepository) DeleteByUserID(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Where("vault_id IN (?)", r.db.Model(&models.Vault{}).Select("id").Where("user_id = ?", userID)).
+		Delete(&models.PasswordHistory{}).Error
+}
+
+// DeleteByVaultID removes the history of an entry.
+func (r *PasswordHistoryRepository) DeleteByVaultID(ctx context.Context, vaultID uuid.UUID) error {
+	return r.db.WithContext(ctx).Where("vault_id = ?", vaultID).Delete(&models.PasswordHistory{}).Error
+}
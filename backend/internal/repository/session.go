@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"gorm.io/gorm"
+)
+
+// Reasons recorded when a session is revoked.
+const (
+	SessionRevokedLogout     = "logout"
+	SessionRevokedTokenReuse = "refresh_token_reuse"
+	SessionRevokedByUser     = "revoked_by_user"
+	SessionRevokedPassword   = "master_password_changed"
+)
+
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func (r *SessionRepository) Create(ctx context.Context, session *models.Session) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
+
+func (r *SessionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &session, err
+}
+
+// GetActiveByUserID lists the sessions that can still authenticate, most
+// recently used first.
+func (r *SessionRepository) GetActiveByUserID(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// RotateRefreshToken swaps the stored refresh token hash only if it still
+// matches oldHash, so two callers presenting the same token cannot both win.
+// It returns false when the session is revoked or the hash has moved on.
+func (r *SessionRepository) RotateRefreshToken(ctx context.Context, id uuid.UUID, oldHash, newHash string, expiresAt time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", id, oldHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash": newHash,
+			"expires_at":         expiresAt,
+			"last_seen_at":       time.Now(),
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// Touch records activity on a session.
+func (r *SessionRepository) Touch(ctx context.Context, id uuid.UUID, ipAddress string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.Session{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"last_seen_at": time.Now(),
+			"ip_address":   ipAddress,
+		}).Error
+}
+
+func (r *SessionRepository) Revoke(ctx context.Context, id uuid.UUID, reason string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		}).Error
+}
+
+// RevokeAllForUser revokes every active session of a user.
+func (r *SessionRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID, reason string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		}).Error
+}
+
+// RevokeOthersForUser revokes every active session of a user except keepID.
+func (r *SessionRepository) RevokeOthersForUser(ctx context.Context, userID, keepID uuid.UUID, reason string) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		})
+	return result.RowsAffected, result.Error
+}
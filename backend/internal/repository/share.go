@@ -8,6 +8,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/tresor/password-manager/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ShareRepository struct {
@@ -18,8 +19,23 @@ func NewShareRepository(db *gorm.DB) *ShareRepository {
 	return &ShareRepository{db: db}
 }
 
+// Create inserts share. The view and copy permissions are written again
+// afterwards because GORM replaces a false value with the column default.
 func (r *ShareRepository) Create(ctx context.Context, share *models.SharedPassword) error {
-	return r.db.WithContext(ctx).Create(share).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(share).Error; err != nil {
+			return err
+		}
+		if share.CanView && share.CanCopy {
+			return nil
+		}
+		return tx.Model(&models.SharedPassword{}).
+			Where("id = ?", share.ID).
+			Updates(map[string]interface{}{
+				"can_view": share.CanView,
+				"can_copy": share.CanCopy,
+			}).Error
+	})
 }
 
 func (r *ShareRepository) GetByToken(ctx context.Context, token string) (*models.SharedPassword, error) {
@@ -31,6 +47,15 @@ func (r *ShareRepository) GetByToken(ctx context.Context, token string) (*models
 	return &share, err
 }
 
+func (r *ShareRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.SharedPassword, error) {
+	var share models.SharedPassword
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&share).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &share, err
+}
+
 func (r *ShareRepository) GetSentShares(ctx context.Context, ownerID uuid.UUID) ([]models.SharedPassword, error) {
 	var shares []models.SharedPassword
 	err := r.db.WithContext(ctx).Where("owner_id = ?", ownerID).Order("created_at DESC").Find(&shares).Error
@@ -43,16 +68,157 @@ func (r *ShareRepository) GetReceivedShares(ctx context.Context, recipientID uui
 	return shares, err
 }
 
-func (r *ShareRepository) IncrementViewCount(ctx context.Context, id uuid.UUID) error {
+// IsSuspended reports whether share is suspended because its entry is in
+// the trash.
+func (r *ShareRepository) IsSuspended(ctx context.Context, share *models.SharedPassword) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.Vault{}).
+		Where("id = ? AND deleted_at IS NOT NULL", share.VaultID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// MarkSuspended sets Suspended on the shares whose entry is in the trash.
+func (r *ShareRepository) MarkSuspended(ctx context.Context, shares []models.SharedPassword) error {
+	if len(shares) == 0 {
+		return nil
+	}
+	vaultIDs := make([]uuid.UUID, len(shares))
+	for i := range shares {
+		vaultIDs[i] = shares[i].VaultID
+	}
+
+	var trashed []uuid.UUID
+	err := r.db.WithContext(ctx).
+		Model(&models.Vault{}).
+		Where("id IN ? AND deleted_at IS NOT NULL", vaultIDs).
+		Pluck("id", &trashed).Error
+	if err != nil {
+		return err
+	}
+
+	inTrash := make(map[uuid.UUID]bool, len(trashed))
+	for _, id := range trashed {
+		inTrash[id] = true
+	}
+	for i := range shares {
+		shares[i].Suspended = inTrash[shares[i].VaultID]
+	}
+	return nil
+}
+
+// IncrementViewCount counts one view of a share and returns the view count it
+// brought the share to, so that exactly one caller sees the first view.
+func (r *ShareRepository) IncrementViewCount(ctx context.Context, id uuid.UUID) (int, error) {
+	var share models.SharedPassword
+	err := r.db.WithContext(ctx).
+		Model(&share).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "view_count"}}}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"view_count":    gorm.Expr("view_count + ?", 1),
+			"last_accessed": time.Now(),
+		}).Error
+	return share.ViewCount, err
+}
+
+// UpdateEncryptedData replaces the payload of a share, used when it is
+// re-sealed to a new recipient key.
+func (r *ShareRepository) UpdateEncryptedData(ctx context.Context, id uuid.UUID, encryptedData string, version int) error {
 	return r.db.WithContext(ctx).
 		Model(&models.SharedPassword{}).
 		Where("id = ?", id).
 		Updates(map[string]interface{}{
-			"view_count":   gorm.Expr("view_count + ?", 1),
-			"last_accessed": time.Now(),
+			"encrypted_data":     encryptedData,
+			"encryption_version": version,
 		}).Error
 }
 
+// GetPendingByInvitationTokenHash returns the live user share, sent to an
+// email without an account, whose invitation token hashes to tokenHash.
+func (r *ShareRepository) GetPendingByInvitationTokenHash(ctx context.Context, tokenHash string) (*models.SharedPassword, error) {
+	var share models.SharedPassword
+	err := r.db.WithContext(ctx).
+		Where("invitation_token_hash = ? AND recipient_id IS NULL AND share_type = ? AND revoked = ?", tokenHash, models.ShareTypeUser, false).
+		First(&share).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &share, err
+}
+
+// BindRecipient attaches a pending share to the account that claimed its
+// invitation, replacing the escrowed payload and burning the invitation
+// token. It reports false if the share was bound in the meantime.
+func (r *ShareRepository) BindRecipient(ctx context.Context, id, recipientID uuid.UUID, encryptedData string, version int) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.SharedPassword{}).
+		Where("id = ? AND recipient_id IS NULL", id).
+		Updates(map[string]interface{}{
+			"recipient_id":          recipientID,
+			"encrypted_data":        encryptedData,
+			"encryption_version":    version,
+			"invitation_token_hash": nil,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// ConsumeView atomically counts one view of a share and returns its payload
+// and the view count it brought the share to. It reports false when the share
+// is revoked, expired, out of views or already burned by the time the row is
+// locked. Consuming the last view wipes the payload.
+func (r *ShareRepository) ConsumeView(ctx context.Context, id uuid.UUID) (string, int, bool, error) {
+	var payload string
+	var viewCount int
+	consumed := false
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var share models.SharedPassword
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&share).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if share.Revoked || share.EncryptedData == "" ||
+			(share.ExpiresAt != nil && now.After(*share.ExpiresAt)) ||
+			(share.MaxViews != nil && share.ViewCount >= *share.MaxViews) {
+			return nil
+		}
+
+		updates := map[string]interface{}{
+			"view_count":    share.ViewCount + 1,
+			"last_accessed": now,
+		}
+		if share.MaxViews != nil && share.ViewCount+1 >= *share.MaxViews {
+			updates["encrypted_data"] = ""
+		}
+		if err := tx.Model(&models.SharedPassword{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		payload = share.EncryptedData
+		viewCount = share.ViewCount + 1
+		consumed = true
+		return nil
+	})
+
+	return payload, viewCount, consumed, err
+}
+
+// Burn wipes the payload of a share that can no longer be opened.
+func (r *ShareRepository) Burn(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&models.SharedPassword{}).
+		Where("id = ?", id).
+		Update("encrypted_data", "").
+		Error
+}
+
 func (r *ShareRepository) Revoke(ctx context.Context, token string, ownerID uuid.UUID) error {
 	return r.db.WithContext(ctx).
 		Where("share_token = ? AND owner_id = ?", token, ownerID).
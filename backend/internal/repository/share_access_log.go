@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"gorm.io/gorm"
+)
+
+type ShareAccessLogRepository struct {
+	db *gorm.DB
+}
+
+func NewShareAccessLogRepository(db *gorm.DB) *ShareAccessLogRepository {
+	return &ShareAccessLogRepository{db: db}
+}
+
+func (r *ShareAccessLogRepository) Create(ctx context.Context, entry *models.ShareAccessLog) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+// GetByShareID lists the most recent access attempts on a share, newest
+// first.
+func (r *ShareAccessLogRepository) GetByShareID(ctx context.Context, shareID uuid.UUID, limit int) ([]models.ShareAccessLog, error) {
+	var entries []models.ShareAccessLog
+	err := r.db.WithContext(ctx).
+		Where("share_id = ?", shareID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"gorm.io/gorm"
+)
+
+type SharedEditRepository struct {
+	db *gorm.DB
+}
+
+func NewSharedEditRepository(db *gorm.DB) *SharedEditRepository {
+	return &SharedEditRepository{db: db}
+}
+
+func (r *SharedEditRepository) Create(ctx context.Context, edit *models.SharedEdit) error {
+	return r.db.WithContext(ctx).Create(edit).Error
+}
+
+func (r *SharedEditRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.SharedEdit, error) {
+	var edit models.SharedEdit
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&edit).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &edit, err
+}
+
+// GetPendingForOwner lists the edits waiting to be applied to an owner's
+// entries, oldest first so that later edits win.
+func (r *SharedEditRepository) GetPendingForOwner(ctx context.Context, ownerID uuid.UUID) ([]models.SharedEdit, error) {
+	var edits []models.SharedEdit
+	err := r.db.WithContext(ctx).
+		Where("owner_id = ? AND applied_at IS NULL", ownerID).
+		Order("created_at ASC").
+		Find(&edits).Error
+	return edits, err
+}
+
+func (r *SharedEditRepository) MarkApplied(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&models.SharedEdit{}).
+		Where("id = ? AND applied_at IS NULL", id).
+		Update("applied_at", time.Now()).
+		Error
+}
+
+// UpdateEncryptedData replaces the payload of an edit, used when it is
+// re-sealed to a new owner key.
+func (r *SharedEditRepository) UpdateEncryptedData(ctx context.Context, id uuid.UUID, encryptedData string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.SharedEdit{}).
+		Where("id = ?", id).
+		Update("encrypted_data", encryptedData).
+		Error
+}
+
+// Delete removes an edit the owner dismissed.
+func (r *SharedEditRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.SharedEdit{}, id).Error
+}
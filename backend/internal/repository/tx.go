@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// TxRepositories exposes repositories bound to a single database transaction.
+type TxRepositories struct {
+	Users           *UserRepository
+	Vaults          *VaultRepository
+	Shares          *ShareRepository
+	Sessions        *SessionRepository
+	SharedEdits     *SharedEditRepository
+	Collections     *CollectionRepository
+	Organizations   *OrganizationRepository
+	EmergencyAccess *EmergencyAccessRepository
+	PasswordHistory *PasswordHistoryRepository
+	Folders         *FolderRepository
+}
+
+// TxManager runs multi-repository writes atomically.
+type TxManager struct {
+	db *gorm.DB
+}
+
+func NewTxManager(db *gorm.DB) *TxManager {
+	return &TxManager{db: db}
+}
+
+// WithinTx runs fn in a transaction. Returning an error from fn rolls back
+// every write made through the provided repositories.
+func (m *TxManager) WithinTx(ctx context.Context, fn func(repos *TxRepositories) error) error {
+	return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&TxRepositories{
+			Users:           NewUserRepository(tx),
+			Vaults:          NewVaultRepository(tx),
+			Shares:          NewShareRepository(tx),
+			Sessions:        NewSessionRepository(tx),
+			SharedEdits:     NewSharedEditRepository(tx),
+			Collections:     NewCollectionRepository(tx),
+			Organizations:   NewOrganizationRepository(tx),
+			EmergencyAccess: NewEmergencyAccessRepository(tx),
+			PasswordHistory: NewPasswordHistoryRepository(tx),
+			Folders:         NewFolderRepository(tx),
+		})
+	})
+}
@@ -60,6 +60,17 @@ func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
 	return r.db.WithContext(ctx).Save(user).Error
 }
 
+// AdvanceTwoFactorStep records step as the last accepted TOTP step, unless
+// that step or a later one was already used. It reports false on a replayed
+// code.
+func (r *UserRepository) AdvanceTwoFactorStep(ctx context.Context, id uuid.UUID, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ? AND (two_factor_last_step IS NULL OR two_factor_last_step < ?)", id, step).
+		Update("two_factor_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
 // Delete deletes a user from the database
 func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return r.db.WithContext(ctx).Delete(&models.User{}, id).Error
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/tresor/password-manager/internal/models"
@@ -17,8 +18,14 @@ func NewVaultRepository(db *gorm.DB) *VaultRepository {
 	return &VaultRepository{db: db}
 }
 
+// Create stores a new entry along with its first revision.
 func (r *VaultRepository) Create(ctx context.Context, vault *models.Vault) error {
-	return r.db.WithContext(ctx).Create(vault).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(vault).Error; err != nil {
+			return err
+		}
+		return tx.Create(models.NewVaultRevision(vault)).Error
+	})
 }
 
 func (r *VaultRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Vault, error) {
@@ -30,27 +37,205 @@ func (r *VaultRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Va
 	return &vault, err
 }
 
+// personalEntries restricts a query to entries in their owner's own vault.
+// Collection entries and organization items are stored under their owner's
+// user ID too, so every per-user query here applies it.
+func personalEntries(db *gorm.DB) *gorm.DB {
+	return db.Where("collection_id IS NULL AND organization_id IS NULL")
+}
+
+// notTrashed leaves out the entries waiting in the trash.
+func notTrashed(db *gorm.DB) *gorm.DB {
+	return db.Where("deleted_at IS NULL")
+}
+
+// GetByUserID lists a user's personal entries, leaving out the trash.
 func (r *VaultRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Vault, error) {
 	var vaults []models.Vault
-	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&vaults).Error
+	err := r.db.WithContext(ctx).Scopes(personalEntries, notTrashed).Where("user_id = ?", userID).Order("created_at DESC").Find(&vaults).Error
 	return vaults, err
 }
 
-func (r *VaultRepository) Update(ctx context.Context, vault *models.Vault) error {
-	return r.db.WithContext(ctx).Save(vault).Error
+// GetAllByUserID lists a user's personal entries including those in the
+// trash, for operations that must cover every entry the account holds.
+func (r *VaultRepository) GetAllByUserID(ctx context.Context, userID uuid.UUID) ([]models.Vault, error) {
+	var vaults []models.Vault
+	err := r.db.WithContext(ctx).Scopes(personalEntries).Where("user_id = ?", userID).Order("created_at DESC").Find(&vaults).Error
+	return vaults, err
 }
 
-func (r *VaultRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&models.Vault{}, id).Error
+// GetTrashByUserID lists the entries in a user's trash, most recently
+// deleted first.
+func (r *VaultRepository) GetTrashByUserID(ctx context.Context, userID uuid.UUID) ([]models.Vault, error) {
+	var vaults []models.Vault
+	err := r.db.WithContext(ctx).
+		Scopes(personalEntries).
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at DESC").
+		Find(&vaults).Error
+	return vaults, err
+}
+
+// GetTrashedBefore lists the entries of every user that went to the trash
+// before cutoff, at most limit of them.
+func (r *VaultRepository) GetTrashedBefore(ctx context.Context, cutoff time.Time, limit int) ([]models.Vault, error) {
+	var vaults []models.Vault
+	err := r.db.WithContext(ctx).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Order("deleted_at ASC").
+		Limit(limit).
+		Find(&vaults).Error
+	return vaults, err
+}
+
+// GetByEncryptionVersion lists a user's entries still on the given encryption
+// version, used to migrate them forward.
+func (r *VaultRepository) GetByEncryptionVersion(ctx context.Context, userID uuid.UUID, version int) ([]models.Vault, error) {
+	var vaults []models.Vault
+	err := r.db.WithContext(ctx).
+		Scopes(personalEntries).
+		Where("user_id = ? AND encryption_version = ?", userID, version).
+		Find(&vaults).Error
+	return vaults, err
+}
+
+// GetBelowEncryptionVersion lists a user's entries written with an
+// encryption version older than version.
+func (r *VaultRepository) GetBelowEncryptionVersion(ctx context.Context, userID uuid.UUID, version int) ([]models.Vault, error) {
+	var vaults []models.Vault
+	err := r.db.WithContext(ctx).
+		Scopes(personalEntries).
+		Where("user_id = ? AND encryption_version < ?", userID, version).
+		Find(&vaults).Error
+	return vaults, err
+}
+
+func (r *VaultRepository) CountBelowEncryptionVersion(ctx context.Context, userID uuid.UUID, version int) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.Vault{}).
+		Scopes(personalEntries).
+		Where("user_id = ? AND encryption_version < ?", userID, version).
+		Count(&count).Error
+	return count, err
+}
+
+// UpdateIfRevision saves vault only if the stored row is still at
+// expectedRevision, bumping the revision and appending it to the entry's
+// revisions. It reports false when another writer got there first.
+func (r *VaultRepository) UpdateIfRevision(ctx context.Context, vault *models.Vault, expectedRevision int) (bool, error) {
+	vault.Revision = expectedRevision + 1
+	updated := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Vault{}).
+			Where("id = ? AND revision = ?", vault.ID, expectedRevision).
+			Select("collection_id", "organization_id", "title", "website", "username", "folder_id", "folder", "tags", "favorite",
+				"encrypted_data", "encryption_salt", "nonce", "encryption_version", "revision", "last_modified_by",
+				"deleted_at", "updated_at").
+			Updates(vault)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		updated = true
+		return tx.Create(models.NewVaultRevision(vault)).Error
+	})
+	if err != nil || !updated {
+		vault.Revision = expectedRevision
+		return false, err
+	}
+	return true, nil
+}
+
+// GetByFolderIDs lists a user's personal entries filed in any of the given
+// folders, including those in the trash.
+func (r *VaultRepository) GetByFolderIDs(ctx context.Context, userID uuid.UUID, folderIDs []uuid.UUID) ([]models.Vault, error) {
+	var vaults []models.Vault
+	if len(folderIDs) == 0 {
+		return vaults, nil
+	}
+	err := r.db.WithContext(ctx).
+		Scopes(personalEntries).
+		Where("user_id = ? AND folder_id IN ?", userID, folderIDs).
+		Find(&vaults).Error
+	return vaults, err
+}
+
+// CountByFolder counts a user's personal entries per folder, leaving out the
+// trash and the entries without a folder.
+func (r *VaultRepository) CountByFolder(ctx context.Context, userID uuid.UUID) (map[uuid.UUID]int64, error) {
+	var rows []struct {
+		FolderID uuid.UUID
+		Count    int64
+	}
+	err := r.db.WithContext(ctx).
+		Model(&models.Vault{}).
+		Scopes(personalEntries, notTrashed).
+		Select("folder_id, COUNT(*) AS count").
+		Where("user_id = ? AND folder_id IS NOT NULL", userID).
+		Group("folder_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		counts[row.FolderID] = row.Count
+	}
+	return counts, nil
+}
+
+// UnfiledFolder is a folder path that personal entries carry without
+// pointing at a folder, as written before folders existed.
+type UnfiledFolder struct {
+	UserID uuid.UUID
+	Folder string
 }
 
-func (r *VaultRepository) Search(ctx context.Context, userID uuid.UUID, searchTerm string) ([]models.Vault, error) {
+// GetUnfiledFolders lists the distinct folder paths of entries that do not
+// point at a folder, at most limit of them.
+func (r *VaultRepository) GetUnfiledFolders(ctx context.Context, limit int) ([]UnfiledFolder, error) {
+	var unfiled []UnfiledFolder
+	err := r.db.WithContext(ctx).
+		Model(&models.Vault{}).
+		Scopes(personalEntries).
+		Distinct("user_id", "folder").
+		Where("folder_id IS NULL AND folder IS NOT NULL AND folder <> ''").
+		Limit(limit).
+		Scan(&unfiled).Error
+	return unfiled, err
+}
+
+// FileFolder points the entries of unfiled at folderID and gives them the
+// folder's path, or takes them out of any folder when folderID is nil.
+func (r *VaultRepository) FileFolder(ctx context.Context, unfiled UnfiledFolder, folderID *uuid.UUID, path *string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.Vault{}).
+		Scopes(personalEntries).
+		Where("user_id = ? AND folder = ? AND folder_id IS NULL", unfiled.UserID, unfiled.Folder).
+		Updates(map[string]any{"folder_id": folderID, "folder": path, "updated_at": time.Now()}).Error
+}
+
+// GetByCollectionID lists the entries of a collection.
+func (r *VaultRepository) GetByCollectionID(ctx context.Context, collectionID uuid.UUID) ([]models.Vault, error) {
 	var vaults []models.Vault
-	searchPattern := "%" + searchTerm + "%"
 	err := r.db.WithContext(ctx).
-		Where("user_id = ?", userID).
-		Where("title ILIKE ? OR website ILIKE ? OR username ILIKE ?", searchPattern, searchPattern, searchPattern).
+		Where("collection_id = ?", collectionID).
 		Order("created_at DESC").
 		Find(&vaults).Error
 	return vaults, err
 }
+
+// GetByOrganizationID lists the items of an organization.
+func (r *VaultRepository) GetByOrganizationID(ctx context.Context, organizationID uuid.UUID) ([]models.Vault, error) {
+	var vaults []models.Vault
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ?", organizationID).
+		Order("created_at DESC").
+		Find(&vaults).Error
+	return vaults, err
+}
+
+func (r *VaultRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.Vault{}, id).Error
+}
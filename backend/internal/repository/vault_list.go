@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/tresor/password-manager/internal/models"
+	"gorm.io/gorm"
+)
+
+// Sort orders of a vault listing.
+const (
+	VaultSortCreated  = "created"
+	VaultSortUpdated  = "updated"
+	VaultSortTitle    = "title"
+	VaultSortLastUsed = "last_used"
+)
+
+// ErrInvalidCursor is returned for a cursor that was not handed out by a
+// listing with the same sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// vaultSort is the column a sort order compares, and the same expression
+// applied to a cursor value. The columns match the indexes created for them.
+type vaultSort struct {
+	column string
+	cursor string
+}
+
+var vaultSorts = map[string]vaultSort{
+	VaultSortCreated:  {column: "created_at", cursor: "?::timestamptz"},
+	VaultSortUpdated:  {column: "updated_at", cursor: "?::timestamptz"},
+	VaultSortTitle:    {column: "LOWER(title)", cursor: "LOWER(?)"},
+	VaultSortLastUsed: {column: "COALESCE(last_used, to_timestamp(0))", cursor: "COALESCE(?::timestamptz, to_timestamp(0))"},
+}
+
+// IsVaultSort reports whether sort is one of the VaultSort orders.
+func IsVaultSort(sort string) bool {
+	_, ok := vaultSorts[sort]
+	return ok
+}
+
+// VaultListOptions narrows a listing of a user's personal entries. Zero
+// values do not filter. Entries in the trash are left out unless
+// IncludeTrashed is set.
+type VaultListOptions struct {
+	Type string
+	// Query keeps the entries whose title, website or username contains it,
+	// ignoring case.
+	Query string
+	// FolderID keeps the entries filed in one folder. Folder keeps those of
+	// one folder path; an empty path keeps the entries without a folder.
+	FolderID *uuid.UUID
+	Folder   *string
+	Favorite *bool
+	// Tags keeps the entries that carry every one of them.
+	Tags           []string
+	UpdatedSince   *time.Time
+	IncludeTrashed bool
+
+	// Sort is one of the VaultSort orders. It defaults to the newest first,
+	// or to the order the entries changed in when UpdatedSince is set.
+	Sort       string
+	Descending bool
+	// After is the cursor of the last entry of the previous page, and Limit
+	// the size of a page; zero lists every entry.
+	After string
+	Limit int
+}
+
+func (opts *VaultListOptions) sortOrder() (string, bool) {
+	switch {
+	case opts.Sort != "":
+		return opts.Sort, opts.Descending
+	case opts.UpdatedSince != nil:
+		return VaultSortUpdated, false
+	default:
+		return VaultSortCreated, true
+	}
+}
+
+// List lists a user's personal entries matching opts, in the order opts
+// asks for, one page at a time when opts.Limit is set.
+func (r *VaultRepository) List(ctx context.Context, userID uuid.UUID, opts VaultListOptions) ([]models.Vault, error) {
+	sortName, descending := opts.sortOrder()
+	sort, ok := vaultSorts[sortName]
+	if !ok {
+		return nil, fmt.Errorf("unknown vault sort %q", sortName)
+	}
+
+	direction, comparison := "ASC", ">"
+	if descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	query := r.filtered(ctx, userID, &opts)
+	if opts.After != "" {
+		value, id, err := decodeVaultCursor(opts.After, sortName)
+		if err != nil {
+			return nil, err
+		}
+		// The ID breaks ties, so that entries sharing a value are neither
+		// skipped nor repeated across pages.
+		query = query.Where(fmt.Sprintf("(%s, id) %s (%s, ?)", sort.column, comparison, sort.cursor), value, id)
+	}
+	query = query.Order(fmt.Sprintf("%s %s, id %s", sort.column, direction, direction))
+	if opts.Limit > 0 {
+		query = query.Limit(opts.Limit)
+	}
+
+	var vaults []models.Vault
+	err := query.Find(&vaults).Error
+	return vaults, err
+}
+
+// Count counts a user's personal entries matching the filters of opts,
+// across all pages.
+func (r *VaultRepository) Count(ctx context.Context, userID uuid.UUID, opts VaultListOptions) (int64, error) {
+	var count int64
+	err := r.filtered(ctx, userID, &opts).Model(&models.Vault{}).Count(&count).Error
+	return count, err
+}
+
+func (r *VaultRepository) filtered(ctx context.Context, userID uuid.UUID, opts *VaultListOptions) *gorm.DB {
+	query := r.db.WithContext(ctx).Scopes(personalEntries).Where("user_id = ?", userID)
+	if !opts.IncludeTrashed {
+		query = query.Scopes(notTrashed)
+	}
+	if opts.Type != "" {
+		query = query.Where("type = ?", opts.Type)
+	}
+	if opts.Query != "" {
+		pattern := likePattern(opts.Query)
+		query = query.Where("(title ILIKE ? OR website ILIKE ? OR username ILIKE ?)", pattern, pattern, pattern)
+	}
+	if opts.FolderID != nil {
+		query = query.Where("folder_id = ?", *opts.FolderID)
+	}
+	if opts.Folder != nil {
+		if *opts.Folder == "" {
+			query = query.Where("(folder IS NULL OR folder = '')")
+		} else {
+			query = query.Where("folder = ?", *opts.Folder)
+		}
+	}
+	if opts.Favorite != nil {
+		query = query.Where("favorite = ?", *opts.Favorite)
+	}
+	if len(opts.Tags) > 0 {
+		query = query.Where("tags @> ?", pq.StringArray(opts.Tags))
+	}
+	if opts.UpdatedSince != nil {
+		query = query.Where("updated_at > ?", *opts.UpdatedSince)
+	}
+	return query
+}
+
+// likePattern matches term anywhere in a value, with the LIKE wildcards in
+// term taken literally.
+func likePattern(term string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + escaper.Replace(term) + "%"
+}
+
+// vaultCursor is the position of an entry in a listing: the value it is
+// sorted by and its ID. It travels as opaque base64url-encoded JSON.
+type vaultCursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    uuid.UUID       `json:"id"`
+}
+
+// VaultCursor returns the cursor that continues a listing made with opts
+// after vault.
+func VaultCursor(opts VaultListOptions, vault *models.Vault) string {
+	sortName, _ := opts.sortOrder()
+
+	var value any
+	switch sortName {
+	case VaultSortCreated:
+		value = vault.CreatedAt
+	case VaultSortUpdated:
+		value = vault.UpdatedAt
+	case VaultSortTitle:
+		value = vault.Title
+	case VaultSortLastUsed:
+		value = vault.LastUsed
+	}
+
+	encodedValue, _ := json.Marshal(value)
+	encoded, _ := json.Marshal(vaultCursor{Sort: sortName, Value: encodedValue, ID: vault.ID})
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeVaultCursor returns the sort value and ID held by a cursor handed
+// out for the sortName order.
+func decodeVaultCursor(encoded, sortName string) (any, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, uuid.Nil, ErrInvalidCursor
+	}
+	var cursor vaultCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.Sort != sortName || cursor.ID == uuid.Nil {
+		return nil, uuid.Nil, ErrInvalidCursor
+	}
+
+	switch sortName {
+	case VaultSortTitle:
+		var title string
+		if err := json.Unmarshal(cursor.Value, &title); err != nil {
+			return nil, uuid.Nil, ErrInvalidCursor
+		}
+		return title, cursor.ID, nil
+	case VaultSortLastUsed:
+		var lastUsed *time.Time
+		if err := json.Unmarshal(cursor.Value, &lastUsed); err != nil {
+			return nil, uuid.Nil, ErrInvalidCursor
+		}
+		return lastUsed, cursor.ID, nil
+	default:
+		var t time.Time
+		if err := json.Unmarshal(cursor.Value, &t); err != nil {
+			return nil, uuid.Nil, ErrInvalidCursor
+		}
+		return t, cursor.ID, nil
+	}
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"gorm.io/gorm"
+)
+
+// VaultRevisionRepository reads the revisions that VaultRepository appends
+// on every write; revisions are never changed once written.
+type VaultRevisionRepository struct {
+	db *gorm.DB
+}
+
+func NewVaultRevisionRepository(db *gorm.DB) *VaultRevisionRepository {
+	return &VaultRevisionRepository{db: db}
+}
+
+// GetByVaultID lists the revisions of an entry, oldest first.
+func (r *VaultRevisionRepository) GetByVaultID(ctx context.Context, vaultID uuid.UUID) ([]models.VaultRevision, error) {
+	var revisions []models.VaultRevision
+	err := r.db.WithContext(ctx).
+		Where("vault_id = ?", vaultID).
+		Order("revision ASC").
+		Find(&revisions).Error
+	return revisions, err
+}
+
+// GetByRevision returns the revision of an entry with the given number.
+func (r *VaultRevisionRepository) GetByRevision(ctx context.Context, vaultID uuid.UUID, revision int) (*models.VaultRevision, error) {
+	var vaultRevision models.VaultRevision
+	err := r.db.WithContext(ctx).
+		Where("vault_id = ? AND revision = ?", vaultID, revision).
+		First(&vaultRevision).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &vaultRevision, err
+}
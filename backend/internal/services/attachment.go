@@ -0,0 +1,344 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/config"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+)
+
+var (
+	ErrAttachmentEmpty    = errors.New("attachment is empty")
+	ErrAttachmentTooLarge = errors.New("attachment exceeds the maximum file size")
+	ErrAttachmentQuota    = errors.New("attachment storage quota exceeded")
+	// ErrAttachmentKey means the file key could not be unwrapped with the
+	// entry's key.
+	ErrAttachmentKey = errors.New("attachment key cannot be opened")
+	// ErrAttachmentSize means the upload did not have the announced size.
+	ErrAttachmentSize = errors.New("attachment size does not match its content")
+	// ErrAttachmentEntryDeleted means the entry was deleted during the upload.
+	ErrAttachmentEntryDeleted = errors.New("attachment entry deleted during upload")
+)
+
+// AttachmentService stores attachments as chunks in a BlobStore.
+//
+// Server-side chunks are sealed with AES-256-GCM under a random per-file
+// key. Each chunk's additional data binds the attachment ID, the chunk index
+// and whether it is the last chunk, so chunks cannot be swapped between
+// files, reordered or dropped from the end without detection.
+type AttachmentService struct {
+	attachmentRepo *repository.AttachmentRepository
+	blobStore      BlobStore
+	cryptoService  *CryptoService
+	maxFileSize    int64
+	userQuota      int64
+	chunkSize      int
+	// staleUploadAfter is how long an upload may stay pending before it is
+	// taken for one abandoned by a stopped server.
+	staleUploadAfter time.Duration
+}
+
+func NewAttachmentService(
+	cfg *config.AttachmentsConfig,
+	attachmentRepo *repository.AttachmentRepository,
+	blobStore BlobStore,
+	cryptoService *CryptoService,
+) *AttachmentService {
+	chunkSize := cfg.ChunkSizeKiB * 1024
+	if chunkSize <= 0 {
+		chunkSize = 1024 * 1024
+	}
+	staleUploadAfter := 2 * time.Duration(cfg.TransferTimeoutMinutes) * time.Minute
+	if staleUploadAfter <= 0 {
+		staleUploadAfter = 24 * time.Hour
+	}
+	return &AttachmentService{
+		attachmentRepo: attachmentRepo,
+		blobStore:      blobStore,
+		cryptoService:  cryptoService,
+		maxFileSize:    int64(cfg.MaxFileSizeMB) * 1024 * 1024,
+		userQuota:      int64(cfg.UserQuotaMB) * 1024 * 1024,
+		chunkSize:      chunkSize,
+
+		staleUploadAfter: staleUploadAfter,
+	}
+}
+
+// MaxFileSize is the largest attachment accepted, in bytes, or 0 when
+// unlimited.
+func (s *AttachmentService) MaxFileSize() int64 {
+	return s.maxFileSize
+}
+
+// Usage returns the bytes used by a user's attachments and their quota,
+// which is 0 when unlimited.
+func (s *AttachmentService) Usage(ctx context.Context, userID uuid.UUID) (int64, int64, error) {
+	used, err := s.attachmentRepo.TotalSizeByUserID(ctx, userID)
+	return used, s.userQuota, err
+}
+
+// Store encrypts content under a fresh file key, stores it in chunks and
+// records the attachment. attachment must carry its VaultID, UserID,
+// FileName, ContentType and Size; EncryptedKey is set to the file key
+// wrapped with itemKey.
+func (s *AttachmentService) Store(ctx context.Context, attachment *models.Attachment, content io.Reader, itemKey []byte) error {
+	fileKey, err := s.cryptoService.GenerateKey()
+	if err != nil {
+		return err
+	}
+	wrappedKey, err := s.cryptoService.SealWithKey(fileKey, itemKey)
+	if err != nil {
+		return err
+	}
+	gcm, err := newChunkCipher(fileKey)
+	if err != nil {
+		return err
+	}
+
+	attachment.EncryptedKey = wrappedKey
+	attachment.EncryptionVersion = models.EncryptionVersionCurrent
+	return s.store(ctx, attachment, content, func(index int, final bool, chunk []byte) ([]byte, error) {
+		return sealChunk(gcm, attachment.ID, index, final, chunk)
+	})
+}
+
+// StoreClientEncrypted stores content encrypted by a zero-knowledge client
+// as is, together with the file key the client wrapped.
+func (s *AttachmentService) StoreClientEncrypted(ctx context.Context, attachment *models.Attachment, content io.Reader, encryptedKey string) error {
+	attachment.EncryptedKey = encryptedKey
+	attachment.EncryptionVersion = models.EncryptionVersionClient
+	return s.store(ctx, attachment, content, func(_ int, _ bool, chunk []byte) ([]byte, error) {
+		return chunk, nil
+	})
+}
+
+func (s *AttachmentService) store(ctx context.Context, attachment *models.Attachment, content io.Reader, seal func(index int, final bool, chunk []byte) ([]byte, error)) error {
+	if attachment.Size <= 0 {
+		return ErrAttachmentEmpty
+	}
+	if s.maxFileSize > 0 && attachment.Size > s.maxFileSize {
+		return ErrAttachmentTooLarge
+	}
+
+	if attachment.ID == uuid.Nil {
+		attachment.ID = uuid.New()
+	}
+	attachment.ChunkSize = s.chunkSize
+	attachment.ChunkCount = int((attachment.Size + int64(s.chunkSize) - 1) / int64(s.chunkSize))
+
+	// The size is reserved before the upload, so that parallel uploads
+	// cannot overshoot the quota together.
+	s.deleteStaleUploads(ctx, attachment.UserID)
+	reserved, err := s.attachmentRepo.Reserve(ctx, attachment, s.userQuota)
+	if err != nil {
+		return err
+	}
+	if !reserved {
+		return ErrAttachmentQuota
+	}
+	if err := s.upload(ctx, attachment, content, seal); err != nil {
+		if deleteErr := s.attachmentRepo.Delete(ctx, attachment.ID); deleteErr != nil {
+			log.Printf("Failed to release pending attachment %s: %v", attachment.ID, deleteErr)
+		}
+		return err
+	}
+
+	stored, err := s.attachmentRepo.MarkStored(ctx, attachment.ID)
+	if err == nil && !stored {
+		err = ErrAttachmentEntryDeleted
+	}
+	if err != nil {
+		s.deleteChunks(ctx, attachment.ID, attachment.ChunkCount)
+		return err
+	}
+	attachment.Pending = false
+	return nil
+}
+
+// upload seals and stores the chunks of content, removing the ones already
+// stored if it fails.
+func (s *AttachmentService) upload(ctx context.Context, attachment *models.Attachment, content io.Reader, seal func(index int, final bool, chunk []byte) ([]byte, error)) error {
+	buf := make([]byte, s.chunkSize)
+	var total int64
+	stored := 0
+	for index := 0; index < attachment.ChunkCount; index++ {
+		n, err := io.ReadFull(content, buf)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			s.deleteChunks(ctx, attachment.ID, stored)
+			return err
+		}
+		total += int64(n)
+		final := index == attachment.ChunkCount-1
+		if n == 0 || (!final && n < s.chunkSize) {
+			s.deleteChunks(ctx, attachment.ID, stored)
+			return ErrAttachmentSize
+		}
+
+		sealed, err := seal(index, final, buf[:n])
+		if err != nil {
+			s.deleteChunks(ctx, attachment.ID, stored)
+			return err
+		}
+		if err := s.blobStore.Put(ctx, chunkKey(attachment.ID, index), sealed); err != nil {
+			s.deleteChunks(ctx, attachment.ID, stored)
+			return err
+		}
+		stored++
+	}
+
+	// Anything left over means the content is larger than announced.
+	if n, _ := content.Read(buf[:1]); n > 0 || total != attachment.Size {
+		s.deleteChunks(ctx, attachment.ID, stored)
+		return ErrAttachmentSize
+	}
+	return nil
+}
+
+// deleteStaleUploads releases the quota held by a user's uploads that were
+// abandoned mid-way. Failures are logged; the next upload tries again.
+func (s *AttachmentService) deleteStaleUploads(ctx context.Context, userID uuid.UUID) {
+	stale, err := s.attachmentRepo.GetStalePending(ctx, userID, time.Now().Add(-s.staleUploadAfter))
+	if err != nil {
+		log.Printf("Failed to list abandoned uploads of user %s: %v", userID, err)
+		return
+	}
+	for i := range stale {
+		if err := s.Delete(ctx, &stale[i]); err != nil {
+			log.Printf("Failed to delete abandoned upload %s: %v", stale[i].ID, err)
+		}
+	}
+}
+
+// OpenKey unwraps the file key of a server-side attachment with the key of
+// its entry.
+func (s *AttachmentService) OpenKey(attachment *models.Attachment, itemKey []byte) ([]byte, error) {
+	fileKey, err := s.cryptoService.OpenWithKey(attachment.EncryptedKey, itemKey)
+	if err != nil {
+		return nil, ErrAttachmentKey
+	}
+	return fileKey, nil
+}
+
+// Stream writes the attachment's content to w one chunk at a time,
+// decrypted with fileKey, or as stored for client-encrypted attachments
+// (fileKey nil).
+func (s *AttachmentService) Stream(ctx context.Context, attachment *models.Attachment, fileKey []byte, w io.Writer) error {
+	var gcm cipher.AEAD
+	if fileKey != nil {
+		var err error
+		if gcm, err = newChunkCipher(fileKey); err != nil {
+			return err
+		}
+	}
+
+	for index := 0; index < attachment.ChunkCount; index++ {
+		chunk, err := s.blobStore.Get(ctx, chunkKey(attachment.ID, index))
+		if err != nil {
+			return fmt.Errorf("chunk %d: %w", index, err)
+		}
+		if gcm != nil {
+			chunk, err = openChunk(gcm, attachment.ID, index, index == attachment.ChunkCount-1, chunk)
+			if err != nil {
+				return fmt.Errorf("chunk %d: %w", index, err)
+			}
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes an attachment record, then its chunks.
+func (s *AttachmentService) Delete(ctx context.Context, attachment *models.Attachment) error {
+	if err := s.attachmentRepo.Delete(ctx, attachment.ID); err != nil {
+		return err
+	}
+	s.deleteChunks(ctx, attachment.ID, attachment.ChunkCount)
+	return nil
+}
+
+// DeleteWithVault runs deleteEntry to remove an entry, then removes the
+// entry's attachments. Chunks that fail to delete are logged and left
+// behind rather than failing a deletion that already happened.
+func (s *AttachmentService) DeleteWithVault(ctx context.Context, vaultID uuid.UUID, deleteEntry func() error) error {
+	attachments, err := s.attachmentRepo.GetByVaultID(ctx, vaultID)
+	if err != nil {
+		return err
+	}
+
+	if err := deleteEntry(); err != nil {
+		return err
+	}
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	if err := s.attachmentRepo.DeleteByVaultID(ctx, vaultID); err != nil {
+		log.Printf("Failed to delete attachment records of vault entry %s: %v", vaultID, err)
+	}
+	for i := range attachments {
+		s.deleteChunks(ctx, attachments[i].ID, attachments[i].ChunkCount)
+	}
+	return nil
+}
+
+// deleteChunks removes the first count chunks of an attachment.
+func (s *AttachmentService) deleteChunks(ctx context.Context, attachmentID uuid.UUID, count int) {
+	for index := 0; index < count; index++ {
+		if err := s.blobStore.Delete(ctx, chunkKey(attachmentID, index)); err != nil {
+			log.Printf("Failed to delete chunk %d of attachment %s: %v", index, attachmentID, err)
+		}
+	}
+}
+
+func chunkKey(attachmentID uuid.UUID, index int) string {
+	return fmt.Sprintf("%s/%d", attachmentID, index)
+}
+
+func newChunkCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkAAD is the additional data authenticated with each chunk.
+func chunkAAD(attachmentID uuid.UUID, index int, final bool) []byte {
+	aad := make([]byte, 0, len(attachmentID)+5)
+	aad = append(aad, attachmentID[:]...)
+	aad = binary.BigEndian.AppendUint32(aad, uint32(index))
+	if final {
+		return append(aad, 1)
+	}
+	return append(aad, 0)
+}
+
+// sealChunk returns the nonce followed by the ciphertext.
+func sealChunk(gcm cipher.AEAD, attachmentID uuid.UUID, index int, final bool, chunk []byte) ([]byte, error) {
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(chunk)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, chunk, chunkAAD(attachmentID, index, final)), nil
+}
+
+func openChunk(gcm cipher.AEAD, attachmentID uuid.UUID, index int, final bool, sealed []byte) ([]byte, error) {
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("chunk too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, chunkAAD(attachmentID, index, final))
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+
+	"github.com/tresor/password-manager/internal/models"
+)
+
+var (
+	// ErrCollectionKey is returned when a member's copy of the collection key
+	// cannot be opened.
+	ErrCollectionKey = errors.New("collection key cannot be opened")
+	// ErrInvalidCollectionData is returned for client uploads that are not
+	// envelopes of the expected kind.
+	ErrInvalidCollectionData = errors.New("invalid collection ciphertext")
+)
+
+// CollectionService handles the keys of shared collections. Each collection
+// has a random key that encrypts its entries; every member holds it sealed to
+// their public key. For server-side accounts the member's copy is opened with
+// the private key unlocked by their master password; zero-knowledge clients
+// do the same locally and upload envelopes in the same format. Organization
+// keys follow the same scheme and are handled here too.
+type CollectionService struct {
+	cryptoService   *CryptoService
+	vaultKeyService *VaultKeyService
+}
+
+func NewCollectionService(cryptoService *CryptoService, vaultKeyService *VaultKeyService) *CollectionService {
+	return &CollectionService{
+		cryptoService:   cryptoService,
+		vaultKeyService: vaultKeyService,
+	}
+}
+
+// GenerateKey returns a new collection key.
+func (s *CollectionService) GenerateKey() ([]byte, error) {
+	return s.cryptoService.GenerateKey()
+}
+
+// SealKeyForUser seals a collection key to user's public key.
+func (s *CollectionService) SealKeyForUser(key []byte, user *models.User) (string, error) {
+	publicKey, err := base64.StdEncoding.DecodeString(user.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return s.cryptoService.SealToPublicKey(key, publicKey)
+}
+
+// OpenKey unlocks user's vault with masterPassword and opens sealedKey, their
+// copy of a collection or org key. It also returns the vault key, for callers
+// that move personal entries into the collection.
+func (s *CollectionService) OpenKey(ctx context.Context, user *models.User, sealedKey, masterPassword string) (collectionKey, vaultKey []byte, err error) {
+	vaultKey, err = s.vaultKeyService.UnlockUser(ctx, user, masterPassword)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKey, err := s.vaultKeyService.OpenPrivateKey(user, vaultKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	collectionKey, err = s.cryptoService.OpenWithPrivateKey(sealedKey, privateKey)
+	if err != nil {
+		return nil, nil, ErrCollectionKey
+	}
+
+	return collectionKey, vaultKey, nil
+}
+
+// SealEntry encrypts plaintext into vault under the collection key.
+func (s *CollectionService) SealEntry(vault *models.Vault, key []byte, plaintext string) error {
+	envelope, err := s.cryptoService.SealWithKey([]byte(plaintext), key)
+	if err != nil {
+		return err
+	}
+
+	vault.EncryptedData = envelope
+	vault.EncryptionSalt = ""
+	vault.Nonce = ""
+	vault.EncryptionVersion = models.EncryptionVersionCurrent
+	return nil
+}
+
+// OpenEntry decrypts a collection entry.
+func (s *CollectionService) OpenEntry(vault *models.Vault, key []byte) (string, error) {
+	plaintext, err := s.cryptoService.OpenWithKey(vault.EncryptedData, key)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// ReencryptEntries moves entries from oldKey to newKey in place, for
+// re-keying after a member leaves.
+func (s *CollectionService) ReencryptEntries(entries []models.Vault, oldKey, newKey []byte) error {
+	for i := range entries {
+		plaintext, err := s.OpenEntry(&entries[i], oldKey)
+		if err != nil {
+			return ErrInvalidCollectionData
+		}
+		if err := s.SealEntry(&entries[i], newKey, plaintext); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateEntryEnvelope checks that a client-encrypted entry is an envelope
+// sealed with a raw key, so that server-side members can open it too.
+func (s *CollectionService) ValidateEntryEnvelope(data string) error {
+	envelope, err := ParseEnvelope(data)
+	if err != nil || envelope.Algorithm != AlgorithmAES256GCM || envelope.KDF.ID != KDFNone {
+		return ErrInvalidCollectionData
+	}
+	return nil
+}
+
+// ValidateSealedKey checks that a client upload is a key sealed to a public
+// key.
+func (s *CollectionService) ValidateSealedKey(data string) error {
+	envelope, err := ParseEnvelope(data)
+	if err != nil || envelope.KDF.ID != KDFX25519HKDF || envelope.EphemeralKey == "" {
+		return ErrInvalidCollectionData
+	}
+	return nil
+}
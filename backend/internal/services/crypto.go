@@ -5,6 +5,7 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha1"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
@@ -15,29 +16,48 @@ import (
 	"strings"
 	"time"
 
+	"github.com/tresor/password-manager/internal/config"
 	"golang.org/x/crypto/argon2"
 )
 
 type CryptoService struct {
 	httpClient *http.Client
+	kdf        KDFParams
 }
 
-func NewCryptoService() *CryptoService {
+func NewCryptoService(cfg *config.CryptoConfig) *CryptoService {
 	return &CryptoService{
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		kdf: KDFParams{
+			ID:          KDFArgon2id,
+			Iterations:  uint32(cfg.Argon2Iterations),
+			MemoryKiB:   uint32(cfg.Argon2MemoryKiB),
+			Parallelism: uint8(cfg.Argon2Parallelism),
+			KeyLength:   32,
+		},
 	}
 }
 
+// KDFParams returns the parameters new password-derived keys are created with.
+func (s *CryptoService) KDFParams() KDFParams {
+	return s.kdf
+}
+
+// DeriveKey derives a key with the currently configured Argon2id parameters.
 func (s *CryptoService) DeriveKey(password string, salt []byte) []byte {
+	return s.DeriveKeyWithParams(password, salt, s.kdf)
+}
+
+func (s *CryptoService) DeriveKeyWithParams(password string, salt []byte, params KDFParams) []byte {
 	return argon2.IDKey(
 		[]byte(password),
 		salt,
-		3,       // iterations
-		64*1024, // memory (64 MB)
-		4,       // parallelism
-		32,      // key length (256 bits)
+		params.Iterations,
+		params.MemoryKiB,
+		params.Parallelism,
+		params.KeyLength,
 	)
 }
 
@@ -49,6 +69,8 @@ func (s *CryptoService) GenerateSalt() ([]byte, error) {
 	return salt, nil
 }
 
+// HashPassword returns a hash of the form "$argon2id$v=19$m=..,t=..,p=..$<hash>"
+// so the parameters it was made with can be recovered, and its salt.
 func (s *CryptoService) HashPassword(password string) (string, string, error) {
 	salt, err := s.GenerateSalt()
 	if err != nil {
@@ -57,13 +79,15 @@ func (s *CryptoService) HashPassword(password string) (string, string, error) {
 
 	hash := s.DeriveKey(password, salt)
 
-	return base64.StdEncoding.EncodeToString(hash),
+	return encodePasswordHash(s.kdf, hash),
 		base64.StdEncoding.EncodeToString(salt),
 		nil
 }
 
+// VerifyPassword accepts both parameterised hashes and bare base64 hashes
+// made with LegacyKDFParams.
 func (s *CryptoService) VerifyPassword(password, hashStr, saltStr string) bool {
-	hash, err := base64.StdEncoding.DecodeString(hashStr)
+	params, hash, err := parsePasswordHash(hashStr)
 	if err != nil {
 		return false
 	}
@@ -73,76 +97,220 @@ func (s *CryptoService) VerifyPassword(password, hashStr, saltStr string) bool {
 		return false
 	}
 
-	derivedKey := s.DeriveKey(password, salt)
+	derivedKey := s.DeriveKeyWithParams(password, salt, params)
+
+	return subtle.ConstantTimeCompare(hash, derivedKey) == 1
+}
+
+// PasswordHashNeedsRehash reports whether a hash was made with parameters
+// other than the configured ones.
+func (s *CryptoService) PasswordHashNeedsRehash(hashStr string) bool {
+	params, _, err := parsePasswordHash(hashStr)
+	return err != nil || params != s.kdf
+}
+
+func encodePasswordHash(params KDFParams, hash []byte) string {
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s",
+		params.ID,
+		argon2.Version,
+		params.MemoryKiB,
+		params.Iterations,
+		params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(hash),
+	)
+}
+
+func parsePasswordHash(hashStr string) (KDFParams, []byte, error) {
+	if !strings.HasPrefix(hashStr, "$") {
+		hash, err := base64.StdEncoding.DecodeString(hashStr)
+		return LegacyKDFParams, hash, err
+	}
+
+	parts := strings.Split(hashStr, "$")
+	if len(parts) != 5 || parts[1] != KDFArgon2id {
+		return KDFParams{}, nil, errors.New("unsupported password hash format")
+	}
+
+	params := KDFParams{ID: KDFArgon2id}
+	var memory, iterations uint32
+	var parallelism uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism); err != nil {
+		return KDFParams{}, nil, err
+	}
+	params.MemoryKiB = memory
+	params.Iterations = iterations
+	params.Parallelism = parallelism
+
+	hash, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return KDFParams{}, nil, err
+	}
+	params.KeyLength = uint32(len(hash))
+
+	return params, hash, nil
+}
+
+// SealWithPassword encrypts plaintext under a key derived from password with
+// a fresh salt and returns an encoded envelope recording the KDF parameters.
+func (s *CryptoService) SealWithPassword(plaintext []byte, password string) (string, error) {
+	salt, err := s.GenerateSalt()
+	if err != nil {
+		return "", err
+	}
+
+	key := s.DeriveKey(password, salt)
+
+	ciphertext, nonce, err := s.EncryptWithKey(plaintext, key)
+	if err != nil {
+		return "", err
+	}
+
+	envelope := &Envelope{
+		Version:    EnvelopeVersion,
+		Algorithm:  AlgorithmAES256GCM,
+		KDF:        s.kdf,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Nonce:      nonce,
+		Ciphertext: ciphertext,
+	}
+	return envelope.Encode()
+}
+
+// SealWithKey encrypts plaintext under an existing key and returns an encoded
+// envelope.
+func (s *CryptoService) SealWithKey(plaintext, key []byte) (string, error) {
+	ciphertext, nonce, err := s.EncryptWithKey(plaintext, key)
+	if err != nil {
+		return "", err
+	}
+
+	envelope := &Envelope{
+		Version:    EnvelopeVersion,
+		Algorithm:  AlgorithmAES256GCM,
+		KDF:        KDFParams{ID: KDFNone},
+		Nonce:      nonce,
+		Ciphertext: ciphertext,
+	}
+	return envelope.Encode()
+}
+
+// OpenWithPassword decrypts an envelope produced by SealWithPassword, using
+// the KDF parameters stored in it.
+func (s *CryptoService) OpenWithPassword(data, password string) ([]byte, error) {
+	envelope, err := ParseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	if envelope.KDF.ID != KDFArgon2id {
+		return nil, errors.New("envelope is not password protected")
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(envelope.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	key := s.DeriveKeyWithParams(password, salt, envelope.KDF)
+
+	plaintext, err := s.DecryptWithKey(envelope.Ciphertext, envelope.Nonce, key)
+	if err != nil {
+		return nil, errors.New("decryption failed: invalid password or corrupted data")
+	}
+	return plaintext, nil
+}
+
+// OpenWithKey decrypts an envelope produced by SealWithKey.
+func (s *CryptoService) OpenWithKey(data string, key []byte) ([]byte, error) {
+	envelope, err := ParseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	if envelope.KDF.ID != KDFNone {
+		return nil, errors.New("envelope is password protected")
+	}
 
-	return string(hash) == string(derivedKey)
+	return s.DecryptWithKey(envelope.Ciphertext, envelope.Nonce, key)
 }
 
-func (s *CryptoService) EncryptData(plaintext, masterPassword string) (ciphertext, salt, nonce string, err error) {
-	saltBytes, err := s.GenerateSalt()
+// DecryptData decrypts data from before envelopes existed: a bare ciphertext
+// with its salt and nonce stored separately, keyed with LegacyKDFParams.
+func (s *CryptoService) DecryptData(ciphertextStr, masterPassword, saltStr, nonceStr string) (string, error) {
+	salt, err := base64.StdEncoding.DecodeString(saltStr)
+	if err != nil {
+		return "", err
+	}
+
+	key := s.DeriveKeyWithParams(masterPassword, salt, LegacyKDFParams)
+
+	plaintext, err := s.DecryptWithKey(ciphertextStr, nonceStr, key)
 	if err != nil {
-		return "", "", "", err
+		return "", errors.New("decryption failed: invalid password or corrupted data")
 	}
 
-	key := s.DeriveKey(masterPassword, saltBytes)
+	return string(plaintext), nil
+}
+
+// GenerateKey returns a random 256-bit symmetric key.
+func (s *CryptoService) GenerateKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
 
+// EncryptWithKey seals plaintext with AES-256-GCM under an existing key,
+// using a fresh random nonce.
+func (s *CryptoService) EncryptWithKey(plaintext, key []byte) (ciphertext, nonce string, err error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
-		return "", "", "", err
+		return "", "", err
 	}
 
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", "", "", err
+		return "", "", err
 	}
 
 	nonceBytes := make([]byte, gcm.NonceSize())
 	if _, err := io.ReadFull(rand.Reader, nonceBytes); err != nil {
-		return "", "", "", err
+		return "", "", err
 	}
 
-	ciphertextBytes := gcm.Seal(nil, nonceBytes, []byte(plaintext), nil)
+	ciphertextBytes := gcm.Seal(nil, nonceBytes, plaintext, nil)
 
 	return base64.StdEncoding.EncodeToString(ciphertextBytes),
-		base64.StdEncoding.EncodeToString(saltBytes),
 		base64.StdEncoding.EncodeToString(nonceBytes),
 		nil
 }
 
-func (s *CryptoService) DecryptData(ciphertextStr, masterPassword, saltStr, nonceStr string) (string, error) {
+func (s *CryptoService) DecryptWithKey(ciphertextStr, nonceStr string, key []byte) ([]byte, error) {
 	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextStr)
 	if err != nil {
-		return "", err
-	}
-
-	salt, err := base64.StdEncoding.DecodeString(saltStr)
-	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	nonce, err := base64.StdEncoding.DecodeString(nonceStr)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	key := s.DeriveKey(masterPassword, salt)
-
 	block, err := aes.NewCipher(key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
 	if err != nil {
-		return "", errors.New("decryption failed: invalid password or corrupted data")
+		return nil, errors.New("decryption failed: invalid key or corrupted data")
 	}
 
-	return string(plaintext), nil
+	return plaintext, nil
 }
 
 func (s *CryptoService) GeneratePassword(length int, useSpecial bool) (string, error) {
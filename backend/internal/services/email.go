@@ -42,6 +42,282 @@ func (s *EmailService) SendShareNotification(recipientEmail, title, shareURL str
 	return d.DialAndSend(m)
 }
 
+// SendShareInvitation tells someone without an account that an entry is
+// waiting for them once they sign up.
+func (s *EmailService) SendShareInvitation(recipientEmail, title, registerURL string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.config.From)
+	m.SetHeader("To", recipientEmail)
+	m.SetHeader("Subject", "A Password Is Waiting For You")
+
+	body := fmt.Sprintf(`
+        <html>
+        <body>
+            <h2>A password has been shared with you</h2>
+            <p>Someone has shared <strong>%s</strong> with you on SecureVault.</p>
+            <p>Create your account with this email address to access it:</p>
+            <p><a href="%s">Create My Account</a></p>
+            <p>The share will appear in your vault as soon as you sign up.</p>
+            <br>
+            <p><em>SecureVault - Your Password Manager</em></p>
+        </body>
+        </html>
+    `, title, registerURL)
+
+	m.SetBody("text/html", body)
+
+	d := gomail.NewDialer(s.config.Host, s.config.Port, s.config.Username, s.config.Password)
+
+	return d.DialAndSend(m)
+}
+
+// SendSharedEditNotification tells an owner that a recipient changed one of
+// their shared entries.
+func (s *EmailService) SendSharedEditNotification(ownerEmail, title, editorEmail string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.config.From)
+	m.SetHeader("To", ownerEmail)
+	m.SetHeader("Subject", "A Shared Password Was Edited")
+
+	body := fmt.Sprintf(`
+        <html>
+        <body>
+            <h2>A shared password was edited</h2>
+            <p><strong>%s</strong> changed <strong>%s</strong>, which you shared with them.</p>
+            <p>Password and notes changes are applied the next time you unlock your vault.</p>
+            <p>If you did not expect this change, revoke the share.</p>
+            <br>
+            <p><em>SecureVault Security</em></p>
+        </body>
+        </html>
+    `, editorEmail, title)
+
+	m.SetBody("text/html", body)
+
+	d := gomail.NewDialer(s.config.Host, s.config.Port, s.config.Username, s.config.Password)
+
+	return d.DialAndSend(m)
+}
+
+// SendShareFirstAccessAlert tells an owner that one of their shares was
+// opened for the first time.
+func (s *EmailService) SendShareFirstAccessAlert(ownerEmail, title, accessedBy, ipAddress string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.config.From)
+	m.SetHeader("To", ownerEmail)
+	m.SetHeader("Subject", "Your Shared Password Was Opened")
+
+	body := fmt.Sprintf(`
+        <html>
+        <body>
+            <h2>Your shared password was opened</h2>
+            <p><strong>%s</strong> opened <strong>%s</strong> for the first time.</p>
+            <p>IP address: %s</p>
+            <p>If you do not recognise this access, revoke the share and change the password.</p>
+            <br>
+            <p><em>SecureVault Security</em></p>
+        </body>
+        </html>
+    `, accessedBy, title, ipAddress)
+
+	m.SetBody("text/html", body)
+
+	d := gomail.NewDialer(s.config.Host, s.config.Port, s.config.Username, s.config.Password)
+
+	return d.DialAndSend(m)
+}
+
+// SendOrganizationInvitation invites someone to join an organization.
+func (s *EmailService) SendOrganizationInvitation(email, organizationName, invitedBy, acceptURL string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.config.From)
+	m.SetHeader("To", email)
+	m.SetHeader("Subject", "You Are Invited To Join "+organizationName)
+
+	body := fmt.Sprintf(`
+        <html>
+        <body>
+            <h2>Join %s on SecureVault</h2>
+            <p><strong>%s</strong> invited you to join the organization <strong>%s</strong>.</p>
+            <p>Sign in or create your account with this email address, then accept the invitation:</p>
+            <p><a href="%s">Accept Invitation</a></p>
+            <p>An administrator will confirm your membership before you can see the organization's items.</p>
+            <br>
+            <p><em>SecureVault - Your Password Manager</em></p>
+        </body>
+        </html>
+    `, organizationName, invitedBy, organizationName, acceptURL)
+
+	m.SetBody("text/html", body)
+
+	d := gomail.NewDialer(s.config.Host, s.config.Port, s.config.Username, s.config.Password)
+
+	return d.DialAndSend(m)
+}
+
+// SendEmergencyAccessInvitation asks someone to become a trusted contact
+// who can request emergency access to a vault.
+func (s *EmailService) SendEmergencyAccessInvitation(email, grantorEmail, accessType string, waitDays int, acceptURL string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.config.From)
+	m.SetHeader("To", email)
+	m.SetHeader("Subject", "You Have Been Named An Emergency Contact")
+
+	body := fmt.Sprintf(`
+        <html>
+        <body>
+            <h2>Become an emergency contact</h2>
+            <p><strong>%s</strong> wants you to be able to request <strong>%s</strong> access to their vault in an emergency.</p>
+            <p>Access is granted if they do not answer your request within %d days.</p>
+            <p>Sign in or create your account with this email address, then accept the invitation:</p>
+            <p><a href="%s">Accept Invitation</a></p>
+            <br>
+            <p><em>SecureVault - Your Password Manager</em></p>
+        </body>
+        </html>
+    `, grantorEmail, accessType, waitDays, acceptURL)
+
+	m.SetBody("text/html", body)
+
+	d := gomail.NewDialer(s.config.Host, s.config.Port, s.config.Username, s.config.Password)
+
+	return d.DialAndSend(m)
+}
+
+// SendEmergencyAccessAccepted tells a grantor that their trusted contact
+// accepted and is waiting to be confirmed.
+func (s *EmailService) SendEmergencyAccessAccepted(grantorEmail, granteeEmail string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.config.From)
+	m.SetHeader("To", grantorEmail)
+	m.SetHeader("Subject", "Your Emergency Contact Accepted")
+
+	body := fmt.Sprintf(`
+        <html>
+        <body>
+            <h2>Confirm your emergency contact</h2>
+            <p><strong>%s</strong> accepted to be your emergency contact.</p>
+            <p>Confirm them from your emergency access settings so that they can request access.</p>
+            <br>
+            <p><em>SecureVault - Your Password Manager</em></p>
+        </body>
+        </html>
+    `, granteeEmail)
+
+	m.SetBody("text/html", body)
+
+	d := gomail.NewDialer(s.config.Host, s.config.Port, s.config.Username, s.config.Password)
+
+	return d.DialAndSend(m)
+}
+
+// SendEmergencyAccessRequested warns a grantor that a trusted contact asked
+// for access and when it will be granted unless they reject it.
+func (s *EmailService) SendEmergencyAccessRequested(grantorEmail, granteeEmail, accessType string, waitDays int) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.config.From)
+	m.SetHeader("To", grantorEmail)
+	m.SetHeader("Subject", "Emergency Access Requested")
+
+	body := fmt.Sprintf(`
+        <html>
+        <body>
+            <h2>Emergency access requested</h2>
+            <p><strong>%s</strong> requested <strong>%s</strong> access to your vault.</p>
+            <p>Access will be granted automatically in %d days unless you reject the request from your emergency access settings.</p>
+            <br>
+            <p><em>SecureVault Security</em></p>
+        </body>
+        </html>
+    `, granteeEmail, accessType, waitDays)
+
+	m.SetBody("text/html", body)
+
+	d := gomail.NewDialer(s.config.Host, s.config.Port, s.config.Username, s.config.Password)
+
+	return d.DialAndSend(m)
+}
+
+// SendEmergencyAccessApproved tells a trusted contact that their request was
+// approved, by the grantor or because the waiting period ran out.
+func (s *EmailService) SendEmergencyAccessApproved(granteeEmail, grantorEmail, accessType string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.config.From)
+	m.SetHeader("To", granteeEmail)
+	m.SetHeader("Subject", "Emergency Access Approved")
+
+	body := fmt.Sprintf(`
+        <html>
+        <body>
+            <h2>Emergency access approved</h2>
+            <p>Your request for <strong>%s</strong> access to the vault of <strong>%s</strong> was approved.</p>
+            <p>Sign in to SecureVault to use it.</p>
+            <br>
+            <p><em>SecureVault - Your Password Manager</em></p>
+        </body>
+        </html>
+    `, accessType, grantorEmail)
+
+	m.SetBody("text/html", body)
+
+	d := gomail.NewDialer(s.config.Host, s.config.Port, s.config.Username, s.config.Password)
+
+	return d.DialAndSend(m)
+}
+
+// SendEmergencyAccessRejected tells a trusted contact that their request was
+// rejected.
+func (s *EmailService) SendEmergencyAccessRejected(granteeEmail, grantorEmail string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.config.From)
+	m.SetHeader("To", granteeEmail)
+	m.SetHeader("Subject", "Emergency Access Rejected")
+
+	body := fmt.Sprintf(`
+        <html>
+        <body>
+            <h2>Emergency access rejected</h2>
+            <p><strong>%s</strong> rejected your request for emergency access to their vault.</p>
+            <br>
+            <p><em>SecureVault - Your Password Manager</em></p>
+        </body>
+        </html>
+    `, grantorEmail)
+
+	m.SetBody("text/html", body)
+
+	d := gomail.NewDialer(s.config.Host, s.config.Port, s.config.Username, s.config.Password)
+
+	return d.DialAndSend(m)
+}
+
+// SendEmergencyTakeoverNotice tells a grantor that a trusted contact set a
+// new master password on their account.
+func (s *EmailService) SendEmergencyTakeoverNotice(grantorEmail, granteeEmail string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.config.From)
+	m.SetHeader("To", grantorEmail)
+	m.SetHeader("Subject", "Your Account Was Taken Over")
+
+	body := fmt.Sprintf(`
+        <html>
+        <body>
+            <h2>Your account was taken over</h2>
+            <p><strong>%s</strong> used emergency access to set a new master password on your account.</p>
+            <p>All your sessions were signed out and two-factor authentication was turned off.</p>
+            <br>
+            <p><em>SecureVault Security</em></p>
+        </body>
+        </html>
+    `, granteeEmail)
+
+	m.SetBody("text/html", body)
+
+	d := gomail.NewDialer(s.config.Host, s.config.Port, s.config.Username, s.config.Password)
+
+	return d.DialAndSend(m)
+}
+
 func (s *EmailService) SendWelcomeEmail(email string) error {
 	m := gomail.NewMessage()
 	m.SetHeader("From", s.config.From)
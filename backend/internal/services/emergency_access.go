@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+)
+
+// emergencyAccessCheckInterval is how often pending requests are checked for
+// an elapsed waiting period.
+const emergencyAccessCheckInterval = 15 * time.Minute
+
+// EmergencyAccessService moves emergency access requests through their
+// lifecycle and emails both parties at each step. Requests the grantor does
+// not answer are approved by RunAutoApproveWorker once their waiting period
+// is over.
+type EmergencyAccessService struct {
+	emergencyAccessRepo *repository.EmergencyAccessRepository
+	emailService        *EmailService
+}
+
+func NewEmergencyAccessService(emergencyAccessRepo *repository.EmergencyAccessRepository, emailService *EmailService) *EmergencyAccessService {
+	return &EmergencyAccessService{
+		emergencyAccessRepo: emergencyAccessRepo,
+		emailService:        emailService,
+	}
+}
+
+// Initiate opens a request on a confirmed grant and warns the grantor. It
+// reports false when the grant was not confirmed or already had a request.
+func (s *EmergencyAccessService) Initiate(ctx context.Context, access *models.EmergencyAccess) (bool, error) {
+	ok, err := s.emergencyAccessRepo.TransitionStatus(ctx, access.ID, models.EmergencyAccessConfirmed, models.EmergencyAccessRecoveryInitiated)
+	if err != nil || !ok {
+		return ok, err
+	}
+
+	go s.emailService.SendEmergencyAccessRequested(access.Grantor.Email, granteeEmail(access), access.Type, access.WaitDays)
+	return true, nil
+}
+
+// Approve grants a pending request and tells the grantee. It reports false
+// when the request was no longer pending.
+func (s *EmergencyAccessService) Approve(ctx context.Context, access *models.EmergencyAccess) (bool, error) {
+	ok, err := s.emergencyAccessRepo.TransitionStatus(ctx, access.ID, models.EmergencyAccessRecoveryInitiated, models.EmergencyAccessRecoveryApproved)
+	if err != nil || !ok {
+		return ok, err
+	}
+
+	go s.emailService.SendEmergencyAccessApproved(granteeEmail(access), access.Grantor.Email, access.Type)
+	return true, nil
+}
+
+// Reject turns down a pending or approved request, taking access away again,
+// and tells the grantee. The grant stays confirmed for future requests.
+func (s *EmergencyAccessService) Reject(ctx context.Context, access *models.EmergencyAccess) (bool, error) {
+	ok, err := s.emergencyAccessRepo.TransitionStatus(ctx, access.ID, access.Status, models.EmergencyAccessConfirmed)
+	if err != nil || !ok {
+		return ok, err
+	}
+
+	go s.emailService.SendEmergencyAccessRejected(granteeEmail(access), access.Grantor.Email)
+	return true, nil
+}
+
+// RunAutoApproveWorker approves requests whose waiting period is over, until
+// ctx is cancelled.
+func (s *EmergencyAccessService) RunAutoApproveWorker(ctx context.Context) {
+	ticker := time.NewTicker(emergencyAccessCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		s.approveDueRequests(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *EmergencyAccessService) approveDueRequests(ctx context.Context) {
+	due, err := s.emergencyAccessRepo.GetDueRecoveries(ctx, time.Now())
+	if err != nil {
+		log.Printf("Failed to fetch due emergency access requests: %v", err)
+		return
+	}
+
+	for i := range due {
+		approved, err := s.Approve(ctx, &due[i])
+		if err != nil {
+			log.Printf("Failed to approve emergency access %s: %v", due[i].ID, err)
+			continue
+		}
+		if approved {
+			log.Printf("Emergency access %s approved after %d days without answer", due[i].ID, due[i].WaitDays)
+		}
+	}
+}
+
+// granteeEmail is the address of the grantee's account, or the invited one
+// if it was not loaded.
+func granteeEmail(access *models.EmergencyAccess) string {
+	if access.Grantee != nil && access.Grantee.Email != "" {
+		return access.Grantee.Email
+	}
+	return access.Email
+}
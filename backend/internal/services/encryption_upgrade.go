@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+)
+
+// RunUpgradeWorker re-encrypts entries that are below the current encryption
+// version, one user at a time, until ctx is cancelled. Jobs are queued by
+// unlocks because the server only holds a user's keys while the user is
+// present.
+func (s *VaultKeyService) RunUpgradeWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-s.upgrades:
+			upgraded, err := s.UpgradeEntries(ctx, job.userID, job.vaultKey, job.masterPassword)
+			if err != nil {
+				log.Printf("Encryption upgrade failed for user %s: %v", job.userID, err)
+			} else if upgraded > 0 {
+				log.Printf("Encryption upgrade re-encrypted %d entries for user %s", upgraded, job.userID)
+			}
+
+			s.pendingMu.Lock()
+			delete(s.pending, job.userID)
+			s.pendingMu.Unlock()
+		}
+	}
+}
+
+// scheduleUpgrade queues a background upgrade if the user has outdated
+// entries and no upgrade is already pending. It never blocks the request.
+func (s *VaultKeyService) scheduleUpgrade(ctx context.Context, userID uuid.UUID, vaultKey []byte, masterPassword string) {
+	outdated, err := s.vaultRepo.CountBelowEncryptionVersion(ctx, userID, models.EncryptionVersionCurrent)
+	if err != nil || outdated == 0 {
+		return
+	}
+
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+
+	if s.pending[userID] {
+		return
+	}
+
+	select {
+	case s.upgrades <- upgradeJob{userID: userID, vaultKey: vaultKey, masterPassword: masterPassword}:
+		s.pending[userID] = true
+	default:
+		// Queue full: the next unlock will try again.
+	}
+}
+
+// UpgradeEntries re-encrypts a user's outdated entries with the current
+// envelope format. Entries that cannot be decrypted are left as they are. It
+// returns how many entries were upgraded.
+func (s *VaultKeyService) UpgradeEntries(ctx context.Context, userID uuid.UUID, vaultKey []byte, masterPassword string) (int, error) {
+	outdated, err := s.vaultRepo.GetBelowEncryptionVersion(ctx, userID, models.EncryptionVersionCurrent)
+	if err != nil {
+		return 0, err
+	}
+
+	upgraded := 0
+	for i := range outdated {
+		vault := &outdated[i]
+
+		plaintext, err := s.OpenEntry(vault, vaultKey, masterPassword)
+		if err != nil {
+			continue
+		}
+
+		if err := s.SealEntry(vault, vaultKey, plaintext); err != nil {
+			return upgraded, err
+		}
+
+		// The entry may have been rewritten since it was read, e.g. by a
+		// switch to client-side encryption; never overwrite a newer revision.
+		updated, err := s.vaultRepo.UpdateIfRevision(ctx, vault, vault.Revision)
+		if err != nil {
+			return upgraded, err
+		}
+		if !updated {
+			continue
+		}
+
+		upgraded++
+	}
+
+	return upgraded, nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Envelope format identifiers.
+const (
+	EnvelopeVersion    = 1
+	AlgorithmAES256GCM = "AES-256-GCM"
+	KDFArgon2id        = "argon2id"
+	// KDFNone marks envelopes sealed with a key from the key hierarchy rather
+	// than one derived from a password.
+	KDFNone = "none"
+	// KDFX25519HKDF marks envelopes sealed to a public key: the key is derived
+	// with HKDF-SHA256 from an X25519 exchange with EphemeralKey.
+	KDFX25519HKDF = "x25519-hkdf-sha256"
+)
+
+// KDFParams records how a key was derived so that data stays readable after
+// the configured cost is raised.
+type KDFParams struct {
+	ID          string `json:"id"`
+	Iterations  uint32 `json:"t,omitempty"`
+	MemoryKiB   uint32 `json:"m,omitempty"`
+	Parallelism uint8  `json:"p,omitempty"`
+	KeyLength   uint32 `json:"len,omitempty"`
+}
+
+// LegacyKDFParams are the Argon2id parameters that were hardcoded before
+// they were stored alongside the data; anything without stored parameters was
+// derived with them.
+var LegacyKDFParams = KDFParams{
+	ID:          KDFArgon2id,
+	Iterations:  3,
+	MemoryKiB:   64 * 1024,
+	Parallelism: 4,
+	KeyLength:   32,
+}
+
+// Envelope is the self-describing ciphertext format used for every piece of
+// encrypted data.
+type Envelope struct {
+	Version      int       `json:"v"`
+	Algorithm    string    `json:"alg"`
+	KDF          KDFParams `json:"kdf"`
+	Salt         string    `json:"salt,omitempty"`
+	EphemeralKey string    `json:"epk,omitempty"`
+	Nonce        string    `json:"nonce"`
+	Ciphertext   string    `json:"ct"`
+}
+
+// Encode serializes the envelope for storage in a text column.
+func (e *Envelope) Encode() (string, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// IsCurrent reports whether the envelope uses the current format and, for
+// password-derived keys, the given KDF parameters.
+func (e *Envelope) IsCurrent(kdf KDFParams) bool {
+	if e.Version != EnvelopeVersion || e.Algorithm != AlgorithmAES256GCM {
+		return false
+	}
+	return e.KDF.ID == KDFNone || e.KDF == kdf
+}
+
+// IsEnvelope reports whether data looks like an encoded envelope rather than
+// a bare base64 ciphertext from before envelopes existed.
+func IsEnvelope(data string) bool {
+	return strings.HasPrefix(data, "{")
+}
+
+func ParseEnvelope(data string) (*Envelope, error) {
+	if !IsEnvelope(data) {
+		return nil, errors.New("not an encryption envelope")
+	}
+
+	var envelope Envelope
+	if err := json.Unmarshal([]byte(data), &envelope); err != nil {
+		return nil, err
+	}
+
+	if envelope.Version != EnvelopeVersion {
+		return nil, errors.New("unsupported envelope version")
+	}
+	if envelope.Algorithm != AlgorithmAES256GCM {
+		return nil, errors.New("unsupported envelope algorithm")
+	}
+
+	return &envelope, nil
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+)
+
+// exportVersion is the version of the ExportFormatJSON layout.
+const exportVersion = 1
+
+// Bitwarden item types.
+const (
+	bitwardenItemLogin      = 1
+	bitwardenItemSecureNote = 2
+	bitwardenItemCard       = 3
+	bitwardenItemIdentity   = 4
+)
+
+// bitwardenCardBrands maps the brands detected by ValidateVaultItem to the
+// names Bitwarden uses.
+var bitwardenCardBrands = map[string]string{
+	"visa":       "Visa",
+	"mastercard": "Mastercard",
+	"amex":       "Amex",
+	"discover":   "Discover",
+	"jcb":        "JCB",
+	"diners":     "Diners Club",
+	"unionpay":   "UnionPay",
+}
+
+type ExportService struct{}
+
+func NewExportService() *ExportService {
+	return &ExportService{}
+}
+
+// Export renders entries in format and returns the file content with a
+// suggested file name.
+func (s *ExportService) Export(entries []models.ExportEntry, format string, now time.Time) ([]byte, string, error) {
+	filename := fmt.Sprintf("vault-export-%s.json", now.Format("2006-01-02"))
+
+	switch format {
+	case models.ExportFormatJSON:
+		content, err := json.MarshalIndent(map[string]interface{}{
+			"version":     exportVersion,
+			"exported_at": now,
+			"entries":     entries,
+		}, "", "  ")
+		return content, filename, err
+	case models.ExportFormatBitwarden:
+		content, err := s.exportBitwarden(entries)
+		return content, "bitwarden-" + filename, err
+	default:
+		return nil, "", fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+type bitwardenExport struct {
+	Encrypted bool              `json:"encrypted"`
+	Folders   []bitwardenFolder `json:"folders"`
+	Items     []bitwardenItem   `json:"items"`
+}
+
+type bitwardenFolder struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type bitwardenItem struct {
+	ID         string               `json:"id"`
+	FolderID   *string              `json:"folderId"`
+	Type       int                  `json:"type"`
+	Name       string               `json:"name"`
+	Notes      *string              `json:"notes"`
+	Favorite   bool                 `json:"favorite"`
+	Fields     []bitwardenField     `json:"fields,omitempty"`
+	Login      *bitwardenLogin      `json:"login,omitempty"`
+	SecureNote *bitwardenSecureNote `json:"secureNote,omitempty"`
+	Card       *bitwardenCard       `json:"card,omitempty"`
+	Identity   *bitwardenIdentity   `json:"identity,omitempty"`
+}
+
+type bitwardenURI struct {
+	Match *int   `json:"match"`
+	URI   string `json:"uri"`
+}
+
+type bitwardenLogin struct {
+	URIs     []bitwardenURI `json:"uris"`
+	Username *string        `json:"username"`
+	Password *string        `json:"password"`
+	TOTP     *string        `json:"totp"`
+}
+
+type bitwardenSecureNote struct {
+	Type int `json:"type"`
+}
+
+type bitwardenCard struct {
+	CardholderName string `json:"cardholderName"`
+	Brand          string `json:"brand"`
+	Number         string `json:"number"`
+	ExpMonth       string `json:"expMonth"`
+	ExpYear        string `json:"expYear"`
+	Code           string `json:"code"`
+}
+
+type bitwardenIdentity struct {
+	Title          string `json:"title"`
+	FirstName      string `json:"firstName"`
+	MiddleName     string `json:"middleName"`
+	LastName       string `json:"lastName"`
+	Address1       string `json:"address1"`
+	Address2       string `json:"address2"`
+	City           string `json:"city"`
+	State          string `json:"state"`
+	PostalCode     string `json:"postalCode"`
+	Country        string `json:"country"`
+	Company        string `json:"company"`
+	Email          string `json:"email"`
+	Phone          string `json:"phone"`
+	SSN            string `json:"ssn"`
+	PassportNumber string `json:"passportNumber"`
+	LicenseNumber  string `json:"licenseNumber"`
+}
+
+// exportBitwarden writes entries as a Bitwarden export. SSH keys and API
+// credentials have no Bitwarden counterpart and become secure notes whose
+// details are custom fields; so do tags, as a comma-separated "Tags" field.
+func (s *ExportService) exportBitwarden(entries []models.ExportEntry) ([]byte, error) {
+	export := bitwardenExport{
+		Folders: []bitwardenFolder{},
+		Items:   make([]bitwardenItem, 0, len(entries)),
+	}
+	folderIDs := make(map[string]string)
+
+	for _, entry := range entries {
+		item := bitwardenItem{
+			ID:       uuid.New().String(),
+			Name:     entry.Title,
+			Notes:    entry.Notes,
+			Favorite: entry.Favorite,
+		}
+
+		if entry.Folder != nil && *entry.Folder != "" {
+			id, ok := folderIDs[*entry.Folder]
+			if !ok {
+				id = uuid.New().String()
+				folderIDs[*entry.Folder] = id
+				export.Folders = append(export.Folders, bitwardenFolder{ID: id, Name: *entry.Folder})
+			}
+			item.FolderID = &id
+		}
+
+		var totp *string
+		for _, field := range entry.Fields {
+			if field.Type == models.CustomFieldTOTP && totp == nil && entry.Type == models.ItemTypeLogin {
+				value := field.Value
+				totp = &value
+				continue
+			}
+			item.Fields = append(item.Fields, toBitwardenField(field))
+		}
+		if len(entry.Tags) > 0 {
+			item.Fields = append(item.Fields, toBitwardenField(models.CustomField{
+				Name:  "Tags",
+				Type:  models.CustomFieldText,
+				Value: strings.Join(entry.Tags, ", "),
+			}))
+		}
+
+		switch entry.Type {
+		case models.ItemTypeCard:
+			item.Type = bitwardenItemCard
+			if card := entry.Card; card != nil {
+				item.Card = &bitwardenCard{
+					CardholderName: card.CardholderName,
+					Brand:          bitwardenCardBrands[card.Brand],
+					Number:         card.Number,
+					ExpMonth:       strconv.Itoa(card.ExpMonth),
+					ExpYear:        strconv.Itoa(card.ExpYear),
+					Code:           card.CVV,
+				}
+			}
+		case models.ItemTypeIdentity:
+			item.Type = bitwardenItemIdentity
+			if identity := entry.Identity; identity != nil {
+				item.Identity = &bitwardenIdentity{
+					Title:          identity.Title,
+					FirstName:      identity.FirstName,
+					MiddleName:     identity.MiddleName,
+					LastName:       identity.LastName,
+					Address1:       identity.Address1,
+					Address2:       identity.Address2,
+					City:           identity.City,
+					State:          identity.State,
+					PostalCode:     identity.PostalCode,
+					Country:        identity.Country,
+					Company:        identity.Company,
+					Email:          identity.Email,
+					Phone:          identity.Phone,
+					SSN:            identity.SSN,
+					PassportNumber: identity.PassportNumber,
+					LicenseNumber:  identity.LicenseNumber,
+				}
+			}
+		case models.ItemTypeSSHKey, models.ItemTypeAPICredential, models.ItemTypeSecureNote:
+			item.Type = bitwardenItemSecureNote
+			item.SecureNote = &bitwardenSecureNote{}
+			item.Fields = append(item.Fields, detailFields(entry)...)
+		default:
+			item.Type = bitwardenItemLogin
+			login := &bitwardenLogin{
+				URIs:     []bitwardenURI{},
+				Username: entry.Username,
+				TOTP:     totp,
+			}
+			if entry.Password != "" {
+				password := entry.Password
+				login.Password = &password
+			}
+			if entry.Website != nil && *entry.Website != "" {
+				login.URIs = append(login.URIs, bitwardenURI{URI: *entry.Website})
+			}
+			item.Login = login
+		}
+
+		export.Items = append(export.Items, item)
+	}
+
+	return json.MarshalIndent(export, "", "  ")
+}
+
+func toBitwardenField(field models.CustomField) bitwardenField {
+	value := field.Value
+	converted := bitwardenField{Name: field.Name, Value: &value, Type: bitwardenFieldText}
+	switch field.Type {
+	case models.CustomFieldHidden, models.CustomFieldTOTP:
+		converted.Type = bitwardenFieldHidden
+	case models.CustomFieldBoolean:
+		converted.Type = bitwardenFieldBoolean
+	}
+	return converted
+}
+
+// detailFields lists the type-specific details of an SSH key or API
+// credential as custom fields.
+func detailFields(entry models.ExportEntry) []bitwardenField {
+	var fields []models.CustomField
+	add := func(name, fieldType, value string) {
+		if value != "" {
+			fields = append(fields, models.CustomField{Name: name, Type: fieldType, Value: value})
+		}
+	}
+
+	if key := entry.SSHKey; key != nil {
+		add("Private key", models.CustomFieldHidden, key.PrivateKey)
+		add("Passphrase", models.CustomFieldHidden, key.Passphrase)
+		add("Public key", models.CustomFieldText, key.PublicKey)
+		add("Fingerprint", models.CustomFieldText, key.Fingerprint)
+	}
+	if credential := entry.APICredential; credential != nil {
+		add("Key ID", models.CustomFieldText, credential.KeyID)
+		add("Secret", models.CustomFieldHidden, credential.Secret)
+		add("Endpoint", models.CustomFieldText, credential.Endpoint)
+		if credential.ExpiresAt != nil {
+			add("Expires at", models.CustomFieldText, credential.ExpiresAt.Format(time.RFC3339))
+		}
+	}
+
+	converted := make([]bitwardenField, len(fields))
+	for i, field := range fields {
+		converted[i] = toBitwardenField(field)
+	}
+	return converted
+}
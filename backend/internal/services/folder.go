@@ -0,0 +1,678 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+)
+
+// Limits on a user's folder tree.
+const (
+	maxFolderNameLen = 100
+	maxFolderDepth   = 10
+)
+
+// folderMigrationBatchSize bounds the folder paths filed per query by
+// MigrateLegacyFolders.
+const folderMigrationBatchSize = 100
+
+// What FolderService.Delete does with the entries of a deleted folder.
+const (
+	// FolderDeleteMove moves the folder's entries and subfolders to another
+	// folder, its parent by default.
+	FolderDeleteMove = "move"
+	// FolderDeleteEntries deletes the subfolders too, and moves every entry
+	// in them to the trash.
+	FolderDeleteEntries = "delete"
+)
+
+var (
+	ErrFolderNotFound = errors.New("folder not found")
+	// ErrFolderExists is returned when a folder would sit next to another of
+	// the same name.
+	ErrFolderExists = errors.New("a folder with this name already exists here")
+	// ErrFolderCycle is returned when a folder would be moved into itself or
+	// one of its subfolders.
+	ErrFolderCycle = errors.New("a folder cannot be moved into itself or its subfolders")
+	// ErrInvalidFolderName is returned for empty names, names that are too
+	// long, and names containing the path separator.
+	ErrInvalidFolderName = fmt.Errorf("folder names must be 1 to %d characters, without %q", maxFolderNameLen, models.FolderPathSeparator)
+	ErrFolderTooDeep     = fmt.Errorf("folders cannot be nested more than %d levels deep", maxFolderDepth)
+	// ErrFolderEntriesModified aborts a change to a folder when one of its
+	// entries was written at the same time.
+	ErrFolderEntriesModified = errors.New("entries of the folder were modified at the same time")
+)
+
+// FolderService manages users' folder trees. Entries name their folder by
+// ID and carry its path in clear, like the rest of their metadata, so that
+// listings and zero-knowledge clients need no extra lookup. Renaming, moving
+// or deleting a folder rewrites the path of every entry below it in the same
+// transaction, each as a new revision of the entry.
+type FolderService struct {
+	folderRepo *repository.FolderRepository
+	vaultRepo  *repository.VaultRepository
+	txManager  *repository.TxManager
+}
+
+func NewFolderService(folderRepo *repository.FolderRepository, vaultRepo *repository.VaultRepository, txManager *repository.TxManager) *FolderService {
+	return &FolderService{
+		folderRepo: folderRepo,
+		vaultRepo:  vaultRepo,
+		txManager:  txManager,
+	}
+}
+
+// List describes every folder of a user with its path and item counts,
+// sorted by path.
+func (s *FolderService) List(ctx context.Context, userID uuid.UUID) ([]models.FolderResponse, error) {
+	tree, err := s.loadTree(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.vaultRepo.CountByFolder(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.FolderResponse, 0, len(tree.byID))
+	for id, folder := range tree.byID {
+		var total int64
+		for _, descendant := range tree.subtree(id) {
+			total += counts[descendant]
+		}
+		responses = append(responses, models.FolderResponse{
+			ID:             folder.ID,
+			ParentID:       folder.ParentID,
+			Name:           folder.Name,
+			Path:           tree.path(id),
+			ItemCount:      counts[id],
+			TotalItemCount: total,
+			CreatedAt:      folder.CreatedAt,
+			UpdatedAt:      folder.UpdatedAt,
+		})
+	}
+	sort.Slice(responses, func(i, j int) bool {
+		return strings.ToLower(responses[i].Path) < strings.ToLower(responses[j].Path)
+	})
+	return responses, nil
+}
+
+// Describe describes one folder like List.
+func (s *FolderService) Describe(ctx context.Context, folder *models.Folder) (*models.FolderResponse, error) {
+	folders, err := s.List(ctx, folder.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range folders {
+		if folders[i].ID == folder.ID {
+			return &folders[i], nil
+		}
+	}
+	return nil, ErrFolderNotFound
+}
+
+// Create adds a folder under req.ParentID, or at the top level.
+func (s *FolderService) Create(ctx context.Context, userID uuid.UUID, req *models.FolderRequest) (*models.Folder, error) {
+	name, err := normalizeFolderName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := s.loadTree(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	depth := 1
+	if req.ParentID != nil {
+		if tree.byID[*req.ParentID] == nil {
+			return nil, ErrFolderNotFound
+		}
+		depth += tree.depth(*req.ParentID)
+	}
+	if depth > maxFolderDepth {
+		return nil, ErrFolderTooDeep
+	}
+	if tree.child(req.ParentID, name) != nil {
+		return nil, ErrFolderExists
+	}
+
+	folder := &models.Folder{
+		ID:       uuid.New(),
+		UserID:   userID,
+		ParentID: req.ParentID,
+		Name:     name,
+	}
+	if err := s.folderRepo.Create(ctx, folder); err != nil {
+		return nil, folderExists(err)
+	}
+	return folder, nil
+}
+
+// Update renames folder and moves it under req.ParentID. The entries in it
+// and in its subfolders get their new path, as revisions by actorID.
+func (s *FolderService) Update(ctx context.Context, folder *models.Folder, req *models.FolderRequest, actorID uuid.UUID, now time.Time) error {
+	name, err := normalizeFolderName(req.Name)
+	if err != nil {
+		return err
+	}
+	tree, err := s.loadTree(ctx, folder.UserID)
+	if err != nil {
+		return err
+	}
+
+	subtree := tree.subtree(folder.ID)
+	if req.ParentID != nil {
+		if tree.byID[*req.ParentID] == nil {
+			return ErrFolderNotFound
+		}
+		if containsID(subtree, *req.ParentID) {
+			return ErrFolderCycle
+		}
+	}
+	if sameParent(folder.ParentID, req.ParentID) && name == folder.Name {
+		return nil
+	}
+
+	depth := 0
+	if req.ParentID != nil {
+		depth = tree.depth(*req.ParentID)
+	}
+	if depth+tree.height(folder.ID) > maxFolderDepth {
+		return ErrFolderTooDeep
+	}
+	if sibling := tree.child(req.ParentID, name); sibling != nil && sibling.ID != folder.ID {
+		return ErrFolderExists
+	}
+
+	err = s.txManager.WithinTx(ctx, func(repos *repository.TxRepositories) error {
+		folder.Name = name
+		folder.ParentID = req.ParentID
+		folder.UpdatedAt = now
+		if err := repos.Folders.Update(ctx, folder); err != nil {
+			return err
+		}
+		tree.byID[folder.ID] = folder
+
+		entries, err := repos.Vaults.GetByFolderIDs(ctx, folder.UserID, subtree)
+		if err != nil {
+			return err
+		}
+		for i := range entries {
+			path := tree.path(*entries[i].FolderID)
+			if err := refileEntry(ctx, repos, &entries[i], entries[i].FolderID, &path, nil, actorID, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return folderExists(err)
+}
+
+// Delete deletes folder. With FolderDeleteMove its entries and subfolders
+// move to targetID, or to the folder's parent when it is nil; with
+// FolderDeleteEntries its subfolders are deleted too and their entries go to
+// the trash, keeping their former path so that restoring them recreates it.
+// It returns the number of entries moved or trashed, each as a revision by
+// actorID.
+func (s *FolderService) Delete(ctx context.Context, folder *models.Folder, mode string, targetID *uuid.UUID, actorID uuid.UUID, now time.Time) (int, error) {
+	tree, err := s.loadTree(ctx, folder.UserID)
+	if err != nil {
+		return 0, err
+	}
+	subtree := tree.subtree(folder.ID)
+
+	switch mode {
+	case FolderDeleteMove:
+		return s.deleteMovingEntries(ctx, tree, folder, subtree, targetID, actorID, now)
+	case FolderDeleteEntries:
+		return s.deleteWithEntries(ctx, folder, subtree, actorID, now)
+	default:
+		return 0, fmt.Errorf("unknown folder delete mode %q", mode)
+	}
+}
+
+func (s *FolderService) deleteMovingEntries(ctx context.Context, tree *folderTree, folder *models.Folder, subtree []uuid.UUID, targetID *uuid.UUID, actorID uuid.UUID, now time.Time) (int, error) {
+	target := folder.ParentID
+	if targetID != nil {
+		if tree.byID[*targetID] == nil {
+			return 0, ErrFolderNotFound
+		}
+		if containsID(subtree, *targetID) {
+			return 0, ErrFolderCycle
+		}
+		target = targetID
+	}
+
+	depth := 0
+	if target != nil {
+		depth = tree.depth(*target)
+	}
+	for _, child := range tree.children[parentKey(&folder.ID)] {
+		if depth+tree.height(child.ID) > maxFolderDepth {
+			return 0, ErrFolderTooDeep
+		}
+		if sibling := tree.child(target, child.Name); sibling != nil && sibling.ID != folder.ID {
+			return 0, ErrFolderExists
+		}
+	}
+
+	moved := 0
+	err := s.txManager.WithinTx(ctx, func(repos *repository.TxRepositories) error {
+		if err := repos.Folders.MoveChildren(ctx, folder.ID, target); err != nil {
+			return err
+		}
+		if err := repos.Folders.Delete(ctx, []uuid.UUID{folder.ID}); err != nil {
+			return err
+		}
+		for _, child := range tree.children[parentKey(&folder.ID)] {
+			child.ParentID = target
+		}
+		delete(tree.byID, folder.ID)
+
+		entries, err := repos.Vaults.GetByFolderIDs(ctx, folder.UserID, subtree)
+		if err != nil {
+			return err
+		}
+		for i := range entries {
+			entry := &entries[i]
+			folderID := entry.FolderID
+			if *folderID == folder.ID {
+				folderID = target
+			}
+			var path *string
+			if folderID != nil {
+				p := tree.path(*folderID)
+				path = &p
+			}
+			if err := refileEntry(ctx, repos, entry, folderID, path, nil, actorID, now); err != nil {
+				return err
+			}
+			moved++
+		}
+		return nil
+	})
+	return moved, folderExists(err)
+}
+
+func (s *FolderService) deleteWithEntries(ctx context.Context, folder *models.Folder, subtree []uuid.UUID, actorID uuid.UUID, now time.Time) (int, error) {
+	trashed := 0
+	err := s.txManager.WithinTx(ctx, func(repos *repository.TxRepositories) error {
+		entries, err := repos.Vaults.GetByFolderIDs(ctx, folder.UserID, subtree)
+		if err != nil {
+			return err
+		}
+		for i := range entries {
+			entry := &entries[i]
+			deletedAt := entry.DeletedAt
+			if deletedAt == nil {
+				deletedAt = &now
+			}
+			if err := refileEntry(ctx, repos, entry, nil, entry.Folder, deletedAt, actorID, now); err != nil {
+				return err
+			}
+			trashed++
+		}
+		return repos.Folders.Delete(ctx, subtree)
+	})
+	return trashed, err
+}
+
+// refileEntry writes entry with a new folder and, when deletedAt is set, in
+// the trash. Entries that would not change are left alone.
+func refileEntry(ctx context.Context, repos *repository.TxRepositories, entry *models.Vault, folderID *uuid.UUID, path *string, deletedAt *time.Time, actorID uuid.UUID, now time.Time) error {
+	if sameParent(entry.FolderID, folderID) && sameString(entry.Folder, path) && (deletedAt == nil || entry.InTrash()) {
+		return nil
+	}
+
+	entry.FolderID = folderID
+	entry.Folder = path
+	if deletedAt != nil {
+		entry.DeletedAt = deletedAt
+	}
+	entry.LastModifiedBy = &actorID
+	entry.UpdatedAt = now
+
+	updated, err := repos.Vaults.UpdateIfRevision(ctx, entry, entry.Revision)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrFolderEntriesModified
+	}
+	return nil
+}
+
+// Assign files vault in folderID, which must be one of the user's folders,
+// or else in the folder at path, creating the folders it names as needed.
+// Without either, the entry is left out of every folder.
+func (s *FolderService) Assign(ctx context.Context, userID uuid.UUID, vault *models.Vault, folderID *uuid.UUID, path *string) error {
+	if folderID != nil {
+		tree, err := s.loadTree(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if tree.byID[*folderID] == nil {
+			return ErrFolderNotFound
+		}
+		folderPath := tree.path(*folderID)
+		vault.FolderID = folderID
+		vault.Folder = &folderPath
+		return nil
+	}
+
+	vault.FolderID = nil
+	vault.Folder = nil
+	if path == nil {
+		return nil
+	}
+	names, err := splitFolderPath(*path, false)
+	if err != nil {
+		return err
+	}
+	return s.fileAt(ctx, userID, vault, names)
+}
+
+// Refile brings vault back into its folder after it was restored from the
+// trash or from a revision: the folder its FolderID names if it still
+// exists, or else the folder at its Folder path, recreated as needed.
+func (s *FolderService) Refile(ctx context.Context, userID uuid.UUID, vault *models.Vault) error {
+	if vault.FolderID != nil {
+		folder, err := s.folderRepo.GetByID(ctx, *vault.FolderID)
+		if err != nil {
+			return err
+		}
+		if folder != nil && folder.UserID == userID {
+			return s.Assign(ctx, userID, vault, vault.FolderID, nil)
+		}
+	}
+
+	vault.FolderID = nil
+	if vault.Folder == nil {
+		return nil
+	}
+	names, _ := splitFolderPath(*vault.Folder, true)
+	return s.fileAt(ctx, userID, vault, names)
+}
+
+// EnsurePath returns the folder at path, creating the folders it names as
+// needed, and its normalized path. Names that are too long are shortened
+// and levels beyond the maximum depth dropped, since it serves imported
+// paths. It returns nil for an empty path.
+func (s *FolderService) EnsurePath(ctx context.Context, userID uuid.UUID, path string) (*models.Folder, string, error) {
+	names, _ := splitFolderPath(path, true)
+	if len(names) == 0 {
+		return nil, "", nil
+	}
+	tree, err := s.loadTree(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	folder, err := s.ensurePath(ctx, tree, userID, names)
+	if err != nil {
+		return nil, "", err
+	}
+	return folder, tree.path(folder.ID), nil
+}
+
+func (s *FolderService) fileAt(ctx context.Context, userID uuid.UUID, vault *models.Vault, names []string) error {
+	vault.FolderID = nil
+	vault.Folder = nil
+	if len(names) == 0 {
+		return nil
+	}
+
+	tree, err := s.loadTree(ctx, userID)
+	if err != nil {
+		return err
+	}
+	folder, err := s.ensurePath(ctx, tree, userID, names)
+	if err != nil {
+		return err
+	}
+	path := tree.path(folder.ID)
+	vault.FolderID = &folder.ID
+	vault.Folder = &path
+	return nil
+}
+
+// ensurePath walks names down tree from the top level, creating the folders
+// that are missing, and returns the last one.
+func (s *FolderService) ensurePath(ctx context.Context, tree *folderTree, userID uuid.UUID, names []string) (*models.Folder, error) {
+	var parentID *uuid.UUID
+	var folder *models.Folder
+	for _, name := range names {
+		folder = tree.child(parentID, name)
+		if folder == nil {
+			folder = &models.Folder{
+				ID:       uuid.New(),
+				UserID:   userID,
+				ParentID: parentID,
+				Name:     name,
+			}
+			if err := s.folderRepo.Create(ctx, folder); err != nil {
+				return nil, folderExists(err)
+			}
+			tree.add(folder)
+		}
+		parentID = &folder.ID
+	}
+	return folder, nil
+}
+
+// MigrateLegacyFolders files the entries written before folders existed,
+// which only carry a folder path, in folders created from that path. It
+// runs once at startup and stops at the first error, to try again on the
+// next start.
+func (s *FolderService) MigrateLegacyFolders(ctx context.Context) {
+	filed := 0
+	for {
+		unfiled, err := s.vaultRepo.GetUnfiledFolders(ctx, folderMigrationBatchSize)
+		if err != nil {
+			log.Printf("Failed to list entries without a folder: %v", err)
+			return
+		}
+		if len(unfiled) == 0 {
+			break
+		}
+
+		for _, legacy := range unfiled {
+			folder, path, err := s.EnsurePath(ctx, legacy.UserID, legacy.Folder)
+			if err != nil {
+				log.Printf("Failed to create folder %q for user %s: %v", legacy.Folder, legacy.UserID, err)
+				return
+			}
+			var folderID *uuid.UUID
+			var folderPath *string
+			if folder != nil {
+				folderID, folderPath = &folder.ID, &path
+			}
+			if err := s.vaultRepo.FileFolder(ctx, legacy, folderID, folderPath); err != nil {
+				log.Printf("Failed to file entries in folder %q for user %s: %v", legacy.Folder, legacy.UserID, err)
+				return
+			}
+			filed++
+		}
+	}
+
+	if filed > 0 {
+		log.Printf("Filed the entries of %d legacy folder paths into folders", filed)
+	}
+}
+
+func (s *FolderService) loadTree(ctx context.Context, userID uuid.UUID) (*folderTree, error) {
+	folders, err := s.folderRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return newFolderTree(folders), nil
+}
+
+// folderTree indexes a user's folders by ID and by parent; top-level
+// folders are under uuid.Nil.
+type folderTree struct {
+	byID     map[uuid.UUID]*models.Folder
+	children map[uuid.UUID][]*models.Folder
+}
+
+func newFolderTree(folders []models.Folder) *folderTree {
+	tree := &folderTree{
+		byID:     make(map[uuid.UUID]*models.Folder, len(folders)),
+		children: make(map[uuid.UUID][]*models.Folder),
+	}
+	for i := range folders {
+		tree.add(&folders[i])
+	}
+	return tree
+}
+
+func (t *folderTree) add(folder *models.Folder) {
+	t.byID[folder.ID] = folder
+	key := parentKey(folder.ParentID)
+	t.children[key] = append(t.children[key], folder)
+}
+
+// child returns the folder named name, ignoring case, under parentID.
+func (t *folderTree) child(parentID *uuid.UUID, name string) *models.Folder {
+	for _, folder := range t.children[parentKey(parentID)] {
+		if t.byID[folder.ID] == folder && sameParent(folder.ParentID, parentID) && strings.EqualFold(folder.Name, name) {
+			return folder
+		}
+	}
+	return nil
+}
+
+// ancestry returns the folder and its parents, the top-level folder last.
+// A parent missing from the tree ends the chain.
+func (t *folderTree) ancestry(id uuid.UUID) []*models.Folder {
+	var chain []*models.Folder
+	for folder := t.byID[id]; folder != nil && len(chain) <= len(t.byID); {
+		chain = append(chain, folder)
+		if folder.ParentID == nil {
+			break
+		}
+		folder = t.byID[*folder.ParentID]
+	}
+	return chain
+}
+
+func (t *folderTree) path(id uuid.UUID) string {
+	chain := t.ancestry(id)
+	names := make([]string, len(chain))
+	for i, folder := range chain {
+		names[len(chain)-1-i] = folder.Name
+	}
+	return strings.Join(names, models.FolderPathSeparator)
+}
+
+// depth is the level of a folder, 1 for top-level folders.
+func (t *folderTree) depth(id uuid.UUID) int {
+	return len(t.ancestry(id))
+}
+
+// height is the number of levels of the subtree rooted at a folder.
+func (t *folderTree) height(id uuid.UUID) int {
+	height := 0
+	for _, child := range t.children[id] {
+		if t.byID[child.ID] == child && sameParent(child.ParentID, &id) {
+			height = max(height, t.height(child.ID))
+		}
+	}
+	return height + 1
+}
+
+// subtree returns a folder and all its subfolders.
+func (t *folderTree) subtree(id uuid.UUID) []uuid.UUID {
+	ids := []uuid.UUID{id}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range t.children[ids[i]] {
+			if t.byID[child.ID] == child && sameParent(child.ParentID, &ids[i]) && !containsID(ids, child.ID) {
+				ids = append(ids, child.ID)
+			}
+		}
+	}
+	return ids
+}
+
+func parentKey(parentID *uuid.UUID) uuid.UUID {
+	if parentID == nil {
+		return uuid.Nil
+	}
+	return *parentID
+}
+
+func sameParent(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameString(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func containsID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// folderExists reports a sibling created concurrently, which the checks on
+// the loaded tree missed but the unique index caught, as ErrFolderExists.
+func folderExists(err error) error {
+	if errors.Is(err, repository.ErrFolderNameTaken) {
+		return ErrFolderExists
+	}
+	return err
+}
+
+func normalizeFolderName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxFolderNameLen || strings.Contains(name, models.FolderPathSeparator) {
+		return "", ErrInvalidFolderName
+	}
+	return name, nil
+}
+
+// splitFolderPath splits a folder path into its names, skipping empty ones.
+// Lenient splitting shortens names that are too long and drops the levels
+// beyond the maximum depth instead of failing.
+func splitFolderPath(path string, lenient bool) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(path, models.FolderPathSeparator) {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if utf8.RuneCountInString(name) > maxFolderNameLen {
+			if !lenient {
+				return nil, ErrInvalidFolderName
+			}
+			name = strings.TrimSpace(string([]rune(name)[:maxFolderNameLen]))
+		}
+		names = append(names, name)
+	}
+
+	if len(names) > maxFolderDepth {
+		if !lenient {
+			return nil, ErrFolderTooDeep
+		}
+		names = names[:maxFolderDepth]
+	}
+	return names, nil
+}
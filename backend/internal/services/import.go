@@ -125,8 +125,14 @@ func (s *ImportService) parseLastPass(content string) ([]models.ImportEntry, err
 	return entries, nil
 }
 
+// parseBitwarden maps each item's folderId to the folder's name. Bitwarden
+// nests folders by naming them "Parent/Child", which is already a path.
 func (s *ImportService) parseBitwarden(content string) ([]models.ImportEntry, error) {
 	var data struct {
+		Folders []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"folders"`
 		Items []struct {
 			Type     int    `json:"type"`
 			Name     string `json:"name"`
@@ -135,11 +141,13 @@ func (s *ImportService) parseBitwarden(content string) ([]models.ImportEntry, er
 			Login    struct {
 				Username string `json:"username"`
 				Password string `json:"password"`
+				TOTP     string `json:"totp"`
 				URIs     []struct {
 					URI string `json:"uri"`
 				} `json:"uris"`
 			} `json:"login"`
-			FolderID string `json:"folderId"`
+			Fields   []bitwardenField `json:"fields"`
+			FolderID string           `json:"folderId"`
 		} `json:"items"`
 	}
 
@@ -147,6 +155,11 @@ func (s *ImportService) parseBitwarden(content string) ([]models.ImportEntry, er
 		return nil, err
 	}
 
+	folderNames := make(map[string]string, len(data.Folders))
+	for _, folder := range data.Folders {
+		folderNames[folder.ID] = folder.Name
+	}
+
 	var entries []models.ImportEntry
 
 	for _, item := range data.Items {
@@ -161,7 +174,8 @@ func (s *ImportService) parseBitwarden(content string) ([]models.ImportEntry, er
 
 		username := stringOrNil(item.Login.Username)
 		notes := stringOrNil(item.Notes)
-		folder := stringOrNil(item.FolderID)
+		// Items of an unlisted folder keep no folder rather than its ID.
+		folder := stringOrNil(folderNames[item.FolderID])
 
 		entry := models.ImportEntry{
 			Title:    item.Name,
@@ -171,8 +185,16 @@ func (s *ImportService) parseBitwarden(content string) ([]models.ImportEntry, er
 			Notes:    notes,
 			Folder:   folder,
 			Favorite: item.Favorite,
+			Fields:   bitwardenCustomFields(item.Fields),
 			Source:   "Bitwarden",
 		}
+		if item.Login.TOTP != "" {
+			entry.Fields = append(entry.Fields, models.CustomField{
+				Name:  "TOTP",
+				Type:  models.CustomFieldTOTP,
+				Value: item.Login.TOTP,
+			})
+		}
 
 		entries = append(entries, entry)
 	}
@@ -223,16 +245,99 @@ func (s *ImportService) parseChrome(content string) ([]models.ImportEntry, error
 	return entries, nil
 }
 
-func (s *ImportService) parseKeePass(content string) ([]models.ImportEntry, error) {
-	type KeePassEntry struct {
-		String []struct {
-			Key   string `xml:"Key"`
-			Value string `xml:"Value"`
-		} `xml:"String"`
+// Bitwarden custom field types.
+const (
+	bitwardenFieldText    = 0
+	bitwardenFieldHidden  = 1
+	bitwardenFieldBoolean = 2
+	bitwardenFieldLinked  = 3
+)
+
+type bitwardenField struct {
+	Name  string  `json:"name"`
+	Value *string `json:"value"`
+	Type  int     `json:"type"`
+}
+
+// bitwardenCustomFields converts an item's custom fields. Linked fields only
+// point at another field of the item and are dropped.
+func bitwardenCustomFields(fields []bitwardenField) []models.CustomField {
+	var custom []models.CustomField
+	for _, field := range fields {
+		value := ""
+		if field.Value != nil {
+			value = *field.Value
+		}
+
+		fieldType := models.CustomFieldText
+		switch field.Type {
+		case bitwardenFieldHidden:
+			fieldType = models.CustomFieldHidden
+		case bitwardenFieldBoolean:
+			fieldType = models.CustomFieldBoolean
+			if value == "" {
+				value = "false"
+			}
+		case bitwardenFieldLinked:
+			continue
+		}
+
+		custom = append(custom, models.CustomField{Name: field.Name, Type: fieldType, Value: value})
+	}
+	return custom
+}
+
+// keePassStandardKeys are the String keys KeePass itself defines; any other
+// key is a custom field.
+var keePassStandardKeys = map[string]bool{
+	"Title":    true,
+	"URL":      true,
+	"UserName": true,
+	"Password": true,
+	"Notes":    true,
+}
+
+// keePassCustomField converts a custom String of a KeePass entry. The TOTP
+// secret written by KeePass ("TimeOtp-Secret-Base32") or KeePassXC ("otp")
+// becomes a TOTP field and the other TOTP settings are dropped; protected
+// values become hidden fields.
+func keePassCustomField(key, value string, protected bool) (models.CustomField, bool) {
+	switch {
+	case key == "otp" || key == "TimeOtp-Secret-Base32":
+		return models.CustomField{Name: "TOTP", Type: models.CustomFieldTOTP, Value: value}, true
+	case strings.HasPrefix(key, "TimeOtp-") || strings.HasPrefix(key, "HmacOtp-"):
+		return models.CustomField{}, false
+	case protected:
+		return models.CustomField{Name: key, Type: models.CustomFieldHidden, Value: value}, true
 	}
+	return models.CustomField{Name: key, Type: models.CustomFieldText, Value: value}, true
+}
 
+type keePassEntry struct {
+	String []struct {
+		Key   string `xml:"Key"`
+		Value struct {
+			Text            string `xml:",chardata"`
+			ProtectInMemory string `xml:"ProtectInMemory,attr"`
+		} `xml:"Value"`
+	} `xml:"String"`
+}
+
+type keePassGroup struct {
+	UUID    string         `xml:"UUID"`
+	Name    string         `xml:"Name"`
+	Entries []keePassEntry `xml:"Entry"`
+	Groups  []keePassGroup `xml:"Group"`
+}
+
+// parseKeePass imports the entries of every group. The root group stands for
+// the database itself, so its entries get no folder and the names of the
+// groups below it make up the folder path. The recycle bin is skipped.
+func (s *ImportService) parseKeePass(content string) ([]models.ImportEntry, error) {
 	type KeePassXML struct {
-		Entries []KeePassEntry `xml:"Root>Group>Entry"`
+		RecycleBinEnabled string         `xml:"Meta>RecycleBinEnabled"`
+		RecycleBinUUID    string         `xml:"Meta>RecycleBinUUID"`
+		Groups            []keePassGroup `xml:"Root>Group"`
 	}
 
 	var data KeePassXML
@@ -240,42 +345,74 @@ func (s *ImportService) parseKeePass(content string) ([]models.ImportEntry, erro
 		return nil, err
 	}
 
-	var entries []models.ImportEntry
+	recycleBin := ""
+	if !strings.EqualFold(data.RecycleBinEnabled, "false") {
+		recycleBin = data.RecycleBinUUID
+	}
 
-	for _, kpEntry := range data.Entries {
-		entry := models.ImportEntry{
-			Source: "KeePass",
+	var entries []models.ImportEntry
+	var walk func(group *keePassGroup, path []string)
+	walk = func(group *keePassGroup, path []string) {
+		if recycleBin != "" && group.UUID == recycleBin {
+			return
 		}
 
-		for _, str := range kpEntry.String {
-			switch str.Key {
-			case "Title":
-				entry.Title = str.Value
-			case "URL":
-				if str.Value != "" {
-					entry.Website = &str.Value
-				}
-			case "UserName":
-				if str.Value != "" {
-					entry.Username = &str.Value
-				}
-			case "Password":
-				entry.Password = str.Value
-			case "Notes":
-				if str.Value != "" {
-					entry.Notes = &str.Value
-				}
+		var folder *string
+		if len(path) > 0 {
+			folder = stringPtr(strings.Join(path, models.FolderPathSeparator))
+		}
+		for i := range group.Entries {
+			if entry, ok := keePassImportEntry(&group.Entries[i], folder); ok {
+				entries = append(entries, entry)
 			}
 		}
 
-		if entry.Title != "" || entry.Password != "" {
-			entries = append(entries, entry)
+		for i := range group.Groups {
+			child := &group.Groups[i]
+			// A separator in a group name would read as another level.
+			name := strings.ReplaceAll(strings.TrimSpace(child.Name), models.FolderPathSeparator, "-")
+			walk(child, append(path[:len(path):len(path)], name))
 		}
 	}
+	for i := range data.Groups {
+		walk(&data.Groups[i], nil)
+	}
 
 	return entries, nil
 }
 
+func keePassImportEntry(kpEntry *keePassEntry, folder *string) (models.ImportEntry, bool) {
+	entry := models.ImportEntry{
+		Folder: folder,
+		Source: "KeePass",
+	}
+
+	for _, str := range kpEntry.String {
+		value := str.Value.Text
+		switch str.Key {
+		case "Title":
+			entry.Title = value
+		case "URL":
+			entry.Website = stringOrNil(value)
+		case "UserName":
+			entry.Username = stringOrNil(value)
+		case "Password":
+			entry.Password = value
+		case "Notes":
+			entry.Notes = stringOrNil(value)
+		}
+
+		if !keePassStandardKeys[str.Key] && value != "" {
+			protected := strings.EqualFold(str.Value.ProtectInMemory, "true")
+			if field, ok := keePassCustomField(str.Key, value, protected); ok {
+				entry.Fields = append(entry.Fields, field)
+			}
+		}
+	}
+
+	return entry, entry.Title != "" || entry.Password != ""
+}
+
 func stringOrNil(s string) *string {
 	if s == "" {
 		return nil
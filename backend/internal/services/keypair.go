@@ -0,0 +1,117 @@
+package services
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// sealedBoxInfo binds keys derived for sealed boxes to this use.
+const sealedBoxInfo = "tresor sealed box v1"
+
+// GenerateKeyPair creates an X25519 keypair used to receive shared items.
+func (s *CryptoService) GenerateKeyPair() (publicKey, privateKey []byte, err error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return priv.PublicKey().Bytes(), priv.Bytes(), nil
+}
+
+// ValidatePublicKey checks that publicKey is a usable X25519 public key.
+func (s *CryptoService) ValidatePublicKey(publicKey []byte) error {
+	_, err := ecdh.X25519().NewPublicKey(publicKey)
+	return err
+}
+
+// SealToPublicKey encrypts plaintext so that only the holder of the private
+// key matching publicKey can read it. A fresh ephemeral X25519 key is agreed
+// with publicKey, HKDF-SHA256 (salt: ephemeral key || recipient key) turns the
+// shared secret into an AES-256-GCM key, and the ephemeral public key is
+// stored in the envelope.
+func (s *CryptoService) SealToPublicKey(plaintext, publicKey []byte) (string, error) {
+	recipient, err := ecdh.X25519().NewPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := deriveSealedBoxKey(ephemeral, recipient, ephemeral.PublicKey().Bytes(), publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, nonce, err := s.EncryptWithKey(plaintext, key)
+	if err != nil {
+		return "", err
+	}
+
+	envelope := &Envelope{
+		Version:      EnvelopeVersion,
+		Algorithm:    AlgorithmAES256GCM,
+		KDF:          KDFParams{ID: KDFX25519HKDF},
+		EphemeralKey: base64.StdEncoding.EncodeToString(ephemeral.PublicKey().Bytes()),
+		Nonce:        nonce,
+		Ciphertext:   ciphertext,
+	}
+	return envelope.Encode()
+}
+
+// OpenWithPrivateKey decrypts an envelope produced by SealToPublicKey.
+func (s *CryptoService) OpenWithPrivateKey(data string, privateKey []byte) ([]byte, error) {
+	envelope, err := ParseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	if envelope.KDF.ID != KDFX25519HKDF {
+		return nil, errors.New("envelope is not sealed to a public key")
+	}
+
+	priv, err := ecdh.X25519().NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeralBytes, err := base64.StdEncoding.DecodeString(envelope.EphemeralKey)
+	if err != nil {
+		return nil, err
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(ephemeralBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := deriveSealedBoxKey(priv, ephemeral, ephemeralBytes, priv.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	return s.DecryptWithKey(envelope.Ciphertext, envelope.Nonce, key)
+}
+
+// deriveSealedBoxKey runs the X25519 exchange between priv and peer and
+// derives the AES key. Both sides salt HKDF with the ephemeral public key
+// followed by the recipient's public key.
+func deriveSealedBoxKey(priv *ecdh.PrivateKey, peer *ecdh.PublicKey, ephemeralPublic, recipientPublic []byte) ([]byte, error) {
+	shared, err := priv.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := append(append([]byte{}, ephemeralPublic...), recipientPublic...)
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(sealedBoxInfo)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -1,10 +1,12 @@
 package services
 
 import (
+	"fmt"
 	"math"
 	"regexp"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/tresor/password-manager/internal/models"
 )
@@ -81,7 +83,10 @@ func (s *PasswordHealthService) GenerateHealthReport(vaults []models.Vault) map[
 	}
 }
 
-func (s *PasswordHealthService) CalculateStrength(password string, lastChanged time.Time) map[string]interface{} {
+// CalculateStrength scores password and lists its weaknesses. When policy is
+// set, the rules it breaks are listed under "policy_violations"; the breach
+// rule needs a network lookup and is left to the caller.
+func (s *PasswordHealthService) CalculateStrength(password string, lastChanged time.Time, policy *models.PasswordPolicy) map[string]interface{} {
 	score := 0
 	var issues []string
 	var suggestions []string
@@ -99,22 +104,7 @@ func (s *PasswordHealthService) CalculateStrength(password string, lastChanged t
 		score += 20
 	}
 
-	hasLower := false
-	hasUpper := false
-	hasDigit := false
-	hasSpecial := false
-
-	for _, char := range password {
-		if unicode.IsLower(char) {
-			hasLower = true
-		} else if unicode.IsUpper(char) {
-			hasUpper = true
-		} else if unicode.IsDigit(char) {
-			hasDigit = true
-		} else {
-			hasSpecial = true
-		}
-	}
+	hasLower, hasUpper, hasDigit, hasSpecial := characterClasses(password)
 
 	varietyCount := 0
 	if hasLower {
@@ -187,13 +177,84 @@ func (s *PasswordHealthService) CalculateStrength(password string, lastChanged t
 		color = "red"
 	}
 
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"score":       score,
 		"strength":    strength,
 		"color":       color,
 		"issues":      issues,
 		"suggestions": suggestions,
 	}
+	if policy != nil {
+		result["policy_violations"] = s.EvaluatePolicy(password, lastChanged, *policy)
+	}
+
+	return result
+}
+
+// EvaluatePolicy lists the rules of policy that password breaks, given when
+// it was last changed. The breach and 2FA rules are not checked here.
+func (s *PasswordHealthService) EvaluatePolicy(password string, lastChanged time.Time, policy models.PasswordPolicy) []models.PolicyViolation {
+	violations := []models.PolicyViolation{}
+
+	if policy.MinLength > 0 && utf8.RuneCountInString(password) < policy.MinLength {
+		violations = append(violations, models.PolicyViolation{
+			Code:    models.PolicyViolationMinLength,
+			Message: fmt.Sprintf("Password must be at least %d characters long", policy.MinLength),
+			Limit:   policy.MinLength,
+		})
+	}
+
+	hasLower, hasUpper, hasDigit, hasSpecial := characterClasses(password)
+	if policy.RequireLower && !hasLower {
+		violations = append(violations, models.PolicyViolation{
+			Code:    models.PolicyViolationLowercase,
+			Message: "Password must contain a lowercase letter",
+		})
+	}
+	if policy.RequireUpper && !hasUpper {
+		violations = append(violations, models.PolicyViolation{
+			Code:    models.PolicyViolationUppercase,
+			Message: "Password must contain an uppercase letter",
+		})
+	}
+	if policy.RequireDigit && !hasDigit {
+		violations = append(violations, models.PolicyViolation{
+			Code:    models.PolicyViolationDigit,
+			Message: "Password must contain a number",
+		})
+	}
+	if policy.RequireSpecial && !hasSpecial {
+		violations = append(violations, models.PolicyViolation{
+			Code:    models.PolicyViolationSpecial,
+			Message: "Password must contain a special character",
+		})
+	}
+
+	if policy.MaxAgeDays > 0 && time.Since(lastChanged) > time.Duration(policy.MaxAgeDays)*24*time.Hour {
+		violations = append(violations, models.PolicyViolation{
+			Code:    models.PolicyViolationMaxAge,
+			Message: fmt.Sprintf("Password must be changed every %d days", policy.MaxAgeDays),
+			Limit:   policy.MaxAgeDays,
+		})
+	}
+
+	return violations
+}
+
+// characterClasses reports which character classes password uses.
+func characterClasses(password string) (hasLower, hasUpper, hasDigit, hasSpecial bool) {
+	for _, char := range password {
+		if unicode.IsLower(char) {
+			hasLower = true
+		} else if unicode.IsUpper(char) {
+			hasUpper = true
+		} else if unicode.IsDigit(char) {
+			hasDigit = true
+		} else {
+			hasSpecial = true
+		}
+	}
+	return hasLower, hasUpper, hasDigit, hasSpecial
 }
 
 func (s *PasswordHealthService) calculateEntropy(password string) float64 {
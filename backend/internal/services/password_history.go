@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/config"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+)
+
+// PasswordHistoryService keeps the passwords that entries held before they
+// were changed, within the retention configured for the instance.
+type PasswordHistoryService struct {
+	historyRepo   *repository.PasswordHistoryRepository
+	cryptoService *CryptoService
+	maxEntries    int
+	maxAge        time.Duration
+}
+
+func NewPasswordHistoryService(cfg *config.HistoryConfig, historyRepo *repository.PasswordHistoryRepository, cryptoService *CryptoService) *PasswordHistoryService {
+	return &PasswordHistoryService{
+		historyRepo:   historyRepo,
+		cryptoService: cryptoService,
+		maxEntries:    cfg.MaxEntries,
+		maxAge:        time.Duration(cfg.MaxAgeDays) * 24 * time.Hour,
+	}
+}
+
+// Seal returns a history record of password for the entry vaultID, sealed
+// with key, without storing it. Callers that update the entry in a
+// transaction create the record there and Prune afterwards.
+func (s *PasswordHistoryService) Seal(vaultID uuid.UUID, key []byte, password string, changedBy uuid.UUID, changedAt time.Time) (*models.PasswordHistory, error) {
+	envelope, err := s.cryptoService.SealWithKey([]byte(password), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PasswordHistory{
+		ID:                uuid.New(),
+		VaultID:           vaultID,
+		EncryptedPassword: envelope,
+		EncryptionVersion: models.EncryptionVersionCurrent,
+		ChangedBy:         changedBy,
+		ChangedAt:         changedAt,
+	}, nil
+}
+
+// Record stores password as replaced by changedBy at changedAt and applies
+// the retention limits.
+func (s *PasswordHistoryService) Record(ctx context.Context, vaultID uuid.UUID, key []byte, password string, changedBy uuid.UUID, changedAt time.Time) error {
+	history, err := s.Seal(vaultID, key, password, changedBy, changedAt)
+	if err != nil {
+		return err
+	}
+	if err := s.historyRepo.Create(ctx, history); err != nil {
+		return err
+	}
+	return s.Prune(ctx, vaultID, changedAt)
+}
+
+// List returns the history of an entry, most recent change first, once
+// records past the retention limits are dropped.
+func (s *PasswordHistoryService) List(ctx context.Context, vaultID uuid.UUID, now time.Time) ([]models.PasswordHistory, error) {
+	if err := s.Prune(ctx, vaultID, now); err != nil {
+		return nil, err
+	}
+	return s.historyRepo.GetByVaultID(ctx, vaultID)
+}
+
+// Open decrypts a history record with the key of its entry.
+func (s *PasswordHistoryService) Open(history *models.PasswordHistory, key []byte) (string, error) {
+	password, err := s.cryptoService.OpenWithKey(history.EncryptedPassword, key)
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
+// Prune applies the retention limits to the history of an entry.
+func (s *PasswordHistoryService) Prune(ctx context.Context, vaultID uuid.UUID, now time.Time) error {
+	var olderThan time.Time
+	if s.maxAge > 0 {
+		olderThan = now.Add(-s.maxAge)
+	}
+	return s.historyRepo.Prune(ctx, vaultID, s.maxEntries, olderThan)
+}
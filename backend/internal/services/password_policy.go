@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/config"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+)
+
+// PasswordPolicyService works out which password policy applies to a user
+// and checks passwords and accounts against it. The instance policy comes
+// from configuration; every organization a user belongs to can tighten it.
+type PasswordPolicyService struct {
+	instance         models.PasswordPolicy
+	userRepo         *repository.UserRepository
+	organizationRepo *repository.OrganizationRepository
+	healthService    *PasswordHealthService
+	breachService    *BreachService
+}
+
+func NewPasswordPolicyService(
+	cfg *config.PolicyConfig,
+	userRepo *repository.UserRepository,
+	organizationRepo *repository.OrganizationRepository,
+	healthService *PasswordHealthService,
+	breachService *BreachService,
+) *PasswordPolicyService {
+	return &PasswordPolicyService{
+		instance: models.PasswordPolicy{
+			MinLength:      cfg.MinLength,
+			RequireLower:   cfg.RequireLower,
+			RequireUpper:   cfg.RequireUpper,
+			RequireDigit:   cfg.RequireDigit,
+			RequireSpecial: cfg.RequireSpecial,
+			MaxAgeDays:     cfg.MaxAgeDays,
+			ForbidBreached: cfg.ForbidBreached,
+			Require2FA:     cfg.Require2FA,
+		},
+		userRepo:         userRepo,
+		organizationRepo: organizationRepo,
+		healthService:    healthService,
+		breachService:    breachService,
+	}
+}
+
+// InstancePolicy returns the configured policy, which applies to everyone,
+// including people who are still registering.
+func (s *PasswordPolicyService) InstancePolicy() models.PasswordPolicy {
+	return s.instance
+}
+
+// EffectivePolicy returns the instance policy merged with the policy of
+// every organization userID belongs to.
+func (s *PasswordPolicyService) EffectivePolicy(ctx context.Context, userID uuid.UUID) (models.PasswordPolicy, error) {
+	memberships, err := s.organizationRepo.GetMembershipsForUser(ctx, userID)
+	if err != nil {
+		return models.PasswordPolicy{}, err
+	}
+
+	policy := s.instance
+	for _, membership := range memberships {
+		policy = policy.Merge(membership.Organization.Policy)
+	}
+	return policy, nil
+}
+
+// EntryPolicy returns the policy for an entry userID writes. Entries of an
+// organization, or of a collection that belongs to one, are also held to that
+// organization's policy, whatever the writer's memberships.
+func (s *PasswordPolicyService) EntryPolicy(ctx context.Context, userID uuid.UUID, organizationID *uuid.UUID) (models.PasswordPolicy, error) {
+	policy, err := s.EffectivePolicy(ctx, userID)
+	if err != nil || organizationID == nil {
+		return policy, err
+	}
+
+	organization, err := s.organizationRepo.GetByID(ctx, *organizationID)
+	if err != nil {
+		return models.PasswordPolicy{}, err
+	}
+	if organization != nil {
+		policy = policy.Merge(organization.Policy)
+	}
+	return policy, nil
+}
+
+// CheckPassword lists the rules of policy that a new password breaks. The
+// breach rule queries Have I Been Pwned; when the lookup fails the password
+// is let through rather than blocking every write while the API is down.
+func (s *PasswordPolicyService) CheckPassword(policy models.PasswordPolicy, password string) []models.PolicyViolation {
+	violations := s.healthService.EvaluatePolicy(password, time.Now(), policy)
+
+	if policy.ForbidBreached {
+		breached, count, err := s.breachService.CheckBreach(password)
+		if err != nil {
+			log.Printf("Password policy breach check failed: %v", err)
+		} else if breached {
+			violations = append(violations, models.PolicyViolation{
+				Code:    models.PolicyViolationBreached,
+				Message: fmt.Sprintf("Password has appeared %d times in data breaches", count),
+			})
+		}
+	}
+
+	return violations
+}
+
+// CheckAccount lists the account-level rules of policy that userID breaks.
+func (s *PasswordPolicyService) CheckAccount(ctx context.Context, userID uuid.UUID, policy models.PasswordPolicy) ([]models.PolicyViolation, error) {
+	violations := []models.PolicyViolation{}
+	if !policy.Require2FA {
+		return violations, nil
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %s not found", userID)
+	}
+
+	if !user.TwoFactorEnabled {
+		violations = append(violations, models.PolicyViolation{
+			Code:    models.PolicyViolation2FA,
+			Message: "Two-factor authentication must be enabled",
+		})
+	}
+	return violations, nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+)
+
+// ShareAccessService keeps the per-share access history and alerts owners
+// the first time one of their shares is opened.
+type ShareAccessService struct {
+	accessLogRepo *repository.ShareAccessLogRepository
+	userRepo      *repository.UserRepository
+	vaultRepo     *repository.VaultRepository
+	emailService  *EmailService
+}
+
+func NewShareAccessService(
+	accessLogRepo *repository.ShareAccessLogRepository,
+	userRepo *repository.UserRepository,
+	vaultRepo *repository.VaultRepository,
+	emailService *EmailService,
+) *ShareAccessService {
+	return &ShareAccessService{
+		accessLogRepo: accessLogRepo,
+		userRepo:      userRepo,
+		vaultRepo:     vaultRepo,
+		emailService:  emailService,
+	}
+}
+
+// Record logs an access attempt on share. Failures are logged and never fail
+// the request.
+func (s *ShareAccessService) Record(ctx context.Context, share *models.SharedPassword, userID *uuid.UUID, ipAddress, userAgent, outcome string) {
+	entry := &models.ShareAccessLog{
+		ID:        uuid.New(),
+		ShareID:   share.ID,
+		UserID:    userID,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		Outcome:   outcome,
+		CreatedAt: time.Now(),
+	}
+	if err := s.accessLogRepo.Create(ctx, entry); err != nil {
+		log.Printf("Failed to record access to share %s: %v", share.ID, err)
+	}
+}
+
+// RecordView logs a granted access to share. viewCount is the count returned
+// by the atomic increment of this view: only the view that brought it to one
+// is the first, and the owner is emailed once even under concurrent views.
+func (s *ShareAccessService) RecordView(ctx context.Context, share *models.SharedPassword, userID *uuid.UUID, ipAddress, userAgent string, viewCount int) {
+	s.Record(ctx, share, userID, ipAddress, userAgent, models.ShareAccessGranted)
+
+	if viewCount == 1 {
+		go s.notifyFirstAccess(*share, userID, ipAddress)
+	}
+}
+
+// notifyFirstAccess emails the owner of share. It runs detached from the
+// request, so it uses its own context.
+func (s *ShareAccessService) notifyFirstAccess(share models.SharedPassword, userID *uuid.UUID, ipAddress string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	owner, err := s.userRepo.GetByID(ctx, share.OwnerID)
+	if err != nil || owner == nil {
+		return
+	}
+
+	title := "a shared item"
+	if vault, err := s.vaultRepo.GetByID(ctx, share.VaultID); err == nil && vault != nil {
+		title = vault.Title
+	}
+
+	accessedBy := "Someone with the link"
+	if userID != nil {
+		if user, err := s.userRepo.GetByID(ctx, *userID); err == nil && user != nil {
+			accessedBy = user.Email
+		}
+	}
+
+	if err := s.emailService.SendShareFirstAccessAlert(owner.Email, title, accessedBy, ipAddress); err != nil {
+		log.Printf("Failed to send first access alert for share %s: %v", share.ID, err)
+	}
+}
+
+// History returns the latest access attempts on a share.
+func (s *ShareAccessService) History(ctx context.Context, shareID uuid.UUID, limit int) ([]models.ShareAccessLog, error) {
+	return s.accessLogRepo.GetByShareID(ctx, shareID, limit)
+}
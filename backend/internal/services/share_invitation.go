@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/tresor/password-manager/internal/config"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+	"golang.org/x/crypto/hkdf"
+)
+
+// invitationKeyInfo binds an invitation key derived from the JWT secret to
+// this use.
+const invitationKeyInfo = "tresor share invitation key v1"
+
+// ErrShareInvitationNotFound is returned for an unknown, used or revoked
+// invitation token, or one sent to another email.
+var ErrShareInvitationNotFound = errors.New("share invitation not found")
+
+// ShareInvitationService escrows shares sent to an email address that has no
+// account yet. The entry is sealed to the server's invitation key until the
+// recipient claims it with the token from their invitation email, then
+// re-sealed to their own public key. Registering the email alone is not
+// enough: nothing proves the registrant owns the address.
+//
+// While a share is pending the server can read it, even when the owner uses
+// client-side encryption: that is the price of sharing with someone who has
+// no key yet.
+type ShareInvitationService struct {
+	shareRepo     *repository.ShareRepository
+	cryptoService *CryptoService
+	privateKey    *ecdh.PrivateKey
+}
+
+func NewShareInvitationService(
+	shareRepo *repository.ShareRepository,
+	cryptoService *CryptoService,
+	cfg *config.SharingConfig,
+	jwtSecret string,
+) (*ShareInvitationService, error) {
+	var seed []byte
+	if cfg.InvitationKey != "" {
+		decoded, err := base64.StdEncoding.DecodeString(cfg.InvitationKey)
+		if err != nil {
+			return nil, err
+		}
+		seed = decoded
+	} else {
+		seed = make([]byte, 32)
+		if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(jwtSecret), nil, []byte(invitationKeyInfo)), seed); err != nil {
+			return nil, err
+		}
+	}
+
+	privateKey, err := ecdh.X25519().NewPrivateKey(seed)
+	if err != nil {
+		return nil, errors.New("invitation key must be 32 bytes")
+	}
+
+	return &ShareInvitationService{
+		shareRepo:     shareRepo,
+		cryptoService: cryptoService,
+		privateKey:    privateKey,
+	}, nil
+}
+
+// PublicKey returns the base64 invitation public key zero-knowledge clients
+// seal pending shares to.
+func (s *ShareInvitationService) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.privateKey.PublicKey().Bytes())
+}
+
+// Seal seals plaintext to the invitation key.
+func (s *ShareInvitationService) Seal(plaintext []byte) (string, error) {
+	return s.cryptoService.SealToPublicKey(plaintext, s.privateKey.PublicKey().Bytes())
+}
+
+// Rekey re-seals an escrowed share payload to a recipient's public key.
+func (s *ShareInvitationService) Rekey(encryptedData, recipientPublicKey string) (string, error) {
+	publicKey, err := base64.StdEncoding.DecodeString(recipientPublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := s.cryptoService.OpenWithPrivateKey(encryptedData, s.privateKey.Bytes())
+	if err != nil {
+		return "", err
+	}
+
+	return s.cryptoService.SealToPublicKey(plaintext, publicKey)
+}
+
+// BindInvitation hands the pending share whose invitation token hashes to
+// tokenHash over to user, who must have the email it was sent to. The share is
+// re-sealed to the user's public key when there is one; otherwise it stays
+// escrowed and is re-keyed on first access. The token is single use.
+func (s *ShareInvitationService) BindInvitation(ctx context.Context, user *models.User, tokenHash string) (*models.SharedPassword, error) {
+	share, err := s.shareRepo.GetPendingByInvitationTokenHash(ctx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if share == nil || !strings.EqualFold(share.RecipientEmail, user.Email) {
+		return nil, ErrShareInvitationNotFound
+	}
+
+	data, version := share.EncryptedData, share.EncryptionVersion
+	if user.PublicKey != "" {
+		rekeyed, err := s.Rekey(share.EncryptedData, user.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		data, version = rekeyed, models.ShareEncryptionSealed
+	}
+
+	bound, err := s.shareRepo.BindRecipient(ctx, share.ID, user.ID, data, version)
+	if err != nil {
+		return nil, err
+	}
+	if !bound {
+		return nil, ErrShareInvitationNotFound
+	}
+	return share, nil
+}
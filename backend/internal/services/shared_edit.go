@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/tresor/password-manager/internal/models"
+)
+
+// ApplySharedEdits merges the pending recipient edits on user's entries into
+// the entries, oldest first, and records the recipient as the last modifier
+// and as the author of any password change in the entry's history.
+// Edits for entries that are gone are dropped; edits that cannot be opened or
+// lose a race with another writer stay pending for the next unlock. It
+// returns how many edits were applied.
+func (s *VaultKeyService) ApplySharedEdits(ctx context.Context, user *models.User, vaultKey []byte, masterPassword string) (int, error) {
+	edits, err := s.sharedEditRepo.GetPendingForOwner(ctx, user.ID)
+	if err != nil || len(edits) == 0 {
+		return 0, err
+	}
+
+	privateKey, err := s.OpenPrivateKey(user, vaultKey)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for i := range edits {
+		edit := &edits[i]
+
+		vault, err := s.vaultRepo.GetByID(ctx, edit.VaultID)
+		if err != nil {
+			return applied, err
+		}
+		if vault == nil || vault.UserID != user.ID || !vault.IsPersonal() {
+			if err := s.sharedEditRepo.Delete(ctx, edit.ID); err != nil {
+				return applied, err
+			}
+			continue
+		}
+
+		plaintext, err := s.cryptoService.OpenWithPrivateKey(edit.EncryptedData, privateKey)
+		if err != nil {
+			continue
+		}
+		var payload models.SharedEditPayload
+		if err := json.Unmarshal(plaintext, &payload); err != nil {
+			continue
+		}
+
+		entry, err := s.OpenEntry(vault, vaultKey, masterPassword)
+		if err != nil {
+			continue
+		}
+		var data models.DecryptedVaultData
+		if err := json.Unmarshal([]byte(entry), &data); err != nil {
+			continue
+		}
+
+		previousPassword := data.Password
+		if payload.Password != nil {
+			data.Password = *payload.Password
+		}
+		if payload.Notes != nil {
+			data.Notes = payload.Notes
+		}
+
+		merged, err := json.Marshal(data)
+		if err != nil {
+			return applied, err
+		}
+		if err := s.SealEntry(vault, vaultKey, string(merged)); err != nil {
+			return applied, err
+		}
+		now := time.Now()
+		vault.LastModifiedBy = &edit.EditorID
+		vault.UpdatedAt = now
+
+		updated, err := s.vaultRepo.UpdateIfRevision(ctx, vault, vault.Revision)
+		if err != nil {
+			return applied, err
+		}
+		if !updated {
+			continue
+		}
+
+		if previousPassword != "" && previousPassword != data.Password {
+			if err := s.historyService.Record(ctx, vault.ID, vaultKey, previousPassword, edit.EditorID, now); err != nil {
+				log.Printf("Failed to record password history for vault entry %s: %v", vault.ID, err)
+			}
+		}
+
+		if err := s.sharedEditRepo.MarkApplied(ctx, edit.ID); err != nil {
+			return applied, err
+		}
+		applied++
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,330 @@
+package services
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+)
+
+// srpGroupN is the 2048-bit group from RFC 5054, appendix A.
+const srpGroupN = "AC6BDB41324A9A9BF166DE5E1389582FAF72B6651987EE07FC3192943DB56050" +
+	"A37329CBB4A099ED8193E0757767A13DD52312AB4B03310DCD7F48A9DA04FD50" +
+	"E8083969EDB767B0CF6095179A163AB3661A05FBD5FAAAE82918A9962F0B93B8" +
+	"55F97993EC975EEAA80D740ADBF4FF747359D041D5C33EA71D281E446B14773B" +
+	"CA97B43A23FB801676BD207A436C6481F1D2B9078717461A5B9D32E688F87748" +
+	"544523B524B0D57D5EA77A2775D2ECFA032CFBDBF52FB3786160279004E57AE6" +
+	"AF874E7303CE53299CCC041C7BC308D82A5698F3A8D0C38271AE35F8E9DBFBB6" +
+	"94B5C803D89F7AE435DE236D525F54759B65E372FCD68EF20FA7111F9E4AFF73"
+
+const (
+	// srpSaltLength is the length of every client salt. Fake challenges use
+	// it too, so salts of another length cannot tell them from real ones.
+	srpSaltLength = 16
+	// SRPHandshakeTTL bounds the time between the two login rounds.
+	SRPHandshakeTTL = 2 * time.Minute
+	// srpMaxHandshakes bounds the handshakes waiting for their second round.
+	srpMaxHandshakes = 10000
+)
+
+var (
+	ErrSRPInvalidVerifier   = errors.New("invalid SRP verifier")
+	ErrSRPInvalidPublic     = errors.New("invalid SRP public value")
+	ErrSRPHandshakeNotFound = errors.New("SRP handshake not found or expired")
+	ErrSRPProofMismatch     = errors.New("SRP client proof mismatch")
+	ErrSRPTooManyHandshakes = errors.New("too many pending SRP handshakes")
+)
+
+var (
+	srpN = mustParseHex(srpGroupN)
+	srpG = big.NewInt(2)
+	// srpK is the SRP-6a multiplier k = H(N | PAD(g)).
+	srpK = new(big.Int).SetBytes(srpHash(srpN.Bytes(), srpPad(srpG)))
+)
+
+// SRPService implements the server side of SRP-6a (RFC 5054 group 2048,
+// SHA-256). The client derives x from its salt and master password however it
+// likes and only ever sends the verifier v = g^x mod N, so the server never
+// learns the master password, not even at login.
+//
+// Group elements are left-padded to the length of N wherever they are hashed.
+// The proofs are
+//
+//	u  = H(PAD(A) | PAD(B))
+//	K  = H(PAD(S))
+//	M1 = H(H(N) xor H(PAD(g)) | H(I) | s | PAD(A) | PAD(B) | K)
+//	M2 = H(PAD(A) | M1 | K)
+//
+// where I is the account email in lower case.
+//
+// Handshakes live in memory between the two rounds and are single use. At
+// most srpMaxHandshakes wait at a time; they are kept in expiry order so that
+// expired ones are dropped without scanning the others.
+type SRPService struct {
+	// fakeSaltKey derives stable fake salts for unknown accounts so that the
+	// first login round does not reveal which emails are registered.
+	fakeSaltKey []byte
+
+	handshakes   map[string]*srpHandshake
+	expiryOrder  *list.List
+	handshakesMu sync.Mutex
+}
+
+func NewSRPService(secret string) *SRPService {
+	return &SRPService{
+		fakeSaltKey: []byte(secret),
+		handshakes:  make(map[string]*srpHandshake),
+		expiryOrder: list.New(),
+	}
+}
+
+// srpHandshake is the server state between the two login rounds. userID is
+// uuid.Nil for handshakes answered on behalf of an unknown account.
+type srpHandshake struct {
+	userID    uuid.UUID
+	identity  string
+	salt      []byte
+	verifier  *big.Int
+	pubA      *big.Int
+	b         *big.Int
+	pubB      *big.Int
+	expiresAt time.Time
+	// queued is the handshake's element in SRPService.expiryOrder.
+	queued *list.Element
+}
+
+// SRPChallenge is the server's answer to the first login round.
+type SRPChallenge struct {
+	HandshakeID  string
+	Salt         []byte
+	ServerPublic []byte
+}
+
+// BeginLogin runs the first login round for email. user is the account found
+// for it, or nil; accounts that do not sign in with PAKE get a fake but
+// plausible challenge whose proof never verifies.
+func (s *SRPService) BeginLogin(user *models.User, email string, clientPublic []byte) (*SRPChallenge, error) {
+	var hs *srpHandshake
+	var err error
+
+	if user != nil && user.UsesPAKE() && user.SRPSalt != nil {
+		salt, decodeErr := base64.StdEncoding.DecodeString(*user.SRPSalt)
+		verifier, verifierErr := base64.StdEncoding.DecodeString(*user.SRPVerifier)
+		if decodeErr != nil || verifierErr != nil {
+			return nil, ErrSRPInvalidVerifier
+		}
+		hs, err = s.startHandshake(user.Email, salt, verifier, clientPublic)
+		if hs != nil {
+			hs.userID = user.ID
+		}
+	} else {
+		hs, err = s.startFakeHandshake(email, clientPublic)
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	hs.expiresAt = now.Add(SRPHandshakeTTL)
+
+	id := uuid.New().String()
+
+	s.handshakesMu.Lock()
+	// Every handshake has the same TTL, so the oldest expire first.
+	for front := s.expiryOrder.Front(); front != nil; front = s.expiryOrder.Front() {
+		key := front.Value.(string)
+		if !now.After(s.handshakes[key].expiresAt) {
+			break
+		}
+		s.expiryOrder.Remove(front)
+		delete(s.handshakes, key)
+	}
+	if len(s.handshakes) >= srpMaxHandshakes {
+		s.handshakesMu.Unlock()
+		return nil, ErrSRPTooManyHandshakes
+	}
+	hs.queued = s.expiryOrder.PushBack(id)
+	s.handshakes[id] = hs
+	s.handshakesMu.Unlock()
+
+	return &SRPChallenge{
+		HandshakeID:  id,
+		Salt:         hs.salt,
+		ServerPublic: srpPad(hs.pubB),
+	}, nil
+}
+
+// FinishLogin consumes a handshake and checks the client proof M1. On
+// success it returns the account and the server proof M2.
+func (s *SRPService) FinishLogin(handshakeID string, clientProof []byte) (uuid.UUID, []byte, error) {
+	s.handshakesMu.Lock()
+	hs, ok := s.handshakes[handshakeID]
+	if ok {
+		s.expiryOrder.Remove(hs.queued)
+		delete(s.handshakes, handshakeID)
+	}
+	s.handshakesMu.Unlock()
+
+	if !ok || time.Now().After(hs.expiresAt) {
+		return uuid.Nil, nil, ErrSRPHandshakeNotFound
+	}
+
+	serverProof, ok := hs.verifyClientProof(clientProof)
+	if !ok || hs.userID == uuid.Nil {
+		return uuid.Nil, nil, ErrSRPProofMismatch
+	}
+
+	return hs.userID, serverProof, nil
+}
+
+// ValidateEnrollment checks a salt and verifier uploaded by a client.
+func (s *SRPService) ValidateEnrollment(salt, verifier []byte) error {
+	if len(salt) != srpSaltLength {
+		return ErrSRPInvalidVerifier
+	}
+
+	v := new(big.Int).SetBytes(verifier)
+	if v.Sign() <= 0 || v.Cmp(srpN) >= 0 {
+		return ErrSRPInvalidVerifier
+	}
+
+	return nil
+}
+
+// startHandshake checks the client's public value A and computes
+// B = k*v + g^b mod N.
+func (s *SRPService) startHandshake(identity string, salt, verifier, clientPublic []byte) (*srpHandshake, error) {
+	pubA := new(big.Int).SetBytes(clientPublic)
+	if new(big.Int).Mod(pubA, srpN).Sign() == 0 {
+		return nil, ErrSRPInvalidPublic
+	}
+
+	v := new(big.Int).SetBytes(verifier)
+	if v.Sign() <= 0 || v.Cmp(srpN) >= 0 {
+		return nil, ErrSRPInvalidVerifier
+	}
+
+	b, err := srpRandomExponent()
+	if err != nil {
+		return nil, err
+	}
+
+	pubB := new(big.Int).Mul(srpK, v)
+	pubB.Add(pubB, new(big.Int).Exp(srpG, b, srpN))
+	pubB.Mod(pubB, srpN)
+
+	return &srpHandshake{
+		identity: normalizeSRPIdentity(identity),
+		salt:     salt,
+		verifier: v,
+		pubA:     pubA,
+		b:        b,
+		pubB:     pubB,
+	}, nil
+}
+
+// startFakeHandshake answers the first round for an unknown account with a
+// salt that stays the same across attempts, of the length every enrolment
+// uses, and a random verifier, so the response is indistinguishable from a
+// real one.
+func (s *SRPService) startFakeHandshake(identity string, clientPublic []byte) (*srpHandshake, error) {
+	mac := hmac.New(sha256.New, s.fakeSaltKey)
+	mac.Write([]byte("srp-salt:" + normalizeSRPIdentity(identity)))
+	salt := mac.Sum(nil)[:srpSaltLength]
+
+	x, err := srpRandomExponent()
+	if err != nil {
+		return nil, err
+	}
+	verifier := new(big.Int).Exp(srpG, x, srpN)
+
+	return s.startHandshake(identity, salt, verifier.Bytes(), clientPublic)
+}
+
+// verifyClientProof checks the client's proof M1 and, if it is valid, returns
+// the server proof M2 the client uses to authenticate the server.
+func (h *srpHandshake) verifyClientProof(clientProof []byte) ([]byte, bool) {
+	padA := srpPad(h.pubA)
+	padB := srpPad(h.pubB)
+
+	u := new(big.Int).SetBytes(srpHash(padA, padB))
+	if u.Sign() == 0 {
+		return nil, false
+	}
+
+	// S = (A * v^u) ^ b mod N
+	base := new(big.Int).Exp(h.verifier, u, srpN)
+	base.Mul(base, h.pubA)
+	base.Mod(base, srpN)
+	premaster := new(big.Int).Exp(base, h.b, srpN)
+	sessionKey := srpHash(srpPad(premaster))
+
+	hashN := srpHash(srpN.Bytes())
+	hashG := srpHash(srpPad(srpG))
+	for i := range hashN {
+		hashN[i] ^= hashG[i]
+	}
+
+	expected := srpHash(
+		hashN,
+		srpHash([]byte(h.identity)),
+		h.salt,
+		padA,
+		padB,
+		sessionKey,
+	)
+	if subtle.ConstantTimeCompare(expected, clientProof) != 1 {
+		return nil, false
+	}
+
+	return srpHash(padA, expected, sessionKey), true
+}
+
+func normalizeSRPIdentity(identity string) string {
+	return strings.ToLower(strings.TrimSpace(identity))
+}
+
+func srpRandomExponent() (*big.Int, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(buf), nil
+}
+
+func srpHash(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+// srpPad left-pads n with zeros to the byte length of N.
+func srpPad(n *big.Int) []byte {
+	size := (srpN.BitLen() + 7) / 8
+	b := n.Bytes()
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
+
+func mustParseHex(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("srp: invalid group prime")
+	}
+	return n
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+)
+
+// srpTestClient is the client side of the protocol, following RFC 5054 with
+// SHA-256 and the 2048-bit group.
+type srpTestClient struct {
+	identity string
+	salt     []byte
+	x        *big.Int
+	a        *big.Int
+	pubA     *big.Int
+}
+
+func newSRPTestClient(t *testing.T, identity, password string) *srpTestClient {
+	t.Helper()
+	salt := bytes.Repeat([]byte{0xA5}, srpSaltLength)
+	a, err := srpRandomExponent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &srpTestClient{
+		identity: normalizeSRPIdentity(identity),
+		salt:     salt,
+		x:        srpTestX(salt, identity, password),
+		a:        a,
+		pubA:     new(big.Int).Exp(srpG, a, srpN),
+	}
+}
+
+// srpTestX is x = H(s | H(I ":" P)).
+func srpTestX(salt []byte, identity, password string) *big.Int {
+	inner := srpHash([]byte(normalizeSRPIdentity(identity) + ":" + password))
+	return new(big.Int).SetBytes(srpHash(salt, inner))
+}
+
+func (c *srpTestClient) verifier() []byte {
+	return new(big.Int).Exp(srpG, c.x, srpN).Bytes()
+}
+
+func (c *srpTestClient) user() *models.User {
+	salt := base64.StdEncoding.EncodeToString(c.salt)
+	verifier := base64.StdEncoding.EncodeToString(c.verifier())
+	return &models.User{
+		ID:          uuid.New(),
+		Email:       c.identity,
+		SRPSalt:     &salt,
+		SRPVerifier: &verifier,
+	}
+}
+
+// proofs computes M1 and the M2 expected from the server for the challenge
+// public value B.
+func (c *srpTestClient) proofs(serverPublic []byte) (m1, m2 []byte) {
+	pubB := new(big.Int).SetBytes(serverPublic)
+	padA, padB := srpPad(c.pubA), srpPad(pubB)
+	u := new(big.Int).SetBytes(srpHash(padA, padB))
+
+	// S = (B - k*g^x) ^ (a + u*x) mod N
+	base := new(big.Int).Exp(srpG, c.x, srpN)
+	base.Mul(base, srpK)
+	base.Sub(pubB, base)
+	base.Mod(base, srpN)
+	exp := new(big.Int).Mul(u, c.x)
+	exp.Add(exp, c.a)
+	sessionKey := srpHash(srpPad(new(big.Int).Exp(base, exp, srpN)))
+
+	hashN := srpHash(srpN.Bytes())
+	hashG := srpHash(srpPad(srpG))
+	for i := range hashN {
+		hashN[i] ^= hashG[i]
+	}
+	m1 = srpHash(hashN, srpHash([]byte(c.identity)), c.salt, padA, padB, sessionKey)
+	m2 = srpHash(padA, m1, sessionKey)
+	return m1, m2
+}
+
+func TestSRPVerifierEnrollment(t *testing.T) {
+	s := NewSRPService("secret")
+	client := newSRPTestClient(t, "Alice@Example.com", "correct horse")
+
+	if err := s.ValidateEnrollment(client.salt, client.verifier()); err != nil {
+		t.Fatalf("valid enrolment rejected: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		salt     []byte
+		verifier []byte
+	}{
+		{"short salt", client.salt[:srpSaltLength-1], client.verifier()},
+		{"long salt", append(bytes.Clone(client.salt), 0), client.verifier()},
+		{"zero verifier", client.salt, []byte{0}},
+		{"verifier equal to N", client.salt, srpN.Bytes()},
+	}
+	for _, tt := range tests {
+		if err := s.ValidateEnrollment(tt.salt, tt.verifier); !errors.Is(err, ErrSRPInvalidVerifier) {
+			t.Errorf("%s: got %v, want ErrSRPInvalidVerifier", tt.name, err)
+		}
+	}
+}
+
+func TestSRPLogin(t *testing.T) {
+	s := NewSRPService("secret")
+	client := newSRPTestClient(t, "alice@example.com", "correct horse")
+	user := client.user()
+
+	challenge, err := s.BeginLogin(user, user.Email, srpPad(client.pubA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(challenge.Salt, client.salt) {
+		t.Fatalf("challenge salt = %x, want %x", challenge.Salt, client.salt)
+	}
+
+	m1, m2 := client.proofs(challenge.ServerPublic)
+	userID, serverProof, err := s.FinishLogin(challenge.HandshakeID, m1)
+	if err != nil {
+		t.Fatalf("valid proof rejected: %v", err)
+	}
+	if userID != user.ID {
+		t.Errorf("user = %s, want %s", userID, user.ID)
+	}
+	if !bytes.Equal(serverProof, m2) {
+		t.Errorf("M2 = %x, want %x", serverProof, m2)
+	}
+
+	if _, _, err := s.FinishLogin(challenge.HandshakeID, m1); !errors.Is(err, ErrSRPHandshakeNotFound) {
+		t.Errorf("reused handshake: got %v, want ErrSRPHandshakeNotFound", err)
+	}
+}
+
+func TestSRPLoginWrongPassword(t *testing.T) {
+	s := NewSRPService("secret")
+	client := newSRPTestClient(t, "alice@example.com", "correct horse")
+	user := client.user()
+
+	challenge, err := s.BeginLogin(user, user.Email, srpPad(client.pubA))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.x = srpTestX(client.salt, client.identity, "wrong password")
+	m1, _ := client.proofs(challenge.ServerPublic)
+	if _, _, err := s.FinishLogin(challenge.HandshakeID, m1); !errors.Is(err, ErrSRPProofMismatch) {
+		t.Errorf("got %v, want ErrSRPProofMismatch", err)
+	}
+}
+
+func TestSRPRejectsZeroClientPublic(t *testing.T) {
+	s := NewSRPService("secret")
+	user := newSRPTestClient(t, "alice@example.com", "correct horse").user()
+
+	for _, pubA := range []*big.Int{
+		big.NewInt(0),
+		srpN,
+		new(big.Int).Mul(srpN, big.NewInt(2)),
+	} {
+		if _, err := s.BeginLogin(user, user.Email, pubA.Bytes()); !errors.Is(err, ErrSRPInvalidPublic) {
+			t.Errorf("A = %s mod N: got %v, want ErrSRPInvalidPublic", new(big.Int).Mod(pubA, srpN), err)
+		}
+		if _, err := s.BeginLogin(nil, user.Email, pubA.Bytes()); !errors.Is(err, ErrSRPInvalidPublic) {
+			t.Errorf("unknown account, A ≡ 0: got %v, want ErrSRPInvalidPublic", err)
+		}
+	}
+}
+
+func TestSRPFakeChallenge(t *testing.T) {
+	s := NewSRPService("secret")
+	client := newSRPTestClient(t, "nobody@example.com", "guess")
+
+	first, err := s.BeginLogin(nil, "nobody@example.com", srpPad(client.pubA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.BeginLogin(nil, "Nobody@Example.com ", srpPad(client.pubA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Salt) != srpSaltLength {
+		t.Errorf("fake salt length = %d, want %d", len(first.Salt), srpSaltLength)
+	}
+	if !bytes.Equal(first.Salt, second.Salt) {
+		t.Error("fake salt changes between attempts")
+	}
+	if len(first.ServerPublic) != len(srpPad(srpN)) {
+		t.Errorf("fake B length = %d, want %d", len(first.ServerPublic), len(srpPad(srpN)))
+	}
+
+	client.salt = first.Salt
+	m1, _ := client.proofs(first.ServerPublic)
+	if _, _, err := s.FinishLogin(first.HandshakeID, m1); !errors.Is(err, ErrSRPProofMismatch) {
+		t.Errorf("got %v, want ErrSRPProofMismatch", err)
+	}
+}
+
+func TestSRPHandshakeLimit(t *testing.T) {
+	s := NewSRPService("secret")
+	client := newSRPTestClient(t, "alice@example.com", "correct horse")
+	user := client.user()
+
+	expiresAt := time.Now().Add(SRPHandshakeTTL)
+	for range srpMaxHandshakes {
+		id := uuid.New().String()
+		s.handshakes[id] = &srpHandshake{expiresAt: expiresAt, queued: s.expiryOrder.PushBack(id)}
+	}
+	if _, err := s.BeginLogin(user, user.Email, srpPad(client.pubA)); !errors.Is(err, ErrSRPTooManyHandshakes) {
+		t.Fatalf("got %v, want ErrSRPTooManyHandshakes", err)
+	}
+
+	// Expired handshakes make room again.
+	oldest := s.expiryOrder.Front().Value.(string)
+	s.handshakes[oldest].expiresAt = time.Now().Add(-time.Second)
+	if _, err := s.BeginLogin(user, user.Email, srpPad(client.pubA)); err != nil {
+		t.Fatalf("expired handshake not pruned: %v", err)
+	}
+	if len(s.handshakes) != srpMaxHandshakes || s.expiryOrder.Len() != srpMaxHandshakes {
+		t.Errorf("%d handshakes, %d queued, want %d", len(s.handshakes), s.expiryOrder.Len(), srpMaxHandshakes)
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/config"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+)
+
+// trashPurgeInterval is how often RunPurgeWorker looks for expired entries.
+const trashPurgeInterval = time.Hour
+
+// trashPurgeBatchSize bounds the entries purged per query, so that a large
+// backlog does not hold one long transaction.
+const trashPurgeBatchSize = 100
+
+// TrashService moves personal entries to and from the trash and deletes
+// them for good, either on request or once they have been in the trash for
+// the configured retention period. Shares of an entry in the trash are
+// suspended, not revoked, so restoring the entry brings them back.
+type TrashService struct {
+	vaultRepo         *repository.VaultRepository
+	attachmentService *AttachmentService
+	retention         time.Duration
+}
+
+func NewTrashService(cfg *config.TrashConfig, vaultRepo *repository.VaultRepository, attachmentService *AttachmentService) *TrashService {
+	return &TrashService{
+		vaultRepo:         vaultRepo,
+		attachmentService: attachmentService,
+		retention:         time.Duration(cfg.RetentionDays) * 24 * time.Hour,
+	}
+}
+
+// RetentionDays is how long entries stay in the trash, or zero when they
+// are only removed by hand.
+func (s *TrashService) RetentionDays() int {
+	return int(s.retention / (24 * time.Hour))
+}
+
+// PurgeAt returns when an entry in the trash is due to be purged, or nil
+// when it is kept until deleted by hand.
+func (s *TrashService) PurgeAt(vault *models.Vault) *time.Time {
+	if vault.DeletedAt == nil || s.retention <= 0 {
+		return nil
+	}
+	purgeAt := vault.DeletedAt.Add(s.retention)
+	return &purgeAt
+}
+
+// Trash moves vault to the trash on behalf of actorID. The move is written
+// as a new revision; it reports false when the entry changed since it was
+// read.
+func (s *TrashService) Trash(ctx context.Context, vault *models.Vault, actorID uuid.UUID, now time.Time) (bool, error) {
+	return s.setDeletedAt(ctx, vault, &now, actorID, now)
+}
+
+// Restore takes vault out of the trash on behalf of actorID, like Trash.
+func (s *TrashService) Restore(ctx context.Context, vault *models.Vault, actorID uuid.UUID, now time.Time) (bool, error) {
+	return s.setDeletedAt(ctx, vault, nil, actorID, now)
+}
+
+func (s *TrashService) setDeletedAt(ctx context.Context, vault *models.Vault, deletedAt *time.Time, actorID uuid.UUID, now time.Time) (bool, error) {
+	previous, previousModifiedBy, previousUpdatedAt := vault.DeletedAt, vault.LastModifiedBy, vault.UpdatedAt
+	vault.DeletedAt = deletedAt
+	vault.LastModifiedBy = &actorID
+	vault.UpdatedAt = now
+
+	updated, err := s.vaultRepo.UpdateIfRevision(ctx, vault, vault.Revision)
+	if err != nil || !updated {
+		vault.DeletedAt, vault.LastModifiedBy, vault.UpdatedAt = previous, previousModifiedBy, previousUpdatedAt
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete removes an entry for good, with its attachments, revisions,
+// password history and shares.
+func (s *TrashService) Delete(ctx context.Context, vaultID uuid.UUID) error {
+	return s.attachmentService.DeleteWithVault(ctx, vaultID, func() error {
+		return s.vaultRepo.Delete(ctx, vaultID)
+	})
+}
+
+// Purge deletes the entries that have been in the trash longer than the
+// retention period and returns how many it deleted.
+func (s *TrashService) Purge(ctx context.Context, now time.Time) (int, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+
+	purged := 0
+	for {
+		expired, err := s.vaultRepo.GetTrashedBefore(ctx, now.Add(-s.retention), trashPurgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		for i := range expired {
+			if err := s.Delete(ctx, expired[i].ID); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+		if len(expired) < trashPurgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// RunPurgeWorker purges expired entries from every trash, until ctx is
+// cancelled. It returns at once when entries are kept until deleted by hand.
+func (s *TrashService) RunPurgeWorker(ctx context.Context) {
+	if s.retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.Purge(ctx, time.Now())
+		if err != nil {
+			log.Printf("Failed to purge the trash: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d vault entries from the trash", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tresor/password-manager/internal/models"
+	"golang.org/x/crypto/ssh"
+)
+
+// maxCardValidityYears bounds how far from now, in either direction, a card
+// expiry may be. Expired cards are accepted: they are kept for the record,
+// and a stored card must stay editable once it expires.
+const maxCardValidityYears = 20
+
+// Limits on the custom fields of one entry.
+const (
+	maxCustomFields        = 100
+	maxCustomFieldNameLen  = 100
+	maxCustomFieldValueLen = 10000
+)
+
+// Limits on the tags of one entry.
+const (
+	maxTags   = 20
+	maxTagLen = 50
+)
+
+// ItemValidationError reports a vault item field that does not fit the
+// item's type. Field uses the JSON path of the field, e.g. "card.number".
+type ItemValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ItemValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidateVaultItem checks that data holds what an item of itemType needs and
+// normalizes it: card numbers lose their separators and get a brand, SSH keys
+// get their public key and fingerprint. Fields that belong to other types
+// are dropped so that the payload only carries what the type uses. Custom
+// fields are checked for every type.
+func ValidateVaultItem(itemType string, data *models.DecryptedVaultData, now time.Time) error {
+	if !models.IsItemType(itemType) {
+		return &ItemValidationError{Field: "type", Message: "unknown item type"}
+	}
+	if err := NormalizeCustomFields(data.Fields); err != nil {
+		return err
+	}
+
+	item := data.VaultItemData
+	data.VaultItemData = models.VaultItemData{}
+	if itemType != models.ItemTypeLogin {
+		data.Password = ""
+	}
+
+	switch itemType {
+	case models.ItemTypeLogin:
+		if data.Password == "" {
+			return &ItemValidationError{Field: "password", Message: "is required"}
+		}
+	case models.ItemTypeSecureNote:
+		if data.Notes == nil || strings.TrimSpace(*data.Notes) == "" {
+			return &ItemValidationError{Field: "notes", Message: "is required"}
+		}
+	case models.ItemTypeCard:
+		if item.Card == nil {
+			return &ItemValidationError{Field: "card", Message: "is required"}
+		}
+		if err := normalizeCard(item.Card, now); err != nil {
+			return err
+		}
+		data.Card = item.Card
+	case models.ItemTypeIdentity:
+		if item.Identity == nil {
+			return &ItemValidationError{Field: "identity", Message: "is required"}
+		}
+		if err := validateIdentity(item.Identity); err != nil {
+			return err
+		}
+		data.Identity = item.Identity
+	case models.ItemTypeSSHKey:
+		if item.SSHKey == nil {
+			return &ItemValidationError{Field: "ssh_key", Message: "is required"}
+		}
+		if err := normalizeSSHKey(item.SSHKey); err != nil {
+			return err
+		}
+		data.SSHKey = item.SSHKey
+	case models.ItemTypeAPICredential:
+		if item.APICredential == nil || item.APICredential.Secret == "" {
+			return &ItemValidationError{Field: "api_credential.secret", Message: "is required"}
+		}
+		data.APICredential = item.APICredential
+	}
+
+	return nil
+}
+
+// NormalizeCustomFields checks each field's value against its type, which
+// defaults to text, and normalizes booleans and TOTP secrets.
+func NormalizeCustomFields(fields []models.CustomField) error {
+	if len(fields) > maxCustomFields {
+		return &ItemValidationError{Field: "fields", Message: fmt.Sprintf("at most %d fields are allowed", maxCustomFields)}
+	}
+
+	for i := range fields {
+		field := &fields[i]
+		path := fmt.Sprintf("fields[%d]", i)
+
+		field.Name = strings.TrimSpace(field.Name)
+		if field.Name == "" || len(field.Name) > maxCustomFieldNameLen {
+			return &ItemValidationError{Field: path + ".name", Message: fmt.Sprintf("must be 1 to %d characters", maxCustomFieldNameLen)}
+		}
+		if len(field.Value) > maxCustomFieldValueLen {
+			return &ItemValidationError{Field: path + ".value", Message: fmt.Sprintf("must be at most %d characters", maxCustomFieldValueLen)}
+		}
+		if field.Type == "" {
+			field.Type = models.CustomFieldText
+		}
+
+		switch field.Type {
+		case models.CustomFieldText, models.CustomFieldHidden:
+		case models.CustomFieldBoolean:
+			value, err := strconv.ParseBool(strings.TrimSpace(field.Value))
+			if err != nil {
+				return &ItemValidationError{Field: path + ".value", Message: "must be true or false"}
+			}
+			field.Value = strconv.FormatBool(value)
+		case models.CustomFieldURL:
+			if field.Value == "" {
+				continue
+			}
+			parsed, err := url.Parse(field.Value)
+			if err != nil || parsed.Scheme == "" || (parsed.Host == "" && parsed.Opaque == "") {
+				return &ItemValidationError{Field: path + ".value", Message: "is not a valid URL"}
+			}
+		case models.CustomFieldTOTP:
+			value, ok := normalizeTOTP(field.Value)
+			if !ok {
+				return &ItemValidationError{Field: path + ".value", Message: "must be an otpauth:// URI or a base32 secret"}
+			}
+			field.Value = value
+		default:
+			return &ItemValidationError{Field: path + ".type", Message: "unknown field type"}
+		}
+	}
+
+	return nil
+}
+
+// NormalizeTags trims and lowercases an entry's tags, dropping empty and
+// repeated ones, so that filtering on a tag does not depend on how it was
+// typed.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > maxTagLen {
+			return nil, &ItemValidationError{Field: "tags", Message: fmt.Sprintf("tags must be at most %d characters", maxTagLen)}
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	if len(normalized) > maxTags {
+		return nil, &ItemValidationError{Field: "tags", Message: fmt.Sprintf("at most %d tags are allowed", maxTags)}
+	}
+	return normalized, nil
+}
+
+// normalizeTOTP accepts an otpauth:// URI with a secret, or a bare base32
+// secret which is returned uppercased and without spaces.
+func normalizeTOTP(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(strings.ToLower(value), "otpauth://") {
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.Query().Get("secret") == "" {
+			return "", false
+		}
+		return value, true
+	}
+
+	secret := strings.ToUpper(strings.ReplaceAll(value, " ", ""))
+	if secret == "" {
+		return "", false
+	}
+	if _, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "=")); err != nil {
+		return "", false
+	}
+	return secret, true
+}
+
+func normalizeCard(card *models.CardData, now time.Time) error {
+	number := strings.NewReplacer(" ", "", "-", "").Replace(card.Number)
+	if len(number) < 12 || len(number) > 19 || strings.Trim(number, "0123456789") != "" {
+		return &ItemValidationError{Field: "card.number", Message: "must be 12 to 19 digits"}
+	}
+	if !luhnValid(number) {
+		return &ItemValidationError{Field: "card.number", Message: "fails the Luhn checksum"}
+	}
+	card.Number = number
+	if card.Brand == "" {
+		card.Brand = cardBrand(number)
+	}
+
+	if card.ExpMonth < 1 || card.ExpMonth > 12 {
+		return &ItemValidationError{Field: "card.exp_month", Message: "must be between 1 and 12"}
+	}
+	if card.ExpYear >= 0 && card.ExpYear < 100 {
+		card.ExpYear += 2000
+	}
+	if card.ExpYear < now.Year()-maxCardValidityYears || card.ExpYear > now.Year()+maxCardValidityYears {
+		return &ItemValidationError{Field: "card.exp_year", Message: fmt.Sprintf("must be within %d years of today", maxCardValidityYears)}
+	}
+
+	if card.CVV != "" {
+		if len(card.CVV) < 3 || len(card.CVV) > 4 || strings.Trim(card.CVV, "0123456789") != "" {
+			return &ItemValidationError{Field: "card.cvv", Message: "must be 3 or 4 digits"}
+		}
+	}
+
+	return nil
+}
+
+// luhnValid reports whether number, a string of digits, passes the Luhn
+// checksum used by every card network.
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// cardBrand guesses the card network from the number's prefix, or returns
+// an empty string.
+func cardBrand(number string) string {
+	prefix := func(n int) int {
+		value := 0
+		for i := 0; i < n && i < len(number); i++ {
+			value = value*10 + int(number[i]-'0')
+		}
+		return value
+	}
+
+	switch {
+	case number[0] == '4':
+		return "visa"
+	case prefix(2) >= 51 && prefix(2) <= 55, prefix(4) >= 2221 && prefix(4) <= 2720:
+		return "mastercard"
+	case prefix(2) == 34, prefix(2) == 37:
+		return "amex"
+	case prefix(4) == 6011, prefix(2) == 65, prefix(3) >= 644 && prefix(3) <= 649:
+		return "discover"
+	case prefix(4) >= 3528 && prefix(4) <= 3589:
+		return "jcb"
+	case prefix(2) == 36, prefix(2) == 38, prefix(3) >= 300 && prefix(3) <= 305:
+		return "diners"
+	case prefix(2) == 62:
+		return "unionpay"
+	}
+	return ""
+}
+
+func validateIdentity(identity *models.IdentityData) error {
+	// Every identity field is omitted when empty.
+	encoded, _ := json.Marshal(identity)
+	if string(encoded) == "{}" {
+		return &ItemValidationError{Field: "identity", Message: "needs at least one field"}
+	}
+
+	if identity.Email != "" {
+		if _, err := mail.ParseAddress(identity.Email); err != nil {
+			return &ItemValidationError{Field: "identity.email", Message: "is not a valid email address"}
+		}
+	}
+
+	return nil
+}
+
+// normalizeSSHKey checks the private key and derives its public key and
+// SHA-256 fingerprint. OpenSSH keys expose their public key even when
+// encrypted; encrypted PEM keys need the passphrase, or a PublicKey from the
+// client.
+func normalizeSSHKey(key *models.SSHKeyData) error {
+	if strings.TrimSpace(key.PrivateKey) == "" {
+		return &ItemValidationError{Field: "ssh_key.private_key", Message: "is required"}
+	}
+
+	var publicKey ssh.PublicKey
+	var signer ssh.Signer
+	var err error
+	if key.Passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(key.PrivateKey), []byte(key.Passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey([]byte(key.PrivateKey))
+	}
+
+	var missing *ssh.PassphraseMissingError
+	switch {
+	case err == nil:
+		publicKey = signer.PublicKey()
+	case errors.As(err, &missing) && missing.PublicKey != nil:
+		publicKey = missing.PublicKey
+	case errors.As(err, &missing):
+		if key.PublicKey == "" {
+			return &ItemValidationError{Field: "ssh_key.public_key", Message: "is required when the key is encrypted and no passphrase is given"}
+		}
+		publicKey, _, _, _, err = ssh.ParseAuthorizedKey([]byte(key.PublicKey))
+		if err != nil {
+			return &ItemValidationError{Field: "ssh_key.public_key", Message: "is not a valid SSH public key"}
+		}
+	default:
+		return &ItemValidationError{Field: "ssh_key.private_key", Message: "cannot be parsed, or the passphrase is wrong"}
+	}
+
+	if key.PublicKey != "" {
+		given, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key.PublicKey))
+		if err != nil || ssh.FingerprintSHA256(given) != ssh.FingerprintSHA256(publicKey) {
+			return &ItemValidationError{Field: "ssh_key.public_key", Message: "does not match the private key"}
+		}
+	}
+
+	key.PublicKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey)))
+	key.Fingerprint = ssh.FingerprintSHA256(publicKey)
+	return nil
+}
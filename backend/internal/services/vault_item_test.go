@@ -0,0 +1,217 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tresor/password-manager/internal/models"
+	"golang.org/x/crypto/ssh"
+)
+
+// vaultItemTestNow is the date the card expiries below are checked against.
+var vaultItemTestNow = time.Date(2026, time.March, 15, 12, 0, 0, 0, time.UTC)
+
+func TestLuhnValid(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{"4111111111111111", true},
+		{"5555555555554444", true},
+		{"378282246310005", true},
+		{"79927398713", true},
+		{"4111111111111112", false},
+		{"79927398710", false},
+		{"1234567812345678", false},
+	}
+	for _, tt := range tests {
+		if got := luhnValid(tt.number); got != tt.want {
+			t.Errorf("luhnValid(%q) = %v, want %v", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestCardBrand(t *testing.T) {
+	tests := []struct {
+		number string
+		want   string
+	}{
+		{"4111111111111111", "visa"},
+		{"5555555555554444", "mastercard"},
+		{"2223003122003222", "mastercard"},
+		{"378282246310005", "amex"},
+		{"6011111111111117", "discover"},
+		{"3530111333300000", "jcb"},
+		{"30569309025904", "diners"},
+		{"6200000000000005", "unionpay"},
+		{"9999999999999995", ""},
+	}
+	for _, tt := range tests {
+		if got := cardBrand(tt.number); got != tt.want {
+			t.Errorf("cardBrand(%q) = %q, want %q", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeCard(t *testing.T) {
+	tests := []struct {
+		name      string
+		card      models.CardData
+		wantYear  int
+		wantField string
+	}{
+		{"valid", models.CardData{Number: "4111111111111111", ExpMonth: 12, ExpYear: 2030}, 2030, ""},
+		{"spaces in number", models.CardData{Number: "4111 1111 1111 1111", ExpMonth: 1, ExpYear: 2030}, 2030, ""},
+		{"dashes in number", models.CardData{Number: "4111-1111-1111-1111", ExpMonth: 1, ExpYear: 2030}, 2030, ""},
+		{"two-digit year", models.CardData{Number: "4111111111111111", ExpMonth: 6, ExpYear: 29}, 2029, ""},
+		{"expires this month", models.CardData{Number: "4111111111111111", ExpMonth: 3, ExpYear: 2026}, 2026, ""},
+		{"already expired", models.CardData{Number: "4111111111111111", ExpMonth: 2, ExpYear: 26}, 2026, ""},
+		{"fails Luhn", models.CardData{Number: "4111111111111112", ExpMonth: 1, ExpYear: 2030}, 0, "card.number"},
+		{"letters in number", models.CardData{Number: "4111a11111111111", ExpMonth: 1, ExpYear: 2030}, 0, "card.number"},
+		{"too short", models.CardData{Number: "41111111111", ExpMonth: 1, ExpYear: 2030}, 0, "card.number"},
+		{"month 0", models.CardData{Number: "4111111111111111", ExpMonth: 0, ExpYear: 2030}, 0, "card.exp_month"},
+		{"month 13", models.CardData{Number: "4111111111111111", ExpMonth: 13, ExpYear: 2030}, 0, "card.exp_month"},
+		{"year too far ahead", models.CardData{Number: "4111111111111111", ExpMonth: 1, ExpYear: 2100}, 0, "card.exp_year"},
+		{"year too far back", models.CardData{Number: "4111111111111111", ExpMonth: 1, ExpYear: 1990}, 0, "card.exp_year"},
+		{"short CVV", models.CardData{Number: "4111111111111111", ExpMonth: 1, ExpYear: 2030, CVV: "12"}, 0, "card.cvv"},
+	}
+	for _, tt := range tests {
+		card := tt.card
+		err := normalizeCard(&card, vaultItemTestNow)
+		if tt.wantField != "" {
+			var invalid *ItemValidationError
+			if !errors.As(err, &invalid) || invalid.Field != tt.wantField {
+				t.Errorf("%s: got %v, want an error on %s", tt.name, err, tt.wantField)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if card.Number != "4111111111111111" {
+			t.Errorf("%s: number = %q, want the digits only", tt.name, card.Number)
+		}
+		if card.Brand != "visa" {
+			t.Errorf("%s: brand = %q, want visa", tt.name, card.Brand)
+		}
+		if card.ExpYear != tt.wantYear {
+			t.Errorf("%s: year = %d, want %d", tt.name, card.ExpYear, tt.wantYear)
+		}
+	}
+}
+
+func TestNormalizeTOTP(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{"JBSWY3DPEHPK3PXP", "JBSWY3DPEHPK3PXP", true},
+		{"jbsw y3dp ehpk 3pxp", "JBSWY3DPEHPK3PXP", true},
+		{"otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP", "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP", true},
+		{"otpauth://totp/Example:alice?issuer=Example", "", false},
+		{"not base32!", "", false},
+		{"   ", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeTOTP(tt.value)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("normalizeTOTP(%q) = %q, %v, want %q, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got, err := NormalizeTags([]string{" Work ", "work", "", "Perso", "  "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "work,perso" {
+		t.Errorf("tags = %q, want [work perso]", got)
+	}
+
+	if _, err := NormalizeTags([]string{strings.Repeat("a", maxTagLen+1)}); err == nil {
+		t.Error("overlong tag accepted")
+	}
+
+	tooMany := make([]string, maxTags+1)
+	for i := range tooMany {
+		tooMany[i] = strings.Repeat("t", i+1)
+	}
+	if _, err := NormalizeTags(tooMany); err == nil {
+		t.Errorf("%d tags accepted", len(tooMany))
+	}
+}
+
+// newTestSSHKey returns an Ed25519 key in OpenSSH format, encrypted when
+// passphrase is not empty, with its public key in authorized_keys format.
+func newTestSSHKey(t *testing.T, passphrase string) (privateKey, publicKey string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, "test")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "test", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(block)), strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub)))
+}
+
+func TestNormalizeSSHKey(t *testing.T) {
+	plain, plainPublic := newTestSSHKey(t, "")
+	encrypted, encryptedPublic := newTestSSHKey(t, "secret")
+
+	tests := []struct {
+		name       string
+		key        models.SSHKeyData
+		wantPublic string
+		wantField  string
+	}{
+		{"unencrypted", models.SSHKeyData{PrivateKey: plain}, plainPublic, ""},
+		{"encrypted with passphrase", models.SSHKeyData{PrivateKey: encrypted, Passphrase: "secret"}, encryptedPublic, ""},
+		{"encrypted without passphrase", models.SSHKeyData{PrivateKey: encrypted}, encryptedPublic, ""},
+		{"wrong passphrase", models.SSHKeyData{PrivateKey: encrypted, Passphrase: "wrong"}, "", "ssh_key.private_key"},
+		{"mismatched public key", models.SSHKeyData{PrivateKey: plain, PublicKey: encryptedPublic}, "", "ssh_key.public_key"},
+		{"not a key", models.SSHKeyData{PrivateKey: "not a key"}, "", "ssh_key.private_key"},
+		{"missing", models.SSHKeyData{}, "", "ssh_key.private_key"},
+	}
+	for _, tt := range tests {
+		key := tt.key
+		err := normalizeSSHKey(&key)
+		if tt.wantField != "" {
+			var invalid *ItemValidationError
+			if !errors.As(err, &invalid) || invalid.Field != tt.wantField {
+				t.Errorf("%s: got %v, want an error on %s", tt.name, err, tt.wantField)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if key.PublicKey != tt.wantPublic {
+			t.Errorf("%s: public key = %q, want %q", tt.name, key.PublicKey, tt.wantPublic)
+		}
+		if !strings.HasPrefix(key.Fingerprint, "SHA256:") {
+			t.Errorf("%s: fingerprint = %q, want a SHA256 fingerprint", tt.name, key.Fingerprint)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tresor/password-manager/internal/models"
+	"github.com/tresor/password-manager/internal/repository"
+)
+
+var (
+	ErrInvalidMasterPassword = errors.New("invalid master password")
+	ErrUserNotFound          = errors.New("user not found")
+	// ErrClientSideEncryption is returned when a server-side unlock is
+	// attempted on an account in zero-knowledge mode.
+	ErrClientSideEncryption = errors.New("account uses client-side encryption")
+)
+
+// upgradeJob carries the key material needed to re-encrypt a user's outdated
+// entries. The master password is only needed for legacy entries.
+type upgradeJob struct {
+	userID         uuid.UUID
+	vaultKey       []byte
+	masterPassword string
+}
+
+// VaultKeyService manages the per-user key hierarchy: a random vault key
+// encrypts every entry and is itself wrapped by a key encryption key derived
+// from the master password. Unlocking costs a single Argon2 derivation no
+// matter how many entries are decrypted afterwards.
+type VaultKeyService struct {
+	userRepo       *repository.UserRepository
+	vaultRepo      *repository.VaultRepository
+	sharedEditRepo *repository.SharedEditRepository
+	cryptoService  *CryptoService
+	historyService *PasswordHistoryService
+
+	upgrades  chan upgradeJob
+	pending   map[uuid.UUID]bool
+	pendingMu sync.Mutex
+}
+
+func NewVaultKeyService(
+	userRepo *repository.UserRepository,
+	vaultRepo *repository.VaultRepository,
+	sharedEditRepo *repository.SharedEditRepository,
+	cryptoService *CryptoService,
+	historyService *PasswordHistoryService,
+) *VaultKeyService {
+	return &VaultKeyService{
+		userRepo:       userRepo,
+		vaultRepo:      vaultRepo,
+		sharedEditRepo: sharedEditRepo,
+		cryptoService:  cryptoService,
+		historyService: historyService,
+		upgrades:       make(chan upgradeJob, 100),
+		pending:        make(map[uuid.UUID]bool),
+	}
+}
+
+// InitializeVaultKey generates a vault key and sharing keypair for a new
+// account and stores them on user, the vault key wrapped under masterPassword.
+// The user still has to be persisted.
+func (s *VaultKeyService) InitializeVaultKey(user *models.User, masterPassword string) ([]byte, error) {
+	vaultKey, err := s.cryptoService.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.WrapVaultKey(user, vaultKey, masterPassword); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.ensureKeyPair(user, vaultKey); err != nil {
+		return nil, err
+	}
+
+	return vaultKey, nil
+}
+
+// WrapVaultKey (re)wraps vaultKey under masterPassword on user with the
+// current KDF parameters. The user still has to be persisted.
+func (s *VaultKeyService) WrapVaultKey(user *models.User, vaultKey []byte, masterPassword string) error {
+	wrapped, err := s.cryptoService.SealWithPassword(vaultKey, masterPassword)
+	if err != nil {
+		return err
+	}
+
+	user.EncryptedVaultKey = &wrapped
+	user.VaultKeySalt = nil
+	user.VaultKeyNonce = nil
+	return nil
+}
+
+// Unlock returns the vault key of userID. Accounts created before the key
+// hierarchy get a vault key on their first unlock, accounts without a sharing
+// keypair get one, and outdated entries are queued for re-encryption.
+func (s *VaultKeyService) Unlock(ctx context.Context, userID uuid.UUID, masterPassword string) ([]byte, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return s.UnlockUser(ctx, user, masterPassword)
+}
+
+// UnlockUser is Unlock for an already loaded user.
+func (s *VaultKeyService) UnlockUser(ctx context.Context, user *models.User, masterPassword string) ([]byte, error) {
+	if user.ClientSideEncryption {
+		return nil, ErrClientSideEncryption
+	}
+
+	var vaultKey []byte
+	rewrap := false
+
+	if user.HasVaultKey() {
+		key, current, err := s.unwrapVaultKey(user, masterPassword)
+		if err != nil {
+			return nil, ErrInvalidMasterPassword
+		}
+		vaultKey = key
+		rewrap = !current
+	} else {
+		if !s.cryptoService.VerifyPassword(masterPassword, user.MasterPasswordHash, user.Salt) {
+			return nil, ErrInvalidMasterPassword
+		}
+
+		key, err := s.cryptoService.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		vaultKey = key
+		rewrap = true
+	}
+
+	if rewrap {
+		if err := s.WrapVaultKey(user, vaultKey, masterPassword); err != nil {
+			return nil, err
+		}
+	}
+
+	generated, err := s.ensureKeyPair(user, vaultKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if rewrap || generated {
+		user.UpdatedAt = time.Now()
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	// Apply recipient edits before the caller reads any entry, so the owner
+	// sees them straight away.
+	if applied, err := s.ApplySharedEdits(ctx, user, vaultKey, masterPassword); err != nil {
+		log.Printf("Failed to apply shared edits for user %s: %v", user.ID, err)
+	} else if applied > 0 {
+		log.Printf("Applied %d shared edits for user %s", applied, user.ID)
+	}
+
+	s.scheduleUpgrade(ctx, user.ID, vaultKey, masterPassword)
+
+	return vaultKey, nil
+}
+
+// OpenPrivateKey returns the private key of user's sharing keypair, which is
+// wrapped under the vault key.
+func (s *VaultKeyService) OpenPrivateKey(user *models.User, vaultKey []byte) ([]byte, error) {
+	if user.PrivateKey == "" {
+		return nil, errors.New("user has no keypair")
+	}
+	return s.cryptoService.OpenWithKey(user.PrivateKey, vaultKey)
+}
+
+// ensureKeyPair gives user a sharing keypair if it has none yet, the private
+// key wrapped under vaultKey. It reports whether one was generated.
+func (s *VaultKeyService) ensureKeyPair(user *models.User, vaultKey []byte) (bool, error) {
+	if user.PublicKey != "" {
+		return false, nil
+	}
+
+	publicKey, privateKey, err := s.cryptoService.GenerateKeyPair()
+	if err != nil {
+		return false, err
+	}
+
+	wrapped, err := s.cryptoService.SealWithKey(privateKey, vaultKey)
+	if err != nil {
+		return false, err
+	}
+
+	user.PublicKey = base64.StdEncoding.EncodeToString(publicKey)
+	user.PrivateKey = wrapped
+	return true, nil
+}
+
+// unwrapVaultKey opens the wrapped vault key and reports whether it is
+// already wrapped with the current envelope format and KDF parameters.
+func (s *VaultKeyService) unwrapVaultKey(user *models.User, masterPassword string) ([]byte, bool, error) {
+	if !IsEnvelope(*user.EncryptedVaultKey) {
+		if user.VaultKeySalt == nil || user.VaultKeyNonce == nil {
+			return nil, false, errors.New("incomplete legacy vault key")
+		}
+		key, err := s.cryptoService.DecryptData(*user.EncryptedVaultKey, masterPassword, *user.VaultKeySalt, *user.VaultKeyNonce)
+		if err != nil {
+			return nil, false, err
+		}
+		return []byte(key), false, nil
+	}
+
+	envelope, err := ParseEnvelope(*user.EncryptedVaultKey)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key, err := s.cryptoService.OpenWithPassword(*user.EncryptedVaultKey, masterPassword)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return key, envelope.IsCurrent(s.cryptoService.KDFParams()), nil
+}
+
+// SealEntry encrypts plaintext into vault under the vault key using the
+// current envelope format.
+func (s *VaultKeyService) SealEntry(vault *models.Vault, vaultKey []byte, plaintext string) error {
+	envelope, err := s.cryptoService.SealWithKey([]byte(plaintext), vaultKey)
+	if err != nil {
+		return err
+	}
+
+	vault.EncryptedData = envelope
+	vault.EncryptionSalt = ""
+	vault.Nonce = ""
+	vault.EncryptionVersion = models.EncryptionVersionCurrent
+	return nil
+}
+
+// OpenEntry decrypts vault whatever encryption version it was written with.
+// The master password is only used for legacy entries.
+func (s *VaultKeyService) OpenEntry(vault *models.Vault, vaultKey []byte, masterPassword string) (string, error) {
+	switch vault.EncryptionVersion {
+	case models.EncryptionVersionLegacy:
+		return s.cryptoService.DecryptData(vault.EncryptedData, masterPassword, vault.EncryptionSalt, vault.Nonce)
+	case models.EncryptionVersionVaultKey:
+		plaintext, err := s.cryptoService.DecryptWithKey(vault.EncryptedData, vault.Nonce, vaultKey)
+		if err != nil {
+			return "", err
+		}
+		return string(plaintext), nil
+	default:
+		plaintext, err := s.cryptoService.OpenWithKey(vault.EncryptedData, vaultKey)
+		if err != nil {
+			return "", err
+		}
+		return string(plaintext), nil
+	}
+}
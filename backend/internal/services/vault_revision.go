@@ -0,0 +1,150 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/tresor/password-manager/internal/models"
+)
+
+// revisionMetadataFields are the fields stored in clear, compared without
+// unlocking, in the order they are reported.
+var revisionMetadataFields = []string{"title", "website", "username", "folder", "tags", "favorite"}
+
+// DiffRevisions lists the fields that differ from one revision to another.
+// The encrypted payloads are compared when both oldData and newData are
+// given; otherwise only the fields stored in clear are. Either revision may
+// be nil, as for the first revision of an entry.
+func DiffRevisions(oldRevision, newRevision *models.VaultRevision, oldData, newData *models.DecryptedVaultData) []models.FieldChange {
+	oldFields := revisionMetadata(oldRevision)
+	newFields := revisionMetadata(newRevision)
+	changes := diffFields(revisionMetadataFields, oldFields, newFields)
+
+	if oldData == nil && newData == nil {
+		return changes
+	}
+	oldSecrets := flattenVaultData(oldData)
+	newSecrets := flattenVaultData(newData)
+
+	keys := make([]string, 0, len(oldSecrets)+len(newSecrets))
+	for key := range oldSecrets {
+		keys = append(keys, key)
+	}
+	for key := range newSecrets {
+		if _, ok := oldSecrets[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		// The password and notes come first, like in the entry itself.
+		ri, rj := secretFieldRank(keys[i]), secretFieldRank(keys[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return keys[i] < keys[j]
+	})
+
+	return append(changes, diffFields(keys, oldSecrets, newSecrets)...)
+}
+
+func secretFieldRank(key string) int {
+	switch key {
+	case "password":
+		return 0
+	case "notes":
+		return 1
+	default:
+		return 2
+	}
+}
+
+func diffFields(keys []string, oldFields, newFields map[string]any) []models.FieldChange {
+	var changes []models.FieldChange
+	for _, key := range keys {
+		oldValue, newValue := oldFields[key], newFields[key]
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		changes = append(changes, models.FieldChange{Field: key, Old: oldValue, New: newValue})
+	}
+	return changes
+}
+
+func revisionMetadata(revision *models.VaultRevision) map[string]any {
+	fields := make(map[string]any)
+	if revision == nil {
+		return fields
+	}
+
+	fields["title"] = revision.Title
+	if revision.Website != nil {
+		fields["website"] = *revision.Website
+	}
+	if revision.Username != nil {
+		fields["username"] = *revision.Username
+	}
+	if revision.Folder != nil {
+		fields["folder"] = *revision.Folder
+	}
+	if len(revision.Tags) > 0 {
+		fields["tags"] = []string(revision.Tags)
+	}
+	fields["favorite"] = revision.Favorite
+	return fields
+}
+
+// flattenVaultData maps every leaf of an entry's payload to its JSON path.
+// Custom fields are keyed by name, with a suffix for repeated names, and
+// keep their type next to their value.
+func flattenVaultData(data *models.DecryptedVaultData) map[string]any {
+	fields := make(map[string]any)
+	if data == nil {
+		return fields
+	}
+
+	if data.Password != "" {
+		fields["password"] = data.Password
+	}
+	if data.Notes != nil && *data.Notes != "" {
+		fields["notes"] = *data.Notes
+	}
+
+	seen := make(map[string]int)
+	for _, field := range data.Fields {
+		seen[field.Name]++
+		key := "fields." + field.Name
+		if n := seen[field.Name]; n > 1 {
+			key = fmt.Sprintf("%s#%d", key, n)
+		}
+		fields[key] = map[string]any{"type": field.Type, "value": field.Value}
+	}
+
+	// The typed members only hold JSON-friendly values, so a round trip
+	// through JSON gives a generic tree to walk.
+	encoded, err := json.Marshal(data.VaultItemData)
+	if err != nil {
+		return fields
+	}
+	var tree map[string]any
+	if err := json.Unmarshal(encoded, &tree); err != nil {
+		return fields
+	}
+	flattenTree("", tree, fields)
+	return fields
+}
+
+func flattenTree(prefix string, tree map[string]any, out map[string]any) {
+	for key, value := range tree {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if subtree, ok := value.(map[string]any); ok {
+			flattenTree(path, subtree, out)
+			continue
+		}
+		out[path] = value
+	}
+}
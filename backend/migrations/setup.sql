@@ -7,34 +7,135 @@
 -- Enable UUID extension
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 
+-- Trigram indexes for the vault search
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 -- Users table
 CREATE TABLE IF NOT EXISTS users (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     email VARCHAR(255) UNIQUE NOT NULL,
-    master_password_hash VARCHAR(255) NOT NULL,
-    salt VARCHAR(255) NOT NULL,
+    master_password_hash VARCHAR(255) NOT NULL DEFAULT '',
+    salt VARCHAR(255) NOT NULL DEFAULT '',
+    encrypted_vault_key TEXT,
+    vault_key_salt VARCHAR(255),
+    vault_key_nonce VARCHAR(255),
+    client_side_encryption BOOLEAN DEFAULT FALSE,
+    protected_vault_key TEXT,
+    srp_salt TEXT,
+    srp_verifier TEXT,
     public_key TEXT,
     private_key TEXT,
     two_factor_enabled BOOLEAN DEFAULT FALSE,
     two_factor_secret VARCHAR(255),
     backup_codes TEXT[],
+    two_factor_last_step BIGINT,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Organizations table (tenants whose items are encrypted with an org key)
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    key_version INTEGER NOT NULL DEFAULT 1,
+    policy_min_length INTEGER NOT NULL DEFAULT 0,
+    policy_require_lower BOOLEAN NOT NULL DEFAULT false,
+    policy_require_upper BOOLEAN NOT NULL DEFAULT false,
+    policy_require_digit BOOLEAN NOT NULL DEFAULT false,
+    policy_require_special BOOLEAN NOT NULL DEFAULT false,
+    policy_max_age_days INTEGER NOT NULL DEFAULT 0,
+    policy_forbid_breached BOOLEAN NOT NULL DEFAULT false,
+    policy_require_2fa BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+-- Organization members (org key sealed to each confirmed member's public key)
+CREATE TABLE IF NOT EXISTS organization_members (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL DEFAULT 'member',
+    status VARCHAR(20) NOT NULL DEFAULT 'accepted',
+    encrypted_key TEXT,
+    key_version INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    UNIQUE (organization_id, user_id)
+);
+
+-- Organization invitations (only a hash of the emailed token is stored)
+CREATE TABLE IF NOT EXISTS organization_invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    invited_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+-- Collections table (shared folders; entries are encrypted with a per-collection key)
+CREATE TABLE IF NOT EXISTS collections (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    key_version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+-- Collection members (collection key sealed to each member's public key)
+CREATE TABLE IF NOT EXISTS collection_members (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL DEFAULT 'viewer',
+    can_view BOOLEAN DEFAULT TRUE,
+    can_copy BOOLEAN DEFAULT TRUE,
+    encrypted_key TEXT NOT NULL,
+    key_version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    UNIQUE (collection_id, user_id)
+);
+
+-- Folders table (per-user folder tree; entries carry the path of theirs)
+CREATE TABLE IF NOT EXISTS folders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parent_id UUID REFERENCES folders(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
 -- Vaults table
 CREATE TABLE IF NOT EXISTS vaults (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL DEFAULT 'login',
     title VARCHAR(255) NOT NULL,
     website VARCHAR(500),
     username VARCHAR(255),
     encrypted_data TEXT NOT NULL,
     encryption_salt VARCHAR(255) NOT NULL,
     nonce VARCHAR(255) NOT NULL,
-    folder VARCHAR(100),
+    encryption_version INTEGER NOT NULL DEFAULT 0,
+    revision INTEGER NOT NULL DEFAULT 1,
+    last_modified_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    collection_id UUID REFERENCES collections(id) ON DELETE CASCADE,
+    organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    folder_id UUID REFERENCES folders(id) ON DELETE SET NULL,
+    folder TEXT,
+    tags TEXT[],
     favorite BOOLEAN DEFAULT FALSE,
     last_used TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
     created_at TIMESTAMPTZ DEFAULT now(),
     updated_at TIMESTAMPTZ DEFAULT now()
 );
@@ -45,15 +146,19 @@ CREATE TABLE IF NOT EXISTS shared_passwords (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     vault_id UUID REFERENCES vaults(id) ON DELETE CASCADE,
     owner_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    share_type VARCHAR(20) NOT NULL DEFAULT 'user',
     recipient_id UUID REFERENCES users(id) ON DELETE SET NULL,
     recipient_email VARCHAR(255) NOT NULL,
     encrypted_data TEXT NOT NULL,
+    encryption_version INTEGER NOT NULL DEFAULT 0,
     share_token VARCHAR(100) UNIQUE NOT NULL,
+    invitation_token_hash VARCHAR(64) UNIQUE,
     expires_at TIMESTAMP,
     max_views INTEGER,
     view_count INTEGER DEFAULT 0,
     require_password BOOLEAN DEFAULT FALSE,
     share_password_hash VARCHAR(255),
+    share_password_salt VARCHAR(255),
     can_view BOOLEAN DEFAULT TRUE,
     can_copy BOOLEAN DEFAULT TRUE,
     can_edit BOOLEAN DEFAULT FALSE,
@@ -62,6 +167,119 @@ CREATE TABLE IF NOT EXISTS shared_passwords (
     last_accessed TIMESTAMP
 );
 
+-- Shared edits table (recipient changes sealed to the owner, waiting to be applied)
+CREATE TABLE IF NOT EXISTS shared_edits (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    share_id UUID NOT NULL REFERENCES shared_passwords(id) ON DELETE CASCADE,
+    vault_id UUID NOT NULL REFERENCES vaults(id) ON DELETE CASCADE,
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    editor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    encrypted_data TEXT NOT NULL,
+    base_revision INTEGER NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    applied_at TIMESTAMPTZ
+);
+
+-- Share access log (one row per attempt to open a share)
+CREATE TABLE IF NOT EXISTS share_access_logs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    share_id UUID NOT NULL REFERENCES shared_passwords(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    ip_address VARCHAR(64),
+    user_agent TEXT,
+    outcome VARCHAR(50) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+-- Sessions table (one row per signed-in device, refresh token stored hashed)
+CREATE TABLE IF NOT EXISTS sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    refresh_token_hash VARCHAR(64) NOT NULL,
+    device_name VARCHAR(255),
+    ip_address VARCHAR(64),
+    user_agent TEXT,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    revoked_reason VARCHAR(50)
+);
+
+-- MFA challenges (logins waiting for their second factor, one per mfa_pending token)
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+-- Emergency access (grantor's vault key sealed to a trusted contact, released after a waiting period)
+CREATE TABLE IF NOT EXISTS emergency_access (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    grantor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    grantee_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    status VARCHAR(30) NOT NULL DEFAULT 'invited',
+    wait_days INTEGER NOT NULL,
+    token_hash VARCHAR(64) UNIQUE,
+    encrypted_key TEXT,
+    recovery_initiated_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+-- Attachments (file metadata; the encrypted chunks live in the blob store)
+CREATE TABLE IF NOT EXISTS attachments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    vault_id UUID NOT NULL REFERENCES vaults(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    chunk_size INTEGER NOT NULL,
+    chunk_count INTEGER NOT NULL,
+    encrypted_key TEXT NOT NULL,
+    encryption_version INTEGER NOT NULL,
+    pending BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+-- Password history (previous passwords of an entry, sealed with the owner's vault key)
+CREATE TABLE IF NOT EXISTS password_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    vault_id UUID NOT NULL REFERENCES vaults(id) ON DELETE CASCADE,
+    encrypted_password TEXT NOT NULL,
+    encryption_version INTEGER NOT NULL,
+    changed_by UUID NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL
+);
+
+-- Vault revisions (append-only snapshot of an entry at each revision)
+CREATE TABLE IF NOT EXISTS vault_revisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    vault_id UUID NOT NULL REFERENCES vaults(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    collection_id UUID,
+    organization_id UUID,
+    type VARCHAR(20) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    website VARCHAR(500),
+    username VARCHAR(255),
+    folder_id UUID,
+    folder TEXT,
+    tags TEXT[],
+    favorite BOOLEAN NOT NULL DEFAULT false,
+    encrypted_data TEXT NOT NULL,
+    encryption_salt VARCHAR(255) NOT NULL,
+    nonce VARCHAR(255) NOT NULL,
+    encryption_version INTEGER NOT NULL,
+    changed_by UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
 -- Audit logs table
 CREATE TABLE IF NOT EXISTS audit_logs (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -76,10 +294,42 @@ CREATE TABLE IF NOT EXISTS audit_logs (
 -- Create indexes
 CREATE INDEX IF NOT EXISTS idx_vaults_user_id ON vaults(user_id);
 CREATE INDEX IF NOT EXISTS idx_vaults_folder ON vaults(folder);
+CREATE INDEX IF NOT EXISTS idx_vaults_folder_id ON vaults(folder_id);
+CREATE INDEX IF NOT EXISTS idx_folders_user_id ON folders(user_id);
+CREATE INDEX IF NOT EXISTS idx_folders_parent_id ON folders(parent_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_folders_user_parent_name ON folders(user_id, COALESCE(parent_id, '00000000-0000-0000-0000-000000000000'), LOWER(name));
+CREATE INDEX IF NOT EXISTS idx_vaults_type ON vaults(type);
 CREATE INDEX IF NOT EXISTS idx_vaults_created_at ON vaults(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_vaults_collection_id ON vaults(collection_id);
+CREATE INDEX IF NOT EXISTS idx_collections_owner ON collections(owner_id);
+CREATE INDEX IF NOT EXISTS idx_collection_members_user ON collection_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_collections_organization ON collections(organization_id);
+CREATE INDEX IF NOT EXISTS idx_vaults_organization_id ON vaults(organization_id);
+CREATE INDEX IF NOT EXISTS idx_vaults_deleted_at ON vaults(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_vaults_tags ON vaults USING gin (tags);
+CREATE INDEX IF NOT EXISTS idx_vaults_title_trgm ON vaults USING gin (title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_vaults_website_trgm ON vaults USING gin (website gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_vaults_username_trgm ON vaults USING gin (username gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_vaults_user_created ON vaults(user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_vaults_user_updated ON vaults(user_id, updated_at, id);
+CREATE INDEX IF NOT EXISTS idx_vaults_user_title ON vaults(user_id, LOWER(title), id);
+CREATE INDEX IF NOT EXISTS idx_vaults_user_last_used ON vaults(user_id, COALESCE(last_used, to_timestamp(0)), id);
+CREATE INDEX IF NOT EXISTS idx_organization_members_user ON organization_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_organization_invitations_email ON organization_invitations(LOWER(email)) WHERE accepted_at IS NULL;
 CREATE INDEX IF NOT EXISTS idx_shared_passwords_owner ON shared_passwords(owner_id);
 CREATE INDEX IF NOT EXISTS idx_shared_passwords_recipient ON shared_passwords(recipient_id);
 CREATE INDEX IF NOT EXISTS idx_shared_passwords_token ON shared_passwords(share_token);
+CREATE INDEX IF NOT EXISTS idx_shared_edits_owner ON shared_edits(owner_id) WHERE applied_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_share_access_logs_share ON share_access_logs(share_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_mfa_challenges_user_id ON mfa_challenges(user_id);
+CREATE INDEX IF NOT EXISTS idx_emergency_access_grantor ON emergency_access(grantor_id);
+CREATE INDEX IF NOT EXISTS idx_emergency_access_grantee ON emergency_access(grantee_id);
+CREATE INDEX IF NOT EXISTS idx_emergency_access_recovery ON emergency_access(recovery_initiated_at) WHERE status = 'recovery_initiated';
+CREATE INDEX IF NOT EXISTS idx_attachments_vault_id ON attachments(vault_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_user_id ON attachments(user_id);
+CREATE INDEX IF NOT EXISTS idx_password_history_vault ON password_history(vault_id, changed_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vault_revisions_vault_revision ON vault_revisions(vault_id, revision);
 CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id);
 CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at DESC);
 
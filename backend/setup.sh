@@ -124,7 +124,8 @@ REDIS_DB=0
 
 # JWT
 JWT_SECRET=$(openssl rand -hex 32)
-JWT_EXPIRE_TIME=24
+JWT_ACCESS_EXPIRE_MINUTES=15
+JWT_REFRESH_EXPIRE_HOURS=720
 
 # Email (optional)
 EMAIL_HOST=smtp.gmail.com
@@ -135,6 +136,32 @@ EMAIL_FROM=SecureVault <noreply@securevault.com>
 
 # Have I Been Pwned (optional)
 HIBP_API_KEY=
+
+# Sharing
+SHARING_INVITATION_KEY=$(openssl rand -base64 32)
+
+# Password policy (0/false disables a rule)
+POLICY_MIN_LENGTH=0
+POLICY_REQUIRE_LOWER=false
+POLICY_REQUIRE_UPPER=false
+POLICY_REQUIRE_DIGIT=false
+POLICY_REQUIRE_SPECIAL=false
+POLICY_MAX_AGE_DAYS=0
+POLICY_FORBID_BREACHED=false
+POLICY_REQUIRE_2FA=false
+
+# Attachment storage ("local" or "s3")
+ATTACHMENTS_STORAGE=local
+ATTACHMENTS_LOCAL_PATH=./data/attachments
+ATTACHMENTS_MAX_FILE_SIZE_MB=100
+ATTACHMENTS_USER_QUOTA_MB=1024
+
+# Password history per entry (0 disables a limit)
+HISTORY_MAX_ENTRIES=20
+HISTORY_MAX_AGE_DAYS=365
+
+# Days deleted entries stay in the trash (0 keeps them until emptied)
+TRASH_RETENTION_DAYS=30
 EOF
     echo -e "${GREEN}✅ .env file created${NC}"
 fi